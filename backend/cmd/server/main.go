@@ -2,7 +2,8 @@ package main
 
 import (
 	"log"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,9 +13,12 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
+	"github.com/valyala/fasthttp"
 
 	"github.com/PervFVCK/strategyforge/internal/handlers"
 	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/PervFVCK/strategyforge/internal/utils"
 	"github.com/PervFVCK/strategyforge/pkg/database"
 )
 
@@ -35,19 +39,56 @@ func main() {
 		log.Fatalf("❌ Failed to run migrations: %v", err)
 	}
 
+	// Backtests run on a bounded pool of background workers
+	workers, err := strconv.Atoi(utils.GetEnv("JOB_WORKERS", "2"))
+	if err != nil || workers < 1 {
+		log.Fatalf("❌ Invalid JOB_WORKERS: must be a positive number")
+	}
+	if err := services.StartJobs(workers); err != nil {
+		log.Fatalf("❌ Failed to start job workers: %v", err)
+	}
+
+	// Uploads are streamed rather than parsed up front, so the body limit
+	// only caps how much of a request is buffered
+	bodyLimit, err := utils.ParseByteSize(utils.GetEnv("MAX_UPLOAD_SIZE", "100MB"))
+	if err != nil {
+		log.Fatalf("❌ Invalid MAX_UPLOAD_SIZE: %v", err)
+	}
+
+	// Uploads and exports move a whole file within one request, so they get
+	// longer to arrive or be sent than the server's timeouts
+	transferTimeout, err := time.ParseDuration(utils.GetEnv("TRANSFER_TIMEOUT", "10m"))
+	if err != nil || transferTimeout <= 0 {
+		log.Fatalf("❌ Invalid TRANSFER_TIMEOUT: must be a positive duration such as 10m")
+	}
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
-		AppName:               "StrategyForge Africa v1.0",
-		ServerHeader:          "StrategyForge",
-		StrictRouting:         true,
-		CaseSensitive:         true,
-		ErrorHandler:          customErrorHandler,
-		DisableStartupMessage: false,
-		ReadTimeout:           10 * time.Second,
-		WriteTimeout:          10 * time.Second,
-		IdleTimeout:           120 * time.Second,
+		AppName:                      "StrategyForge Africa v1.0",
+		ServerHeader:                 "StrategyForge",
+		StrictRouting:                true,
+		CaseSensitive:                true,
+		ErrorHandler:                 customErrorHandler,
+		DisableStartupMessage:        false,
+		ReadTimeout:                  10 * time.Second,
+		WriteTimeout:                 10 * time.Second,
+		IdleTimeout:                  120 * time.Second,
+		BodyLimit:                    int(bodyLimit),
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
+	app.Server().HeaderReceived = func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+		path, _, _ := strings.Cut(string(header.RequestURI()), "?")
+		switch method := string(header.Method()); {
+		case method == fiber.MethodPost && path == "/api/v1/upload":
+			return fasthttp.RequestConfig{ReadTimeout: transferTimeout}
+		case method == fiber.MethodGet && strings.HasPrefix(path, "/api/v1/datasets/") && strings.HasSuffix(path, "/export"):
+			return fasthttp.RequestConfig{WriteTimeout: transferTimeout}
+		}
+		return fasthttp.RequestConfig{}
+	}
+
 	// Security Middleware - Using only fields that exist in helmet
 	app.Use(helmet.New(helmet.Config{
 		XSSProtection:             "1; mode=block",
@@ -64,7 +105,7 @@ func main() {
 
 	// CORS
 	app.Use(cors.New(cors.Config{
-		AllowOrigins:     getEnv("FRONTEND_URL", "http://localhost:5173"),
+		AllowOrigins:     utils.GetEnv("FRONTEND_URL", "http://localhost:5173"),
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
 		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
 		AllowCredentials: true,
@@ -125,21 +166,69 @@ func main() {
 	auth.Post("/refresh", handlers.HandleRefreshToken)
 	auth.Post("/google-oauth", handlers.HandleGoogleOAuth)
 
+	// Replay sockets authorize with the ticket of their session, as
+	// browsers cannot send the JWT header on a WebSocket
+	api.Get("/replay/:id/ws", handlers.HandleReplaySocket)
+
 	// Protected routes (require JWT)
 	protected := api.Group("/", middleware.JWTMiddleware)
 	protected.Get("/me", handlers.HandleGetCurrentUser)
 	protected.Post("/logout", handlers.HandleLogout)
 
-	// Future protected routes
-	protected.Post("/upload", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"message": "Upload endpoint - Coming in Phase 2"})
-	})
-	protected.Post("/backtest", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"message": "Backtest endpoint - Coming in Phase 3"})
-	})
-	protected.Get("/strategies", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"message": "Strategies endpoint - Coming in Phase 6"})
-	})
+	// Price data
+	protected.Post("/upload", handlers.HandleUpload)
+	protected.Get("/datasets", handlers.HandleListDatasets)
+	protected.Get("/datasets/:id", handlers.HandleGetDataset)
+	protected.Post("/datasets/:id/confirm", handlers.HandleConfirmDataset)
+	protected.Get("/datasets/:id/bars", handlers.HandleGetBars)
+	protected.Get("/datasets/:id/quality", handlers.HandleGetQuality)
+	protected.Post("/datasets/:id/repair", handlers.HandleRepairDataset)
+	protected.Get("/datasets/:id/export", handlers.HandleExportDataset)
+
+	// Backtesting
+	protected.Get("/instruments", handlers.HandleListInstruments)
+	protected.Get("/indicators", handlers.HandleListIndicators)
+	protected.Post("/backtest", handlers.HandleRunBacktest)
+	protected.Post("/backtest/portfolio", handlers.HandleRunPortfolioBacktest)
+	protected.Get("/backtest", handlers.HandleListBacktests)
+	protected.Get("/backtest/:id", handlers.HandleGetBacktest)
+	protected.Delete("/backtest/:id", handlers.HandleDeleteBacktest)
+	protected.Post("/backtest/:id/montecarlo", handlers.HandleMonteCarlo)
+	protected.Get("/backtest/:id/montecarlo", handlers.HandleListMonteCarlo)
+	protected.Get("/backtest/:id/chart", handlers.HandleGetChart)
+
+	// Optimization
+	protected.Post("/optimize", handlers.HandleOptimize)
+	protected.Get("/optimizations", handlers.HandleListOptimizations)
+	protected.Get("/optimizations/:id", handlers.HandleGetOptimization)
+	protected.Get("/optimizations/:id/heatmap", handlers.HandleGetHeatmap)
+	protected.Delete("/optimizations/:id", handlers.HandleDeleteOptimization)
+	protected.Post("/walkforward", handlers.HandleWalkForward)
+
+	// Monte Carlo simulations
+	protected.Get("/montecarlo/:id", handlers.HandleGetMonteCarlo)
+	protected.Delete("/montecarlo/:id", handlers.HandleDeleteMonteCarlo)
+
+	// Bar replay
+	protected.Post("/replay", handlers.HandleCreateReplay)
+	protected.Get("/replay", handlers.HandleListReplays)
+	protected.Get("/replay/:id", handlers.HandleGetReplay)
+	protected.Delete("/replay/:id", handlers.HandleDeleteReplay)
+
+	// Background jobs
+	protected.Get("/jobs", handlers.HandleListJobs)
+	protected.Get("/jobs/:id", handlers.HandleGetJob)
+	protected.Get("/jobs/:id/events", handlers.HandleJobEvents)
+	protected.Post("/jobs/:id/cancel", handlers.HandleCancelJob)
+
+	// Strategies
+	protected.Get("/strategies", handlers.HandleListStrategies)
+	protected.Post("/strategies", handlers.HandleCreateStrategy)
+	protected.Post("/strategies/check", handlers.HandleCheckStrategy)
+	protected.Post("/strategies/convert", handlers.HandleConvertStrategy)
+	protected.Get("/strategies/:id", handlers.HandleGetStrategy)
+	protected.Put("/strategies/:id", handlers.HandleUpdateStrategy)
+	protected.Delete("/strategies/:id", handlers.HandleDeleteStrategy)
 
 	// Pro-only routes
 	pro := protected.Group("/", middleware.RequireProMiddleware)
@@ -157,7 +246,7 @@ func main() {
 	})
 
 	// Start server
-	port := getEnv("PORT", "8080")
+	port := utils.GetEnv("PORT", "8080")
 	log.Println("========================================")
 	log.Println("🚀 StrategyForge Africa API")
 	log.Println("========================================")
@@ -188,10 +277,3 @@ func customErrorHandler(c *fiber.Ctx, err error) error {
 		"code":    code,
 	})
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
@@ -0,0 +1,275 @@
+// Package chart shapes bars, indicators, trades and equity curves into the
+// series data TradingView Lightweight Charts takes, thinned to what the
+// chart can show.
+package chart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// Bounds of a chart request
+const (
+	DefaultWidth = 1000 // Pixels
+	MinWidth     = 100
+	MaxWidth     = 8000
+	MaxBars      = 20000 // Returned at an explicit timeframe
+)
+
+// Equity curve downsampling methods
+const (
+	SamplingLTTB   = "lttb"   // Keeps the curve's shape
+	SamplingMinMax = "minmax" // Keeps every bucket's lowest and highest point
+)
+
+// Colors of the library's default theme
+const (
+	colorUp         = "#26a69a"
+	colorDown       = "#ef5350"
+	colorVolumeUp   = "rgba(38, 166, 154, 0.5)"
+	colorVolumeDown = "rgba(239, 83, 80, 0.5)"
+)
+
+// Marker positions and shapes
+const (
+	aboveBar  = "aboveBar"
+	belowBar  = "belowBar"
+	arrowUp   = "arrowUp"
+	arrowDown = "arrowDown"
+	circle    = "circle"
+)
+
+// Candle is a candlestick series item. Times are UTC seconds.
+type Candle struct {
+	Time  int64   `json:"time"`
+	Open  float64 `json:"open"`
+	High  float64 `json:"high"`
+	Low   float64 `json:"low"`
+	Close float64 `json:"close"`
+}
+
+// Histogram is a histogram series item
+type Histogram struct {
+	Time  int64   `json:"time"`
+	Value float64 `json:"value"`
+	Color string  `json:"color,omitempty"`
+}
+
+// Point is a line series item. A point without a value is whitespace,
+// leaving a gap in the line.
+type Point struct {
+	Time  int64    `json:"time"`
+	Value *float64 `json:"value,omitempty"`
+}
+
+// Marker is a series marker drawn on a candle
+type Marker struct {
+	ID       string `json:"id"`
+	Time     int64  `json:"time"`
+	Position string `json:"position"`
+	Shape    string `json:"shape"`
+	Color    string `json:"color"`
+	Text     string `json:"text"`
+}
+
+// Line is one output of an indicator
+type Line struct {
+	Name string  `json:"name"`
+	Data []Point `json:"data"`
+}
+
+// Indicator is an indicator's lines over the candles
+type Indicator struct {
+	Name    string             `json:"name"`
+	Title   string             `json:"title"`
+	Params  map[string]float64 `json:"params"`
+	Overlay bool               `json:"overlay"` // Drawn over the candles rather than in a pane of its own
+	Lines   []Line             `json:"lines"`
+}
+
+// Chart is everything drawn for a visible range. Each slice is ready to
+// pass to a series' setData, or setMarkers for Markers.
+type Chart struct {
+	Symbol     string      `json:"symbol"`
+	Timeframe  string      `json:"timeframe"`
+	From       time.Time   `json:"from"`
+	To         time.Time   `json:"to"` // Exclusive
+	Width      int         `json:"width"`
+	Candles    []Candle    `json:"candles"`
+	Volume     []Histogram `json:"volume"`
+	Indicators []Indicator `json:"indicators"`
+	Markers    []Marker    `json:"markers"`
+	Sampling   string      `json:"sampling"`
+	Equity     []Point     `json:"equity"`
+	Balance    []Point     `json:"balance"`
+}
+
+// PickTimeframe returns the finest timeframe, no finer than base, at which
+// the span fits in width bars, one a pixel. Bars are counted at their
+// nominal length, so markets that close leave the chart sparser.
+func PickTimeframe(base resample.Timeframe, span time.Duration, width int) resample.Timeframe {
+	tf := base
+	for _, candidate := range resample.Timeframes {
+		if candidate.Duration() < base.Duration() {
+			continue
+		}
+		tf = candidate
+		if span/candidate.Duration() <= time.Duration(width) {
+			break
+		}
+	}
+	return tf
+}
+
+// Candles converts bars to candles and volume
+func Candles(bars []marketdata.Bar) ([]Candle, []Histogram) {
+	candles := make([]Candle, len(bars))
+	volume := make([]Histogram, len(bars))
+	for i, b := range bars {
+		at := b.Time.Unix()
+		candles[i] = Candle{Time: at, Open: b.Open, High: b.High, Low: b.Low, Close: b.Close}
+		color := colorVolumeUp
+		if b.Close < b.Open {
+			color = colorVolumeDown
+		}
+		volume[i] = Histogram{Time: at, Value: b.Volume, Color: color}
+	}
+	return candles, volume
+}
+
+// Points pairs values with the times of bars, leaving whitespace for NaN
+func Points(bars []marketdata.Bar, values []float64) []Point {
+	points := make([]Point, len(bars))
+	for i, b := range bars {
+		points[i].Time = b.Time.Unix()
+		if v := values[i]; !math.IsNaN(v) && !math.IsInf(v, 0) {
+			points[i].Value = &v
+		}
+	}
+	return points
+}
+
+// Markers places the entries and exits of trades on the candles they fell
+// in, leaving out those outside the bars. A candle shows at most one marker
+// for buys, one for sells and one for exits; trades sharing a candle are
+// counted in one, so zooming out does not flood the chart.
+func Markers(trades []engine.Trade, bars []marketdata.Bar, tf resample.Timeframe) []Marker {
+	if len(bars) == 0 {
+		return []Marker{}
+	}
+	end := bars[len(bars)-1].Time.Add(tf.Duration())
+	// candle returns the index of the last bar opening at or before t
+	candle := func(t time.Time) (int, bool) {
+		if t.Before(bars[0].Time) || !t.Before(end) {
+			return 0, false
+		}
+		return sort.Search(len(bars), func(i int) bool { return bars[i].Time.After(t) }) - 1, true
+	}
+
+	// Trades are grouped by candle and kind: buy, sell or exit
+	kinds := []string{"buy", "sell", "exit"}
+	type group struct {
+		bar, kind int
+		first     engine.Trade
+		count     int
+		profit    float64
+		short     bool // Every trade closed was a sell
+	}
+	groups := make(map[[2]int]*group)
+	var order []*group
+	add := func(bar, kind int, t engine.Trade) {
+		g, ok := groups[[2]int{bar, kind}]
+		if !ok {
+			g = &group{bar: bar, kind: kind, first: t, short: true}
+			groups[[2]int{bar, kind}] = g
+			order = append(order, g)
+		}
+		g.count++
+		g.profit += t.Profit
+		g.short = g.short && t.Side == engine.Sell
+	}
+	for _, t := range trades {
+		if bar, ok := candle(t.EntryTime); ok {
+			kind := 0
+			if t.Side == engine.Sell {
+				kind = 1
+			}
+			add(bar, kind, t)
+		}
+		if bar, ok := candle(t.ExitTime); ok {
+			add(bar, 2, t)
+		}
+	}
+
+	markers := make([]Marker, 0, len(order))
+	for _, g := range order {
+		m := Marker{Time: bars[g.bar].Time.Unix()}
+		kind := kinds[g.kind]
+		switch kind {
+		case "buy":
+			m.Position, m.Shape, m.Color = belowBar, arrowUp, colorUp
+			m.ID, m.Text = fmt.Sprintf("trade-%d-entry", g.first.ID), fmt.Sprintf("Buy %g", g.first.Volume)
+		case "sell":
+			m.Position, m.Shape, m.Color = aboveBar, arrowDown, colorDown
+			m.ID, m.Text = fmt.Sprintf("trade-%d-entry", g.first.ID), fmt.Sprintf("Sell %g", g.first.Volume)
+		default:
+			// Exits sit on the side the closing order trades from
+			m.Position, m.Shape, m.Color = aboveBar, circle, colorUp
+			if g.short {
+				m.Position = belowBar
+			}
+			if g.profit < 0 {
+				m.Color = colorDown
+			}
+			m.ID, m.Text = fmt.Sprintf("trade-%d-exit", g.first.ID), fmt.Sprintf("%+.2f", g.profit)
+		}
+		if g.count > 1 {
+			m.ID, m.Text = fmt.Sprintf("bar-%d-%s", m.Time, kind), fmt.Sprintf("%d %ss", g.count, kind)
+			if kind == "exit" {
+				m.Text += fmt.Sprintf(" %+.2f", g.profit)
+			}
+		}
+		markers = append(markers, m)
+	}
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].Time < markers[j].Time })
+	return markers
+}
+
+// Curves thins the part of an equity curve within [from, to) to at most
+// width points by sampling, returning the equity and balance lines. Points
+// sharing a second keep the last, as a series takes one value per time.
+func Curves(curve []engine.EquityPoint, from, to time.Time, width int, sampling string) ([]Point, []Point) {
+	var xs, equity, balance []float64
+	for _, p := range curve {
+		if p.Time.Before(from) || !p.Time.Before(to) {
+			continue
+		}
+		x := float64(p.Time.Unix())
+		if n := len(xs); n > 0 && xs[n-1] == x {
+			equity[n-1], balance[n-1] = p.Equity, p.Balance
+			continue
+		}
+		xs, equity, balance = append(xs, x), append(equity, p.Equity), append(balance, p.Balance)
+	}
+
+	var kept []int
+	if sampling == SamplingMinMax {
+		kept = MinMax(equity, width)
+	} else {
+		kept = LTTB(xs, equity, width)
+	}
+	equityLine := make([]Point, len(kept))
+	balanceLine := make([]Point, len(kept))
+	for i, j := range kept {
+		at := int64(xs[j])
+		equityLine[i] = Point{Time: at, Value: &equity[j]}
+		balanceLine[i] = Point{Time: at, Value: &balance[j]}
+	}
+	return equityLine, balanceLine
+}
@@ -0,0 +1,95 @@
+package chart
+
+import "math"
+
+// LTTB picks at most threshold points of a series with the
+// Largest-Triangle-Three-Buckets algorithm, which keeps its visual shape.
+// xs must be ascending. It returns the indices kept, in order; the first and
+// last points are always among them.
+func LTTB(xs, ys []float64, threshold int) []int {
+	n := len(xs)
+	if threshold >= n || threshold < 3 {
+		return all(n, threshold)
+	}
+
+	kept := make([]int, 0, threshold)
+	kept = append(kept, 0)
+	// The points between the first and last are split into threshold-2
+	// buckets; each keeps the point forming the largest triangle with the
+	// one kept before it and the average of the bucket after
+	size := float64(n-2) / float64(threshold-2)
+	a := 0
+	for i := range threshold - 2 {
+		start := int(math.Floor(float64(i)*size)) + 1
+		end := int(math.Floor(float64(i+1)*size)) + 1
+
+		nextStart, nextEnd := end, min(int(math.Floor(float64(i+2)*size))+1, n)
+		var avgX, avgY float64
+		for j := nextStart; j < nextEnd; j++ {
+			avgX += xs[j]
+			avgY += ys[j]
+		}
+		count := float64(nextEnd - nextStart)
+		avgX, avgY = avgX/count, avgY/count
+
+		best, area := start, -1.0
+		for j := start; j < end; j++ {
+			s := math.Abs((xs[a]-avgX)*(ys[j]-ys[a]) - (xs[a]-xs[j])*(avgY-ys[a]))
+			if s > area {
+				best, area = j, s
+			}
+		}
+		kept = append(kept, best)
+		a = best
+	}
+	return append(kept, n-1)
+}
+
+// MinMax picks at most threshold points of a series by keeping the lowest
+// and highest of each bucket, so no peak or trough is lost. It returns the
+// indices kept, in order; the first and last points are always among them.
+func MinMax(ys []float64, threshold int) []int {
+	n := len(ys)
+	if threshold >= n || threshold < 4 {
+		return all(n, threshold)
+	}
+
+	kept := make([]int, 0, threshold)
+	kept = append(kept, 0)
+	buckets := (threshold - 2) / 2
+	size := float64(n-2) / float64(buckets)
+	for i := range buckets {
+		start := int(math.Floor(float64(i)*size)) + 1
+		end := min(int(math.Floor(float64(i+1)*size))+1, n-1)
+		if start >= end {
+			continue
+		}
+		lo, hi := start, start
+		for j := start + 1; j < end; j++ {
+			if ys[j] < ys[lo] {
+				lo = j
+			}
+			if ys[j] > ys[hi] {
+				hi = j
+			}
+		}
+		kept = append(kept, min(lo, hi))
+		if lo != hi {
+			kept = append(kept, max(lo, hi))
+		}
+	}
+	return append(kept, n-1)
+}
+
+// all returns every index of a series too short to thin, or its ends when
+// the threshold is too small to thin it to
+func all(n, threshold int) []int {
+	if n > threshold && n > 2 {
+		return []int{0, n - 1}
+	}
+	kept := make([]int, n)
+	for i := range kept {
+		kept[i] = i
+	}
+	return kept
+}
@@ -0,0 +1,580 @@
+// Package csvdialect infers the layout of text price files.
+//
+// Uploads arrive from HistData, MetaTrader 4/5, TradingView and Dukascopy,
+// each with its own delimiter, column order, timestamp format and clock.
+// Detect makes a best guess from a sample of lines; the guess is shown to
+// the user, who may correct it before the full file is parsed with Reader.
+package csvdialect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Role is the meaning of one column
+type Role string
+
+const (
+	RoleIgnore    Role = "ignore"
+	RoleTime      Role = "time"  // Full timestamp in one column
+	RoleDate      Role = "date"  // Date part, joined with RoleClock
+	RoleClock     Role = "clock" // Time-of-day part
+	RoleOpen      Role = "open"
+	RoleHigh      Role = "high"
+	RoleLow       Role = "low"
+	RoleClose     Role = "close"
+	RoleVolume    Role = "volume"
+	RoleBid       Role = "bid"
+	RoleAsk       Role = "ask"
+	RoleBidVolume Role = "bidVolume"
+	RoleAskVolume Role = "askVolume"
+	RoleSpread    Role = "spread"
+)
+
+// Data sources Detect can recognize
+const (
+	SourceGeneric     = "generic"
+	SourceHistData    = "histdata"
+	SourceMT4         = "mt4"
+	SourceMT5         = "mt5"
+	SourceTradingView = "tradingview"
+	SourceDukascopy   = "dukascopy"
+)
+
+// SampleLines is how many leading lines Detect inspects
+const SampleLines = 200
+
+// Delimiter value meaning "one or more spaces or tabs"
+const DelimiterWhitespace = " "
+
+// Dialect describes how to parse a text price file
+type Dialect struct {
+	Source     string   `json:"source"`
+	Delimiter  string   `json:"delimiter"`
+	HasHeader  bool     `json:"hasHeader"`
+	Columns    []Role   `json:"columns"`
+	TimeFormat string   `json:"timeFormat"`           // Go layout, "unix" or "unix_ms"
+	Timezone   string   `json:"timezone"`             // IANA name, "UTC", "EST" or "NY+7"
+	Kind       string   `json:"kind"`                 // tick or bar
+	SpreadUnit float64  `json:"spreadUnit,omitempty"` // Price per spread unit, e.g. 0.00001 for MT5 points
+	Confidence float64  `json:"confidence"`           // 0..1, how sure Detect is
+	Notes      []string `json:"notes,omitempty"`
+}
+
+// ErrNoData is returned when a sample holds no usable rows
+var ErrNoData = errors.New("no data rows found")
+
+// headerNames maps normalized header labels to column roles
+var headerNames = map[string]Role{
+	"time": RoleTime, "datetime": RoleTime, "timestamp": RoleTime,
+	"gmttime": RoleTime, "localtime": RoleTime, "date/time": RoleTime,
+	"date": RoleDate, "day": RoleDate,
+	"clock": RoleClock, "hour": RoleClock,
+	"open": RoleOpen, "o": RoleOpen,
+	"high": RoleHigh, "h": RoleHigh,
+	"low": RoleLow, "l": RoleLow,
+	"close": RoleClose, "c": RoleClose, "price": RoleClose, "last": RoleClose,
+	"volume": RoleVolume, "vol": RoleVolume, "tickvol": RoleVolume, "tickvolume": RoleVolume,
+	"bid": RoleBid, "ask": RoleAsk,
+	"bidvolume": RoleBidVolume, "askvolume": RoleAskVolume,
+	"spread": RoleSpread,
+}
+
+// Validate checks that a dialect, possibly edited by a user, is usable
+func (d *Dialect) Validate() error {
+	switch d.Delimiter {
+	case ",", ";", "\t", "|", DelimiterWhitespace:
+	default:
+		return fmt.Errorf("unsupported delimiter %q", d.Delimiter)
+	}
+
+	has := d.roleSet()
+	if !has[RoleTime] && !has[RoleDate] {
+		return errors.New("a time column is required")
+	}
+	if has[RoleTime] && (has[RoleDate] || has[RoleClock]) {
+		return errors.New("use either a time column or date and clock columns")
+	}
+	if has[RoleClock] && !has[RoleDate] {
+		return errors.New("a clock column requires a date column")
+	}
+
+	switch d.Kind {
+	case marketdata.KindBar:
+		if !has[RoleClose] {
+			return errors.New("bar data requires a close column")
+		}
+	case marketdata.KindTick:
+		if !has[RoleBid] && !has[RoleAsk] {
+			return errors.New("tick data requires a bid or ask column")
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", d.Kind)
+	}
+
+	if d.TimeFormat == "" {
+		return errors.New("a time format is required")
+	}
+	if _, err := LoadZone(d.Timezone); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *Dialect) roleSet() map[Role]bool {
+	has := make(map[Role]bool, len(d.Columns))
+	for _, r := range d.Columns {
+		if r != RoleIgnore {
+			has[r] = true
+		}
+	}
+	return has
+}
+
+// split breaks a line into trimmed, unquoted fields
+func (d *Dialect) split(line string) []string {
+	var fields []string
+	if d.Delimiter == DelimiterWhitespace {
+		fields = strings.Fields(line)
+	} else {
+		fields = strings.Split(line, d.Delimiter)
+	}
+	for i, f := range fields {
+		fields[i] = strings.Trim(strings.TrimSpace(f), `"'`)
+	}
+	return fields
+}
+
+// DetectReader detects the dialect of the first SampleLines lines of r
+func DetectReader(r io.Reader) (*Dialect, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for len(lines) < SampleLines && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sample: %w", err)
+	}
+	return Detect(lines)
+}
+
+// Detect guesses the dialect of a sample of lines
+func Detect(lines []string) (*Dialect, error) {
+	lines = cleanLines(lines)
+	if len(lines) == 0 {
+		return nil, ErrNoData
+	}
+
+	d := &Dialect{Source: SourceGeneric, Confidence: 1}
+
+	d.Delimiter = detectDelimiter(lines)
+	if d.Delimiter == "" {
+		return nil, errors.New("could not determine column delimiter")
+	}
+
+	rows := make([][]string, len(lines))
+	for i, line := range lines {
+		rows[i] = d.split(line)
+	}
+
+	var header []string
+	if isHeader(rows[0]) {
+		d.HasHeader = true
+		header = rows[0]
+		rows = rows[1:]
+	}
+	if len(rows) == 0 {
+		return nil, ErrNoData
+	}
+
+	width := len(rows[0])
+	for _, row := range rows {
+		if len(row) != width {
+			d.Confidence -= 0.2
+			d.Notes = append(d.Notes, "rows have differing column counts")
+			break
+		}
+	}
+
+	if header != nil {
+		d.Columns = rolesFromHeader(header)
+	}
+	if !d.columnsUsable() {
+		if header != nil {
+			d.Notes = append(d.Notes, "header not recognized, columns inferred from values")
+			d.Confidence -= 0.1
+		}
+		if err := d.inferColumns(rows); err != nil {
+			return nil, err
+		}
+	} else {
+		d.Kind = kindFromRoles(d.roleSet())
+	}
+
+	if err := d.detectTime(rows); err != nil {
+		return nil, err
+	}
+	d.detectSource(header)
+	if d.Source == SourceMT5 {
+		d.SpreadUnit = d.detectPoint(rows)
+	}
+
+	if d.Confidence < 0 {
+		d.Confidence = 0
+	}
+	d.Confidence = math.Round(d.Confidence*100) / 100
+	return d, nil
+}
+
+// cleanLines drops blank lines and a leading byte order mark
+func cleanLines(lines []string) []string {
+	out := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if i == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// detectDelimiter picks the delimiter that splits lines most consistently
+func detectDelimiter(lines []string) string {
+	best, bestScore, bestCount := "", 0.0, 0
+	for _, delim := range []string{",", ";", "\t", "|"} {
+		counts := make(map[int]int)
+		for _, line := range lines {
+			counts[strings.Count(line, delim)]++
+		}
+		mode, modeLines := 0, 0
+		for count, n := range counts {
+			if n > modeLines || (n == modeLines && count > mode) {
+				mode, modeLines = count, n
+			}
+		}
+		if mode == 0 {
+			continue
+		}
+		score := float64(modeLines) / float64(len(lines))
+		if score > bestScore || (score == bestScore && mode > bestCount) {
+			best, bestScore, bestCount = delim, score, mode
+		}
+	}
+	if best != "" {
+		return best
+	}
+	if len(strings.Fields(lines[len(lines)-1])) > 1 {
+		return DelimiterWhitespace
+	}
+	return ""
+}
+
+// isHeader reports whether a row is column labels rather than data
+func isHeader(row []string) bool {
+	letters := false
+	for _, f := range row {
+		if isNumber(f) {
+			return false
+		}
+		if strings.IndexFunc(f, isLetter) >= 0 {
+			letters = true
+		}
+	}
+	return letters
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// rolesFromHeader maps header labels such as "<TICKVOL>" or "Gmt time"
+func rolesFromHeader(header []string) []Role {
+	roles := make([]Role, len(header))
+	for i, label := range header {
+		key := strings.ToLower(label)
+		key = strings.NewReplacer("<", "", ">", "", " ", "", "_", "", "-", "").Replace(key)
+		if role, ok := headerNames[key]; ok {
+			roles[i] = role
+		} else {
+			roles[i] = RoleIgnore
+		}
+	}
+
+	// MT5 splits the timestamp into <DATE> and <TIME>
+	seen := make(map[Role]bool)
+	for _, r := range roles {
+		seen[r] = true
+	}
+	if seen[RoleDate] && seen[RoleTime] {
+		for i, r := range roles {
+			if r == RoleTime {
+				roles[i] = RoleClock
+			}
+		}
+	}
+
+	// Keep only the first volume column; MT5 exports both TICKVOL and VOL
+	seen = make(map[Role]bool)
+	for i, r := range roles {
+		if r == RoleIgnore {
+			continue
+		}
+		if seen[r] {
+			roles[i] = RoleIgnore
+		}
+		seen[r] = true
+	}
+	return roles
+}
+
+// columnsUsable reports whether the roles describe a time and price series
+func (d *Dialect) columnsUsable() bool {
+	has := d.roleSet()
+	hasTime := has[RoleTime] || has[RoleDate]
+	return hasTime && kindFromRoles(has) != ""
+}
+
+// kindFromRoles prefers ticks, since MT5 tick exports also carry a LAST column
+func kindFromRoles(has map[Role]bool) string {
+	switch {
+	case has[RoleBid] || has[RoleAsk]:
+		return marketdata.KindTick
+	case has[RoleClose]:
+		return marketdata.KindBar
+	}
+	return ""
+}
+
+// inferColumns assigns roles from the shape of the values
+func (d *Dialect) inferColumns(rows [][]string) error {
+	width := len(rows[0])
+	d.Columns = make([]Role, width)
+	for i := range d.Columns {
+		d.Columns[i] = RoleIgnore
+	}
+
+	// Timestamp first: either one column or a date column plus a clock column
+	first := 1
+	if width > 2 && looksLikeClock(column(rows, 1)) {
+		d.Columns[0], d.Columns[1] = RoleDate, RoleClock
+		first = 2
+	} else {
+		d.Columns[0] = RoleTime
+	}
+
+	var numeric []int
+	for i := first; i < width; i++ {
+		if allNumeric(column(rows, i)) {
+			numeric = append(numeric, i)
+		}
+	}
+
+	switch {
+	case len(numeric) >= 4 && ohlcConsistent(rows, numeric[:4]):
+		d.Kind = marketdata.KindBar
+		for j, role := range []Role{RoleOpen, RoleHigh, RoleLow, RoleClose} {
+			d.Columns[numeric[j]] = role
+		}
+		if len(numeric) > 4 {
+			d.Columns[numeric[4]] = RoleVolume
+		}
+	case len(numeric) >= 2:
+		d.Kind = marketdata.KindTick
+		bid, ask := numeric[0], numeric[1]
+		if !lessOrEqual(rows, bid, ask) {
+			bid, ask = ask, bid
+		}
+		d.Columns[bid], d.Columns[ask] = RoleBid, RoleAsk
+		if len(numeric) >= 4 {
+			// Dukascopy order: ask, bid, ask volume, bid volume
+			if ask < bid {
+				d.Columns[numeric[2]], d.Columns[numeric[3]] = RoleAskVolume, RoleBidVolume
+			} else {
+				d.Columns[numeric[2]], d.Columns[numeric[3]] = RoleBidVolume, RoleAskVolume
+			}
+			d.Confidence -= 0.2
+			d.Notes = append(d.Notes, "four price-like columns are not valid OHLC; treated as ticks")
+		} else if len(numeric) == 3 {
+			d.Columns[numeric[2]] = RoleVolume
+		}
+	case len(numeric) == 1:
+		d.Kind = marketdata.KindBar
+		d.Columns[numeric[0]] = RoleClose
+		d.Confidence -= 0.2
+		d.Notes = append(d.Notes, "single price column treated as close")
+	default:
+		return errors.New("file does not contain price columns")
+	}
+	return nil
+}
+
+func column(rows [][]string, i int) []string {
+	values := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if i < len(row) {
+			values = append(values, row[i])
+		}
+	}
+	return values
+}
+
+func allNumeric(values []string) bool {
+	for _, v := range values {
+		if !isNumber(v) {
+			return false
+		}
+	}
+	return len(values) > 0
+}
+
+// looksLikeClock matches "13:45", "13:45:00" and HistData's "134500"
+func looksLikeClock(values []string) bool {
+	for _, v := range values {
+		if strings.Count(v, ":") >= 1 && len(v) <= 12 {
+			continue
+		}
+		if len(v) == 6 && allDigits(v) {
+			continue
+		}
+		return false
+	}
+	return len(values) > 0
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// ohlcConsistent checks high >= open, close >= low for columns in OHLC order
+func ohlcConsistent(rows [][]string, cols []int) bool {
+	for _, row := range rows {
+		o, _ := strconv.ParseFloat(row[cols[0]], 64)
+		h, _ := strconv.ParseFloat(row[cols[1]], 64)
+		l, _ := strconv.ParseFloat(row[cols[2]], 64)
+		c, _ := strconv.ParseFloat(row[cols[3]], 64)
+		if h < math.Max(o, c) || l > math.Min(o, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// lessOrEqual reports whether column a never exceeds column b
+func lessOrEqual(rows [][]string, a, b int) bool {
+	for _, row := range rows {
+		x, _ := strconv.ParseFloat(row[a], 64)
+		y, _ := strconv.ParseFloat(row[b], 64)
+		if x > y {
+			return false
+		}
+	}
+	return true
+}
+
+// timeValues joins the timestamp columns of each row
+func (d *Dialect) timeValues(rows [][]string) []string {
+	values := make([]string, 0, len(rows))
+	for _, row := range rows {
+		var date, clock string
+		for i, role := range d.Columns {
+			if i >= len(row) {
+				break
+			}
+			switch role {
+			case RoleTime, RoleDate:
+				date = row[i]
+			case RoleClock:
+				clock = row[i]
+			}
+		}
+		if clock != "" {
+			date += " " + clock
+		}
+		values = append(values, date)
+	}
+	return values
+}
+
+func (d *Dialect) detectTime(rows [][]string) error {
+	format, ok := detectTimeFormat(d.timeValues(rows))
+	if !ok {
+		return errors.New("could not recognize the timestamp format")
+	}
+	d.TimeFormat = format
+	if inversions, _ := scoreLayout(format, d.timeValues(rows)); inversions > 0 {
+		d.Confidence -= 0.2
+		d.Notes = append(d.Notes, "timestamps are not in chronological order")
+	}
+	return nil
+}
+
+// detectPoint infers the price increment from the most decimals seen in
+// the price columns, used to convert MT5's spread in points
+func (d *Dialect) detectPoint(rows [][]string) float64 {
+	decimals := 0
+	for i, role := range d.Columns {
+		switch role {
+		case RoleOpen, RoleHigh, RoleLow, RoleClose, RoleBid, RoleAsk:
+		default:
+			continue
+		}
+		for _, v := range column(rows, i) {
+			if dot := strings.IndexByte(v, '.'); dot >= 0 && len(v)-dot-1 > decimals {
+				decimals = len(v) - dot - 1
+			}
+		}
+	}
+	return math.Pow10(-decimals)
+}
+
+// detectSource recognizes well-known exporters and picks their clock
+func (d *Dialect) detectSource(header []string) {
+	joined := strings.ToLower(strings.Join(header, " "))
+	d.Timezone = "UTC"
+
+	switch {
+	case strings.Contains(joined, "gmt time") || strings.Contains(joined, "local time") ||
+		strings.HasPrefix(d.TimeFormat, "02.01.2006 15:04:05"):
+		d.Source = SourceDukascopy
+	case strings.Contains(joined, "<date>"):
+		d.Source = SourceMT5
+		d.Timezone = ZoneBroker
+		d.Notes = append(d.Notes, "MetaTrader exports use broker server time; confirm the timezone")
+	case d.Delimiter == ";" && d.TimeFormat == "20060102 150405",
+		d.Delimiter == "," && d.TimeFormat == LayoutHistDataTick:
+		d.Source = SourceHistData
+		d.Timezone = ZoneHistData
+	case strings.HasPrefix(d.TimeFormat, "2006.01.02") && !d.HasHeader:
+		d.Source = SourceMT4
+		d.Timezone = ZoneBroker
+		d.Notes = append(d.Notes, "MetaTrader exports use broker server time; confirm the timezone")
+	case d.HasHeader && len(header) > 0 && strings.EqualFold(header[0], "time") &&
+		(d.TimeFormat == FormatUnix || d.TimeFormat == time.RFC3339Nano):
+		d.Source = SourceTradingView
+	default:
+		d.Confidence -= 0.1
+		d.Notes = append(d.Notes, "source not recognized; timestamps assumed to be UTC")
+	}
+}
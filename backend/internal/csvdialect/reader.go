@@ -0,0 +1,227 @@
+package csvdialect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Record is one parsed row. Exactly one of Bar or Tick is set, according
+// to the dialect's Kind.
+type Record struct {
+	Bar  *marketdata.Bar  `json:"bar,omitempty"`
+	Tick *marketdata.Tick `json:"tick,omitempty"`
+}
+
+// Time returns the record's timestamp
+func (r Record) Time() time.Time {
+	if r.Bar != nil {
+		return r.Bar.Time
+	}
+	if r.Tick != nil {
+		return r.Tick.Time
+	}
+	return time.Time{}
+}
+
+// ParseError reports the line a parse failure occurred on
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Reader parses a text price file with a known dialect
+type Reader struct {
+	scanner *bufio.Scanner
+	dialect Dialect
+	zone    *Zone
+	index   map[Role]int
+	line    int
+	last    marketdata.Tick // Carries forward fields MT5 omits when unchanged
+}
+
+// NewReader validates the dialect and prepares to parse r
+func NewReader(r io.Reader, d Dialect) (*Reader, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	zone, err := LoadZone(d.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[Role]int)
+	for i, role := range d.Columns {
+		if role != RoleIgnore {
+			index[role] = i
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	return &Reader{
+		scanner: scanner,
+		dialect: d,
+		zone:    zone,
+		index:   index,
+	}, nil
+}
+
+// Read returns the next record, or io.EOF at the end of input. Blank lines
+// and the header row are skipped.
+func (r *Reader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if r.line == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+			if r.dialect.HasHeader {
+				continue
+			}
+		}
+		if line == "" {
+			continue
+		}
+
+		record, err := r.parse(r.dialect.split(line))
+		if err != nil {
+			return Record{}, &ParseError{Line: r.line, Err: err}
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+// Line returns the number of the last line read
+func (r *Reader) Line() int {
+	return r.line
+}
+
+func (r *Reader) parse(fields []string) (Record, error) {
+	if len(fields) < len(r.dialect.Columns) {
+		return Record{}, fmt.Errorf("expected %d columns, found %d", len(r.dialect.Columns), len(fields))
+	}
+
+	var value string
+	if i, ok := r.index[RoleTime]; ok {
+		value = fields[i]
+	} else {
+		value = fields[r.index[RoleDate]]
+		if i, ok := r.index[RoleClock]; ok {
+			value += " " + fields[i]
+		}
+	}
+	t, err := r.zone.ParseTime(r.dialect.TimeFormat, value)
+	if err != nil {
+		return Record{}, err
+	}
+
+	if r.dialect.Kind == marketdata.KindTick {
+		return r.parseTick(t, fields)
+	}
+	return r.parseBar(t, fields)
+}
+
+func (r *Reader) parseBar(t time.Time, fields []string) (Record, error) {
+	bar := marketdata.Bar{Time: t}
+
+	var err error
+	if bar.Close, err = r.number(fields, RoleClose); err != nil {
+		return Record{}, err
+	}
+	// Close-only series become flat bars
+	bar.Open, bar.High, bar.Low = bar.Close, bar.Close, bar.Close
+	for _, f := range []struct {
+		role Role
+		dst  *float64
+	}{
+		{RoleOpen, &bar.Open},
+		{RoleHigh, &bar.High},
+		{RoleLow, &bar.Low},
+		{RoleVolume, &bar.Volume},
+		{RoleSpread, &bar.Spread},
+	} {
+		if _, ok := r.index[f.role]; !ok {
+			continue
+		}
+		if *f.dst, err = r.number(fields, f.role); err != nil {
+			return Record{}, err
+		}
+	}
+
+	if r.dialect.SpreadUnit > 0 {
+		bar.Spread *= r.dialect.SpreadUnit
+	}
+
+	if !bar.Valid() {
+		return Record{}, errors.New("inconsistent OHLC prices")
+	}
+	return Record{Bar: &bar}, nil
+}
+
+func (r *Reader) parseTick(t time.Time, fields []string) (Record, error) {
+	tick := r.last
+	tick.Time = t
+
+	for _, f := range []struct {
+		role Role
+		dst  *float64
+	}{
+		{RoleBid, &tick.Bid},
+		{RoleAsk, &tick.Ask},
+		{RoleBidVolume, &tick.BidVolume},
+		{RoleAskVolume, &tick.AskVolume},
+		{RoleVolume, &tick.BidVolume},
+	} {
+		i, ok := r.index[f.role]
+		if !ok || fields[i] == "" {
+			continue
+		}
+		v, err := r.number(fields, f.role)
+		if err != nil {
+			return Record{}, err
+		}
+		*f.dst = v
+	}
+
+	// One-sided feeds quote a single price
+	if _, ok := r.index[RoleAsk]; !ok {
+		tick.Ask = tick.Bid
+	}
+	if _, ok := r.index[RoleBid]; !ok {
+		tick.Bid = tick.Ask
+	}
+	if tick.Bid <= 0 || tick.Ask <= 0 {
+		return Record{}, errors.New("missing bid or ask price")
+	}
+
+	r.last = tick
+	return Record{Tick: &tick}, nil
+}
+
+func (r *Reader) number(fields []string, role Role) (float64, error) {
+	s := fields[r.index[role]]
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q", role, s)
+	}
+	return v, nil
+}
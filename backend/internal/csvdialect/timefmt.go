@@ -0,0 +1,208 @@
+package csvdialect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Termux and slim containers often ship without zoneinfo
+)
+
+// Special time formats that are not Go layouts
+const (
+	FormatUnix      = "unix"    // Seconds since the epoch
+	FormatUnixMilli = "unix_ms" // Milliseconds since the epoch
+)
+
+// LayoutHistDataTick is HistData's tick timestamp, with milliseconds
+// appended to the seconds without a separator: "20230102 000000123"
+const LayoutHistDataTick = "20060102 150405000"
+
+// Special zone names understood by LoadZone
+const (
+	// ZoneHistData is HistData's fixed EST, UTC-5 all year with no DST
+	ZoneHistData = "EST"
+	// ZoneBroker is the common MetaTrader server clock: New York time plus
+	// seven hours, so daily bars close at 17:00 New York (UTC+2 in winter,
+	// UTC+3 in summer)
+	ZoneBroker = "NY+7"
+)
+
+// timeLayouts are tried in order during detection. Day-first and
+// month-first variants of ambiguous layouts are resolved by ordering.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006.01.02 15:04:05.000",
+	"2006.01.02 15:04:05",
+	"2006.01.02 15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"02.01.2006 15:04:05.000 GMT-0700",
+	"02.01.2006 15:04:05.000",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"01.02.2006 15:04:05.000",
+	"01.02.2006 15:04:05",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	LayoutHistDataTick,
+	"20060102 150405",
+	"20060102 15:04:05",
+	"20060102 15:04",
+	"2006-01-02",
+	"2006.01.02",
+	"2006/01/02",
+	"02.01.2006",
+	"01.02.2006",
+	"02/01/2006",
+	"01/02/2006",
+	"20060102",
+}
+
+// Zone converts wall-clock timestamps from a data source to UTC
+type Zone struct {
+	name   string
+	loc    *time.Location
+	offset time.Duration // Added to loc's wall clock, used by ZoneBroker
+}
+
+// LoadZone resolves an IANA zone name or one of the special zone names
+func LoadZone(name string) (*Zone, error) {
+	switch strings.ToUpper(name) {
+	case "", "UTC", "GMT":
+		return &Zone{name: "UTC", loc: time.UTC}, nil
+	case ZoneHistData:
+		return &Zone{name: ZoneHistData, loc: time.FixedZone("EST", -5*60*60)}, nil
+	case ZoneBroker:
+		ny, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			return nil, err
+		}
+		return &Zone{name: ZoneBroker, loc: ny, offset: 7 * time.Hour}, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return &Zone{name: name, loc: loc}, nil
+}
+
+// Name returns the zone's name as given to LoadZone
+func (z *Zone) Name() string {
+	return z.name
+}
+
+// ParseTime parses value with format in this zone and returns UTC. Layouts
+// that carry an explicit offset override the zone.
+func (z *Zone) ParseTime(format, value string) (time.Time, error) {
+	switch format {
+	case FormatUnix, FormatUnixMilli:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+		}
+		if format == FormatUnixMilli {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	case LayoutHistDataTick:
+		if len(value) != len(LayoutHistDataTick) {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+		}
+		format = "20060102 150405.000"
+		value = value[:len(value)-3] + "." + value[len(value)-3:]
+	}
+
+	if z.offset == 0 {
+		t, err := time.ParseInLocation(format, value, z.loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+		}
+		return t.UTC(), nil
+	}
+
+	// Shift the wall clock back into loc before resolving DST
+	wall, err := time.ParseInLocation(format, value, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+	}
+	wall = wall.Add(-z.offset)
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(),
+		wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), z.loc)
+	return t.UTC(), nil
+}
+
+// detectTimeFormat returns the layout that parses every value, preferring
+// the one that keeps the series in chronological order
+func detectTimeFormat(values []string) (string, bool) {
+	if len(values) == 0 {
+		return "", false
+	}
+
+	if format, ok := detectEpoch(values); ok {
+		return format, true
+	}
+
+	best, bestInversions := "", -1
+	for _, layout := range timeLayouts {
+		inversions, ok := scoreLayout(layout, values)
+		if !ok {
+			continue
+		}
+		if bestInversions < 0 || inversions < bestInversions {
+			best, bestInversions = layout, inversions
+		}
+		if inversions == 0 {
+			break
+		}
+	}
+	return best, bestInversions >= 0
+}
+
+// scoreLayout counts out-of-order timestamps when values are parsed with
+// layout, failing if any value does not parse
+func scoreLayout(layout string, values []string) (int, bool) {
+	zone := &Zone{name: "UTC", loc: time.UTC}
+	var prev time.Time
+	inversions := 0
+	for i, v := range values {
+		t, err := zone.ParseTime(layout, v)
+		if err != nil {
+			return 0, false
+		}
+		if i > 0 && t.Before(prev) {
+			inversions++
+		}
+		prev = t
+	}
+	return inversions, true
+}
+
+// detectEpoch recognizes integer seconds or milliseconds since 1970
+func detectEpoch(values []string) (string, bool) {
+	digits := 0
+	for _, v := range values {
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return "", false
+		}
+		if digits == 0 {
+			digits = len(v)
+		} else if len(v) != digits {
+			return "", false
+		}
+	}
+	switch digits {
+	case 10:
+		return FormatUnix, true
+	case 13:
+		return FormatUnixMilli, true
+	}
+	return "", false
+}
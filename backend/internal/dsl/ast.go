@@ -0,0 +1,129 @@
+// Package dsl is the text language strategies are written in. Source is
+// parsed into a Program, type-checked, and run in the engine by Strategy.
+package dsl
+
+// Program is a parsed strategy
+type Program struct {
+	Name    string
+	Params  []*Param
+	Lets    []*Let // In source order; each may use those before it
+	Size    Expr   // Default lots for entries without their own; nil leaves sizing to the backtest
+	Entries []*Entry
+	Exits   []*Exit
+}
+
+// Param is a tunable constant. Backtests may override its default within
+// the optional range.
+type Param struct {
+	Pos     Pos
+	Name    string
+	Default float64
+	Min     *float64
+	Max     *float64
+}
+
+// Let names a value computed on every bar, or on every bar of a higher
+// Timeframe, keeping its value between them
+type Let struct {
+	Pos       Pos
+	Name      string
+	Timeframe string // Empty for the strategy's own bars
+	Value     Expr
+}
+
+// Timeframes lists the higher timeframes the program's lets are computed
+// on, in order of first use
+func (p *Program) Timeframes() []string {
+	var list []string
+	seen := make(map[string]bool)
+	for _, l := range p.Lets {
+		if l.Timeframe != "" && !seen[l.Timeframe] {
+			seen[l.Timeframe] = true
+			list = append(list, l.Timeframe)
+		}
+	}
+	return list
+}
+
+// Sides of entry and exit rules
+const (
+	Long  = "long"
+	Short = "short"
+)
+
+// Entry opens a position when its condition holds at a bar close
+type Entry struct {
+	Pos        Pos
+	Side       string
+	When       Expr
+	Size       Expr // Lots; nil uses the program's default
+	StopLoss   Expr // Price; nil for none
+	TakeProfit Expr // Price; nil for none
+}
+
+// Exit closes positions on one side when its condition holds at a bar close
+type Exit struct {
+	Pos  Pos
+	Side string
+	When Expr
+}
+
+// Expr is an expression node
+type Expr interface {
+	Position() Pos
+}
+
+// Number is a numeric literal
+type Number struct {
+	At    Pos
+	Value float64
+}
+
+// Bool is true or false
+type Bool struct {
+	At    Pos
+	Value bool
+}
+
+// Ident refers to a param, a let or a built-in value such as close
+type Ident struct {
+	At   Pos
+	Name string
+}
+
+// Unary is "-" or "not" applied to an operand
+type Unary struct {
+	At Pos
+	Op string
+	X  Expr
+}
+
+// Binary is an arithmetic, comparison or logical operation
+type Binary struct {
+	At Pos // Of the operator
+	Op string
+	X  Expr
+	Y  Expr
+}
+
+// Call invokes a built-in function
+type Call struct {
+	At   Pos
+	Func string
+	Args []Expr
+}
+
+// Index reads an expression's value a constant number of bars ago
+type Index struct {
+	At     Pos // Of the opening bracket
+	X      Expr
+	Offset Expr
+}
+
+func (e *Number) Position() Pos { return e.At }
+func (e *Bool) Position() Pos   { return e.At }
+func (e *Ident) Position() Pos  { return e.At }
+func (e *Unary) Position() Pos  { return e.At }
+func (e *Binary) Position() Pos { return e.At }
+func (e *Call) Position() Pos   { return e.At }
+func (e *Index) Position() Pos  { return e.At }
@@ -0,0 +1,281 @@
+package dsl
+
+import (
+	"math"
+
+	"github.com/PervFVCK/strategyforge/internal/indicators"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// Type is the type of an expression
+type Type int
+
+const (
+	TypeNumber Type = iota + 1
+	TypeBool
+)
+
+func (t Type) String() string {
+	if t == TypeBool {
+		return "bool"
+	}
+	return "number"
+}
+
+// MaxLookback bounds indicator periods and [n] offsets
+const MaxLookback = 5000
+
+// frame is what built-in values read on each bar
+type frame struct {
+	bar     marketdata.Bar
+	index   int // Bars seen, from zero
+	balance float64
+	equity  float64
+	free    float64
+	net     float64 // Open lots, positive long and negative short
+	pip     float64
+	pipVal  float64
+}
+
+// valueSpec is a built-in name such as close
+type valueSpec struct {
+	typ Type
+	get func(f *frame) float64
+	doc string
+}
+
+var values = map[string]valueSpec{
+	"open":        {TypeNumber, func(f *frame) float64 { return f.bar.Open }, "bar open"},
+	"high":        {TypeNumber, func(f *frame) float64 { return f.bar.High }, "bar high"},
+	"low":         {TypeNumber, func(f *frame) float64 { return f.bar.Low }, "bar low"},
+	"close":       {TypeNumber, func(f *frame) float64 { return f.bar.Close }, "bar close"},
+	"volume":      {TypeNumber, func(f *frame) float64 { return f.bar.Volume }, "bar volume"},
+	"spread":      {TypeNumber, func(f *frame) float64 { return f.bar.Spread }, "bar spread in price units"},
+	"bar_index":   {TypeNumber, func(f *frame) float64 { return float64(f.index) }, "bars seen before this one"},
+	"hour":        {TypeNumber, func(f *frame) float64 { return float64(f.bar.Time.Hour()) }, "UTC hour the bar opened"},
+	"minute":      {TypeNumber, func(f *frame) float64 { return float64(f.bar.Time.Minute()) }, "UTC minute the bar opened"},
+	"day_of_week": {TypeNumber, func(f *frame) float64 { return float64(f.bar.Time.Weekday()) }, "0 is Sunday"},
+	"balance":     {TypeNumber, func(f *frame) float64 { return f.balance }, "account balance"},
+	"equity":      {TypeNumber, func(f *frame) float64 { return f.equity }, "account equity"},
+	"free_margin": {TypeNumber, func(f *frame) float64 { return f.free }, "equity not held as margin"},
+	"position":    {TypeNumber, func(f *frame) float64 { return f.net }, "open lots, negative when short"},
+	"pip":         {TypeNumber, func(f *frame) float64 { return f.pip }, "price distance of one pip"},
+	"pip_value":   {TypeNumber, func(f *frame) float64 { return f.pipVal }, "account currency per pip per lot"},
+}
+
+// argSpec describes one function argument. Constant arguments must be
+// known before the first bar: literals, params and arithmetic on them.
+type argSpec struct {
+	name     string
+	typ      Type
+	constant bool
+}
+
+// funcSpec describes a built-in function. Pure functions map arguments to
+// a result; stateful ones keep a separate state per call site, created from
+// their constant arguments and updated with the others on every bar.
+type funcSpec struct {
+	args   []argSpec
+	result Type
+	pure   func(args []float64) float64
+	state  func(consts []float64) stateful
+}
+
+type stateful interface {
+	update(f *frame, args []float64) float64
+}
+
+var (
+	num    = argSpec{name: "value", typ: TypeNumber}
+	cond   = argSpec{name: "condition", typ: TypeBool}
+	period = argSpec{name: "period", typ: TypeNumber, constant: true}
+	fast   = argSpec{name: "fast period", typ: TypeNumber, constant: true}
+	slow   = argSpec{name: "slow period", typ: TypeNumber, constant: true}
+	signal = argSpec{name: "signal period", typ: TypeNumber, constant: true}
+)
+
+var funcs = map[string]funcSpec{
+	"abs":   pure1(math.Abs),
+	"sqrt":  pure1(math.Sqrt),
+	"log":   pure1(math.Log),
+	"exp":   pure1(math.Exp),
+	"round": pure1(math.Round),
+	"floor": pure1(math.Floor),
+	"ceil":  pure1(math.Ceil),
+	"min":   {args: []argSpec{num, num}, result: TypeNumber, pure: func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {args: []argSpec{num, num}, result: TypeNumber, pure: func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":   {args: []argSpec{num, num}, result: TypeNumber, pure: func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"iff": {args: []argSpec{cond, num, num}, result: TypeNumber, pure: func(a []float64) float64 {
+		if truthy(a[0]) {
+			return a[1]
+		}
+		return a[2]
+	}},
+	"na": {args: []argSpec{num}, result: TypeBool, pure: func(a []float64) float64 { return boolean(math.IsNaN(a[0])) }},
+	"nz": {args: []argSpec{num, {name: "replacement", typ: TypeNumber}}, result: TypeNumber, pure: func(a []float64) float64 {
+		if math.IsNaN(a[0]) {
+			return a[1]
+		}
+		return a[0]
+	}},
+
+	"sma":         series(func(c []float64) indicators.Series { return indicators.NewSMA(int(c[0])) }),
+	"ema":         series(func(c []float64) indicators.Series { return indicators.NewEMA(int(c[0])) }),
+	"wma":         series(func(c []float64) indicators.Series { return indicators.NewWMA(int(c[0])) }),
+	"highest":     series(func(c []float64) indicators.Series { return indicators.NewHighest(int(c[0])) }),
+	"lowest":      series(func(c []float64) indicators.Series { return indicators.NewLowest(int(c[0])) }),
+	"stdev":       series(func(c []float64) indicators.Series { return indicators.NewStdDev(int(c[0])) }),
+	"rsi":         series(func(c []float64) indicators.Series { return indicators.NewRSI(int(c[0])) }),
+	"macd":        {args: []argSpec{num, fast, slow}, result: TypeNumber, state: newMACD(false)},
+	"macd_signal": {args: []argSpec{num, fast, slow, signal}, result: TypeNumber, state: newMACD(true)},
+	"atr": bars([]argSpec{period}, func(c []float64) (indicators.Indicator, func() float64) {
+		a := indicators.NewATR(int(c[0]))
+		return a, a.Value
+	}),
+	"adx": bars([]argSpec{period}, func(c []float64) (indicators.Indicator, func() float64) {
+		a := indicators.NewADX(int(c[0]))
+		return a, a.Value
+	}),
+	"sar": bars(nil, func([]float64) (indicators.Indicator, func() float64) {
+		p := indicators.NewPSAR(0.02, 0.2)
+		return p, p.Value
+	}),
+	"vwap": bars(nil, func([]float64) (indicators.Indicator, func() float64) {
+		// The forex New York close; arguments are never invalid
+		w, _ := indicators.NewVWAP(resample.NewYorkClose(), resample.D1)
+		return w, w.Value
+	}),
+	"crossover":  cross(1),
+	"crossunder": cross(-1),
+}
+
+func pure1(f func(float64) float64) funcSpec {
+	return funcSpec{args: []argSpec{num}, result: TypeNumber, pure: func(a []float64) float64 { return f(a[0]) }}
+}
+
+func truthy(v float64) bool {
+	return v != 0 && !math.IsNaN(v)
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ring is a fixed window of the latest values
+type ring struct {
+	values []float64
+	next   int
+	size   int // Values held, up to len(values)
+}
+
+func newRing(n int) *ring {
+	return &ring{values: make([]float64, n)}
+}
+
+func (r *ring) push(v float64) {
+	if r.size < len(r.values) {
+		r.size++
+	}
+	r.values[r.next] = v
+	r.next = (r.next + 1) % len(r.values)
+}
+
+// ago returns the value pushed n pushes ago, zero being the latest
+func (r *ring) ago(n int) float64 {
+	if n >= r.size {
+		return math.NaN()
+	}
+	return r.values[(r.next-1-n+2*len(r.values))%len(r.values)]
+}
+
+// seriesState feeds its first argument to an indicator of one series
+type seriesState struct {
+	s indicators.Series
+}
+
+func series(build func(consts []float64) indicators.Series) funcSpec {
+	return funcSpec{args: []argSpec{num, period}, result: TypeNumber, state: func(c []float64) stateful {
+		return seriesState{build(c)}
+	}}
+}
+
+func (s seriesState) update(_ *frame, a []float64) float64 {
+	return s.s.Add(a[0])
+}
+
+// barState feeds each bar to an indicator of the bars themselves
+type barState struct {
+	ind   indicators.Indicator
+	value func() float64
+}
+
+func bars(args []argSpec, build func(consts []float64) (indicators.Indicator, func() float64)) funcSpec {
+	return funcSpec{args: args, result: TypeNumber, state: func(c []float64) stateful {
+		ind, value := build(c)
+		return barState{ind, value}
+	}}
+}
+
+func (s barState) update(f *frame, _ []float64) float64 {
+	s.ind.Update(f.bar)
+	return s.value()
+}
+
+// macdState is the MACD line, or its signal line
+type macdState struct {
+	m      *indicators.MACD
+	signal bool
+}
+
+func newMACD(signal bool) func(c []float64) stateful {
+	return func(c []float64) stateful {
+		n := 9.0
+		if signal {
+			n = c[2]
+		}
+		return macdState{indicators.NewMACD(int(c[0]), int(c[1]), int(n)), signal}
+	}
+}
+
+func (s macdState) update(_ *frame, a []float64) float64 {
+	line := s.m.Add(a[0])
+	if s.signal {
+		return s.m.Signal()
+	}
+	return line
+}
+
+// crossed reports a sign change of a - b between the previous bar and this
+// one, upwards for direction 1 and downwards for -1
+type crossed struct {
+	direction float64
+	prev      float64
+}
+
+func cross(direction float64) funcSpec {
+	return funcSpec{args: []argSpec{num, num}, result: TypeBool, state: func([]float64) stateful {
+		return &crossed{direction: direction, prev: math.NaN()}
+	}}
+}
+
+func (s *crossed) update(_ *frame, a []float64) float64 {
+	diff := (a[0] - a[1]) * s.direction
+	prev := s.prev
+	s.prev = diff
+	return boolean(prev <= 0 && diff > 0)
+}
+
+// lagged returns its input from a fixed number of bars ago, for x[n]
+type lagged struct {
+	w *ring
+	n int
+}
+
+func (s *lagged) update(_ *frame, a []float64) float64 {
+	s.w.push(a[0])
+	return s.w.ago(s.n)
+}
@@ -0,0 +1,393 @@
+package dsl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// Compile parses and type-checks a strategy's source
+func Compile(src string) (*Program, error) {
+	prog, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := Check(prog); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+type checker struct {
+	params map[string]*Param
+	lets   map[string]Type
+	broken map[string]bool   // Lets whose value had errors, not reported again where used
+	frames map[string]string // Timeframe of each let, empty for the strategy's own
+	frame  string            // Timeframe of the let being checked
+	errs   Errors
+}
+
+// Check type-checks a program, whether parsed or built some other way. It
+// verifies constant arguments with the params' default values.
+func Check(prog *Program) error {
+	c := &checker{
+		params: make(map[string]*Param),
+		lets:   make(map[string]Type),
+		broken: make(map[string]bool),
+		frames: make(map[string]string),
+	}
+
+	for _, p := range prog.Params {
+		if c.declared(p.Pos, p.Name) {
+			continue
+		}
+		c.params[p.Name] = p
+		if (p.Min == nil) != (p.Max == nil) {
+			c.errs.add(p.Pos, "param %s needs both ends of its range", p.Name)
+			continue
+		}
+		if p.Min != nil {
+			if *p.Min > *p.Max {
+				c.errs.add(p.Pos, "range of %s is empty: %g is above %g", p.Name, *p.Min, *p.Max)
+			} else if p.Default < *p.Min || p.Default > *p.Max {
+				c.errs.add(p.Pos, "default %g of %s is outside its range [%g, %g]", p.Default, p.Name, *p.Min, *p.Max)
+			}
+		}
+	}
+
+	for _, l := range prog.Lets {
+		if l.Timeframe != "" {
+			if tf, err := resample.ParseTimeframe(l.Timeframe); err != nil {
+				c.errs.add(l.Pos, "unknown timeframe %s for %s", l.Timeframe, l.Name)
+			} else if string(tf) != l.Timeframe {
+				c.errs.add(l.Pos, "write the timeframe of %s as %s", l.Name, tf)
+			}
+		}
+		c.frame = l.Timeframe
+		typ, _ := c.expr(l.Value)
+		c.frame = ""
+		if c.declared(l.Pos, l.Name) {
+			continue
+		}
+		c.frames[l.Name] = l.Timeframe
+		if typ == 0 {
+			c.broken[l.Name] = true
+			continue
+		}
+		c.lets[l.Name] = typ
+	}
+
+	if prog.Size != nil {
+		c.want(prog.Size, TypeNumber, "size")
+	}
+	for _, e := range prog.Entries {
+		c.want(e.When, TypeBool, "entry condition")
+		for _, setting := range []struct {
+			name string
+			expr Expr
+		}{{"size", e.Size}, {"stop_loss", e.StopLoss}, {"take_profit", e.TakeProfit}} {
+			if setting.expr != nil {
+				c.want(setting.expr, TypeNumber, setting.name)
+			}
+		}
+	}
+	for _, e := range prog.Exits {
+		c.want(e.When, TypeBool, "exit condition")
+	}
+	if len(prog.Entries) == 0 && len(c.errs) == 0 {
+		c.errs.add(Pos{1, 1}, "strategy has no entry rules")
+	}
+
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		a, b := c.errs[i].Pos, c.errs[j].Pos
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return c.errs.err()
+}
+
+// declared reports, and records an error, when a name is already taken
+func (c *checker) declared(pos Pos, name string) bool {
+	switch {
+	case c.params[name] != nil:
+		c.errs.add(pos, "%s is already declared as a param", name)
+	case c.lets[name] != 0 || c.broken[name]:
+		c.errs.add(pos, "%s is already declared with let", name)
+	case values[name].typ != 0:
+		c.errs.add(pos, "%s is a built-in value and cannot be redeclared", name)
+	case funcs[name].result != 0:
+		c.errs.add(pos, "%s is a built-in function and cannot be redeclared", name)
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *checker) want(e Expr, want Type, what string) {
+	if typ, _ := c.expr(e); typ != 0 && typ != want {
+		c.errs.add(e.Position(), "%s must be a %s, not a %s", what, want, typ)
+	}
+}
+
+// expr returns an expression's type, zero after an error, and whether it
+// is a constant
+func (c *checker) expr(e Expr) (Type, bool) {
+	switch e := e.(type) {
+	case *Number:
+		return TypeNumber, true
+	case *Bool:
+		return TypeBool, false
+	case *Ident:
+		return c.ident(e)
+	case *Unary:
+		typ, constant := c.expr(e.X)
+		want := TypeNumber
+		if e.Op == "not" {
+			want = TypeBool
+		}
+		if typ != 0 && typ != want {
+			c.errs.add(e.At, "%s needs a %s, not a %s", e.Op, want, typ)
+			return 0, false
+		}
+		return want, constant && e.Op == "-"
+	case *Binary:
+		return c.binary(e)
+	case *Call:
+		return c.call(e)
+	case *Index:
+		typ, _ := c.expr(e.X)
+		c.constant(e.Offset, "offset", 0)
+		return typ, false
+	}
+	return 0, false
+}
+
+func (c *checker) ident(e *Ident) (Type, bool) {
+	if c.params[e.Name] != nil {
+		return TypeNumber, true
+	}
+	if typ := c.lets[e.Name]; typ != 0 {
+		// The strategy's bars may read any let, but a let on a higher
+		// timeframe only sees values that change with its own bars
+		if c.frame != "" && c.frames[e.Name] != c.frame {
+			on := "the strategy's own bars"
+			if c.frames[e.Name] != "" {
+				on = c.frames[e.Name] + " bars"
+			}
+			c.errs.add(e.At, "%s is computed on %s and cannot be used on %s bars", e.Name, on, c.frame)
+			return 0, false
+		}
+		return typ, false
+	}
+	if c.broken[e.Name] {
+		return 0, false
+	}
+	if v, ok := values[e.Name]; ok {
+		return v.typ, false
+	}
+	if _, ok := funcs[e.Name]; ok {
+		c.errs.add(e.At, "%s is a function; call it as %s(...)", e.Name, e.Name)
+		return 0, false
+	}
+	c.errs.add(e.At, "undefined name %s%s", e.Name, c.suggest(e.Name))
+	return 0, false
+}
+
+func (c *checker) binary(e *Binary) (Type, bool) {
+	x, xc := c.expr(e.X)
+	y, yc := c.expr(e.Y)
+	if x == 0 || y == 0 {
+		return 0, false
+	}
+	switch e.Op {
+	case "and", "or":
+		if x != TypeBool || y != TypeBool {
+			c.errs.add(e.At, "%s needs bool operands, not %s and %s", e.Op, x, y)
+			return 0, false
+		}
+		return TypeBool, false
+	case "==", "!=":
+		if x != y {
+			c.errs.add(e.At, "cannot compare a %s with a %s", x, y)
+			return 0, false
+		}
+		return TypeBool, false
+	case "<", "<=", ">", ">=":
+		if x != TypeNumber || y != TypeNumber {
+			c.errs.add(e.At, "%s needs number operands, not %s and %s", e.Op, x, y)
+			return 0, false
+		}
+		return TypeBool, false
+	}
+	if x != TypeNumber || y != TypeNumber {
+		c.errs.add(e.At, "%s needs number operands, not %s and %s", e.Op, x, y)
+		return 0, false
+	}
+	return TypeNumber, xc && yc
+}
+
+func (c *checker) call(e *Call) (Type, bool) {
+	spec, ok := funcs[e.Func]
+	if !ok {
+		if _, isValue := values[e.Func]; isValue || c.params[e.Func] != nil || c.lets[e.Func] != 0 {
+			c.errs.add(e.At, "%s is a value, not a function", e.Func)
+		} else {
+			c.errs.add(e.At, "unknown function %s%s", e.Func, c.suggest(e.Func))
+		}
+		return 0, false
+	}
+	if len(e.Args) != len(spec.args) {
+		c.errs.add(e.At, "%s takes %d argument%s, got %d", e.Func, len(spec.args), plural(len(spec.args)), len(e.Args))
+		return 0, false
+	}
+
+	constant := spec.pure != nil
+	for i, arg := range e.Args {
+		want := spec.args[i]
+		if want.constant {
+			c.constant(arg, fmt.Sprintf("%s of %s", want.name, e.Func), 1)
+			continue
+		}
+		typ, argConst := c.expr(arg)
+		if typ != 0 && typ != want.typ {
+			c.errs.add(arg.Position(), "%s of %s must be a %s, not a %s", want.name, e.Func, want.typ, typ)
+		}
+		constant = constant && argConst
+	}
+	return spec.result, constant && spec.result == TypeNumber
+}
+
+// constant checks that e is a whole number known before the first bar and,
+// with default params, within [min, MaxLookback]
+func (c *checker) constant(e Expr, what string, min int) {
+	typ, constant := c.expr(e)
+	if typ == 0 {
+		return
+	}
+	if typ != TypeNumber || !constant {
+		c.errs.add(e.Position(), "%s must be a constant number: a literal, a param or arithmetic on them", what)
+		return
+	}
+	defaults := make(map[string]float64, len(c.params))
+	for name, p := range c.params {
+		defaults[name] = p.Default
+	}
+	if err := checkWhole(constValue(e, defaults), what, min); err != "" {
+		c.errs.add(e.Position(), "%s", err)
+	}
+}
+
+func checkWhole(v float64, what string, min int) string {
+	switch {
+	case math.IsNaN(v) || v != math.Trunc(v):
+		return fmt.Sprintf("%s must be a whole number, got %g", what, v)
+	case v < float64(min) || v > MaxLookback:
+		return fmt.Sprintf("%s must be between %d and %d, got %g", what, min, MaxLookback, v)
+	}
+	return ""
+}
+
+// constValue evaluates a constant expression with the given params
+func constValue(e Expr, params map[string]float64) float64 {
+	switch e := e.(type) {
+	case *Number:
+		return e.Value
+	case *Ident:
+		return params[e.Name]
+	case *Unary:
+		return -constValue(e.X, params)
+	case *Binary:
+		return arith(e.Op, constValue(e.X, params), constValue(e.Y, params))
+	case *Call:
+		args := make([]float64, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = constValue(a, params)
+		}
+		return funcs[e.Func].pure(args)
+	}
+	return math.NaN()
+}
+
+// arith applies an arithmetic operator. Division by zero gives NaN rather
+// than infinity so that it reads as "no value".
+func arith(op string, x, y float64) float64 {
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		if y == 0 {
+			return math.NaN()
+		}
+		return x / y
+	case "%":
+		if y == 0 {
+			return math.NaN()
+		}
+		return math.Mod(x, y)
+	}
+	return math.NaN()
+}
+
+// suggest names a close match for a misspelt identifier
+func (c *checker) suggest(name string) string {
+	var names []string
+	for n := range values {
+		names = append(names, n)
+	}
+	for n := range funcs {
+		names = append(names, n)
+	}
+	for n := range c.params {
+		names = append(names, n)
+	}
+	for n, typ := range c.lets {
+		if typ != 0 {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	best, bestDist := "", 3
+	for _, n := range names {
+		if d := distance(name, n); d < bestDist {
+			best, bestDist = n, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s?)", best)
+}
+
+// distance is the Levenshtein edit distance between two short names
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,44 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxErrors caps how many problems are reported for one source
+const maxErrors = 20
+
+// Error is a problem at a position in the source
+type Error struct {
+	Pos     Pos    `json:"pos"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Errors is every problem found in a source, in source order
+type Errors []Error
+
+func (es *Errors) add(pos Pos, format string, args ...any) {
+	if len(*es) < maxErrors {
+		*es = append(*es, Error{Pos: pos, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (es Errors) Error() string {
+	lines := make([]string, len(es))
+	for i, e := range es {
+		lines[i] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// err returns the list as an error, or nil when it is empty
+func (es Errors) err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
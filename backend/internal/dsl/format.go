@@ -0,0 +1,161 @@
+package dsl
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ValidName reports whether name can be written as an identifier
+func ValidName(name string) bool {
+	if name == "" || keywords[name] {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Format writes a program as source text. Parsing the text gives back the
+// same program, apart from positions.
+func Format(prog *Program) string {
+	var b strings.Builder
+	if prog.Name != "" {
+		name := strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(prog.Name)
+		b.WriteString("strategy \"" + name + "\"\n")
+	}
+	if len(prog.Params) > 0 {
+		b.WriteString("\n")
+	}
+	for _, p := range prog.Params {
+		b.WriteString("param " + p.Name + " = " + formatNumber(p.Default))
+		if p.Min != nil && p.Max != nil {
+			b.WriteString(" in [" + formatNumber(*p.Min) + ", " + formatNumber(*p.Max) + "]")
+		}
+		b.WriteString("\n")
+	}
+	if len(prog.Lets) > 0 {
+		b.WriteString("\n")
+	}
+	for _, l := range prog.Lets {
+		b.WriteString("let " + l.Name)
+		if l.Timeframe != "" {
+			b.WriteString(" on " + l.Timeframe)
+		}
+		b.WriteString(" = " + FormatExpr(l.Value) + "\n")
+	}
+	if prog.Size != nil {
+		b.WriteString("\nsize = " + FormatExpr(prog.Size) + "\n")
+	}
+	for _, e := range prog.Entries {
+		b.WriteString("\nentry " + e.Side + " when " + FormatExpr(e.When))
+		if e.Size == nil && e.StopLoss == nil && e.TakeProfit == nil {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(" {\n")
+		for _, setting := range []struct {
+			name string
+			expr Expr
+		}{{"size", e.Size}, {"stop_loss", e.StopLoss}, {"take_profit", e.TakeProfit}} {
+			if setting.expr != nil {
+				b.WriteString("    " + setting.name + " = " + FormatExpr(setting.expr) + "\n")
+			}
+		}
+		b.WriteString("}\n")
+	}
+	if len(prog.Exits) > 0 {
+		b.WriteString("\n")
+	}
+	for _, e := range prog.Exits {
+		b.WriteString("exit " + e.Side + " when " + FormatExpr(e.When) + "\n")
+	}
+	return strings.TrimPrefix(b.String(), "\n")
+}
+
+// Binding strength of printed expressions, loosest first
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precCompare
+	precAdd
+	precMul
+	precNeg
+	precIndex
+	precPrimary
+)
+
+// FormatExpr writes an expression with only the parentheses it needs
+func FormatExpr(e Expr) string {
+	text, _ := formatExpr(e)
+	return text
+}
+
+// formatExpr returns an expression's text and how tightly it binds
+func formatExpr(e Expr) (string, int) {
+	switch e := e.(type) {
+	case *Number:
+		if e.Value < 0 {
+			return formatNumber(e.Value), precNeg
+		}
+		return formatNumber(e.Value), precPrimary
+	case *Bool:
+		return strconv.FormatBool(e.Value), precPrimary
+	case *Ident:
+		return e.Name, precPrimary
+	case *Unary:
+		if e.Op == "not" {
+			// The operand of not may be a comparison, or another not
+			return "not " + wrap(e.X, precNot), precNot
+		}
+		return "-" + wrap(e.X, precNeg), precNeg
+	case *Binary:
+		prec := binaryPrec(e.Op)
+		// Operators group to the left, and comparisons do not chain
+		left := prec
+		if prec == precCompare {
+			left++
+		}
+		return wrap(e.X, left) + " " + e.Op + " " + wrap(e.Y, prec+1), prec
+	case *Call:
+		args := make([]string, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = FormatExpr(a)
+		}
+		return e.Func + "(" + strings.Join(args, ", ") + ")", precPrimary
+	case *Index:
+		return wrap(e.X, precIndex) + "[" + FormatExpr(e.Offset) + "]", precIndex
+	}
+	return "", precPrimary
+}
+
+// wrap parenthesizes an operand that binds looser than min
+func wrap(e Expr, min int) string {
+	text, prec := formatExpr(e)
+	if prec < min {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func binaryPrec(op string) int {
+	switch op {
+	case "or":
+		return precOr
+	case "and":
+		return precAnd
+	case "+", "-":
+		return precAdd
+	case "*", "/", "%":
+		return precMul
+	}
+	return precCompare
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
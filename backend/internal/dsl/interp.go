@@ -0,0 +1,411 @@
+package dsl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// eval computes a node's value for the current bar. Bools are 1 or 0 and a
+// missing value, such as an indicator still warming up, is NaN.
+type eval func(f *frame) float64
+
+// Strategy runs a checked program in the backtest engine. Every expression
+// is evaluated on every bar, so indicators inside conditions that are not
+// reached still see each bar. Lets on a higher timeframe are evaluated on
+// each of its bars instead.
+type Strategy struct {
+	prog   *Program
+	params map[string]float64
+	frame  frame
+	lets   []eval
+	values []float64            // Current let values, by declaration order
+	on     []resample.Timeframe // Timeframe of each let, empty for the strategy's own
+	higher map[resample.Timeframe]*frame
+	names  map[string]int
+	size   eval // Program default; nil leaves sizing to the backtest
+	rules  []rule
+}
+
+type rule struct {
+	entry      bool
+	side       engine.Side
+	when       eval
+	size       eval // Nil uses the program default
+	stopLoss   eval // Nil for none
+	takeProfit eval
+}
+
+// NewStrategy prepares a checked program with parameter overrides. Unknown
+// parameters and values outside a param's range are rejected.
+func NewStrategy(prog *Program, overrides map[string]any) (*Strategy, error) {
+	s := &Strategy{
+		prog:   prog,
+		params: make(map[string]float64),
+		names:  make(map[string]int),
+		higher: make(map[resample.Timeframe]*frame),
+	}
+
+	declared := make(map[string]*Param, len(prog.Params))
+	for _, p := range prog.Params {
+		declared[p.Name] = p
+		s.params[p.Name] = p.Default
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := declared[name]
+		if p == nil {
+			return nil, fmt.Errorf("strategy has no param %s", name)
+		}
+		v, ok := toFloat(overrides[name])
+		if !ok {
+			return nil, fmt.Errorf("param %s must be a number", name)
+		}
+		if p.Min != nil && (v < *p.Min || v > *p.Max) {
+			return nil, fmt.Errorf("param %s must be between %g and %g, got %g", name, *p.Min, *p.Max, v)
+		}
+		s.params[name] = v
+	}
+
+	c := &compiler{s: s}
+	s.values = make([]float64, len(prog.Lets))
+	for i, l := range prog.Lets {
+		s.lets = append(s.lets, c.expr(l.Value))
+		s.names[l.Name] = i
+		var tf resample.Timeframe
+		if l.Timeframe != "" {
+			tf = resample.Timeframe(l.Timeframe)
+			if s.higher[tf] == nil {
+				s.higher[tf] = &frame{}
+			}
+		}
+		s.on = append(s.on, tf)
+	}
+	s.size = c.optional(prog.Size)
+	for _, e := range prog.Exits {
+		s.rules = append(s.rules, rule{side: side(e.Side), when: c.expr(e.When)})
+	}
+	for _, e := range prog.Entries {
+		s.rules = append(s.rules, rule{
+			entry:      true,
+			side:       side(e.Side),
+			when:       c.expr(e.When),
+			size:       c.optional(e.Size),
+			stopLoss:   c.optional(e.StopLoss),
+			takeProfit: c.optional(e.TakeProfit),
+		})
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return s, nil
+}
+
+// Params returns the parameter values in use
+func (s *Strategy) Params() map[string]float64 {
+	return s.params
+}
+
+func side(name string) engine.Side {
+	if name == Short {
+		return engine.Sell
+	}
+	return engine.Buy
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// Init implements engine.Strategy, subscribing to the timeframes of lets
+// computed on higher timeframes
+func (s *Strategy) Init(ctx *engine.Context) error {
+	s.frame.index = -1
+	for _, name := range s.prog.Timeframes() {
+		tf := resample.Timeframe(name)
+		if err := ctx.Subscribe(tf); err != nil {
+			return err
+		}
+		s.higher[tf].index = -1
+	}
+	return nil
+}
+
+// OnTimeframeBar implements engine.TimeframeStrategy. The lets on the
+// bar's timeframe are evaluated, and keep their values until its next bar.
+func (s *Strategy) OnTimeframeBar(ctx *engine.Context, tf resample.Timeframe, bar marketdata.Bar) error {
+	f := s.higher[tf]
+	if f == nil {
+		return nil
+	}
+	f.update(ctx, bar)
+	for i, let := range s.lets {
+		if s.on[i] == tf {
+			s.values[i] = let(f)
+		}
+	}
+	return nil
+}
+
+// OnBar evaluates the program on a closed bar. Exit rules act before entry
+// rules; an entry is skipped while a position on its side is open or
+// ordered, closes positions on the other side, and is skipped when its
+// size, stop loss or take profit has no value yet.
+func (s *Strategy) OnBar(ctx *engine.Context, bar marketdata.Bar) error {
+	f := &s.frame
+	f.update(ctx, bar)
+	for i, let := range s.lets {
+		if s.on[i] == "" {
+			s.values[i] = let(f)
+		}
+	}
+
+	// Evaluate everything before acting so stateful calls see every bar
+	type decision struct {
+		rule                       *rule
+		fire                       bool
+		size, stopLoss, takeProfit float64
+	}
+	size := optional(s.size, f, 0)
+	decisions := make([]decision, len(s.rules))
+	for i := range s.rules {
+		r := &s.rules[i]
+		d := decision{rule: r, fire: truthy(r.when(f)), size: size}
+		if r.entry {
+			d.size, d.stopLoss, d.takeProfit = optional(r.size, f, size), optional(r.stopLoss, f, 0), optional(r.takeProfit, f, 0)
+		}
+		decisions[i] = d
+	}
+
+	// Warm-up bars only feed the stateful calls
+	if ctx.WarmingUp() {
+		return nil
+	}
+	for _, d := range decisions {
+		if d.fire && !d.rule.entry {
+			s.closeSide(ctx, d.rule.side)
+		}
+	}
+	for _, d := range decisions {
+		if !d.fire || !d.rule.entry || s.holding(ctx, d.rule.side) {
+			continue
+		}
+		// A setting without a value yet skips the entry
+		if math.IsNaN(d.size) || math.IsNaN(d.stopLoss) || math.IsNaN(d.takeProfit) || d.size < 0 {
+			continue
+		}
+		s.closeSide(ctx, -d.rule.side)
+		// Rejections are recorded in the trade log and do not stop the run
+		ctx.Submit(engine.Order{
+			Side:       d.rule.side,
+			Type:       engine.Market,
+			Volume:     floorLots(d.size, ctx.Instrument().LotStep),
+			StopLoss:   d.stopLoss,
+			TakeProfit: d.takeProfit,
+		})
+	}
+	return nil
+}
+
+// update moves a frame to its next bar
+func (f *frame) update(ctx *engine.Context, bar marketdata.Bar) {
+	f.index++
+	f.bar = bar
+	f.balance, f.equity, f.free = ctx.Balance(), ctx.Equity(), ctx.FreeMargin()
+	f.pip = ctx.PipSize()
+	if value, err := ctx.PipValue(); err == nil {
+		f.pipVal = value
+	}
+	f.net = 0
+	for _, p := range ctx.Positions() {
+		f.net += float64(p.Side) * p.Volume
+	}
+}
+
+func (s *Strategy) holding(ctx *engine.Context, side engine.Side) bool {
+	for _, p := range ctx.Positions() {
+		if p.Side == side {
+			return true
+		}
+	}
+	for _, o := range ctx.Orders() {
+		if o.Side == side {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Strategy) closeSide(ctx *engine.Context, side engine.Side) {
+	for _, p := range ctx.Positions() {
+		if p.Side == side {
+			ctx.Close(p.ID)
+		}
+	}
+}
+
+// floorLots rounds a size down to the lot step. Zero stays zero, leaving
+// sizing to the backtest.
+func floorLots(lots, step float64) float64 {
+	if lots == 0 || step <= 0 {
+		return lots
+	}
+	return math.Round(math.Floor(lots/step+1e-9)*step*1e8) / 1e8
+}
+
+// compiler turns checked expressions into evaluation closures
+type compiler struct {
+	s   *Strategy
+	err error
+}
+
+// optional compiles an expression that may be absent
+func (c *compiler) optional(e Expr) eval {
+	if e == nil {
+		return nil
+	}
+	return c.expr(e)
+}
+
+// optional evaluates an optional expression, or returns def when absent
+func optional(e eval, f *frame, def float64) float64 {
+	if e == nil {
+		return def
+	}
+	return e(f)
+}
+
+func (c *compiler) expr(e Expr) eval {
+	switch e := e.(type) {
+	case *Number:
+		v := e.Value
+		return func(*frame) float64 { return v }
+	case *Bool:
+		v := boolean(e.Value)
+		return func(*frame) float64 { return v }
+	case *Ident:
+		if v, ok := c.s.params[e.Name]; ok {
+			return func(*frame) float64 { return v }
+		}
+		if i, ok := c.s.names[e.Name]; ok {
+			lets := c.s.values
+			return func(*frame) float64 { return lets[i] }
+		}
+		return values[e.Name].get
+	case *Unary:
+		x := c.expr(e.X)
+		if e.Op == "not" {
+			return func(f *frame) float64 { return boolean(!truthy(x(f))) }
+		}
+		return func(f *frame) float64 { return -x(f) }
+	case *Binary:
+		return c.binary(e)
+	case *Call:
+		return c.call(e)
+	case *Index:
+		v := constValue(e.Offset, c.s.params)
+		if msg := checkWhole(v, "offset", 0); msg != "" {
+			c.fail(e.Offset.Position(), msg)
+			v = 0
+		}
+		n := int(v)
+		x := c.expr(e.X)
+		if n == 0 {
+			return x
+		}
+		state := &lagged{w: newRing(n + 1), n: n}
+		return func(f *frame) float64 { return state.update(f, []float64{x(f)}) }
+	}
+	return func(*frame) float64 { return math.NaN() }
+}
+
+func (c *compiler) binary(e *Binary) eval {
+	x, y := c.expr(e.X), c.expr(e.Y)
+	switch e.Op {
+	case "and":
+		return func(f *frame) float64 {
+			a, b := truthy(x(f)), truthy(y(f))
+			return boolean(a && b)
+		}
+	case "or":
+		return func(f *frame) float64 {
+			a, b := truthy(x(f)), truthy(y(f))
+			return boolean(a || b)
+		}
+	case "==":
+		return func(f *frame) float64 { return boolean(x(f) == y(f)) }
+	case "!=":
+		return func(f *frame) float64 {
+			a, b := x(f), y(f)
+			return boolean(a != b && !math.IsNaN(a) && !math.IsNaN(b))
+		}
+	case "<":
+		return func(f *frame) float64 { return boolean(x(f) < y(f)) }
+	case "<=":
+		return func(f *frame) float64 { return boolean(x(f) <= y(f)) }
+	case ">":
+		return func(f *frame) float64 { return boolean(x(f) > y(f)) }
+	case ">=":
+		return func(f *frame) float64 { return boolean(x(f) >= y(f)) }
+	}
+	op := e.Op
+	return func(f *frame) float64 { return arith(op, x(f), y(f)) }
+}
+
+func (c *compiler) call(e *Call) eval {
+	spec := funcs[e.Func]
+	var (
+		consts []float64
+		args   []eval
+	)
+	for i, a := range e.Args {
+		if spec.args[i].constant {
+			v := constValue(a, c.s.params)
+			if msg := checkWhole(v, fmt.Sprintf("%s of %s", spec.args[i].name, e.Func), 1); msg != "" {
+				c.fail(a.Position(), msg)
+			}
+			consts = append(consts, v)
+			continue
+		}
+		args = append(args, c.expr(a))
+	}
+
+	buf := make([]float64, len(args))
+	collect := func(f *frame) []float64 {
+		for i, a := range args {
+			buf[i] = a(f)
+		}
+		return buf
+	}
+	if spec.pure != nil {
+		return func(f *frame) float64 { return spec.pure(collect(f)) }
+	}
+	if c.err != nil {
+		return func(*frame) float64 { return math.NaN() }
+	}
+	state := spec.state(consts)
+	return func(f *frame) float64 { return state.update(f, collect(f)) }
+}
+
+// fail records the first problem found with overridden params
+func (c *compiler) fail(pos Pos, msg string) {
+	if c.err == nil {
+		c.err = Errors{{Pos: pos, Message: msg}}
+	}
+}
@@ -0,0 +1,173 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token kinds
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNewline
+	tokIdent
+	tokNumber
+	tokString
+	tokKeyword
+	tokOp // Operators and punctuation
+)
+
+// Keywords reserved by the language
+var keywords = map[string]bool{
+	"strategy": true, "param": true, "let": true, "size": true,
+	"entry": true, "exit": true, "when": true, "long": true, "short": true,
+	"stop_loss": true, "take_profit": true, "in": true,
+	"and": true, "or": true, "not": true, "true": true, "false": true,
+}
+
+// Pos is a position in the source, counted from 1. Columns count runes.
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  Pos
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokNewline:
+		return "end of line"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits source into tokens. Newlines are significant because each
+// declaration ends at the end of its line, except inside brackets.
+func lex(src string) ([]token, Errors) {
+	var (
+		tokens []token
+		errs   Errors
+		depth  int // Open ( and [ brackets, inside which newlines are ignored
+		line   = 1
+		col    = 1
+		i      = 0
+	)
+	advance := func(n int) {
+		for _, r := range src[i : i+n] {
+			if r == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+		}
+		i += n
+	}
+
+	for i < len(src) {
+		pos := Pos{line, col}
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case r == '\n':
+			if depth == 0 && len(tokens) > 0 && tokens[len(tokens)-1].kind != tokNewline {
+				tokens = append(tokens, token{kind: tokNewline, text: "\n", pos: pos})
+			}
+			advance(1)
+		case r == ' ' || r == '\t' || r == '\r':
+			advance(1)
+		case r == '#' || strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			advance(end)
+		case r == '"':
+			end := strings.IndexAny(src[i+1:], "\"\n")
+			if end < 0 || src[i+1+end] != '"' {
+				errs.add(pos, "string is not terminated")
+				end = strings.IndexByte(src[i+1:], '\n')
+				if end < 0 {
+					end = len(src) - i - 1
+				}
+				advance(end + 1)
+				continue
+			}
+			tokens = append(tokens, token{kind: tokString, text: src[i+1 : i+1+end], pos: pos})
+			advance(end + 2)
+		case (r < utf8.RuneSelf && isDigit(byte(r))) || (r == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			end := i
+			for end < len(src) && (isDigit(src[end]) || src[end] == '.') {
+				end++
+			}
+			text := src[i:end]
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				errs.add(pos, "malformed number %q", text)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text, num: n, pos: pos})
+			advance(end - i)
+		case r == '_' || unicode.IsLetter(r):
+			end := i
+			for end < len(src) {
+				r, size := utf8.DecodeRuneInString(src[end:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				end += size
+			}
+			text := src[i:end]
+			kind := tokIdent
+			if keywords[text] {
+				kind = tokKeyword
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: pos})
+			advance(end - i)
+		default:
+			op := ""
+			for _, candidate := range []string{"<=", ">=", "==", "!=", "+", "-", "*", "/", "%", "<", ">", "=", "(", ")", "[", "]", "{", "}", ","} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				errs.add(pos, "unexpected character %q", r)
+				advance(size)
+				continue
+			}
+			switch op {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				if depth > 0 {
+					depth--
+				}
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: pos})
+			advance(len(op))
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: Pos{line, col}})
+	return tokens, errs
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
@@ -0,0 +1,362 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxSourceSize bounds the length of a strategy's source in bytes
+const MaxSourceSize = 64 << 10
+
+// maxDepth bounds expression nesting so hostile input cannot exhaust the stack
+const maxDepth = 64
+
+// bail unwinds the parser out of a declaration after a syntax error
+var bail = errors.New("syntax error")
+
+type parser struct {
+	tokens []token
+	i      int
+	depth  int
+	block  bool // Inside an entry block, where recovery skips to its end
+	errs   Errors
+	prog   *Program
+}
+
+// Parse reads a strategy's source into a program without checking it. Use
+// Compile to parse and check in one step.
+func Parse(src string) (*Program, error) {
+	if len(src) > MaxSourceSize {
+		return nil, Errors{{Pos: Pos{1, 1}, Message: fmt.Sprintf("source is longer than %d KB", MaxSourceSize>>10)}}
+	}
+	tokens, errs := lex(src)
+	p := &parser{tokens: tokens, errs: errs, prog: &Program{}}
+	for p.peek().kind != tokEOF {
+		p.declaration()
+	}
+	return p.prog, p.errs.err()
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// fail records an error at a token and unwinds to the declaration loop
+func (p *parser) fail(t token, format string, args ...any) {
+	p.errs.add(t.pos, format, args...)
+	panic(bail)
+}
+
+func (p *parser) is(kind tokenKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && t.text == text
+}
+
+func (p *parser) expect(kind tokenKind, text string) token {
+	t := p.next()
+	if t.kind != kind || t.text != text {
+		p.fail(t, "expected %q, found %s", text, t)
+	}
+	return t
+}
+
+func (p *parser) ident(what string) token {
+	t := p.next()
+	if t.kind != tokIdent {
+		if t.kind == tokKeyword {
+			p.fail(t, "%q is a reserved word and cannot be used as a %s", t.text, what)
+		}
+		p.fail(t, "expected a %s, found %s", what, t)
+	}
+	return t
+}
+
+// endLine requires the declaration to end here
+func (p *parser) endLine() {
+	t := p.peek()
+	switch t.kind {
+	case tokNewline:
+		p.next()
+	case tokEOF:
+	default:
+		p.fail(t, "unexpected %s after the end of the declaration", t)
+	}
+}
+
+// skipLine discards the rest of a line after an error
+func (p *parser) skipLine() {
+	for {
+		t := p.next()
+		if t.kind == tokNewline || t.kind == tokEOF {
+			return
+		}
+	}
+}
+
+// skipBlock discards the rest of an entry block after an error
+func (p *parser) skipBlock() {
+	p.block = false
+	for {
+		t := p.next()
+		if (t.kind == tokOp && t.text == "}") || t.kind == tokEOF {
+			return
+		}
+	}
+}
+
+func (p *parser) declaration() {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != bail {
+				panic(r)
+			}
+			p.depth = 0
+			if p.block {
+				p.skipBlock()
+			} else {
+				p.skipLine()
+			}
+		}
+	}()
+
+	t := p.next()
+	switch {
+	case t.kind == tokNewline:
+	case t.kind == tokKeyword && t.text == "strategy":
+		name := p.next()
+		if name.kind != tokString {
+			p.fail(name, "expected the strategy name in quotes, found %s", name)
+		}
+		p.prog.Name = name.text
+		p.endLine()
+	case t.kind == tokKeyword && t.text == "param":
+		p.param()
+	case t.kind == tokKeyword && t.text == "let":
+		name := p.ident("name")
+		let := &Let{Pos: name.pos, Name: name.text}
+		// "on" is only special here, so it stays usable as a name
+		if p.is(tokIdent, "on") {
+			p.next()
+			let.Timeframe = p.ident("timeframe").text
+		}
+		p.expect(tokOp, "=")
+		let.Value = p.expr()
+		p.prog.Lets = append(p.prog.Lets, let)
+		p.endLine()
+	case t.kind == tokKeyword && t.text == "size":
+		if p.prog.Size != nil {
+			p.fail(t, "size is already set")
+		}
+		p.expect(tokOp, "=")
+		p.prog.Size = p.expr()
+		p.endLine()
+	case t.kind == tokKeyword && t.text == "entry":
+		p.entry(t)
+	case t.kind == tokKeyword && t.text == "exit":
+		exit := &Exit{Pos: t.pos, Side: p.side()}
+		p.expect(tokKeyword, "when")
+		exit.When = p.expr()
+		p.prog.Exits = append(p.prog.Exits, exit)
+		p.endLine()
+	default:
+		p.fail(t, "expected a declaration (strategy, param, let, size, entry or exit), found %s", t)
+	}
+}
+
+func (p *parser) param() {
+	name := p.ident("parameter name")
+	p.expect(tokOp, "=")
+	param := &Param{Pos: name.pos, Name: name.text, Default: p.signedNumber()}
+	if p.is(tokKeyword, "in") {
+		p.next()
+		p.expect(tokOp, "[")
+		lo := p.signedNumber()
+		p.expect(tokOp, ",")
+		hi := p.signedNumber()
+		p.expect(tokOp, "]")
+		param.Min, param.Max = &lo, &hi
+	}
+	p.prog.Params = append(p.prog.Params, param)
+	p.endLine()
+}
+
+func (p *parser) signedNumber() float64 {
+	sign := 1.0
+	if p.is(tokOp, "-") {
+		p.next()
+		sign = -1
+	}
+	t := p.next()
+	if t.kind != tokNumber {
+		p.fail(t, "expected a number, found %s", t)
+	}
+	return sign * t.num
+}
+
+func (p *parser) side() string {
+	t := p.next()
+	if t.kind != tokKeyword || (t.text != Long && t.text != Short) {
+		p.fail(t, "expected long or short, found %s", t)
+	}
+	return t.text
+}
+
+func (p *parser) entry(at token) {
+	entry := &Entry{Pos: at.pos, Side: p.side()}
+	p.expect(tokKeyword, "when")
+	entry.When = p.expr()
+	p.prog.Entries = append(p.prog.Entries, entry)
+	if !p.is(tokOp, "{") {
+		p.endLine()
+		return
+	}
+
+	p.next()
+	p.block = true
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokNewline:
+			continue
+		case t.kind == tokOp && t.text == "}":
+			p.block = false
+			p.endLine()
+			return
+		case t.kind == tokEOF:
+			p.fail(t, "entry block opened at %s is not closed", at.pos)
+		}
+
+		var slot *Expr
+		switch {
+		case t.kind == tokKeyword && t.text == "size":
+			slot = &entry.Size
+		case t.kind == tokKeyword && t.text == "stop_loss":
+			slot = &entry.StopLoss
+		case t.kind == tokKeyword && t.text == "take_profit":
+			slot = &entry.TakeProfit
+		default:
+			p.fail(t, "expected size, stop_loss or take_profit, found %s", t)
+		}
+		if *slot != nil {
+			p.fail(t, "%s is already set for this entry", t.text)
+		}
+		p.expect(tokOp, "=")
+		*slot = p.expr()
+		if t := p.peek(); t.kind != tokNewline && !p.is(tokOp, "}") {
+			p.fail(t, "unexpected %s after the end of the setting", t)
+		}
+	}
+}
+
+// Binary operator precedence, lowest first
+var precedence = [][]string{
+	{"or"},
+	{"and"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) expr() Expr {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		p.fail(p.peek(), "expression is nested too deeply")
+	}
+	return p.binary(0)
+}
+
+func (p *parser) binary(level int) Expr {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	if level == 2 {
+		// "not" binds looser than comparisons: not a > b is not (a > b)
+		if p.is(tokKeyword, "not") {
+			t := p.next()
+			return &Unary{At: t.pos, Op: "not", X: p.binary(level)}
+		}
+	}
+	x := p.binary(level + 1)
+	for {
+		t := p.peek()
+		if !(t.kind == tokOp || t.kind == tokKeyword) || !contains(precedence[level], t.text) {
+			return x
+		}
+		p.next()
+		y := p.binary(level + 1)
+		x = &Binary{At: t.pos, Op: t.text, X: x, Y: y}
+		if level == 2 && p.isComparison() {
+			p.fail(p.peek(), "comparisons cannot be chained; join them with and")
+		}
+	}
+}
+
+func (p *parser) isComparison() bool {
+	t := p.peek()
+	return t.kind == tokOp && contains(precedence[2], t.text)
+}
+
+func (p *parser) unary() Expr {
+	if p.is(tokOp, "-") {
+		t := p.next()
+		return &Unary{At: t.pos, Op: "-", X: p.unary()}
+	}
+	x := p.primary()
+	for p.is(tokOp, "[") {
+		t := p.next()
+		offset := p.expr()
+		p.expect(tokOp, "]")
+		x = &Index{At: t.pos, X: x, Offset: offset}
+	}
+	return x
+}
+
+func (p *parser) primary() Expr {
+	t := p.next()
+	switch {
+	case t.kind == tokNumber:
+		return &Number{At: t.pos, Value: t.num}
+	case t.kind == tokKeyword && (t.text == "true" || t.text == "false"):
+		return &Bool{At: t.pos, Value: t.text == "true"}
+	case t.kind == tokIdent:
+		if !p.is(tokOp, "(") {
+			return &Ident{At: t.pos, Name: t.text}
+		}
+		p.next()
+		call := &Call{At: t.pos, Func: t.text}
+		for !p.is(tokOp, ")") {
+			call.Args = append(call.Args, p.expr())
+			if !p.is(tokOp, ")") {
+				p.expect(tokOp, ",")
+			}
+		}
+		p.next()
+		return call
+	case t.kind == tokOp && t.text == "(":
+		x := p.expr()
+		p.expect(tokOp, ")")
+		return x
+	case t.kind == tokNewline || t.kind == tokEOF:
+		p.fail(t, "expression is incomplete")
+	}
+	p.fail(t, "expected a value, found %s", t)
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+// Package dukascopy decodes Dukascopy .bi5 hourly tick files.
+//
+// A .bi5 file is an LZMA-compressed sequence of 20-byte big-endian records:
+//
+//	uint32  milliseconds since the start of the hour
+//	uint32  ask price in points
+//	uint32  bid price in points
+//	float32 ask volume (millions)
+//	float32 bid volume (millions)
+package dukascopy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// RecordSize is the length of one decompressed tick record
+const RecordSize = 20
+
+// ErrCorrupt is returned when a file does not decompress to whole records
+var ErrCorrupt = errors.New("corrupt bi5 data")
+
+// PointScale returns the integer-to-price divisor Dukascopy uses for symbol.
+// JPY-quoted pairs and metals are quoted to three decimals, everything else
+// to five.
+func PointScale(symbol string) float64 {
+	symbol = strings.ToUpper(symbol)
+	switch {
+	case strings.HasSuffix(symbol, "JPY"),
+		strings.HasPrefix(symbol, "XAU"),
+		strings.HasPrefix(symbol, "XAG"):
+		return 1e3
+	default:
+		return 1e5
+	}
+}
+
+// Decoder reads ticks one at a time from a bi5 stream
+type Decoder struct {
+	r     *bufio.Reader
+	hour  time.Time
+	scale float64
+	buf   [RecordSize]byte
+}
+
+// NewDecoder returns a decoder for a compressed bi5 stream. hour is the
+// start of the hour the file covers; scale is the price divisor, usually
+// PointScale(symbol).
+func NewDecoder(r io.Reader, hour time.Time, scale float64) (*Decoder, error) {
+	lz, err := lzma.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return &Decoder{
+		r:     bufio.NewReaderSize(lz, 64*1024),
+		hour:  hour.UTC(),
+		scale: scale,
+	}, nil
+}
+
+// Next returns the next tick, or io.EOF when the stream is exhausted
+func (d *Decoder) Next() (marketdata.Tick, error) {
+	n, err := io.ReadFull(d.r, d.buf[:])
+	if err == io.EOF {
+		return marketdata.Tick{}, io.EOF
+	}
+	if err != nil {
+		if n > 0 {
+			return marketdata.Tick{}, ErrCorrupt
+		}
+		return marketdata.Tick{}, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	b := d.buf[:]
+	offset := binary.BigEndian.Uint32(b[0:4])
+	if offset >= uint32(time.Hour/time.Millisecond) {
+		return marketdata.Tick{}, fmt.Errorf("%w: tick offset %dms outside hour", ErrCorrupt, offset)
+	}
+
+	return marketdata.Tick{
+		Time:      d.hour.Add(time.Duration(offset) * time.Millisecond),
+		Ask:       float64(binary.BigEndian.Uint32(b[4:8])) / d.scale,
+		Bid:       float64(binary.BigEndian.Uint32(b[8:12])) / d.scale,
+		AskVolume: float64(math.Float32frombits(binary.BigEndian.Uint32(b[12:16]))),
+		BidVolume: float64(math.Float32frombits(binary.BigEndian.Uint32(b[16:20]))),
+	}, nil
+}
+
+// Decode reads every tick from a compressed bi5 stream
+func Decode(r io.Reader, hour time.Time, scale float64) ([]marketdata.Tick, error) {
+	dec, err := NewDecoder(r, hour, scale)
+	if err != nil {
+		return nil, err
+	}
+
+	var ticks []marketdata.Tick
+	for {
+		tick, err := dec.Next()
+		if err == io.EOF {
+			return ticks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ticks = append(ticks, tick)
+	}
+}
+
+// DecodeFile decodes a single bi5 file. Zero-length files, which Dukascopy
+// publishes for hours without trading, yield no ticks.
+func DecodeFile(path string, hour time.Time, scale float64) ([]marketdata.Tick, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bi5 file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat bi5 file: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil, nil
+	}
+
+	return Decode(f, hour, scale)
+}
@@ -0,0 +1,37 @@
+package dukascopy
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// hourPathPattern matches the Dukascopy layout YYYY/MM/DD/HHh_ticks.bi5.
+// Months are zero-based, exactly as in Dukascopy's datafeed URLs.
+var hourPathPattern = regexp.MustCompile(`(\d{4})[/\\_-](\d{2})[/\\_-](\d{2})[/\\_-](\d{2})h_ticks\.bi5$`)
+
+// ParseHourPath extracts the hour a bi5 file covers from its path, e.g.
+// "EURUSD/2023/00/02/13h_ticks.bi5" is 2023-01-02 13:00 UTC. Flattened
+// names using "_" or "-" between parts are accepted too.
+func ParseHourPath(path string) (time.Time, bool) {
+	m := hourPathPattern.FindStringSubmatch(filepath.ToSlash(path))
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	hour, _ := strconv.Atoi(m[4])
+	if month > 11 || day < 1 || day > 31 || hour > 23 {
+		return time.Time{}, false
+	}
+
+	t := time.Date(year, time.Month(month+1), day, hour, 0, 0, 0, time.UTC)
+	// time.Date normalizes overflow such as Feb 30, which is not a real hour file
+	if t.Day() != day {
+		return time.Time{}, false
+	}
+	return t, true
+}
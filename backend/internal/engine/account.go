@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// Ledger entry types
+const (
+	LedgerDeposit    = "deposit"
+	LedgerTrade      = "trade"
+	LedgerCommission = "commission"
+	LedgerSwap       = "swap"
+)
+
+// LedgerEntry is a single change to the account balance
+type LedgerEntry struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Amount  float64   `json:"amount"`
+	Balance float64   `json:"balance"` // After the entry
+	TradeID int64     `json:"tradeId,omitempty"`
+	Note    string    `json:"note,omitempty"`
+}
+
+// EquityPoint samples the account at a bar close
+type EquityPoint struct {
+	Time    time.Time `json:"time"`
+	Balance float64   `json:"balance"`
+	Equity  float64   `json:"equity"`
+}
+
+// maxEquityPoints bounds the stored equity curve; longer runs are thinned
+const maxEquityPoints = 4000
+
+// Account is the ledger of a simulated trading account
+type Account struct {
+	Currency string
+	balance  float64
+	equity   float64
+	ledger   []LedgerEntry
+
+	margin   float64 // Held by open positions
+	minLevel float64 // Lowest margin level seen, zero before any position
+
+	peak        float64 // Highest equity so far
+	maxDD       float64 // Largest peak-to-trough fall in equity
+	maxDDPct    float64 // Largest fall as a fraction of the peak it fell from
+	curve       []EquityPoint
+	stride      int // Samples merged into each curve point
+	pendingLow  *EquityPoint
+	pendingSeen int
+
+	session resample.Session
+	daily   []EquityPoint // Last sample of each trading day, dated at its midnight UTC
+}
+
+func newAccount(currency string, deposit float64, at time.Time, session resample.Session) *Account {
+	a := &Account{Currency: currency, stride: 1, session: session}
+	a.post(at, LedgerDeposit, deposit, 0, "initial deposit")
+	a.equity = a.balance
+	a.peak = a.balance
+	return a
+}
+
+// Balance returns the realized account balance
+func (a *Account) Balance() float64 {
+	return a.balance
+}
+
+// Equity returns the balance plus floating profit at the last mark
+func (a *Account) Equity() float64 {
+	return a.equity
+}
+
+// Margin returns the margin held by open positions
+func (a *Account) Margin() float64 {
+	return a.margin
+}
+
+// FreeMargin returns the equity available to open new positions
+func (a *Account) FreeMargin() float64 {
+	return a.equity - a.margin
+}
+
+// MarginLevel returns equity as a percentage of used margin, or zero with
+// no open positions
+func (a *Account) MarginLevel() float64 {
+	if a.margin == 0 {
+		return 0
+	}
+	return a.equity / a.margin * 100
+}
+
+func (a *Account) post(at time.Time, kind string, amount float64, tradeID int64, note string) {
+	a.balance += amount
+	a.ledger = append(a.ledger, LedgerEntry{
+		Time:    at,
+		Type:    kind,
+		Amount:  amount,
+		Balance: a.balance,
+		TradeID: tradeID,
+		Note:    note,
+	})
+}
+
+// mark updates equity and the drawdown statistics
+func (a *Account) mark(equity float64) {
+	a.equity = equity
+	if equity > a.peak {
+		a.peak = equity
+	}
+	if dd := a.peak - equity; dd > a.maxDD {
+		a.maxDD = dd
+	}
+	if a.peak > 0 {
+		if pct := (a.peak - equity) / a.peak; pct > a.maxDDPct {
+			a.maxDDPct = pct
+		}
+	}
+}
+
+// sample appends to the equity curve. When the curve grows past its bound,
+// neighbouring points are merged keeping the lower equity, so drawdowns stay
+// visible at any resolution.
+func (a *Account) sample(at time.Time) {
+	p := EquityPoint{Time: at, Balance: a.balance, Equity: a.equity}
+	a.closeDay(p)
+	if a.pendingLow == nil || p.Equity < a.pendingLow.Equity {
+		a.pendingLow = &p
+	}
+	a.pendingSeen++
+	if a.pendingSeen < a.stride {
+		return
+	}
+	a.curve = append(a.curve, *a.pendingLow)
+	a.pendingLow, a.pendingSeen = nil, 0
+
+	if len(a.curve) >= 2*maxEquityPoints {
+		merged := a.curve[:0]
+		for i := 0; i+1 < len(a.curve); i += 2 {
+			low := a.curve[i]
+			if a.curve[i+1].Equity < low.Equity {
+				low = a.curve[i+1]
+			}
+			merged = append(merged, low)
+		}
+		a.curve = merged
+		a.stride *= 2
+	}
+}
+
+// finalPoint records the closing state even if it fell between samples
+func (a *Account) finalPoint(at time.Time) {
+	p := EquityPoint{Time: at, Balance: a.balance, Equity: a.equity}
+	a.curve = append(a.curve, p)
+	a.pendingLow, a.pendingSeen = nil, 0
+	a.closeDay(p)
+}
+
+// closeDay keeps p as the latest state of its trading day
+func (a *Account) closeDay(p EquityPoint) {
+	a.daily = appendDay(a.daily, a.session, p)
+}
+
+// appendDay adds p to a daily series, replacing the point of its trading
+// day if there is one
+func appendDay(daily []EquityPoint, session resample.Session, p EquityPoint) []EquityPoint {
+	p.Time = session.TradingDay(p.Time)
+	if n := len(daily); n > 0 && daily[n-1].Time.Equal(p.Time) {
+		daily[n-1] = p
+		return daily
+	}
+	return append(daily, p)
+}
@@ -0,0 +1,594 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// quote is a two-sided price at a point in time
+type quote struct {
+	time time.Time
+	bid  float64
+	ask  float64
+}
+
+// Trigger kinds
+const (
+	triggerFill = iota
+	triggerStopLoss
+	triggerTakeProfit
+)
+
+// trigger is a price level at which something happens, expressed on the
+// bid so that ask-side levels can be compared along a bid price path
+type trigger struct {
+	kind  int
+	order *Order
+	pos   *Position
+	level float64 // Bid price at which the trigger fires
+	up    bool    // Fires when the bid rises to level, rather than falls
+	price float64 // Execution price when reached by continuous movement
+}
+
+// broker simulates order handling and position keeping for one symbol
+type broker struct {
+	cfg       *Config
+	book      *book
+	account   *Account
+	queued    []*Order // Market orders waiting for the next price
+	closing   []int64  // Positions to close at the next price
+	pending   []*Order
+	positions []*Position
+	trades    []Trade
+	events    []Event
+	last      quote
+	spread    float64 // Spread of the price being processed
+
+	costs    *costModel
+	totals   CostTotals
+	rollover time.Time // Next session close at which swaps are charged
+	err      error     // First conversion failure, reported by the engine
+	stopOuts int       // Positions of this symbol liquidated
+}
+
+func newBroker(cfg *Config, bk *book) *broker {
+	b := &broker{cfg: cfg, book: bk, account: bk.account, costs: newCostModel(cfg.Costs, cfg.Instrument.PipSize)}
+	bk.brokers = append(bk.brokers, b)
+	return b
+}
+
+func (b *broker) log(at time.Time, kind string, orderID, positionID int64, price float64, message string) {
+	b.events = append(b.events, Event{
+		Time:     at,
+		Kind:     kind,
+		OrderID:  orderID,
+		Position: positionID,
+		Price:    price,
+		Message:  message,
+	})
+}
+
+// submit validates and accepts an order. Market orders fill at the next
+// price the broker sees; pending orders wait for their trigger.
+func (b *broker) submit(o Order, now time.Time) (*Order, error) {
+	if o.Symbol == "" {
+		o.Symbol = b.cfg.Symbol
+	}
+	if o.Side != Buy && o.Side != Sell {
+		return nil, errors.New("order side must be buy or sell")
+	}
+	switch o.Type {
+	case "":
+		o.Type = Market
+	case Market:
+	case Limit, Stop:
+		if o.Price <= 0 {
+			return nil, fmt.Errorf("%s orders need a price", o.Type)
+		}
+	default:
+		return nil, fmt.Errorf("unknown order type %q", o.Type)
+	}
+	if o.StopLoss < 0 || o.TakeProfit < 0 {
+		return nil, errors.New("stop loss and take profit must be positive prices")
+	}
+
+	if o.Volume == 0 {
+		volume, err := b.size(o)
+		if err != nil {
+			return nil, err
+		}
+		o.Volume = volume
+	}
+	if o.Volume <= 0 || math.IsNaN(o.Volume) {
+		return nil, errors.New("order volume must be positive")
+	}
+	if err := b.checkVolume(o.Volume); err != nil {
+		return nil, err
+	}
+
+	o.ID = b.book.id()
+	o.Created = now
+	order := &o
+	if o.Type == Market {
+		b.queued = append(b.queued, order)
+	} else {
+		b.pending = append(b.pending, order)
+	}
+	b.log(now, EventOrderPlaced, o.ID, 0, o.Price, fmt.Sprintf("%s %s %.2f lots", o.Side, o.Type, o.Volume))
+	return order, nil
+}
+
+// size applies the configured sizing rule to an order without a volume
+func (b *broker) size(o Order) (float64, error) {
+	sizing := b.cfg.Sizing
+	switch sizing.Mode {
+	case SizingFixed:
+		return sizing.Lots, nil
+	case SizingRisk:
+		if o.StopLoss == 0 {
+			return 0, errors.New("risk-based sizing needs a stop loss")
+		}
+		entry := o.Price
+		if o.Type == Market {
+			entry = b.last.ask
+			if o.Side == Sell {
+				entry = b.last.bid
+			}
+		}
+		distance := math.Abs(entry - o.StopLoss)
+		if distance == 0 || entry == 0 {
+			return 0, errors.New("stop loss is at the entry price")
+		}
+		inst := b.cfg.Instrument
+		rate, err := b.rate(inst.Quote, b.cfg.Currency)
+		if err != nil {
+			return 0, err
+		}
+		risk := b.account.Equity() * sizing.RiskPercent / 100
+		lots := risk / (distance * inst.ContractSize * rate)
+		lots = math.Floor(lots/inst.LotStep+1e-9) * inst.LotStep
+		if lots < inst.MinLot {
+			return 0, fmt.Errorf("risk of %.2f%% is below the minimum lot size", sizing.RiskPercent)
+		}
+		if inst.MaxLot > 0 && lots > inst.MaxLot {
+			lots = inst.MaxLot
+		}
+		return round(lots, 8), nil
+	default:
+		return 0, fmt.Errorf("unknown sizing mode %q", sizing.Mode)
+	}
+}
+
+// checkVolume enforces the instrument's lot limits
+func (b *broker) checkVolume(volume float64) error {
+	inst := b.cfg.Instrument
+	if volume < inst.MinLot {
+		return fmt.Errorf("volume %g is below the minimum lot of %g", volume, inst.MinLot)
+	}
+	if inst.MaxLot > 0 && volume > inst.MaxLot {
+		return fmt.Errorf("volume %g is above the maximum lot of %g", volume, inst.MaxLot)
+	}
+	if steps := volume / inst.LotStep; math.Abs(steps-math.Round(steps)) > 1e-6 {
+		return fmt.Errorf("volume %g is not a multiple of the lot step %g", volume, inst.LotStep)
+	}
+	return nil
+}
+
+// rate returns the price of one unit of from in to at the current time. The
+// traded pair's own mid price is used where it applies.
+func (b *broker) rate(from, to string) (float64, error) {
+	inst := b.cfg.Instrument
+	mid := (b.last.bid + b.last.ask) / 2
+	switch {
+	case from == to:
+		return 1, nil
+	case from == inst.Quote && to == inst.Base && mid > 0:
+		return 1 / mid, nil
+	case from == inst.Base && mid > 0:
+		quote, err := b.rate(inst.Quote, to)
+		return mid * quote, err
+	case b.cfg.Converter == nil:
+		return 0, fmt.Errorf("no exchange rate from %s to %s", from, to)
+	}
+	return b.cfg.Converter.Rate(from, to, b.last.time)
+}
+
+// toAccount converts an amount in the quote currency to the account
+// currency. A failed conversion is kept for the engine to report and the
+// amount counts as zero.
+func (b *broker) toAccount(amount float64) float64 {
+	if amount == 0 {
+		return 0
+	}
+	rate, err := b.rate(b.cfg.Instrument.Quote, b.cfg.Currency)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return 0
+	}
+	return amount * rate
+}
+
+// cancel removes a pending or queued order
+func (b *broker) cancel(id int64, now time.Time) error {
+	for _, list := range []*[]*Order{&b.pending, &b.queued} {
+		for i, o := range *list {
+			if o.ID == id {
+				*list = append((*list)[:i], (*list)[i+1:]...)
+				b.log(now, EventOrderCancelled, id, 0, 0, "")
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("order %d not found", id)
+}
+
+// requestClose schedules a position to close at the next price
+func (b *broker) requestClose(id int64) error {
+	if b.position(id) == nil {
+		return fmt.Errorf("position %d not found", id)
+	}
+	for _, pending := range b.closing {
+		if pending == id {
+			return nil
+		}
+	}
+	b.closing = append(b.closing, id)
+	return nil
+}
+
+// modify changes a position's protective levels
+func (b *broker) modify(id int64, stopLoss, takeProfit float64, now time.Time) error {
+	p := b.position(id)
+	if p == nil {
+		return fmt.Errorf("position %d not found", id)
+	}
+	if stopLoss < 0 || takeProfit < 0 {
+		return errors.New("stop loss and take profit must be positive prices")
+	}
+	p.StopLoss, p.TakeProfit = stopLoss, takeProfit
+	b.log(now, EventPositionEdited, 0, id, 0, fmt.Sprintf("sl %g tp %g", stopLoss, takeProfit))
+	return nil
+}
+
+func (b *broker) position(id int64) *Position {
+	for _, p := range b.positions {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// triggers lists every level that could fire, given the current spread
+func (b *broker) triggers(spread float64) []trigger {
+	var ts []trigger
+	for _, o := range b.pending {
+		t := trigger{kind: triggerFill, order: o, price: o.Price}
+		switch {
+		case o.Side == Buy && o.Type == Limit:
+			t.level, t.up = o.Price-spread, false
+		case o.Side == Buy && o.Type == Stop:
+			t.level, t.up = o.Price-spread, true
+		case o.Side == Sell && o.Type == Limit:
+			t.level, t.up = o.Price, true
+		case o.Side == Sell && o.Type == Stop:
+			t.level, t.up = o.Price, false
+		}
+		ts = append(ts, t)
+	}
+	for _, p := range b.positions {
+		// Longs exit on the bid, shorts on the ask
+		offset := 0.0
+		if p.Side == Sell {
+			offset = spread
+		}
+		if p.StopLoss > 0 {
+			ts = append(ts, trigger{kind: triggerStopLoss, pos: p, level: p.StopLoss - offset, up: p.Side == Sell, price: p.StopLoss})
+		}
+		if p.TakeProfit > 0 {
+			ts = append(ts, trigger{kind: triggerTakeProfit, pos: p, level: p.TakeProfit - offset, up: p.Side == Buy, price: p.TakeProfit})
+		}
+	}
+	return ts
+}
+
+// at processes a discrete price: queued orders and closes fill at it, and
+// any level it jumped past fires at it rather than at the level
+func (b *broker) at(q quote) {
+	b.rollOver(q.time)
+	b.last = q
+	b.spread = q.ask - q.bid
+	b.expire(q.time)
+
+	queued := b.queued
+	b.queued = nil
+	for _, o := range queued {
+		price := q.ask
+		if o.Side == Sell {
+			price = q.bid
+		}
+		b.fill(o, price, q.time, true)
+	}
+
+	closing := b.closing
+	b.closing = nil
+	for _, id := range closing {
+		if p := b.position(id); p != nil {
+			b.close(p, b.exitPrice(p, q), q.time, ExitSignal, true)
+		}
+	}
+
+	for {
+		fired := false
+		for _, t := range b.triggers(q.ask - q.bid) {
+			if (t.up && q.bid >= t.level) || (!t.up && q.bid <= t.level) {
+				b.execute(t, b.gapPrice(t, q), q.time)
+				fired = true
+				break
+			}
+		}
+		if !fired {
+			break
+		}
+	}
+	b.markTo(q)
+}
+
+// walk moves the bid continuously from the last price to bid, firing
+// levels in the order they are reached at exactly their price
+func (b *broker) walk(bid, spread float64, at time.Time) {
+	b.spread = spread
+	current := b.last.bid
+	up := bid >= current
+	for {
+		var next *trigger
+		for _, t := range b.triggers(spread) {
+			reached := (up && t.up && t.level >= current && t.level <= bid) ||
+				(!up && !t.up && t.level <= current && t.level >= bid)
+			if !reached {
+				continue
+			}
+			if next == nil || math.Abs(t.level-current) < math.Abs(next.level-current) {
+				t := t
+				next = &t
+			}
+		}
+		if next == nil {
+			break
+		}
+		b.execute(*next, next.price, at)
+		current = next.level
+	}
+	b.markTo(quote{time: at, bid: bid, ask: bid + spread})
+}
+
+func (b *broker) gapPrice(t trigger, q quote) float64 {
+	if t.kind == triggerFill {
+		if t.order.Side == Buy {
+			return q.ask
+		}
+		return q.bid
+	}
+	return b.exitPrice(t.pos, q)
+}
+
+func (b *broker) exitPrice(p *Position, q quote) float64 {
+	if p.Side == Buy {
+		return q.bid
+	}
+	return q.ask
+}
+
+func (b *broker) execute(t trigger, price float64, at time.Time) {
+	switch t.kind {
+	case triggerFill:
+		for i, o := range b.pending {
+			if o == t.order {
+				b.pending = append(b.pending[:i], b.pending[i+1:]...)
+				break
+			}
+		}
+		b.fill(t.order, price, at, t.order.Type == Stop)
+	case triggerStopLoss:
+		b.close(t.pos, price, at, ExitStopLoss, true)
+	case triggerTakeProfit:
+		b.close(t.pos, price, at, ExitTakeProfit, false)
+	}
+}
+
+func (b *broker) expire(now time.Time) {
+	kept := b.pending[:0]
+	for _, o := range b.pending {
+		if !o.Expires.IsZero() && !now.Before(o.Expires) {
+			b.log(now, EventOrderExpired, o.ID, 0, o.Price, "")
+			continue
+		}
+		kept = append(kept, o)
+	}
+	b.pending = kept
+}
+
+// fill opens a position from an order, slipping the price against it when
+// the order type allows. Orders the free margin cannot cover are rejected.
+func (b *broker) fill(o *Order, price float64, at time.Time, slip bool) {
+	margin, err := b.requiredMargin(o.Volume)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return
+	}
+	if free := b.account.FreeMargin(); margin > free {
+		b.log(at, EventOrderRejected, o.ID, 0, price,
+			fmt.Sprintf("not enough margin: %.2f needed, %.2f free", margin, free))
+		return
+	}
+	if err := b.book.allow(b, o); err != nil {
+		b.log(at, EventOrderRejected, o.ID, 0, price, err.Error())
+		return
+	}
+
+	var slipped float64
+	if slip {
+		slipped = b.costs.slippage(o.Volume)
+		price += slipped * float64(o.Side)
+	}
+
+	p := &Position{
+		ID:         b.book.id(),
+		Symbol:     o.Symbol,
+		Side:       o.Side,
+		Volume:     o.Volume,
+		EntryPrice: price,
+		EntryTime:  at,
+		StopLoss:   o.StopLoss,
+		TakeProfit: o.TakeProfit,
+		Slippage:   round(slipped/b.cfg.Instrument.PipSize, 1),
+		Margin:     round(margin, 2),
+		Comment:    o.Comment,
+	}
+	b.positions = append(b.positions, p)
+	b.account.margin += p.Margin
+	b.log(at, EventOrderFilled, o.ID, p.ID, price, "")
+
+	units := o.Volume * b.cfg.Instrument.ContractSize
+	b.totals.Spread += b.toAccount(b.spread * units)
+	b.totals.Slippage += b.toAccount(slipped * units)
+	p.Commission = b.charge(p, at, "open")
+}
+
+// charge posts the commission for one side of a position
+func (b *broker) charge(p *Position, at time.Time, note string) float64 {
+	fee := round(b.costs.commission(p.Volume), 2)
+	if fee == 0 {
+		return 0
+	}
+	b.account.post(at, LedgerCommission, -fee, p.ID, note)
+	b.totals.Commission += fee
+	return fee
+}
+
+// close realizes a position's profit and swap into the account
+func (b *broker) close(p *Position, price float64, at time.Time, reason string, slip bool) {
+	var slipped float64
+	if slip {
+		slipped = b.costs.slippage(p.Volume)
+		price -= slipped * float64(p.Side)
+		b.totals.Slippage += b.toAccount(slipped * p.Volume * b.cfg.Instrument.ContractSize)
+	}
+
+	b.excursion(p, price)
+	for i, open := range b.positions {
+		if open == p {
+			b.positions = append(b.positions[:i], b.positions[i+1:]...)
+			break
+		}
+	}
+	b.account.margin -= p.Margin
+
+	profit := round(b.profit(p, price), 2)
+	swap := round(p.Swap, 2)
+	b.account.post(at, LedgerTrade, profit, p.ID, reason)
+	if swap != 0 {
+		b.account.post(at, LedgerSwap, swap, p.ID, "")
+	}
+	commission := p.Commission + b.charge(p, at, "close")
+
+	trade := Trade{
+		ID:         p.ID,
+		Symbol:     p.Symbol,
+		Side:       p.Side,
+		Volume:     p.Volume,
+		EntryPrice: p.EntryPrice,
+		EntryTime:  p.EntryTime,
+		ExitPrice:  price,
+		ExitTime:   at,
+		StopLoss:   p.StopLoss,
+		TakeProfit: p.TakeProfit,
+		Pips:       round((price-p.EntryPrice)*float64(p.Side)/b.cfg.Instrument.PipSize, 1),
+		Profit:     round(profit-commission+swap, 2),
+		Commission: round(commission, 2),
+		Swap:       swap,
+		Slippage:   round(p.Slippage+slipped/b.cfg.Instrument.PipSize, 1),
+		MAE:        round(p.MAE, 1),
+		MFE:        round(p.MFE, 1),
+		Reason:     reason,
+		Comment:    p.Comment,
+	}
+	b.trades = append(b.trades, trade)
+	b.log(at, EventPositionClosed, 0, p.ID, price, reason)
+}
+
+// excursion widens a position's MAE and MFE to include a move to price
+func (b *broker) excursion(p *Position, price float64) {
+	move := (price - p.EntryPrice) * float64(p.Side) / b.cfg.Instrument.PipSize
+	p.MAE = max(p.MAE, -move)
+	p.MFE = max(p.MFE, move)
+}
+
+// profit is the position's result in the account currency if closed at
+// price, converted at the current rate
+func (b *broker) profit(p *Position, price float64) float64 {
+	return b.toAccount((price - p.EntryPrice) * float64(p.Side) * p.Volume * b.cfg.Instrument.ContractSize)
+}
+
+// markTo revalues open positions at a new price and enforces the margin
+// levels
+func (b *broker) markTo(q quote) {
+	b.last = q
+	for _, p := range b.positions {
+		b.excursion(p, b.exitPrice(p, q))
+	}
+	b.revalue()
+	b.checkMargin(q.time)
+}
+
+// revalue updates account equity and used margin from the open positions
+// of every broker trading from the account, each at its own last price
+func (b *broker) revalue() {
+	equity, margin := b.account.Balance(), 0.0
+	for _, owner := range b.book.brokers {
+		for _, p := range owner.positions {
+			equity += owner.profit(p, owner.exitPrice(p, owner.last)) + p.Swap
+			margin += p.Margin
+		}
+	}
+	b.account.margin = margin
+	b.account.mark(equity)
+}
+
+// closeAll closes every open position at the last price
+func (b *broker) closeAll(reason string) {
+	for len(b.positions) > 0 {
+		p := b.positions[0]
+		b.close(p, b.exitPrice(p, b.last), b.last.time, reason, false)
+	}
+	b.markTo(b.last)
+}
+
+// rollOver accrues swap on open positions for every session close between
+// the last price and now
+func (b *broker) rollOver(now time.Time) {
+	session := b.cfg.Session
+	if b.rollover.IsZero() {
+		_, b.rollover = session.Bounds(resample.D1, now)
+		return
+	}
+	for !now.Before(b.rollover) {
+		day := session.TradingDay(b.rollover.Add(-time.Nanosecond)).Weekday()
+		for _, p := range b.positions {
+			rate, nights := b.costs.swapPips(p.Side, day)
+			inst := b.cfg.Instrument
+			swap := b.toAccount(rate * float64(nights) * inst.PipSize * p.Volume * inst.ContractSize)
+			p.Swap += swap
+			b.totals.Swap += swap
+		}
+		_, b.rollover = session.Bounds(resample.D1, b.rollover)
+	}
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Spread modes
+const (
+	SpreadVariable = "variable" // The spread recorded in the data, per tick or averaged per bar
+	SpreadFixed    = "fixed"    // A constant spread in place of the data's
+)
+
+// Slippage modes
+const (
+	SlippageNone   = "none"
+	SlippageFixed  = "fixed"  // Pips on every slipped fill
+	SlippageRandom = "random" // Uniform between zero and Pips
+	SlippageVolume = "volume" // Pips plus PipsPerLot for each lot traded
+)
+
+// SpreadModel sets the distance between bid and ask
+type SpreadModel struct {
+	Mode    string  `json:"mode"`
+	Pips    float64 `json:"pips,omitempty"`    // Fixed mode
+	MinPips float64 `json:"minPips,omitempty"` // Variable mode floor, for data without spreads
+}
+
+// SlippageModel moves fills against the trader. It applies to market and
+// stop orders and to stop-loss and signal exits; limit orders and take
+// profits fill at their price.
+type SlippageModel struct {
+	Mode       string  `json:"mode"`
+	Pips       float64 `json:"pips,omitempty"`
+	PipsPerLot float64 `json:"pipsPerLot,omitempty"`
+	Seed       uint64  `json:"seed,omitempty"` // Random mode; runs with the same seed slip alike
+}
+
+// CommissionModel charges a fee per lot on every fill, opening and closing
+type CommissionModel struct {
+	PerLot float64 `json:"perLot,omitempty"` // Account currency per lot per side
+}
+
+// SwapModel charges or credits positions held over the daily rollover, at
+// the session close. One weekday carries three nights to cover the weekend.
+type SwapModel struct {
+	Long      float64 `json:"long,omitempty"`  // Pips per lot per night; negative is a charge
+	Short     float64 `json:"short,omitempty"` // Pips per lot per night
+	TripleDay string  `json:"tripleDay,omitempty"`
+}
+
+// Costs are the execution costs applied by the simulated broker
+type Costs struct {
+	Spread     SpreadModel     `json:"spread"`
+	Slippage   SlippageModel   `json:"slippage"`
+	Commission CommissionModel `json:"commission"`
+	Swap       SwapModel       `json:"swap"`
+}
+
+// CostTotals adds up what execution costs took from a run, in account
+// currency. Swap is signed; the others are amounts paid.
+type CostTotals struct {
+	Spread     float64 `json:"spread"`
+	Slippage   float64 `json:"slippage"`
+	Commission float64 `json:"commission"`
+	Swap       float64 `json:"swap"`
+}
+
+func (c CostTotals) rounded() CostTotals {
+	return CostTotals{
+		Spread:     round(c.Spread, 2),
+		Slippage:   round(c.Slippage, 2),
+		Commission: round(c.Commission, 2),
+		Swap:       round(c.Swap, 2),
+	}
+}
+
+func (c *Costs) normalize() error {
+	switch c.Spread.Mode {
+	case "":
+		c.Spread.Mode = SpreadVariable
+	case SpreadVariable:
+	case SpreadFixed:
+		if c.Spread.Pips <= 0 {
+			return errors.New("fixed spread needs pips")
+		}
+	default:
+		return fmt.Errorf("unknown spread mode %q", c.Spread.Mode)
+	}
+	if c.Spread.Pips < 0 || c.Spread.MinPips < 0 {
+		return errors.New("spread pips must not be negative")
+	}
+
+	switch c.Slippage.Mode {
+	case "":
+		c.Slippage.Mode = SlippageNone
+	case SlippageNone, SlippageFixed, SlippageRandom, SlippageVolume:
+	default:
+		return fmt.Errorf("unknown slippage mode %q", c.Slippage.Mode)
+	}
+	if c.Slippage.Pips < 0 || c.Slippage.PipsPerLot < 0 {
+		return errors.New("slippage pips must not be negative")
+	}
+
+	if c.Commission.PerLot < 0 {
+		return errors.New("commission must not be negative")
+	}
+
+	if c.Swap.TripleDay == "" {
+		c.Swap.TripleDay = "wednesday"
+	}
+	if _, err := parseWeekday(c.Swap.TripleDay); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(name, d.String()) || strings.EqualFold(name, d.String()[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
+
+// costModel applies a normalized Costs to prices and fills
+type costModel struct {
+	costs  Costs
+	pip    float64
+	triple time.Weekday
+	rng    *rand.Rand
+}
+
+func newCostModel(costs Costs, pip float64) *costModel {
+	triple, _ := parseWeekday(costs.Swap.TripleDay)
+	seed := costs.Slippage.Seed
+	return &costModel{
+		costs:  costs,
+		pip:    pip,
+		triple: triple,
+		rng:    rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+// spread returns the spread to trade at, given the one in the data
+func (m *costModel) spread(data float64) float64 {
+	s := m.costs.Spread
+	if s.Mode == SpreadFixed {
+		return s.Pips * m.pip
+	}
+	if floor := s.MinPips * m.pip; data < floor {
+		return floor
+	}
+	return data
+}
+
+// slippage returns the adverse price move for a fill of volume lots
+func (m *costModel) slippage(volume float64) float64 {
+	s := m.costs.Slippage
+	switch s.Mode {
+	case SlippageFixed:
+		return s.Pips * m.pip
+	case SlippageRandom:
+		return m.rng.Float64() * s.Pips * m.pip
+	case SlippageVolume:
+		return (s.Pips + s.PipsPerLot*volume) * m.pip
+	}
+	return 0
+}
+
+// commission returns the fee for one side of volume lots
+func (m *costModel) commission(volume float64) float64 {
+	return m.costs.Commission.PerLot * volume
+}
+
+// swapPips returns the swap rate for a side and the nights charged at a
+// rollover that ends the given trading day. Weekend rollovers are covered
+// by the triple day.
+func (m *costModel) swapPips(side Side, day time.Weekday) (float64, int) {
+	rate := m.costs.Swap.Long
+	if side == Sell {
+		rate = m.costs.Swap.Short
+	}
+	switch {
+	case day == time.Saturday || day == time.Sunday:
+		return rate, 0
+	case day == m.triple:
+		return rate, 3
+	}
+	return rate, 1
+}
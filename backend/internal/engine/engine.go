@@ -0,0 +1,687 @@
+// Package engine is an event-driven backtester. Prices are pushed in one bar
+// or tick at a time; a simulated broker fills orders against them and a
+// Strategy reacts to each completed bar.
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/fx"
+	"github.com/PervFVCK/strategyforge/internal/instruments"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// Mode selects the granularity of the event loop
+type Mode string
+
+const (
+	// ModeBar replays bars, walking each one open, high/low, close
+	ModeBar Mode = "bar"
+	// ModeTick replays every tick and builds bars for the strategy from them
+	ModeTick Mode = "tick"
+)
+
+// Sizing modes
+const (
+	SizingFixed = "fixed" // Lots per order
+	SizingRisk  = "risk"  // Percent of equity lost if the stop loss is hit
+)
+
+// Sizing decides the volume of orders submitted without one
+type Sizing struct {
+	Mode        string  `json:"mode"`
+	Lots        float64 `json:"lots,omitempty"`
+	RiskPercent float64 `json:"riskPercent,omitempty"`
+}
+
+// Config describes a backtest run
+type Config struct {
+	Symbol         string                 `json:"symbol"`
+	Timeframe      resample.Timeframe     `json:"timeframe"`
+	Timeframes     []resample.Timeframe   `json:"timeframes,omitempty"` // Higher timeframes subscribed to, shortest first
+	Mode           Mode                   `json:"mode"`
+	InitialBalance float64                `json:"initialBalance"`
+	Currency       string                 `json:"currency"`   // Account currency
+	Instrument     instruments.Instrument `json:"instrument"` // Looked up from Symbol when empty
+	Converter      Converter              `json:"-"`          // Rates the instrument's own prices cannot give
+	Sizing         Sizing                 `json:"sizing"`
+	Costs          Costs                  `json:"costs"`
+	Margin         MarginConfig           `json:"margin"`
+	HistorySize    int                    `json:"-"` // Bars kept for Context.History
+	Session        resample.Session       `json:"-"` // Bar boundaries in tick mode, and the swap rollover
+}
+
+// Defaults applied by New
+const (
+	DefaultInitialBalance = 10000
+	DefaultHistorySize    = 500
+)
+
+// DefaultCurrency is the account currency when none is configured
+const DefaultCurrency = "USD"
+
+// AccountCurrency checks that an account currency is supported, returning
+// it upper-cased, or DefaultCurrency when empty
+func AccountCurrency(code string) (string, error) {
+	if code == "" {
+		return DefaultCurrency, nil
+	}
+	code = strings.ToUpper(code)
+	if !instruments.IsCurrency(code) {
+		return "", fmt.Errorf("unsupported account currency %q", code)
+	}
+	return code, nil
+}
+
+// Converter supplies exchange rates between currencies
+type Converter interface {
+	// Rate returns the price of one unit of from in to, as known at time at
+	Rate(from, to string, at time.Time) (float64, error)
+}
+
+func (c *Config) normalize() error {
+	if c.Symbol == "" {
+		return errors.New("symbol is required")
+	}
+	switch c.Mode {
+	case "":
+		c.Mode = ModeBar
+	case ModeBar, ModeTick:
+	default:
+		return fmt.Errorf("unknown mode %q", c.Mode)
+	}
+	if c.Mode == ModeTick && !c.Timeframe.Valid() {
+		return errors.New("tick mode needs a timeframe for strategy bars")
+	}
+	if c.InitialBalance == 0 {
+		c.InitialBalance = DefaultInitialBalance
+	}
+	if c.InitialBalance < 0 {
+		return errors.New("initial balance must be positive")
+	}
+	currency, err := AccountCurrency(c.Currency)
+	if err != nil {
+		return err
+	}
+	c.Currency = currency
+	if c.Instrument.Symbol == "" {
+		inst, err := instruments.Lookup(c.Symbol)
+		if err != nil {
+			return err
+		}
+		c.Instrument = inst
+	}
+	inst := c.Instrument
+	if inst.PipSize <= 0 || inst.ContractSize <= 0 || inst.MinLot <= 0 || inst.LotStep <= 0 || inst.Quote == "" {
+		return fmt.Errorf("instrument %s is incomplete", inst.Symbol)
+	}
+	if fx.Need(c.Currency, inst) != "" && c.Converter == nil {
+		return fmt.Errorf("converting %s profits to %s needs exchange rates", inst.Quote, c.Currency)
+	}
+	switch c.Sizing.Mode {
+	case "":
+		c.Sizing.Mode = SizingFixed
+		fallthrough
+	case SizingFixed:
+		if c.Sizing.Lots == 0 {
+			c.Sizing.Lots = c.Instrument.MinLot * 10
+		}
+		if c.Sizing.Lots < 0 {
+			return errors.New("sizing lots must be positive")
+		}
+	case SizingRisk:
+		if c.Sizing.RiskPercent <= 0 || c.Sizing.RiskPercent > 100 {
+			return errors.New("sizing riskPercent must be between 0 and 100")
+		}
+	default:
+		return fmt.Errorf("unknown sizing mode %q", c.Sizing.Mode)
+	}
+	if err := c.Costs.normalize(); err != nil {
+		return err
+	}
+	if err := c.Margin.normalize(); err != nil {
+		return err
+	}
+	if c.HistorySize <= 0 {
+		c.HistorySize = DefaultHistorySize
+	}
+	if c.Session.Zone == nil {
+		c.Session = resample.NewYorkClose()
+	}
+	return nil
+}
+
+// Strategy reacts to completed bars by placing orders through the Context
+type Strategy interface {
+	// Init runs once before any data
+	Init(ctx *Context) error
+	// OnBar runs after each bar closes. Market orders placed here fill at the
+	// next bar's open, so a strategy cannot trade on a price it has not seen.
+	OnBar(ctx *Context, bar marketdata.Bar) error
+}
+
+// TickStrategy is implemented by strategies that also want every tick in
+// tick mode
+type TickStrategy interface {
+	OnTick(ctx *Context, tick marketdata.Tick) error
+}
+
+// TimeframeStrategy is implemented by strategies that subscribe to higher
+// timeframes and want each of their bars
+type TimeframeStrategy interface {
+	// OnTimeframeBar runs when a bar of a subscribed timeframe closes,
+	// before OnBar for the strategy bar that closes with it
+	OnTimeframeBar(ctx *Context, tf resample.Timeframe, bar marketdata.Bar) error
+}
+
+// Engine runs a strategy over a price stream
+type Engine struct {
+	cfg       Config
+	strategy  Strategy
+	ctx       *Context
+	book      *book
+	account   *Account
+	broker    *broker
+	resampler *resample.Resampler
+	history   []marketdata.Bar
+	higher    []*higher // Subscribed timeframes, shortest first
+	bars      int
+	ticks     int
+	start     time.Time
+	finished  bool
+}
+
+// New validates the configuration and initializes the strategy
+func New(cfg Config, strategy Strategy) (*Engine, error) {
+	if err := cfg.normalize(); err != nil {
+		return nil, err
+	}
+
+	e := &Engine{cfg: cfg, strategy: strategy, book: &book{}}
+	e.ctx = &Context{engine: e}
+	if cfg.Mode == ModeTick {
+		r, err := resample.New(resample.Options{
+			Timeframe: cfg.Timeframe,
+			Session:   cfg.Session,
+		}, e.closeBar)
+		if err != nil {
+			return nil, err
+		}
+		e.resampler = r
+	}
+	subscribed := cfg.Timeframes
+	e.cfg.Timeframes = nil
+	for _, tf := range subscribed {
+		if err := e.subscribe(tf); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := strategy.Init(e.ctx); err != nil {
+		return nil, fmt.Errorf("strategy init: %w", err)
+	}
+	return e, nil
+}
+
+// Config returns the normalized configuration
+func (e *Engine) Config() Config {
+	return e.cfg
+}
+
+// begin opens the account at the time of the first price, unless a
+// portfolio sharing it already has
+func (e *Engine) begin(at time.Time) {
+	if e.broker != nil {
+		return
+	}
+	e.start = at
+	if e.book.account == nil {
+		e.book.account = newAccount(e.cfg.Currency, e.cfg.InitialBalance, at, e.cfg.Session)
+	}
+	e.account = e.book.account
+	e.broker = newBroker(&e.cfg, e.book)
+}
+
+// OnBar replays one bar in bar mode. The broker sees the open, then the
+// extreme nearer the open, the other extreme and the close, so stops and
+// targets inside the bar fire in a plausible order.
+func (e *Engine) OnBar(bar marketdata.Bar) error {
+	if e.cfg.Mode != ModeBar {
+		return errors.New("engine is in tick mode")
+	}
+	if e.finished {
+		return errors.New("backtest already finished")
+	}
+	e.begin(bar.Time)
+
+	spread := e.broker.costs.spread(bar.Spread)
+	e.broker.at(quote{time: bar.Time, bid: bar.Open, ask: bar.Open + spread})
+	first, second := bar.High, bar.Low
+	if bar.Close >= bar.Open {
+		first, second = bar.Low, bar.High
+	}
+	for _, price := range []float64{first, second, bar.Close} {
+		e.broker.walk(price, spread, bar.Time)
+	}
+	if e.broker.err != nil {
+		return e.broker.err
+	}
+	return e.closeBar(bar)
+}
+
+// WarmUp hands the strategy a bar from before the test, so its indicators
+// are ready when trading starts. Nothing can be traded on a warm-up bar, and
+// none is counted in the result; every one must come before the first bar.
+func (e *Engine) WarmUp(bar marketdata.Bar) error {
+	if e.cfg.Mode != ModeBar {
+		return errors.New("engine is in tick mode")
+	}
+	if e.broker != nil {
+		return errors.New("warm-up bars must come before the first bar")
+	}
+	return e.dispatch(bar)
+}
+
+// OnTick replays one tick in tick mode. A tick that opens a new bar first
+// closes the previous one, so orders placed on that bar fill at this tick.
+func (e *Engine) OnTick(tick marketdata.Tick) error {
+	if e.cfg.Mode != ModeTick {
+		return errors.New("engine is in bar mode")
+	}
+	if e.finished {
+		return errors.New("backtest already finished")
+	}
+	e.begin(tick.Time)
+	e.ticks++
+
+	if err := e.resampler.AddTick(tick); err != nil {
+		return err
+	}
+	spread := e.broker.costs.spread(tick.Spread())
+	e.broker.at(quote{time: tick.Time, bid: tick.Bid, ask: tick.Bid + spread})
+	if e.broker.err != nil {
+		return e.broker.err
+	}
+
+	if ts, ok := e.strategy.(TickStrategy); ok {
+		if err := ts.OnTick(e.ctx, tick); err != nil {
+			return fmt.Errorf("strategy: %w", err)
+		}
+	}
+	return nil
+}
+
+// closeBar records a completed bar and hands it to the strategy
+func (e *Engine) closeBar(bar marketdata.Bar) error {
+	e.bars++
+	if !e.book.portfolio {
+		e.account.sample(bar.Time)
+	}
+	return e.dispatch(bar)
+}
+
+// dispatch adds a bar to the history and hands it to the strategy, after
+// the bars of higher timeframes that close with it
+func (e *Engine) dispatch(bar marketdata.Bar) error {
+	e.history = e.keep(e.history, bar)
+
+	if len(e.higher) > 0 {
+		_, end := e.cfg.Session.Bounds(e.cfg.Timeframe, bar.Time)
+		for _, h := range e.higher {
+			if err := h.resampler.AddBar(bar); err != nil {
+				return err
+			}
+			if err := h.resampler.Advance(end); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := e.strategy.OnBar(e.ctx, bar); err != nil {
+		return fmt.Errorf("strategy: %w", err)
+	}
+	return nil
+}
+
+// keep appends a bar to a history, dropping old bars beyond HistorySize
+// now and then
+func (e *Engine) keep(history []marketdata.Bar, bar marketdata.Bar) []marketdata.Bar {
+	history = append(history, bar)
+	if len(history) > 2*e.cfg.HistorySize {
+		history = append(history[:0], history[len(history)-e.cfg.HistorySize:]...)
+	}
+	return history
+}
+
+// higher is a subscribed timeframe, built from the strategy's bars
+type higher struct {
+	tf        resample.Timeframe
+	resampler *resample.Resampler
+	history   []marketdata.Bar
+}
+
+// subscribe builds bars of a higher timeframe alongside the strategy's.
+// Subscribing twice to the same timeframe does nothing.
+func (e *Engine) subscribe(tf resample.Timeframe) error {
+	base := e.cfg.Timeframe
+	switch {
+	case e.account != nil:
+		return errors.New("timeframes must be subscribed to before the first bar")
+	case !tf.Valid():
+		return fmt.Errorf("unknown timeframe %q", tf)
+	case !base.Valid():
+		return fmt.Errorf("subscribing to %s needs the strategy's own timeframe", tf)
+	case tf.Duration() <= base.Duration():
+		return fmt.Errorf("%s is not a higher timeframe than the strategy's %s", tf, base)
+	case base == resample.W1 && tf == resample.MN:
+		return errors.New("weekly bars cannot be built into monthly ones")
+	}
+	for _, h := range e.higher {
+		if h.tf == tf {
+			return nil
+		}
+	}
+
+	h := &higher{tf: tf}
+	r, err := resample.New(resample.Options{Timeframe: tf, Session: e.cfg.Session, Source: base}, func(bar marketdata.Bar) error {
+		h.history = e.keep(h.history, bar)
+		if ts, ok := e.strategy.(TimeframeStrategy); ok {
+			if err := ts.OnTimeframeBar(e.ctx, tf, bar); err != nil {
+				return fmt.Errorf("strategy: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.resampler = r
+
+	i := len(e.higher)
+	for i > 0 && e.higher[i-1].tf.Duration() > tf.Duration() {
+		i--
+	}
+	e.higher = append(e.higher[:i], append([]*higher{h}, e.higher[i:]...)...)
+	e.cfg.Timeframes = make([]resample.Timeframe, len(e.higher))
+	for i, h := range e.higher {
+		e.cfg.Timeframes[i] = h.tf
+	}
+	return nil
+}
+
+// Finish closes open positions at the last price and returns the result
+func (e *Engine) Finish() (*Result, error) {
+	if e.finished {
+		return nil, errors.New("backtest already finished")
+	}
+	if e.account == nil {
+		return nil, errors.New("no price data in range")
+	}
+	if err := e.finish(); err != nil {
+		return nil, err
+	}
+	e.account.finalPoint(e.broker.last.time)
+	return e.result(), nil
+}
+
+// finish ends the run for this engine's symbol, cancelling its orders and
+// closing its positions
+func (e *Engine) finish() error {
+	if e.resampler != nil {
+		if err := e.resampler.Flush(); err != nil {
+			return err
+		}
+	}
+	e.finished = true
+
+	for _, o := range e.broker.pending {
+		e.broker.log(e.broker.last.time, EventOrderCancelled, o.ID, 0, o.Price, "end of test")
+	}
+	for _, o := range e.broker.queued {
+		e.broker.log(e.broker.last.time, EventOrderCancelled, o.ID, 0, 0, "end of test")
+	}
+	e.broker.pending, e.broker.queued = nil, nil
+	e.broker.closeAll(ExitEndOfTest)
+	return e.broker.err
+}
+
+// Context is the strategy's view of the engine
+type Context struct {
+	engine *Engine
+}
+
+// Symbol returns the traded symbol
+func (c *Context) Symbol() string {
+	return c.engine.cfg.Symbol
+}
+
+// Time returns the time of the latest price
+func (c *Context) Time() time.Time {
+	if c.engine.broker == nil {
+		return time.Time{}
+	}
+	return c.engine.broker.last.time
+}
+
+// Bid returns the latest bid
+func (c *Context) Bid() float64 {
+	if c.engine.broker == nil {
+		return 0
+	}
+	return c.engine.broker.last.bid
+}
+
+// Ask returns the latest ask
+func (c *Context) Ask() float64 {
+	if c.engine.broker == nil {
+		return 0
+	}
+	return c.engine.broker.last.ask
+}
+
+// Instrument returns the traded instrument's specification
+func (c *Context) Instrument() instruments.Instrument {
+	return c.engine.cfg.Instrument
+}
+
+// PipSize returns the price distance of one pip
+func (c *Context) PipSize() float64 {
+	return c.engine.cfg.Instrument.PipSize
+}
+
+// Pips converts a distance in pips to a price distance
+func (c *Context) Pips(n float64) float64 {
+	return n * c.engine.cfg.Instrument.PipSize
+}
+
+// Margin returns the margin held by open positions
+func (c *Context) Margin() float64 {
+	if c.engine.account == nil {
+		return 0
+	}
+	return c.engine.account.Margin()
+}
+
+// FreeMargin returns the equity available to open new positions
+func (c *Context) FreeMargin() float64 {
+	if c.engine.account == nil {
+		return c.engine.cfg.InitialBalance
+	}
+	return c.engine.account.FreeMargin()
+}
+
+// MarginLevel returns equity as a percentage of used margin, or zero with
+// no open positions
+func (c *Context) MarginLevel() float64 {
+	if c.engine.account == nil {
+		return 0
+	}
+	return c.engine.account.MarginLevel()
+}
+
+// PipValue returns the account-currency value of a one pip move on one lot
+func (c *Context) PipValue() (float64, error) {
+	inst := c.engine.cfg.Instrument
+	if c.engine.broker == nil {
+		return 0, errors.New("no prices yet")
+	}
+	rate, err := c.engine.broker.rate(inst.Quote, c.engine.cfg.Currency)
+	if err != nil {
+		return 0, err
+	}
+	return inst.PipSize * inst.ContractSize * rate, nil
+}
+
+// History returns up to n completed bars, oldest first, ending with the
+// latest. The slice must not be modified.
+func (c *Context) History(n int) []marketdata.Bar {
+	h := c.engine.history
+	if n <= 0 || n > len(h) {
+		n = len(h)
+	}
+	return h[len(h)-n:]
+}
+
+// Subscribe adds bars of a higher timeframe, built from the same data as
+// the strategy's. Each becomes visible, through Bars and OnTimeframeBar,
+// only once it has closed. Strategies subscribe in Init.
+func (c *Context) Subscribe(tf resample.Timeframe) error {
+	return c.engine.subscribe(tf)
+}
+
+// Bars returns up to n closed bars of a subscribed timeframe or of the
+// strategy's own, oldest first, and nil for other timeframes. A higher
+// timeframe bar still forming is never included. The slice must not be
+// modified.
+func (c *Context) Bars(tf resample.Timeframe, n int) []marketdata.Bar {
+	if tf == c.engine.cfg.Timeframe {
+		return c.History(n)
+	}
+	for _, h := range c.engine.higher {
+		if h.tf == tf {
+			if n <= 0 || n > len(h.history) {
+				n = len(h.history)
+			}
+			return h.history[len(h.history)-n:]
+		}
+	}
+	return nil
+}
+
+// WarmingUp reports whether the strategy cannot trade yet: in Init, and
+// on bars handed to it by Engine.WarmUp
+func (c *Context) WarmingUp() bool {
+	return c.engine.broker == nil
+}
+
+// Balance returns the realized account balance
+func (c *Context) Balance() float64 {
+	if c.engine.account == nil {
+		return c.engine.cfg.InitialBalance
+	}
+	return c.engine.account.Balance()
+}
+
+// Equity returns the balance plus floating profit
+func (c *Context) Equity() float64 {
+	if c.engine.account == nil {
+		return c.engine.cfg.InitialBalance
+	}
+	return c.engine.account.Equity()
+}
+
+// Submit places an order. A zero volume is sized by the configured rule.
+func (c *Context) Submit(o Order) (*Order, error) {
+	if c.engine.broker == nil {
+		return nil, errors.New("no prices yet")
+	}
+	order, err := c.engine.broker.submit(o, c.Time())
+	if err != nil {
+		c.engine.broker.log(c.Time(), EventOrderRejected, 0, 0, o.Price, err.Error())
+		return nil, err
+	}
+	return order, nil
+}
+
+// Buy places a market buy order
+func (c *Context) Buy(volume, stopLoss, takeProfit float64) (*Order, error) {
+	return c.Submit(Order{Side: Buy, Type: Market, Volume: volume, StopLoss: stopLoss, TakeProfit: takeProfit})
+}
+
+// Sell places a market sell order
+func (c *Context) Sell(volume, stopLoss, takeProfit float64) (*Order, error) {
+	return c.Submit(Order{Side: Sell, Type: Market, Volume: volume, StopLoss: stopLoss, TakeProfit: takeProfit})
+}
+
+// Cancel withdraws a pending order
+func (c *Context) Cancel(orderID int64) error {
+	if c.engine.broker == nil {
+		return fmt.Errorf("order %d not found", orderID)
+	}
+	return c.engine.broker.cancel(orderID, c.Time())
+}
+
+// Close closes a position at the next price
+func (c *Context) Close(positionID int64) error {
+	if c.engine.broker == nil {
+		return fmt.Errorf("position %d not found", positionID)
+	}
+	return c.engine.broker.requestClose(positionID)
+}
+
+// CloseAll closes every open position at the next price
+func (c *Context) CloseAll() {
+	if c.engine.broker == nil {
+		return
+	}
+	for _, p := range c.engine.broker.positions {
+		c.engine.broker.requestClose(p.ID)
+	}
+}
+
+// Modify changes a position's stop loss and take profit; zero removes them
+func (c *Context) Modify(positionID int64, stopLoss, takeProfit float64) error {
+	if c.engine.broker == nil {
+		return fmt.Errorf("position %d not found", positionID)
+	}
+	return c.engine.broker.modify(positionID, stopLoss, takeProfit, c.Time())
+}
+
+// Positions returns copies of the open positions
+func (c *Context) Positions() []Position {
+	if c.engine.broker == nil {
+		return nil
+	}
+	out := make([]Position, len(c.engine.broker.positions))
+	for i, p := range c.engine.broker.positions {
+		out[i] = *p
+	}
+	return out
+}
+
+// Trades returns the closed trades, oldest first. The slice must not be
+// modified.
+func (c *Context) Trades() []Trade {
+	if c.engine.broker == nil {
+		return nil
+	}
+	return c.engine.broker.trades
+}
+
+// Orders returns copies of the working orders
+func (c *Context) Orders() []Order {
+	if c.engine.broker == nil {
+		return nil
+	}
+	var out []Order
+	for _, list := range [][]*Order{c.engine.broker.queued, c.engine.broker.pending} {
+		for _, o := range list {
+			out = append(out, *o)
+		}
+	}
+	return out
+}
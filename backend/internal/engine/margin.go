@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// Leverage limits and margin defaults
+const (
+	MinLeverage            = 30
+	MaxLeverage            = 500
+	DefaultLeverage        = 100
+	DefaultMarginCallLevel = 100 // Percent
+	DefaultStopOutLevel    = 50  // Percent
+)
+
+// MarginConfig sets the account's leverage and the margin levels at which
+// the broker warns and then liquidates. Levels are equity as a percentage
+// of used margin.
+type MarginConfig struct {
+	Leverage        int     `json:"leverage"` // 1:Leverage
+	MarginCallLevel float64 `json:"marginCallLevel"`
+	StopOutLevel    float64 `json:"stopOutLevel"`
+}
+
+func (m *MarginConfig) normalize() error {
+	if m.Leverage == 0 {
+		m.Leverage = DefaultLeverage
+	}
+	if m.Leverage < MinLeverage || m.Leverage > MaxLeverage {
+		return fmt.Errorf("leverage must be between 1:%d and 1:%d", MinLeverage, MaxLeverage)
+	}
+	if m.MarginCallLevel == 0 {
+		m.MarginCallLevel = DefaultMarginCallLevel
+	}
+	if m.StopOutLevel == 0 {
+		m.StopOutLevel = DefaultStopOutLevel
+	}
+	if m.StopOutLevel < 0 || m.MarginCallLevel < m.StopOutLevel {
+		return fmt.Errorf("margin call level must be at or above the stop-out level")
+	}
+	return nil
+}
+
+// requiredMargin is the margin in account currency to hold volume lots
+func (b *broker) requiredMargin(volume float64) (float64, error) {
+	inst := b.cfg.Instrument
+	rate, err := b.rate(inst.Base, b.cfg.Currency)
+	if err != nil {
+		return 0, err
+	}
+	return volume * inst.ContractSize * rate / float64(b.cfg.Margin.Leverage), nil
+}
+
+// checkMargin warns once when the margin level falls to the margin call
+// level, and at the stop-out level closes the most losing position of the
+// account until the level recovers or nothing is left open
+func (b *broker) checkMargin(at time.Time) {
+	a, bk := b.account, b.book
+	if a.margin == 0 {
+		bk.marginCalled = false
+		return
+	}
+	level := a.MarginLevel()
+	if level < a.minLevel || a.minLevel == 0 {
+		a.minLevel = level
+	}
+
+	cfg := b.cfg.Margin
+	if level <= cfg.StopOutLevel {
+		for a.MarginLevel() <= cfg.StopOutLevel {
+			var (
+				owner       *broker
+				worst       *Position
+				worstProfit float64
+			)
+			for _, ob := range bk.brokers {
+				for _, p := range ob.positions {
+					if profit := ob.profit(p, ob.exitPrice(p, ob.last)); worst == nil || profit < worstProfit {
+						owner, worst, worstProfit = ob, p, profit
+					}
+				}
+			}
+			if worst == nil {
+				break
+			}
+			owner.log(at, EventStopOut, 0, worst.ID, owner.exitPrice(worst, owner.last),
+				fmt.Sprintf("margin level %.1f%% at or below %.1f%%", a.MarginLevel(), cfg.StopOutLevel))
+			owner.stopOuts++
+			owner.close(worst, owner.exitPrice(worst, owner.last), at, ExitStopOut, false)
+			b.revalue()
+		}
+		return
+	}
+
+	if level <= cfg.MarginCallLevel {
+		if !bk.marginCalled {
+			bk.marginCalled = true
+			bk.marginCalls++
+			b.log(at, EventMarginCall, 0, 0, 0,
+				fmt.Sprintf("margin level %.1f%% at or below %.1f%%", level, cfg.MarginCallLevel))
+		}
+		return
+	}
+	bk.marginCalled = false
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Side is the direction of an order or position
+type Side int8
+
+const (
+	Buy  Side = 1
+	Sell Side = -1
+)
+
+func (s Side) String() string {
+	if s == Sell {
+		return "sell"
+	}
+	return "buy"
+}
+
+// MarshalText encodes the side as "buy" or "sell"
+func (s Side) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText accepts "buy"/"long" or "sell"/"short"
+func (s *Side) UnmarshalText(b []byte) error {
+	switch strings.ToLower(string(b)) {
+	case "buy", "long":
+		*s = Buy
+	case "sell", "short":
+		*s = Sell
+	default:
+		return fmt.Errorf("unknown side %q", b)
+	}
+	return nil
+}
+
+// OrderType selects how an order is filled
+type OrderType string
+
+const (
+	Market OrderType = "market" // Filled at the next available price
+	Limit  OrderType = "limit"  // Filled at Price or better
+	Stop   OrderType = "stop"   // Becomes a market order once Price trades
+)
+
+// Order is an instruction to open a position
+type Order struct {
+	ID         int64     `json:"id"`
+	Symbol     string    `json:"symbol"`
+	Side       Side      `json:"side"`
+	Type       OrderType `json:"type"`
+	Volume     float64   `json:"volume"`          // Lots; sized by the engine when zero
+	Price      float64   `json:"price,omitempty"` // Trigger price of limit and stop orders
+	StopLoss   float64   `json:"stopLoss,omitempty"`
+	TakeProfit float64   `json:"takeProfit,omitempty"`
+	Expires    time.Time `json:"expires,omitempty"` // Pending orders only; zero never expires
+	Comment    string    `json:"comment,omitempty"`
+	Created    time.Time `json:"created"`
+}
+
+// Position is an open trade. Positions are hedged: opposite orders open new
+// positions rather than reducing existing ones.
+type Position struct {
+	ID         int64     `json:"id"`
+	Symbol     string    `json:"symbol"`
+	Side       Side      `json:"side"`
+	Volume     float64   `json:"volume"`
+	EntryPrice float64   `json:"entryPrice"`
+	EntryTime  time.Time `json:"entryTime"`
+	StopLoss   float64   `json:"stopLoss,omitempty"`
+	TakeProfit float64   `json:"takeProfit,omitempty"`
+	Commission float64   `json:"commission,omitempty"` // Paid on opening
+	Swap       float64   `json:"swap,omitempty"`       // Accrued, realized on close
+	Slippage   float64   `json:"slippage,omitempty"`   // Pips lost on entry
+	Margin     float64   `json:"margin"`               // Held while open, in account currency
+	MAE        float64   `json:"mae"`                  // Largest move against the position so far, in pips
+	MFE        float64   `json:"mfe"`                  // Largest move in its favour so far, in pips
+	Comment    string    `json:"comment,omitempty"`
+}
+
+// Exit reasons recorded on trades
+const (
+	ExitSignal     = "signal"
+	ExitStopLoss   = "stop_loss"
+	ExitTakeProfit = "take_profit"
+	ExitEndOfTest  = "end_of_test"
+	ExitStopOut    = "stop_out" // Liquidated by the broker
+)
+
+// Trade is a closed position
+type Trade struct {
+	ID         int64     `json:"id"`
+	Symbol     string    `json:"symbol"`
+	Side       Side      `json:"side"`
+	Volume     float64   `json:"volume"`
+	EntryPrice float64   `json:"entryPrice"`
+	EntryTime  time.Time `json:"entryTime"`
+	ExitPrice  float64   `json:"exitPrice"`
+	ExitTime   time.Time `json:"exitTime"`
+	StopLoss   float64   `json:"stopLoss,omitempty"`
+	TakeProfit float64   `json:"takeProfit,omitempty"`
+	Pips       float64   `json:"pips"`
+	Profit     float64   `json:"profit"`     // Account currency, after commission and swap
+	Commission float64   `json:"commission"` // Both sides
+	Swap       float64   `json:"swap"`
+	Slippage   float64   `json:"slippage"` // Pips lost on entry and exit
+	MAE        float64   `json:"mae"`      // Maximum adverse excursion: the largest move against it while open, in pips
+	MFE        float64   `json:"mfe"`      // Maximum favourable excursion, in pips
+	Reason     string    `json:"reason"`
+	Comment    string    `json:"comment,omitempty"`
+}
+
+// Event kinds in the trade log
+const (
+	EventOrderPlaced    = "order_placed"
+	EventOrderFilled    = "order_filled"
+	EventOrderCancelled = "order_cancelled"
+	EventOrderExpired   = "order_expired"
+	EventOrderRejected  = "order_rejected"
+	EventPositionClosed = "position_closed"
+	EventPositionEdited = "position_modified"
+	EventMarginCall     = "margin_call"
+	EventStopOut        = "stop_out"
+)
+
+// Event is one entry of the trade log
+type Event struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	OrderID  int64     `json:"orderId,omitempty"`
+	Position int64     `json:"positionId,omitempty"`
+	Price    float64   `json:"price,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
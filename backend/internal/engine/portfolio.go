@@ -0,0 +1,419 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/instruments"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Portfolio limits and defaults
+const (
+	MaxPortfolioSymbols      = 20
+	DefaultCorrelation       = 0.7
+	DefaultCorrelationPeriod = 100
+)
+
+// ExposureLimits cap the risk a portfolio piles onto related positions.
+// Zero leaves a limit off. An order that would break a limit is rejected
+// when it would fill.
+type ExposureLimits struct {
+	// MaxPositions caps the open positions across all symbols
+	MaxPositions int `json:"maxPositions,omitempty"`
+	// MaxCurrencyExposure caps the net long or short position in any one
+	// currency, the account's own included, as a multiple of equity. Long
+	// EURUSD and long GBPUSD are both short USD, so this also limits pairs
+	// that tend to move together.
+	MaxCurrencyExposure float64 `json:"maxCurrencyExposure,omitempty"`
+	// MaxCorrelated caps the positions that would win or lose together:
+	// those on the same symbol and side, and those on symbols whose recent
+	// bar returns correlate by at least Correlation. On negatively
+	// correlated symbols, opposite sides count as aligned.
+	MaxCorrelated     int     `json:"maxCorrelated,omitempty"`
+	Correlation       float64 `json:"correlation,omitempty"`       // DefaultCorrelation when zero
+	CorrelationPeriod int     `json:"correlationPeriod,omitempty"` // Bars; DefaultCorrelationPeriod when zero
+}
+
+func (l *ExposureLimits) normalize() error {
+	if l.MaxPositions < 0 || l.MaxCorrelated < 0 || l.MaxCurrencyExposure < 0 {
+		return errors.New("exposure limits must be positive")
+	}
+	if l.Correlation == 0 {
+		l.Correlation = DefaultCorrelation
+	}
+	if l.Correlation <= 0 || l.Correlation > 1 {
+		return errors.New("correlation threshold must be between 0 and 1")
+	}
+	if l.CorrelationPeriod == 0 {
+		l.CorrelationPeriod = DefaultCorrelationPeriod
+	}
+	if l.CorrelationPeriod < 10 || l.CorrelationPeriod > 5000 {
+		return errors.New("correlation period must be between 10 and 5000 bars")
+	}
+	return nil
+}
+
+// book is an account and the brokers trading from it: one for a single
+// symbol run, one per symbol for a portfolio
+type book struct {
+	account *Account
+	brokers []*broker
+	nextID  int64 // Shared so order and position IDs are unique in the account
+	limits  ExposureLimits
+
+	portfolio    bool // Equity is sampled by the portfolio, once per time step
+	returns      map[*broker][]float64
+	marginCalled bool // Margin level is below the call level and was reported
+	marginCalls  int
+}
+
+func (bk *book) id() int64 {
+	bk.nextID++
+	return bk.nextID
+}
+
+// allow checks an order about to fill on broker b against the exposure
+// limits
+func (bk *book) allow(b *broker, o *Order) error {
+	l := bk.limits
+	open := 0
+	for _, ob := range bk.brokers {
+		open += len(ob.positions)
+	}
+	if l.MaxPositions > 0 && open >= l.MaxPositions {
+		return fmt.Errorf("exposure limit: %d positions already open", open)
+	}
+
+	if l.MaxCorrelated > 0 {
+		aligned := 1
+		for _, ob := range bk.brokers {
+			corr := 1.0
+			if ob != b {
+				corr = correlation(bk.returns[b], bk.returns[ob], l.CorrelationPeriod)
+				if math.Abs(corr) < l.Correlation {
+					continue
+				}
+			}
+			for _, p := range ob.positions {
+				if float64(p.Side)*float64(o.Side)*corr > 0 {
+					aligned++
+				}
+			}
+		}
+		if aligned > l.MaxCorrelated {
+			return fmt.Errorf("exposure limit: %d correlated positions, above %d", aligned, l.MaxCorrelated)
+		}
+	}
+
+	if l.MaxCurrencyExposure > 0 {
+		before := make(map[string]float64)
+		for _, ob := range bk.brokers {
+			for _, p := range ob.positions {
+				if err := ob.expose(before, p.Side, p.Volume); err != nil {
+					return err
+				}
+			}
+		}
+		after := make(map[string]float64, len(before))
+		for currency, v := range before {
+			after[currency] = v
+		}
+		if err := b.expose(after, o.Side, o.Volume); err != nil {
+			return err
+		}
+		limit := l.MaxCurrencyExposure * bk.account.Equity()
+		for _, currency := range []string{b.cfg.Instrument.Base, b.cfg.Instrument.Quote} {
+			if v := math.Abs(after[currency]); v > limit && v > math.Abs(before[currency]) {
+				return fmt.Errorf("exposure limit: %s exposure of %.0f %s, above %.0f",
+					currency, v, b.cfg.Currency, limit)
+			}
+		}
+	}
+	return nil
+}
+
+// expose adds the account-currency value of a position of volume lots to
+// the net exposure per currency: long the base and short the quote for a
+// buy
+func (b *broker) expose(exposure map[string]float64, side Side, volume float64) error {
+	inst := b.cfg.Instrument
+	base, err := b.rate(inst.Base, b.cfg.Currency)
+	if err != nil {
+		return err
+	}
+	units := float64(side) * volume * inst.ContractSize
+	exposure[inst.Base] += units * base
+	exposure[inst.Quote] -= units * base
+	return nil
+}
+
+// record appends each broker's latest bar return, NaN for symbols without
+// a bar in the step, keeping what the correlation period needs
+func (bk *book) record(closes map[*broker]float64, prev map[*broker]float64) {
+	if bk.returns == nil {
+		bk.returns = make(map[*broker][]float64)
+	}
+	n := bk.limits.CorrelationPeriod
+	for _, b := range bk.brokers {
+		r := math.NaN()
+		if c, ok := closes[b]; ok && prev[b] > 0 {
+			r = c/prev[b] - 1
+		}
+		list := append(bk.returns[b], r)
+		if len(list) > 2*n {
+			list = append(list[:0], list[len(list)-n:]...)
+		}
+		bk.returns[b] = list
+	}
+}
+
+// correlation is the Pearson correlation of the latest n returns of two
+// symbols, skipping steps where either had no bar. Both lists end at the
+// latest step. Too little overlap counts as uncorrelated.
+func correlation(x, y []float64, n int) float64 {
+	n = min(n, len(x), len(y))
+	x, y = x[len(x)-n:], y[len(y)-n:]
+	var count, sx, sy, sxx, syy, sxy float64
+	for i := range x {
+		if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+			continue
+		}
+		count++
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		syy += y[i] * y[i]
+		sxy += x[i] * y[i]
+	}
+	if count < float64(len(x))/2 || count < 10 {
+		return 0
+	}
+	cov := sxy - sx*sy/count
+	vx, vy := sxx-sx*sx/count, syy-sy*sy/count
+	if vx <= 0 || vy <= 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// Portfolio runs one strategy over several symbols trading from a single
+// account. Each symbol has an engine of its own, with its own strategy
+// instance, broker and costs; margin, equity and the exposure limits are
+// shared.
+type Portfolio struct {
+	engines  []*Engine
+	book     *book
+	now      time.Time // Time of the bars being processed
+	closes   map[*broker]float64
+	prev     map[*broker]float64
+	finished bool
+}
+
+// NewPortfolio validates one configuration per symbol and initializes
+// their strategies. The symbols must share the account settings, mode and
+// timeframe.
+func NewPortfolio(cfgs []Config, limits ExposureLimits, strategies []Strategy) (*Portfolio, error) {
+	switch {
+	case len(cfgs) < 2:
+		return nil, errors.New("a portfolio needs at least two symbols")
+	case len(cfgs) > MaxPortfolioSymbols:
+		return nil, fmt.Errorf("a portfolio can trade at most %d symbols", MaxPortfolioSymbols)
+	case len(strategies) != len(cfgs):
+		return nil, errors.New("every symbol needs a strategy")
+	}
+	if err := limits.normalize(); err != nil {
+		return nil, err
+	}
+
+	p := &Portfolio{
+		book:   &book{limits: limits, portfolio: true},
+		closes: make(map[*broker]float64),
+		prev:   make(map[*broker]float64),
+	}
+	seen := make(map[string]bool)
+	for i, cfg := range cfgs {
+		e, err := New(cfg, strategies[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.Symbol, err)
+		}
+		cfg = e.cfg
+		if cfg.Mode != ModeBar {
+			return nil, errors.New("portfolios replay bars only")
+		}
+		if len(p.engines) > 0 {
+			shared := p.engines[0].cfg
+			switch {
+			case cfg.Timeframe != shared.Timeframe:
+				return nil, fmt.Errorf("%s has timeframe %s, not %s", cfg.Symbol, cfg.Timeframe, shared.Timeframe)
+			case cfg.Currency != shared.Currency || cfg.InitialBalance != shared.InitialBalance ||
+				cfg.Margin != shared.Margin || cfg.Session.Zone.String() != shared.Session.Zone.String() ||
+				cfg.Session.Close != shared.Session.Close:
+				return nil, fmt.Errorf("%s does not share the account settings", cfg.Symbol)
+			}
+		}
+		if seen[cfg.Symbol] {
+			return nil, fmt.Errorf("%s appears twice", cfg.Symbol)
+		}
+		seen[cfg.Symbol] = true
+		e.book = p.book
+		p.engines = append(p.engines, e)
+	}
+	return p, nil
+}
+
+// Symbols lists the traded symbols, in the order given
+func (p *Portfolio) Symbols() []string {
+	list := make([]string, len(p.engines))
+	for i, e := range p.engines {
+		list[i] = e.cfg.Symbol
+	}
+	return list
+}
+
+// OnBar replays a bar of the i-th symbol. Bars of all symbols must arrive
+// in time order; those with the same time may come in any order.
+func (p *Portfolio) OnBar(i int, bar marketdata.Bar) error {
+	if i < 0 || i >= len(p.engines) {
+		return fmt.Errorf("no symbol %d in the portfolio", i)
+	}
+	if p.finished {
+		return errors.New("backtest already finished")
+	}
+	if bar.Time.Before(p.now) {
+		return fmt.Errorf("%s bar at %s arrived after %s", p.engines[i].cfg.Symbol, bar.Time, p.now)
+	}
+	if bar.Time.After(p.now) {
+		p.step()
+		p.now = bar.Time
+	}
+
+	e := p.engines[i]
+	if err := e.OnBar(bar); err != nil {
+		return fmt.Errorf("%s: %w", e.cfg.Symbol, err)
+	}
+	p.closes[e.broker] = bar.Close
+	return p.err()
+}
+
+// step ends a point in time: equity is sampled once for the account, and
+// the symbols' returns recorded for correlation
+func (p *Portfolio) step() {
+	if len(p.closes) == 0 {
+		return
+	}
+	p.book.account.sample(p.now)
+	p.book.record(p.closes, p.prev)
+	for b, c := range p.closes {
+		p.prev[b] = c
+	}
+	clear(p.closes)
+}
+
+// err reports the first conversion failure of any symbol, which may come
+// from revaluing its positions on another symbol's bar
+func (p *Portfolio) err() error {
+	for _, b := range p.book.brokers {
+		if b.err != nil {
+			return fmt.Errorf("%s: %w", b.cfg.Symbol, b.err)
+		}
+	}
+	return nil
+}
+
+// PortfolioResult is everything a portfolio run produced. The embedded
+// Result is the whole account with every symbol's trades; Breakdown has
+// each symbol's own.
+type PortfolioResult struct {
+	Result
+	Limits    ExposureLimits `json:"limits"`
+	Symbols   []string       `json:"symbols"`
+	Breakdown []*Result      `json:"breakdown,omitempty"`
+}
+
+// Finish closes every position and returns the account's result with a
+// breakdown per symbol
+func (p *Portfolio) Finish() (*PortfolioResult, error) {
+	if p.finished {
+		return nil, errors.New("backtest already finished")
+	}
+	if p.book.account == nil {
+		return nil, errors.New("no price data in range")
+	}
+	p.step()
+	p.finished = true
+
+	var end time.Time
+	for _, e := range p.engines {
+		if e.broker == nil {
+			return nil, fmt.Errorf("no %s price data in range", e.cfg.Symbol)
+		}
+		if err := e.finish(); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.cfg.Symbol, err)
+		}
+		if e.broker.last.time.After(end) {
+			end = e.broker.last.time
+		}
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	p.book.account.finalPoint(end)
+	return p.result(end), nil
+}
+
+func (p *Portfolio) result(end time.Time) *PortfolioResult {
+	a := p.book.account
+	cfg := p.engines[0].cfg
+	cfg.Symbol = strings.Join(p.Symbols(), ",")
+	cfg.Instrument = instruments.Instrument{}
+
+	r := &PortfolioResult{Limits: p.book.limits, Symbols: p.Symbols()}
+	s := Summary{
+		InitialBalance:    cfg.InitialBalance,
+		FinalBalance:      round(a.Balance(), 2),
+		NetProfit:         round(a.Balance()-cfg.InitialBalance, 2),
+		MaxDrawdown:       a.maxDDPct * 100,
+		MaxDrawdownAmount: a.maxDD,
+		MarginCalls:       p.book.marginCalls,
+		MinMarginLevel:    round(a.minLevel, 1),
+		Start:             p.engines[0].start,
+		End:               end,
+	}
+	var trades []Trade
+	var events []Event
+	for _, e := range p.engines {
+		child := e.symbolResult()
+		r.Breakdown = append(r.Breakdown, child)
+		trades = append(trades, child.Trades...)
+		events = append(events, child.Events...)
+		s.Bars += e.bars
+		s.StopOuts += e.broker.stopOuts
+		s.Costs.Spread += e.broker.totals.Spread
+		s.Costs.Slippage += e.broker.totals.Slippage
+		s.Costs.Commission += e.broker.totals.Commission
+		s.Costs.Swap += e.broker.totals.Swap
+		if e.start.Before(s.Start) {
+			s.Start = e.start
+		}
+	}
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].ExitTime.Before(trades[j].ExitTime) })
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	s.Costs = s.Costs.rounded()
+	tradeStats(&s, trades)
+
+	r.Result = Result{
+		Config:  cfg,
+		Summary: s,
+		Trades:  nonNil(trades),
+		Equity:  nonNil(a.curve),
+		Daily:   nonNil(a.daily),
+		Ledger:  nonNil(a.ledger),
+		Events:  nonNil(events),
+	}
+	return r
+}
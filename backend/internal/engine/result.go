@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// Summary holds the headline statistics of a run
+type Summary struct {
+	InitialBalance    float64    `json:"initialBalance"`
+	FinalBalance      float64    `json:"finalBalance"`
+	NetProfit         float64    `json:"netProfit"`
+	GrossProfit       float64    `json:"grossProfit"`
+	GrossLoss         float64    `json:"grossLoss"` // Positive amount
+	TotalTrades       int        `json:"totalTrades"`
+	Wins              int        `json:"wins"`
+	Losses            int        `json:"losses"`
+	WinRate           float64    `json:"winRate"`      // Percent
+	ProfitFactor      float64    `json:"profitFactor"` // Zero with no losing trades
+	MaxDrawdown       float64    `json:"maxDrawdown"`  // Percent of peak equity
+	MaxDrawdownAmount float64    `json:"maxDrawdownAmount"`
+	Costs             CostTotals `json:"costs"`
+	MarginCalls       int        `json:"marginCalls"`
+	StopOuts          int        `json:"stopOuts"`       // Positions liquidated
+	MinMarginLevel    float64    `json:"minMarginLevel"` // Percent, zero if nothing was opened
+	Bars              int        `json:"bars"`
+	Ticks             int        `json:"ticks,omitempty"`
+	Start             time.Time  `json:"start"`
+	End               time.Time  `json:"end"`
+}
+
+// Result is everything a run produced. It is stored as the backtest's
+// ResultData.
+type Result struct {
+	Config  Config        `json:"config"`
+	Summary Summary       `json:"summary"`
+	Trades  []Trade       `json:"trades"`
+	Equity  []EquityPoint `json:"equity"`
+	Daily   []EquityPoint `json:"daily"` // Closing state of each trading day, dated at its midnight UTC
+	Ledger  []LedgerEntry `json:"ledger"`
+	Events  []Event       `json:"events"`
+}
+
+func (e *Engine) result() *Result {
+	b, a := e.broker, e.account
+	s := Summary{
+		InitialBalance:    e.cfg.InitialBalance,
+		FinalBalance:      round(a.Balance(), 2),
+		NetProfit:         round(a.Balance()-e.cfg.InitialBalance, 2),
+		MaxDrawdown:       a.maxDDPct * 100,
+		MaxDrawdownAmount: a.maxDD,
+		Bars:              e.bars,
+		Ticks:             e.ticks,
+		Costs:             b.totals.rounded(),
+		MarginCalls:       e.book.marginCalls,
+		StopOuts:          b.stopOuts,
+		MinMarginLevel:    round(a.minLevel, 1),
+		Start:             e.start,
+		End:               b.last.time,
+	}
+	tradeStats(&s, b.trades)
+
+	return &Result{
+		Config:  e.cfg,
+		Summary: s,
+		Trades:  nonNil(b.trades),
+		Equity:  nonNil(a.curve),
+		Daily:   nonNil(a.daily),
+		Ledger:  nonNil(a.ledger),
+		Events:  nonNil(b.events),
+	}
+}
+
+// symbolResult is one symbol's part of a portfolio run. Its balance and
+// equity are the initial balance plus that symbol's closed trades, and its
+// ledger holds the account entries of those trades.
+func (e *Engine) symbolResult() *Result {
+	b := e.broker
+	s := Summary{
+		InitialBalance: e.cfg.InitialBalance,
+		Bars:           e.bars,
+		Costs:          b.totals.rounded(),
+		StopOuts:       b.stopOuts,
+		Start:          e.start,
+		End:            b.last.time,
+	}
+	tradeStats(&s, b.trades)
+
+	balance, peak := e.cfg.InitialBalance, e.cfg.InitialBalance
+	curve := []EquityPoint{{Time: e.start, Balance: balance, Equity: balance}}
+	ids := make(map[int64]bool, len(b.trades))
+	for _, t := range b.trades {
+		ids[t.ID] = true
+		balance += t.Profit
+		curve = append(curve, EquityPoint{Time: t.ExitTime, Balance: balance, Equity: balance})
+		peak = max(peak, balance)
+		if dd := peak - balance; dd > s.MaxDrawdownAmount {
+			s.MaxDrawdownAmount = dd
+		}
+		if peak > 0 {
+			s.MaxDrawdown = max(s.MaxDrawdown, (peak-balance)/peak*100)
+		}
+	}
+	s.FinalBalance = round(balance, 2)
+	s.NetProfit = round(balance-e.cfg.InitialBalance, 2)
+	var daily []EquityPoint
+	for _, p := range curve {
+		daily = appendDay(daily, e.cfg.Session, p)
+	}
+
+	var ledger []LedgerEntry
+	for _, l := range e.account.ledger {
+		if ids[l.TradeID] {
+			ledger = append(ledger, l)
+		}
+	}
+	return &Result{
+		Config:  e.cfg,
+		Summary: s,
+		Trades:  nonNil(b.trades),
+		Equity:  curve,
+		Daily:   daily,
+		Ledger:  nonNil(ledger),
+		Events:  nonNil(b.events),
+	}
+}
+
+// tradeStats fills in the statistics of a list of closed trades
+func tradeStats(s *Summary, trades []Trade) {
+	s.TotalTrades = len(trades)
+	for _, t := range trades {
+		if t.Profit > 0 {
+			s.Wins++
+			s.GrossProfit += t.Profit
+		} else {
+			s.Losses++
+			s.GrossLoss -= t.Profit
+		}
+	}
+	if s.TotalTrades > 0 {
+		s.WinRate = float64(s.Wins) / float64(s.TotalTrades) * 100
+	}
+	if s.GrossLoss > 0 {
+		s.ProfitFactor = s.GrossProfit / s.GrossLoss
+	}
+	s.GrossProfit = round(s.GrossProfit, 2)
+	s.GrossLoss = round(s.GrossLoss, 2)
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(v*scale) / scale
+}
+
+// nonNil keeps empty lists encoding as [] rather than null
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package engine
+
+import "errors"
+
+// Stitch joins the results of consecutive runs of one symbol, each started
+// from the balance the one before it ended on, into a single result. Trade,
+// order and position IDs are renumbered to stay unique, and the drawdown
+// is measured across the joins. The configuration is the first run's.
+func Stitch(runs []*Result) (*Result, error) {
+	if len(runs) == 0 {
+		return nil, errors.New("no runs to stitch")
+	}
+	first, last := runs[0], runs[len(runs)-1]
+	out := &Result{
+		Config: first.Config,
+		Summary: Summary{
+			InitialBalance: first.Summary.InitialBalance,
+			FinalBalance:   last.Summary.FinalBalance,
+			NetProfit:      round(last.Summary.FinalBalance-first.Summary.InitialBalance, 2),
+			Start:          first.Summary.Start,
+			End:            last.Summary.End,
+		},
+		Trades: []Trade{},
+		Equity: []EquityPoint{},
+		Daily:  []EquityPoint{},
+		Ledger: []LedgerEntry{},
+		Events: []Event{},
+	}
+	s := &out.Summary
+
+	var offset int64 // Highest ID used by the runs so far
+	var costs CostTotals
+	peak := first.Summary.InitialBalance
+	for _, run := range runs {
+		rs := run.Summary
+		s.Bars += rs.Bars
+		s.Ticks += rs.Ticks
+		s.MarginCalls += rs.MarginCalls
+		s.StopOuts += rs.StopOuts
+		if rs.MinMarginLevel > 0 && (s.MinMarginLevel == 0 || rs.MinMarginLevel < s.MinMarginLevel) {
+			s.MinMarginLevel = rs.MinMarginLevel
+		}
+		costs.Spread += rs.Costs.Spread
+		costs.Slippage += rs.Costs.Slippage
+		costs.Commission += rs.Costs.Commission
+		costs.Swap += rs.Costs.Swap
+		s.MaxDrawdown = max(s.MaxDrawdown, rs.MaxDrawdown)
+		s.MaxDrawdownAmount = max(s.MaxDrawdownAmount, rs.MaxDrawdownAmount)
+
+		high := offset
+		shift := func(id int64) int64 {
+			if id == 0 {
+				return 0
+			}
+			high = max(high, id+offset)
+			return id + offset
+		}
+		for _, t := range run.Trades {
+			t.ID = shift(t.ID)
+			out.Trades = append(out.Trades, t)
+		}
+		for _, l := range run.Ledger {
+			l.TradeID = shift(l.TradeID)
+			out.Ledger = append(out.Ledger, l)
+		}
+		for _, e := range run.Events {
+			e.OrderID, e.Position = shift(e.OrderID), shift(e.Position)
+			out.Events = append(out.Events, e)
+		}
+		offset = high
+
+		for _, p := range run.Equity {
+			out.Equity = append(out.Equity, p)
+			peak = max(peak, p.Equity)
+			if dd := peak - p.Equity; dd > s.MaxDrawdownAmount {
+				s.MaxDrawdownAmount = dd
+			}
+			if peak > 0 {
+				s.MaxDrawdown = max(s.MaxDrawdown, (peak-p.Equity)/peak*100)
+			}
+		}
+		// A day split between two runs keeps the later run's close
+		for _, p := range run.Daily {
+			if n := len(out.Daily); n > 0 && out.Daily[n-1].Time.Equal(p.Time) {
+				out.Daily[n-1] = p
+				continue
+			}
+			out.Daily = append(out.Daily, p)
+		}
+	}
+	s.Costs = costs.rounded()
+	tradeStats(s, out.Trades)
+	return out, nil
+}
@@ -0,0 +1,105 @@
+// Package fx converts amounts between currencies using historical rates
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/instruments"
+)
+
+// ErrNoRate is returned when no loaded series can convert between two
+// currencies at the requested time
+var ErrNoRate = errors.New("no conversion rate")
+
+// Series is a pair's price history, oldest first
+type Series struct {
+	times  []time.Time
+	prices []float64
+}
+
+// Add appends a price. Prices must be added in time order.
+func (s *Series) Add(at time.Time, price float64) {
+	s.times = append(s.times, at)
+	s.prices = append(s.prices, price)
+}
+
+// Len returns the number of prices
+func (s *Series) Len() int {
+	return len(s.times)
+}
+
+// At returns the latest price at or before t
+func (s *Series) At(t time.Time) (float64, bool) {
+	i := sort.Search(len(s.times), func(i int) bool { return s.times[i].After(t) })
+	if i == 0 {
+		return 0, false
+	}
+	return s.prices[i-1], true
+}
+
+// Table holds the series of several pairs and converts through them
+type Table struct {
+	pairs map[string]*Series // By base+quote
+}
+
+// NewTable returns an empty table
+func NewTable() *Table {
+	return &Table{pairs: make(map[string]*Series)}
+}
+
+// Set stores the series of a pair, replacing any previous one
+func (t *Table) Set(base, quote string, s *Series) {
+	t.pairs[base+quote] = s
+}
+
+// Has reports whether the table can convert directly between two currencies
+func (t *Table) Has(from, to string) bool {
+	return t.pairs[from+to] != nil || t.pairs[to+from] != nil
+}
+
+// Rate returns the price of one unit of from in to at time at. It uses the
+// pair in either direction, or crosses through USD.
+func (t *Table) Rate(from, to string, at time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	if rate, ok := t.direct(from, to, at); ok {
+		return rate, nil
+	}
+	if from != "USD" && to != "USD" {
+		left, lok := t.direct(from, "USD", at)
+		right, rok := t.direct("USD", to, at)
+		if lok && rok {
+			return left * right, nil
+		}
+	}
+	return 0, fmt.Errorf("%w from %s to %s at %s", ErrNoRate, from, to, at.Format(time.RFC3339))
+}
+
+func (t *Table) direct(from, to string, at time.Time) (float64, bool) {
+	if s := t.pairs[from+to]; s != nil {
+		if price, ok := s.At(at); ok && price > 0 {
+			return price, true
+		}
+	}
+	if s := t.pairs[to+from]; s != nil {
+		if price, ok := s.At(at); ok && price > 0 {
+			return 1 / price, true
+		}
+	}
+	return 0, false
+}
+
+// Need returns the currency that must be converted into the account
+// currency to trade inst, or "" when its own prices suffice. Profits are in
+// the quote currency; the base currency's value follows from the quote's
+// through the instrument's own price.
+func Need(account string, inst instruments.Instrument) string {
+	if inst.Quote == account || inst.Base == account {
+		return ""
+	}
+	return inst.Quote
+}
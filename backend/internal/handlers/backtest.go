@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+var backtestService = &services.BacktestService{}
+
+// HandleRunBacktest queues a run of a strategy over a dataset. The returned
+// job reports progress and, once done, the ID of the stored result.
+func HandleRunBacktest(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.BacktestRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	job, err := backtestService.Submit(userID, req)
+	if err != nil {
+		return backtestError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+		"message": "Backtest queued",
+	})
+}
+
+// HandleRunPortfolioBacktest queues a run of a strategy over several
+// datasets sharing one account, stored with a breakdown per symbol
+func HandleRunPortfolioBacktest(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.PortfolioRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	job, err := backtestService.SubmitPortfolio(userID, req)
+	if err != nil {
+		return backtestError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+		"message": "Portfolio backtest queued",
+	})
+}
+
+// HandleListBacktests returns a page of the user's backtest history,
+// filtered by the query string
+func HandleListBacktests(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	page, err := backtestService.List(userID, services.BacktestQuery{
+		Pair:       c.Query("pair"),
+		Timeframe:  c.Query("timeframe"),
+		Strategy:   c.Query("strategy"),
+		StrategyID: c.Query("strategyId"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+		ParentID:   c.Query("parentId"),
+		Cursor:     c.Query("cursor"),
+		Limit:      c.QueryInt("limit"),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    page,
+	})
+}
+
+// HandleGetBacktest returns a stored backtest with its full result and
+// metrics
+func HandleGetBacktest(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	detail, err := backtestService.Get(userID, c.Params("id"))
+	if err != nil {
+		return backtestError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    detail,
+	})
+}
+
+// HandleDeleteBacktest removes one of the user's backtests
+func HandleDeleteBacktest(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	if err := backtestService.Delete(userID, c.Params("id")); err != nil {
+		return backtestError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Backtest deleted",
+	})
+}
+
+// backtestError maps a failed run or lookup to a response
+func backtestError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrBacktestNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Not Found",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrDatasetNotFound):
+		return datasetError(c, err)
+	case errors.Is(err, jobs.ErrQueueFull):
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":   "Too Many Requests",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrStrategyNotFound):
+		return strategyError(c, err, nil)
+	case errors.Is(err, services.ErrDatasetNotReady):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Conflict",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "Backtest Failed",
+		"message": err.Error(),
+	})
+}
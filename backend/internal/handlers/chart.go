@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+var chartService = &services.ChartService{}
+
+// HandleGetChart returns a backtest's candles, indicators, trade markers
+// and equity curve for the range in ?from= and ?to=, sized for a chart
+// ?width= pixels wide
+func HandleGetChart(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	query := services.ChartQuery{
+		Width:      c.QueryInt("width"),
+		Timeframe:  c.Query("tf"),
+		Indicators: c.Query("indicators"),
+		Sampling:   c.Query("sampling"),
+	}
+	for name, dst := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Bad Request",
+				"message": name + " must be an RFC 3339 timestamp",
+			})
+		}
+		*dst = t
+	}
+
+	data, err := chartService.Chart(userID, c.Params("id"), query)
+	if err != nil {
+		if errors.Is(err, services.ErrBacktestNotFound) ||
+			errors.Is(err, services.ErrDatasetNotFound) ||
+			errors.Is(err, services.ErrDatasetNotReady) {
+			return backtestError(c, err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Chart Failed",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
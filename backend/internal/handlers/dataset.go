@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/csvdialect"
+	"github.com/PervFVCK/strategyforge/internal/ingest"
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+var datasetService = &services.DatasetService{}
+
+// HandleUpload streams a multipart price data upload to disk
+func HandleUpload(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	mediaType, params, err := mime.ParseMediaType(string(c.Request().Header.ContentType()))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Expected multipart/form-data upload",
+		})
+	}
+
+	// Read the body as a stream so large files never sit in memory. There is
+	// no stream when the server buffered the body instead, which it must not
+	// do for an upload of any size.
+	body := c.Context().RequestBodyStream()
+	if body == nil {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":   "Upload Failed",
+			"message": "Upload body could not be streamed",
+		})
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+
+	// Form fields may come before or after the file, so the file is saved
+	// as it streams in and imported once every part has been read
+	var (
+		req  services.UploadRequest
+		file *services.UploadedFile
+	)
+	fail := func(status int, message string) error {
+		if file != nil {
+			datasetService.Discard(file)
+		}
+		c.Context().SetConnectionClose()
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": message,
+		})
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fiber.StatusBadRequest, "Malformed multipart body")
+		}
+
+		switch part.FormName() {
+		case "symbol":
+			value, _ := io.ReadAll(io.LimitReader(part, 32))
+			req.Symbol = string(value)
+		case "hour":
+			value, _ := io.ReadAll(io.LimitReader(part, 64))
+			req.Hour = string(value)
+		case "file":
+			if file != nil {
+				return fail(fiber.StatusBadRequest, "Only one file can be uploaded at a time")
+			}
+			file, err = datasetService.Receive(userID, partFilename(part), part)
+			if err != nil {
+				return uploadError(c, err)
+			}
+		}
+		part.Close()
+	}
+	if file == nil {
+		return fail(fiber.StatusBadRequest, "Missing file field")
+	}
+
+	response, err := datasetService.Upload(userID, req, file)
+	if err != nil {
+		return uploadError(c, err)
+	}
+
+	status, message := fiber.StatusAccepted, "File uploaded and queued for import"
+	if response.Dataset.Status == models.DatasetStatusPending {
+		status, message = fiber.StatusCreated, "File uploaded. Please confirm the detected layout"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": true,
+		"data":    response,
+		"message": message,
+	})
+}
+
+// partFilename returns the name a file part was sent with. Part.FileName
+// drops its directories, which carry the hour of a Dukascopy file.
+func partFilename(part *multipart.Part) string {
+	_, params, err := mime.ParseMediaType(part.Header.Get(fiber.HeaderContentDisposition))
+	if err != nil {
+		return part.FileName()
+	}
+	return params["filename"]
+}
+
+// uploadError maps a failed upload to a response. The rest of the body may
+// be unread, so the connection is closed rather than reused.
+func uploadError(c *fiber.Ctx, err error) error {
+	c.Context().SetConnectionClose()
+	status := fiber.StatusBadRequest
+	switch {
+	case errors.Is(err, ingest.ErrTooLarge):
+		status = fiber.StatusRequestEntityTooLarge
+	case errors.Is(err, jobs.ErrQueueFull):
+		status = fiber.StatusTooManyRequests
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   "Upload Failed",
+		"message": err.Error(),
+	})
+}
+
+// HandleListDatasets returns the user's uploaded datasets
+func HandleListDatasets(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	datasets, err := datasetService.List(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Internal Server Error",
+			"message": "Failed to load datasets",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    datasets,
+	})
+}
+
+// HandleGetDataset returns a single dataset
+func HandleGetDataset(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	dataset, err := datasetService.Get(userID, c.Params("id"))
+	if err != nil {
+		return datasetError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    dataset,
+	})
+}
+
+// HandleConfirmDataset confirms or corrects the detected CSV layout
+func HandleConfirmDataset(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	// An empty body accepts the detected layout as-is
+	var req struct {
+		Dialect *csvdialect.Dialect `json:"dialect"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Bad Request",
+				"message": "Invalid request payload",
+			})
+		}
+	}
+
+	response, err := datasetService.Confirm(userID, c.Params("id"), req.Dialect)
+	if err != nil {
+		if errors.Is(err, services.ErrDatasetNotFound) {
+			return datasetError(c, err)
+		}
+		if errors.Is(err, jobs.ErrQueueFull) {
+			return backtestError(c, err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Confirmation Failed",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"data":    response,
+		"message": "Layout confirmed. Dataset queued for import",
+	})
+}
+
+// HandleGetBars returns a dataset resampled to the timeframe in ?tf=
+func HandleGetBars(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	if c.Query("tf") == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "tf query parameter is required",
+		})
+	}
+
+	query := services.BarsQuery{
+		Timeframe:    c.Query("tf"),
+		Basis:        c.Query("basis"),
+		Gaps:         c.Query("gaps"),
+		SessionZone:  c.Query("sessionZone"),
+		SessionClose: c.Query("sessionClose"),
+		Limit:        c.QueryInt("limit"),
+	}
+	for name, dst := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Bad Request",
+				"message": name + " must be an RFC 3339 timestamp",
+			})
+		}
+		*dst = t
+	}
+
+	response, err := datasetService.Bars(userID, c.Params("id"), query)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDatasetNotFound):
+			return datasetError(c, err)
+		case errors.Is(err, services.ErrDatasetNotReady):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "Conflict",
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Resampling Failed",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// HandleGetQuality returns the data quality report made when the dataset
+// was imported
+func HandleGetQuality(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	dataset, err := datasetService.Get(userID, c.Params("id"))
+	if err != nil {
+		return datasetError(c, err)
+	}
+	if dataset.Quality == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Not Found",
+			"message": "No quality report for this dataset",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    dataset.Quality,
+	})
+}
+
+// HandleRepairDataset creates a repaired copy of a dataset
+func HandleRepairDataset(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.RepairRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	dataset, err := datasetService.Repair(userID, c.Params("id"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDatasetNotFound):
+			return datasetError(c, err)
+		case errors.Is(err, services.ErrDatasetNotReady):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "Conflict",
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Repair Failed",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    dataset,
+		"message": "Repaired dataset created",
+	})
+}
+
+// HandleExportDataset sends a dataset's bars as a MetaTrader 4 history
+// file, selected by ?format=hst&version=400|401&tf=
+func HandleExportDataset(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	export, err := datasetService.Export(userID, c.Params("id"), services.ExportQuery{
+		Format:    c.Query("format"),
+		Version:   c.QueryInt("version"),
+		Timeframe: c.Query("tf"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDatasetNotFound):
+			return datasetError(c, err)
+		case errors.Is(err, services.ErrDatasetNotReady):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "Conflict",
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Export Failed",
+			"message": err.Error(),
+		})
+	}
+
+	c.Attachment(export.Filename)
+	c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+	return c.SendStream(export.File, int(export.Size))
+}
+
+// datasetError maps dataset lookup failures to HTTP responses
+func datasetError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrDatasetNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Not Found",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "Internal Server Error",
+		"message": "Failed to load dataset",
+	})
+}
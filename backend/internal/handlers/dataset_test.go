@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// uploadApp serves HandleUpload as the server does, streaming request bodies
+// past a small buffer
+func uploadApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		BodyLimit:                    1024,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		c.Locals("userID", "user")
+		return c.Next()
+	}, HandleUpload)
+	return app
+}
+
+// multipartUpload builds an upload form holding one file
+func multipartUpload(t *testing.T, filename string, content []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("symbol", "EURUSD"); err != nil {
+		t.Fatal(err)
+	}
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, form.FormDataContentType()
+}
+
+func TestUploadRejectsFileOverMaxUploadSize(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("UPLOAD_DIR", dir)
+	t.Setenv("MAX_UPLOAD_SIZE", "64KB")
+
+	row := "2024.01.02,00:00,1.10000,1.10010,1.09990,1.10005,100\n"
+	content := []byte(strings.Repeat(row, 1<<20/len(row)+1))
+	body, contentType := multipartUpload(t, "EURUSD.csv", content)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/upload", body)
+	req.Header.Set(fiber.HeaderContentType, contentType)
+	resp, err := uploadApp().Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusRequestEntityTooLarge {
+		message, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status = %d, want %d: %s", resp.StatusCode, fiber.StatusRequestEntityTooLarge, message)
+	}
+
+	// Nothing of the rejected file is kept
+	var left []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			left = append(left, path)
+		}
+		return nil
+	})
+	if len(left) > 0 {
+		t.Errorf("rejected upload left files behind: %v", left)
+	}
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"github.com/PervFVCK/strategyforge/internal/indicators"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleListIndicators returns the indicators charts can draw, with their
+// params and output lines
+func HandleListIndicators(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    indicators.Specs(),
+	})
+}
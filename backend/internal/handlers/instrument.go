@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"github.com/PervFVCK/strategyforge/internal/instruments"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleListInstruments returns the instrument registry and the supported
+// account currencies
+func HandleListInstruments(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"instruments": instruments.All(),
+			"currencies":  instruments.Currencies,
+		},
+	})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+var jobService = &services.JobService{}
+
+// Event streams send a comment this often so idle connections stay open, and
+// give each write this long to complete
+const (
+	streamPingInterval = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+// HandleListJobs returns the user's recent background jobs
+func HandleListJobs(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	list, err := jobService.List(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Internal Server Error",
+			"message": "Failed to list jobs",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    list,
+	})
+}
+
+// HandleGetJob returns one of the user's jobs with its latest progress
+func HandleGetJob(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	job, err := jobService.Get(userID, c.Params("id"))
+	if err != nil {
+		return jobError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// HandleCancelJob cancels one of the user's queued or running jobs
+func HandleCancelJob(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	job, err := jobService.Cancel(userID, c.Params("id"))
+	if err != nil {
+		return jobError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+		"message": "Job cancelled",
+	})
+}
+
+// HandleJobEvents streams a job's state as Server-Sent Events until it
+// finishes. Each event is a "job" event carrying the job as JSON.
+func HandleJobEvents(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	job, updates, unsubscribe, err := jobService.Subscribe(userID, c.Params("id"))
+	if err != nil {
+		return jobError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// The server's write timeout is armed once per response, so a stream
+	// that outlives it extends the deadline before every write
+	conn := c.Context().Conn()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+		write := func(chunk string) bool {
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := w.WriteString(chunk); err != nil {
+				return false
+			}
+			return w.Flush() == nil
+		}
+		send := func(job *models.Job) bool {
+			data, err := json.Marshal(job)
+			return err == nil && write(fmt.Sprintf("event: job\ndata: %s\n\n", data))
+		}
+
+		if !send(job) || job.Finished() {
+			return
+		}
+		ping := time.NewTicker(streamPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case next := <-updates:
+				if !send(&next) || next.Finished() {
+					return
+				}
+			case <-ping.C:
+				if !write(": ping\n\n") {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// jobError maps job service errors to responses
+func jobError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Not Found",
+			"message": err.Error(),
+		})
+	case errors.Is(err, jobs.ErrJobFinished):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Conflict",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "Internal Server Error",
+		"message": "Failed to load job",
+	})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/montecarlo"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+var monteCarloService = &services.MonteCarloService{}
+
+// HandleMonteCarlo queues a simulation of altered sequences of a stored
+// backtest's trades. The returned job reports progress and, once done, the
+// ID of the stored simulation, whose seed repeats it.
+func HandleMonteCarlo(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var cfg montecarlo.Config
+	if err := c.BodyParser(&cfg); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	job, err := monteCarloService.Submit(userID, c.Params("id"), cfg)
+	if err != nil {
+		return monteCarloError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+		"message": "Simulation queued",
+	})
+}
+
+// HandleListMonteCarlo returns a backtest's simulations without their
+// reports
+func HandleListMonteCarlo(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	list, err := monteCarloService.List(userID, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Internal Server Error",
+			"message": "Failed to list simulations",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    list,
+	})
+}
+
+// HandleGetMonteCarlo returns a simulation with its report
+func HandleGetMonteCarlo(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	sim, err := monteCarloService.Get(userID, c.Params("id"))
+	if err != nil {
+		return monteCarloError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    sim,
+	})
+}
+
+// HandleDeleteMonteCarlo removes one of the user's simulations
+func HandleDeleteMonteCarlo(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	if err := monteCarloService.Delete(userID, c.Params("id")); err != nil {
+		return monteCarloError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Simulation deleted",
+	})
+}
+
+// monteCarloError maps simulation service errors to responses. Those
+// shared with backtests map as they do there.
+func monteCarloError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrSimulationNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Not Found",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrBacktestNotFound),
+		errors.Is(err, jobs.ErrQueueFull):
+		return backtestError(c, err)
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "Simulation Failed",
+		"message": err.Error(),
+	})
+}
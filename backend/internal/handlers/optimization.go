@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+var optimizationService = &services.OptimizationService{}
+
+// HandleOptimize queues a search of a strategy's parameters. The returned
+// job reports progress and, once done, the ID of the stored optimization.
+func HandleOptimize(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.OptimizeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	job, err := optimizationService.Submit(userID, req)
+	if err != nil {
+		return optimizationError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+		"message": "Optimization queued",
+	})
+}
+
+// HandleListOptimizations returns the user's optimizations without their
+// trials
+func HandleListOptimizations(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	list, err := optimizationService.List(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Internal Server Error",
+			"message": "Failed to list optimizations",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    list,
+	})
+}
+
+// HandleGetOptimization returns an optimization with every trial it ran
+func HandleGetOptimization(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	opt, err := optimizationService.Get(userID, c.Params("id"))
+	if err != nil {
+		return optimizationError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    opt,
+	})
+}
+
+// HandleGetHeatmap returns an optimization's scores over the parameters
+// in ?x= and ?y=
+func HandleGetHeatmap(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	heatmap, err := optimizationService.Heatmap(userID, c.Params("id"), c.Query("x"), c.Query("y"))
+	if err != nil {
+		return optimizationError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    heatmap,
+	})
+}
+
+// HandleDeleteOptimization removes one of the user's optimizations
+func HandleDeleteOptimization(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	if err := optimizationService.Delete(userID, c.Params("id")); err != nil {
+		return optimizationError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Optimization deleted",
+	})
+}
+
+// optimizationError maps optimization service errors to responses. Those
+// shared with backtests map as they do there.
+func optimizationError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrOptimizationNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Not Found",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrDatasetNotFound),
+		errors.Is(err, services.ErrStrategyNotFound),
+		errors.Is(err, services.ErrDatasetNotReady),
+		errors.Is(err, jobs.ErrQueueFull):
+		return backtestError(c, err)
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "Optimization Failed",
+		"message": err.Error(),
+	})
+}
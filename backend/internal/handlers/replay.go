@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/replay"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+var replayService = &services.ReplayService{}
+
+// A replay client that sends nothing for this long is disconnected; each
+// write gets this long to complete
+const (
+	replayReadTimeout  = 10 * time.Minute
+	replayWriteTimeout = 10 * time.Second
+)
+
+// HandleCreateReplay opens a bar replay session. Its ticket connects the
+// WebSocket at /replay/:id/ws?ticket=...
+func HandleCreateReplay(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.ReplayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	session, err := replayService.Create(userID, req)
+	if err != nil {
+		return replayError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    session,
+		"message": "Replay session opened",
+	})
+}
+
+// HandleListReplays returns the user's open replay sessions
+func HandleListReplays(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    replayService.List(userID),
+	})
+}
+
+// HandleGetReplay returns an open replay session
+func HandleGetReplay(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	session, err := replayService.Get(userID, c.Params("id"))
+	if err != nil {
+		return replayError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    session,
+	})
+}
+
+// HandleDeleteReplay closes a replay session, discarding trades not saved
+func HandleDeleteReplay(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	if err := replayService.Delete(userID, c.Params("id")); err != nil {
+		return replayError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Replay session closed",
+	})
+}
+
+// HandleReplaySocket upgrades to the WebSocket of a replay session.
+// Browsers cannot set headers on a WebSocket, so the session's ticket in the
+// query authorizes it instead of a JWT.
+func HandleReplaySocket(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{
+			"error":   "Upgrade Required",
+			"message": "Connect with a WebSocket",
+		})
+	}
+
+	session, err := replayService.Connect(c.Params("id"), c.Query("ticket"))
+	if err != nil {
+		return replayError(c, err)
+	}
+	if session.Connected() {
+		return replayError(c, replay.ErrSessionInUse)
+	}
+
+	c.Locals("session", session)
+	return replaySocket(c)
+}
+
+var replaySocket = websocket.New(func(conn *websocket.Conn) {
+	session := conn.Locals("session").(*replay.Session)
+	if err := session.Serve(socket{conn}); errors.Is(err, replay.ErrSessionInUse) {
+		socket{conn}.WriteJSON(replay.Message{Type: replay.MessageError, Message: err.Error()})
+	}
+})
+
+// socket sets the deadlines of a replay connection, which outlives the
+// server's own timeouts
+type socket struct {
+	*websocket.Conn
+}
+
+func (s socket) ReadJSON(v any) error {
+	s.SetReadDeadline(time.Now().Add(replayReadTimeout))
+	return s.Conn.ReadJSON(v)
+}
+
+func (s socket) WriteJSON(v any) error {
+	s.SetWriteDeadline(time.Now().Add(replayWriteTimeout))
+	return s.Conn.WriteJSON(v)
+}
+
+// replayError maps replay service errors to responses. Those shared with
+// backtests map as they do there.
+func replayError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, replay.ErrSessionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Not Found",
+			"message": err.Error(),
+		})
+	case errors.Is(err, replay.ErrSessionInUse):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Conflict",
+			"message": err.Error(),
+		})
+	case errors.Is(err, replay.ErrTooManySessions):
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":   "Too Many Requests",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrDatasetNotFound),
+		errors.Is(err, services.ErrDatasetNotReady):
+		return backtestError(c, err)
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "Replay Failed",
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+var strategyService = &services.StrategyService{}
+
+// HandleListStrategies returns the user's saved strategies
+func HandleListStrategies(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	strategies, err := strategyService.List(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Internal Server Error",
+			"message": "Failed to list strategies",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    strategies,
+	})
+}
+
+// HandleGetStrategy returns one of the user's strategies or a public one
+func HandleGetStrategy(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	strategy, err := strategyService.Get(userID, c.Params("id"))
+	if err != nil {
+		return strategyError(c, err, nil)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    strategy,
+		"check":   strategyService.Check(strategy.Code),
+	})
+}
+
+// HandleCheckStrategy compiles code without saving it, for editor feedback
+func HandleCheckStrategy(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.StrategyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    strategyService.Check(req.Code),
+	})
+}
+
+// HandleConvertStrategy converts code between text and the visual builder's
+// graph
+func HandleConvertStrategy(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.ConvertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	code, check, err := strategyService.Convert(req)
+	if err != nil {
+		if check == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Bad Request",
+				"message": err.Error(),
+			})
+		}
+		return strategyError(c, err, check)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"format": req.To,
+			"code":   code,
+		},
+	})
+}
+
+// HandleCreateStrategy saves a new strategy once its code compiles
+func HandleCreateStrategy(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.StrategyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	strategy, check, err := strategyService.Create(userID, req)
+	if err != nil {
+		return strategyError(c, err, check)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    strategy,
+		"check":   check,
+		"message": "Strategy saved",
+	})
+}
+
+// HandleUpdateStrategy replaces one of the user's strategies
+func HandleUpdateStrategy(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.StrategyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	strategy, check, err := strategyService.Update(userID, c.Params("id"), req)
+	if err != nil {
+		return strategyError(c, err, check)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    strategy,
+		"check":   check,
+		"message": "Strategy saved",
+	})
+}
+
+// HandleDeleteStrategy removes one of the user's strategies
+func HandleDeleteStrategy(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	if err := strategyService.Delete(userID, c.Params("id")); err != nil {
+		return strategyError(c, err, nil)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Strategy deleted",
+	})
+}
+
+// strategyError maps strategy service errors to responses. Compile errors
+// carry their positions so editors can mark them; other problems with a
+// checked request are bad requests.
+func strategyError(c *fiber.Ctx, err error, check *services.StrategyCheck) error {
+	switch {
+	case errors.Is(err, services.ErrStrategyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "Not Found",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidStrategy):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "Invalid Strategy",
+			"message": err.Error(),
+			"errors":  check.Errors,
+		})
+	}
+	if check != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "Internal Server Error",
+		"message": "Strategy request failed",
+	})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/PervFVCK/strategyforge/internal/middleware"
+	"github.com/PervFVCK/strategyforge/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+var walkForwardService = &services.WalkForwardService{}
+
+// HandleWalkForward queues a walk-forward analysis. Once done, the job's
+// result is the stitched out-of-sample backtest, whose children are the
+// runs of each window.
+func HandleWalkForward(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+	}
+
+	var req services.WalkForwardRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Bad Request",
+			"message": "Invalid request payload",
+		})
+	}
+
+	job, err := walkForwardService.Submit(userID, req)
+	if err != nil {
+		return optimizationError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+		"message": "Walk-forward analysis queued",
+	})
+}
@@ -0,0 +1,208 @@
+package indicators
+
+import (
+	"math"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// series holds what every single-series indicator shares
+type series struct {
+	value float64
+	ready bool
+}
+
+func (s *series) Value() float64 {
+	if !s.ready {
+		return math.NaN()
+	}
+	return s.value
+}
+
+func (s *series) Ready() bool {
+	return s.ready
+}
+
+// SMA is the simple moving average
+type SMA struct {
+	series
+	n   int
+	w   *ring
+	sum float64
+}
+
+// NewSMA averages the latest n values
+func NewSMA(n int) *SMA {
+	n = period(n)
+	return &SMA{n: n, w: newRing(n)}
+}
+
+func (s *SMA) WarmUp() int               { return s.n }
+func (s *SMA) Update(bar marketdata.Bar) { s.Add(bar.Close) }
+func (s *SMA) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	if old, full := s.w.push(v); full {
+		s.sum -= old
+	}
+	s.sum += v
+	s.value, s.ready = s.sum/float64(s.n), s.w.full()
+	return s.Value()
+}
+
+// EMA is the exponential moving average, seeded with the simple average of
+// its first n values
+type EMA struct {
+	series
+	n     int
+	alpha float64
+	seen  int
+}
+
+// NewEMA smooths with weight 2/(n+1) on each new value
+func NewEMA(n int) *EMA {
+	n = period(n)
+	return &EMA{n: n, alpha: 2 / float64(n+1)}
+}
+
+// NewRMA is Wilder's moving average, an EMA weighting each new value 1/n.
+// RSI, ATR and ADX smooth with it.
+func NewRMA(n int) *EMA {
+	n = period(n)
+	return &EMA{n: n, alpha: 1 / float64(n)}
+}
+
+func (e *EMA) WarmUp() int               { return e.n }
+func (e *EMA) Update(bar marketdata.Bar) { e.Add(bar.Close) }
+func (e *EMA) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	e.seen++
+	if e.seen <= e.n {
+		e.value += (v - e.value) / float64(e.seen)
+		e.ready = e.seen == e.n
+	} else {
+		e.value += (v - e.value) * e.alpha
+	}
+	return e.Value()
+}
+
+// WMA is the linearly weighted moving average: the newest of n values has
+// weight n and the oldest weight 1
+type WMA struct {
+	series
+	n        int
+	w        *ring
+	sum      float64 // Plain sum of the window
+	weighted float64
+}
+
+// NewWMA weights the latest n values linearly
+func NewWMA(n int) *WMA {
+	n = period(n)
+	return &WMA{n: n, w: newRing(n)}
+}
+
+func (m *WMA) WarmUp() int               { return m.n }
+func (m *WMA) Update(bar marketdata.Bar) { m.Add(bar.Close) }
+func (m *WMA) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	size := m.w.size
+	old, full := m.w.push(v)
+	if full {
+		// Every weight drops by one, which removes the oldest value entirely
+		m.weighted += float64(m.n)*v - m.sum
+		m.sum += v - old
+	} else {
+		m.weighted += float64(size+1) * v
+		m.sum += v
+	}
+	m.value, m.ready = m.weighted/float64(m.n*(m.n+1)/2), m.w.full()
+	return m.Value()
+}
+
+// StdDev is the population standard deviation of the latest n values
+type StdDev struct {
+	series
+	n          int
+	w          *ring
+	shift      float64 // First value, subtracted to keep the sums small
+	sum, sumSq float64
+}
+
+// NewStdDev measures the spread of the latest n values
+func NewStdDev(n int) *StdDev {
+	n = period(n)
+	return &StdDev{n: n, w: newRing(n)}
+}
+
+func (s *StdDev) WarmUp() int               { return s.n }
+func (s *StdDev) Update(bar marketdata.Bar) { s.Add(bar.Close) }
+func (s *StdDev) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	if s.w.size == 0 {
+		s.shift = v
+	}
+	x := v - s.shift
+	if old, full := s.w.push(x); full {
+		s.sum -= old
+		s.sumSq -= old * old
+	}
+	s.sum += x
+	s.sumSq += x * x
+	mean := s.sum / float64(s.n)
+	s.value, s.ready = math.Sqrt(math.Max(s.sumSq/float64(s.n)-mean*mean, 0)), s.w.full()
+	return s.Value()
+}
+
+// Highest is the highest of the latest n values
+type Highest struct {
+	series
+	n int
+	e *extreme
+}
+
+// NewHighest tracks the highest of the latest n values
+func NewHighest(n int) *Highest {
+	return &Highest{n: period(n), e: newHighest(n)}
+}
+
+func (h *Highest) WarmUp() int               { return h.n }
+func (h *Highest) Update(bar marketdata.Bar) { h.Add(bar.High) }
+func (h *Highest) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	h.value = h.e.push(v)
+	h.ready = !math.IsNaN(h.value)
+	return h.Value()
+}
+
+// Lowest is the lowest of the latest n values
+type Lowest struct {
+	series
+	n int
+	e *extreme
+}
+
+// NewLowest tracks the lowest of the latest n values
+func NewLowest(n int) *Lowest {
+	return &Lowest{n: period(n), e: newLowest(n)}
+}
+
+func (l *Lowest) WarmUp() int               { return l.n }
+func (l *Lowest) Update(bar marketdata.Bar) { l.Add(bar.Low) }
+func (l *Lowest) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	l.value = l.e.push(v)
+	l.ready = !math.IsNaN(l.value)
+	return l.Value()
+}
@@ -0,0 +1,33 @@
+package indicators
+
+import "testing"
+
+// StockCharts' worked example of moving averages, with the published values
+// rounded to cents
+var stockChartsCloses = []float64{22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+	22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63,
+	23.82, 23.87, 23.65, 23.19, 23.10, 23.33, 22.68, 23.10, 22.40, 22.17}
+
+func TestMovingAverages(t *testing.T) {
+	tests := []struct {
+		name string
+		ind  Series
+		want []float64
+	}{
+		{"sma", NewSMA(10), []float64{nan, nan, nan, nan, nan, nan, nan, nan, nan, 22.22,
+			22.21, 22.23, 22.26, 22.31, 22.42, 22.61, 22.77, 22.91, 23.08, 23.21,
+			23.38, 23.53, 23.65, 23.71, 23.69, 23.61, 23.51, 23.43, 23.28, 23.13}},
+		{"ema", NewEMA(10), []float64{nan, nan, nan, nan, nan, nan, nan, nan, nan, 22.22,
+			22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28, 23.34,
+			23.43, 23.51, 23.54, 23.47, 23.40, 23.39, 23.26, 23.23, 23.08, 22.92}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]float64, len(stockChartsCloses))
+			for i, v := range stockChartsCloses {
+				got[i] = tt.ind.Add(v)
+			}
+			expectSeries(t, tt.name, got, tt.want, 0.01)
+		})
+	}
+}
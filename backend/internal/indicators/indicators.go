@@ -0,0 +1,132 @@
+// Package indicators computes technical indicators one bar at a time. Each
+// update takes constant time (amortized, for window extremes), so the same
+// values can be produced while a backtest runs and when drawing charts.
+//
+// An indicator reports NaN until it has seen enough bars to be meaningful;
+// WarmUp tells how many that is. Indicators of a single series, such as
+// SMA, take values with Add and also implement Indicator by reading the
+// bar's close (its high for Highest and its low for Lowest).
+package indicators
+
+import (
+	"math"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Indicator is updated with each closed bar, oldest first
+type Indicator interface {
+	Update(bar marketdata.Bar)
+	// Ready reports whether the latest update produced a value
+	Ready() bool
+	// WarmUp is the number of bars needed before the first value
+	WarmUp() int
+}
+
+// Series is an indicator of a single value series
+type Series interface {
+	Indicator
+	// Add takes the next value and returns the indicator's value, NaN while
+	// warming up. NaN inputs are skipped and return NaN.
+	Add(v float64) float64
+	Value() float64
+}
+
+func period(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// ring holds the latest n values of a series
+type ring struct {
+	values []float64
+	next   int
+	size   int
+}
+
+func newRing(n int) *ring {
+	return &ring{values: make([]float64, period(n))}
+}
+
+// push adds a value and returns the one it displaced, if the ring was full
+func (r *ring) push(v float64) (old float64, full bool) {
+	full = r.size == len(r.values)
+	if full {
+		old = r.values[r.next]
+	} else {
+		r.size++
+	}
+	r.values[r.next] = v
+	r.next = (r.next + 1) % len(r.values)
+	return old, full
+}
+
+func (r *ring) full() bool {
+	return r.size == len(r.values)
+}
+
+// ago returns the value pushed n pushes ago, zero being the latest, or NaN
+func (r *ring) ago(n int) float64 {
+	if n < 0 || n >= r.size {
+		return math.NaN()
+	}
+	return r.values[(r.next-1-n+2*len(r.values))%len(r.values)]
+}
+
+// extreme tracks the highest or lowest of the latest n values with a
+// monotonic queue: each value is added and dropped once.
+type extreme struct {
+	n     int
+	keep  func(kept, added float64) bool // Whether an older value still matters
+	index []int
+	value []float64
+	head  int
+	count int // Values seen
+}
+
+func newHighest(n int) *extreme {
+	return &extreme{n: period(n), keep: func(kept, added float64) bool { return kept > added }}
+}
+
+func newLowest(n int) *extreme {
+	return &extreme{n: period(n), keep: func(kept, added float64) bool { return kept < added }}
+}
+
+// push adds a value and returns the extreme of the window, NaN until full
+func (e *extreme) push(v float64) float64 {
+	for len(e.value) > e.head && !e.keep(e.value[len(e.value)-1], v) {
+		e.index = e.index[:len(e.index)-1]
+		e.value = e.value[:len(e.value)-1]
+	}
+	e.index = append(e.index, e.count)
+	e.value = append(e.value, v)
+	e.count++
+	if e.index[e.head] <= e.count-1-e.n {
+		e.head++
+	}
+	// Reclaim the dropped front now and then so the queue stays bounded
+	if e.head > e.n {
+		e.index = append(e.index[:0], e.index[e.head:]...)
+		e.value = append(e.value[:0], e.value[e.head:]...)
+		e.head = 0
+	}
+	if e.count < e.n {
+		return math.NaN()
+	}
+	return e.value[e.head]
+}
+
+// trueRange is the bar's range extended to the previous close
+func trueRange(bar marketdata.Bar, prevClose float64) float64 {
+	if math.IsNaN(prevClose) {
+		return bar.High - bar.Low
+	}
+	return math.Max(bar.High, prevClose) - math.Min(bar.Low, prevClose)
+}
+
+// Typical returns the average of a bar's high, low and close
+func Typical(bar marketdata.Bar) float64 {
+	return (bar.High + bar.Low + bar.Close) / 3
+}
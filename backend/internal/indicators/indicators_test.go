@@ -0,0 +1,189 @@
+package indicators
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// nan marks a value still warming up in the expected series
+var nan = math.NaN()
+
+// Thirty daily bars used by the reference series of the bar indicators
+var (
+	refHigh = []float64{48.70, 48.72, 48.90, 48.87, 48.82, 49.05, 49.20, 49.35, 49.92, 50.19, 50.12, 49.66, 49.88, 50.19, 50.36,
+		50.57, 50.65, 50.43, 49.63, 50.33, 50.29, 50.17, 49.32, 48.50, 48.32, 46.80, 47.80, 48.39, 48.66, 48.79}
+	refLow = []float64{47.79, 48.14, 48.39, 48.37, 48.24, 48.64, 48.94, 48.86, 49.50, 49.87, 49.20, 48.90, 49.43, 49.73, 49.26,
+		50.09, 50.30, 49.21, 48.98, 49.61, 49.20, 49.43, 48.08, 47.64, 41.55, 44.28, 47.31, 47.20, 47.90, 47.73}
+	refClose = []float64{48.16, 48.61, 48.75, 48.63, 48.74, 49.03, 49.07, 49.32, 49.91, 50.13, 49.53, 49.50, 49.75, 50.03, 50.31,
+		50.52, 50.41, 49.34, 49.37, 50.23, 49.24, 49.93, 48.43, 48.18, 46.57, 45.41, 47.77, 47.72, 48.62, 47.85}
+)
+
+func refBars() []marketdata.Bar {
+	bars := make([]marketdata.Bar, len(refClose))
+	for i := range bars {
+		bars[i] = marketdata.Bar{High: refHigh[i], Low: refLow[i], Close: refClose[i]}
+	}
+	return bars
+}
+
+// expectSeries compares an indicator's values with a reference series,
+// where NaN means the indicator should still be warming up
+func expectSeries(t *testing.T, name string, got, want []float64, tol float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d values, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		switch {
+		case math.IsNaN(want[i]) != math.IsNaN(got[i]):
+			t.Errorf("%s[%d] = %v, want %v", name, i, got[i], want[i])
+		case math.Abs(got[i]-want[i]) > tol:
+			t.Errorf("%s[%d] = %.6f, want %.6f", name, i, got[i], want[i])
+		}
+	}
+}
+
+// randomBars is a random walk long enough to wrap every window many times
+func randomBars(n int) []marketdata.Bar {
+	rng := rand.New(rand.NewSource(1))
+	bars := make([]marketdata.Bar, n)
+	price := 100.0
+	for i := range bars {
+		open := price
+		price += rng.NormFloat64()
+		high := math.Max(open, price) + rng.Float64()
+		low := math.Min(open, price) - rng.Float64()
+		// Repeated closes give ties for the window extremes to handle
+		if i%7 == 0 {
+			price = math.Round(price)
+		}
+		bars[i] = marketdata.Bar{Open: open, High: high, Low: low, Close: price, Volume: rng.Float64() * 1000}
+	}
+	return bars
+}
+
+// window returns the n values ending at i, or nil before there are n
+func window(values []float64, i, n int) []float64 {
+	if i < n-1 {
+		return nil
+	}
+	return values[i-n+1 : i+1]
+}
+
+// TestStreamingMatchesBatch checks each windowed indicator, updated one bar
+// at a time, against its value recomputed from the whole window at every bar
+func TestStreamingMatchesBatch(t *testing.T) {
+	bars := randomBars(2000)
+	closes := make([]float64, len(bars))
+	highs := make([]float64, len(bars))
+	lows := make([]float64, len(bars))
+	for i, b := range bars {
+		closes[i], highs[i], lows[i] = b.Close, b.High, b.Low
+	}
+
+	mean := func(w []float64) float64 {
+		sum := 0.0
+		for _, v := range w {
+			sum += v
+		}
+		return sum / float64(len(w))
+	}
+	stdDev := func(w []float64) float64 {
+		m, sum := mean(w), 0.0
+		for _, v := range w {
+			sum += (v - m) * (v - m)
+		}
+		return math.Sqrt(sum / float64(len(w)))
+	}
+	highest := func(w []float64) float64 {
+		best := math.Inf(-1)
+		for _, v := range w {
+			best = math.Max(best, v)
+		}
+		return best
+	}
+	lowest := func(w []float64) float64 {
+		best := math.Inf(1)
+		for _, v := range w {
+			best = math.Min(best, v)
+		}
+		return best
+	}
+	weighted := func(w []float64) float64 {
+		sum, weights := 0.0, 0.0
+		for j, v := range w {
+			sum += float64(j+1) * v
+			weights += float64(j + 1)
+		}
+		return sum / weights
+	}
+
+	tests := []struct {
+		name   string
+		n      int
+		values []float64
+		ind    func(n int) Series
+		batch  func(w []float64) float64
+	}{
+		{"sma", 20, closes, func(n int) Series { return NewSMA(n) }, mean},
+		{"wma", 15, closes, func(n int) Series { return NewWMA(n) }, weighted},
+		{"stdev", 20, closes, func(n int) Series { return NewStdDev(n) }, stdDev},
+		{"highest", 1, highs, func(n int) Series { return NewHighest(n) }, highest},
+		{"highest", 9, highs, func(n int) Series { return NewHighest(n) }, highest},
+		{"lowest", 9, lows, func(n int) Series { return NewLowest(n) }, lowest},
+		{"lowest", 50, lows, func(n int) Series { return NewLowest(n) }, lowest},
+	}
+	for _, tt := range tests {
+		ind := tt.ind(tt.n)
+		for i, v := range tt.values {
+			got := ind.Add(v)
+			w := window(tt.values, i, tt.n)
+			if w == nil {
+				if !math.IsNaN(got) {
+					t.Fatalf("%s(%d)[%d] = %v while warming up", tt.name, tt.n, i, got)
+				}
+				continue
+			}
+			if want := tt.batch(w); math.Abs(got-want) > 1e-9 {
+				t.Fatalf("%s(%d)[%d] = %v, recomputed %v", tt.name, tt.n, i, got, want)
+			}
+		}
+	}
+
+	// Bar indicators built on the same windows
+	const n, smooth, d = 14, 3, 3
+	bollinger := NewBollinger(n, 2)
+	donchian := NewDonchian(n)
+	stoch := NewStochastic(n, smooth, d)
+	var raw, ks []float64
+	for i, b := range bars {
+		bollinger.Update(b)
+		donchian.Update(b)
+		stoch.Update(b)
+		if w := window(closes, i, n); w != nil {
+			m, sd := mean(w), stdDev(w)
+			want := Band{m + 2*sd, m, m - 2*sd}
+			if got := bollinger.Band(); math.Abs(got.Upper-want.Upper) > 1e-9 || math.Abs(got.Lower-want.Lower) > 1e-9 {
+				t.Fatalf("bollinger[%d] = %+v, recomputed %+v", i, got, want)
+			}
+			hi, lo := highest(window(highs, i, n)), lowest(window(lows, i, n))
+			if got := donchian.Band(); got.Upper != hi || got.Lower != lo {
+				t.Fatalf("donchian[%d] = %+v, recomputed %v-%v", i, got, lo, hi)
+			}
+			raw = append(raw, (b.Close-lo)/(hi-lo)*100)
+		}
+		wantK, wantD := nan, nan
+		if w := window(raw, len(raw)-1, smooth); w != nil {
+			wantK = mean(w)
+			ks = append(ks, wantK)
+			if w := window(ks, len(ks)-1, d); w != nil {
+				wantD = mean(w)
+			}
+		}
+		expectSeries(t, "stochastic k", []float64{stoch.K()}, []float64{wantK}, 1e-9)
+		expectSeries(t, "stochastic d", []float64{stoch.D()}, []float64{wantD}, 1e-9)
+	}
+}
@@ -0,0 +1,232 @@
+package indicators
+
+import (
+	"math"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// RSI is Wilder's relative strength index, from 0 to 100
+type RSI struct {
+	series
+	n          int
+	prev       float64
+	gain, loss *EMA
+}
+
+// NewRSI compares average gains and losses over n changes
+func NewRSI(n int) *RSI {
+	n = period(n)
+	return &RSI{n: n, prev: math.NaN(), gain: NewRMA(n), loss: NewRMA(n)}
+}
+
+// WarmUp counts the first bar, which has no change yet
+func (r *RSI) WarmUp() int               { return r.n + 1 }
+func (r *RSI) Update(bar marketdata.Bar) { r.Add(bar.Close) }
+func (r *RSI) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	prev := r.prev
+	r.prev = v
+	if math.IsNaN(prev) {
+		return math.NaN()
+	}
+	gain := r.gain.Add(math.Max(v-prev, 0))
+	loss := r.loss.Add(math.Max(prev-v, 0))
+	r.ready = r.gain.Ready()
+	switch {
+	case !r.ready:
+	case loss == 0 && gain == 0:
+		r.value = 50
+	case loss == 0:
+		r.value = 100
+	default:
+		r.value = 100 - 100/(1+gain/loss)
+	}
+	return r.Value()
+}
+
+// MACD is the difference of a fast and a slow EMA, with an EMA of that
+// difference as its signal line
+type MACD struct {
+	fast, slow, signal *EMA
+	line, hist         float64
+	lineReady          bool
+}
+
+// NewMACD builds MACD(fast, slow, signal), conventionally (12, 26, 9)
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+func (m *MACD) WarmUp() int               { return max(m.fast.n, m.slow.n) + m.signal.n - 1 }
+func (m *MACD) Ready() bool               { return m.signal.Ready() }
+func (m *MACD) Update(bar marketdata.Bar) { m.Add(bar.Close) }
+
+// Add takes the next value and returns the MACD line
+func (m *MACD) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	fast, slow := m.fast.Add(v), m.slow.Add(v)
+	m.lineReady = m.fast.Ready() && m.slow.Ready()
+	if m.lineReady {
+		m.line = fast - slow
+		m.hist = m.line - m.signal.Add(m.line)
+	}
+	return m.Value()
+}
+
+// Value is the MACD line, available before the signal line is
+func (m *MACD) Value() float64 {
+	if !m.lineReady {
+		return math.NaN()
+	}
+	return m.line
+}
+
+// Signal is the EMA of the MACD line
+func (m *MACD) Signal() float64 {
+	return m.signal.Value()
+}
+
+// Histogram is the MACD line minus its signal
+func (m *MACD) Histogram() float64 {
+	if !m.Ready() {
+		return math.NaN()
+	}
+	return m.hist
+}
+
+// Stochastic is the close's place in the recent high-low range, from 0 to
+// 100. %K may be smoothed; %D is a moving average of %K.
+type Stochastic struct {
+	n          int
+	high       *extreme
+	low        *extreme
+	k, d       *SMA
+	kVal, dVal float64
+}
+
+// NewStochastic builds the stochastic oscillator over n bars with %K
+// smoothed over smooth bars (1 for the fast stochastic) and %D over d
+func NewStochastic(n, smooth, d int) *Stochastic {
+	return &Stochastic{n: period(n), high: newHighest(n), low: newLowest(n), k: NewSMA(smooth), d: NewSMA(d)}
+}
+
+func (s *Stochastic) WarmUp() int { return s.n + s.k.n + s.d.n - 2 }
+func (s *Stochastic) Ready() bool { return s.d.Ready() }
+
+func (s *Stochastic) Update(bar marketdata.Bar) {
+	hi, lo := s.high.push(bar.High), s.low.push(bar.Low)
+	s.kVal, s.dVal = math.NaN(), math.NaN()
+	if math.IsNaN(hi) {
+		return
+	}
+	raw := 50.0 // A flat range has the close in the middle
+	if hi > lo {
+		raw = (bar.Close - lo) / (hi - lo) * 100
+	}
+	s.kVal = s.k.Add(raw)
+	if s.k.Ready() {
+		s.dVal = s.d.Add(s.kVal)
+	}
+}
+
+// K is %K, NaN while warming up
+func (s *Stochastic) K() float64 { return s.kVal }
+
+// D is %D, NaN while warming up
+func (s *Stochastic) D() float64 { return s.dVal }
+
+// ADX is Wilder's average directional index with its +DI and -DI lines
+type ADX struct {
+	n                 int
+	bars              int
+	prev              marketdata.Bar
+	tr, plusDM, minDM *wilderSum
+	dx                *EMA
+	plusDI, minusDI   float64
+	adx               float64
+}
+
+// NewADX measures trend strength over n bars, conventionally 14
+func NewADX(n int) *ADX {
+	n = period(n)
+	return &ADX{n: n, tr: newWilderSum(n), plusDM: newWilderSum(n), minDM: newWilderSum(n), dx: NewRMA(n)}
+}
+
+// WarmUp is one bar for the first change, n for the DI lines and n more
+// for the average of DX
+func (a *ADX) WarmUp() int { return 2 * a.n }
+func (a *ADX) Ready() bool { return a.dx.Ready() }
+
+func (a *ADX) Update(bar marketdata.Bar) {
+	a.bars++
+	prev := a.prev
+	a.prev = bar
+	a.plusDI, a.minusDI, a.adx = math.NaN(), math.NaN(), math.NaN()
+	if a.bars == 1 {
+		return
+	}
+
+	up, down := bar.High-prev.High, prev.Low-bar.Low
+	plus, minus := 0.0, 0.0
+	if up > down && up > 0 {
+		plus = up
+	}
+	if down > up && down > 0 {
+		minus = down
+	}
+	tr := a.tr.add(trueRange(bar, prev.Close))
+	plus = a.plusDM.add(plus)
+	minus = a.minDM.add(minus)
+	if math.IsNaN(tr) {
+		return
+	}
+	if tr > 0 {
+		a.plusDI, a.minusDI = 100*plus/tr, 100*minus/tr
+	} else {
+		a.plusDI, a.minusDI = 0, 0
+	}
+	dx := 0.0
+	if sum := a.plusDI + a.minusDI; sum > 0 {
+		dx = 100 * math.Abs(a.plusDI-a.minusDI) / sum
+	}
+	a.adx = a.dx.Add(dx)
+}
+
+// Value is the ADX, NaN while warming up
+func (a *ADX) Value() float64 { return a.adx }
+
+// PlusDI is the positive directional indicator, available after n+1 bars
+func (a *ADX) PlusDI() float64 { return a.plusDI }
+
+// MinusDI is the negative directional indicator
+func (a *ADX) MinusDI() float64 { return a.minusDI }
+
+// wilderSum is Wilder's running total: the sum of the first n values, then
+// each new value replaces an average one
+type wilderSum struct {
+	n    int
+	seen int
+	sum  float64
+}
+
+func newWilderSum(n int) *wilderSum {
+	return &wilderSum{n: period(n)}
+}
+
+func (w *wilderSum) add(v float64) float64 {
+	w.seen++
+	if w.seen <= w.n {
+		w.sum += v
+		if w.seen < w.n {
+			return math.NaN()
+		}
+		return w.sum
+	}
+	w.sum += v - w.sum/float64(w.n)
+	return w.sum
+}
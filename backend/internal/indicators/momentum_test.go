@@ -0,0 +1,113 @@
+package indicators
+
+import "testing"
+
+func TestRSI(t *testing.T) {
+	// StockCharts' worked example of Wilder's RSI(14)
+	closes := []float64{44.3389, 44.0902, 44.1497, 43.6124, 44.3278, 44.8264, 45.0955, 45.4245, 45.8433, 46.0826,
+		45.8931, 46.0328, 45.6140, 46.2820, 46.2820, 46.0028, 46.0328, 46.4116, 46.2222, 45.6439,
+		46.2122, 46.2521, 45.7137, 46.4515, 45.7835, 45.3548, 44.0288, 44.1783, 44.2181, 44.5672,
+		43.4205, 42.6628, 43.1314}
+	want := []float64{nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan,
+		70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+		54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77}
+
+	rsi := NewRSI(14)
+	got := make([]float64, len(closes))
+	for i, v := range closes {
+		got[i] = rsi.Add(v)
+	}
+	expectSeries(t, "rsi", got, want, 0.005)
+}
+
+func TestRSIBounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		closes []float64
+		want   float64
+	}{
+		{"only gains", []float64{1, 2, 3, 4}, 100},
+		{"only losses", []float64{4, 3, 2, 1}, 0},
+		{"flat", []float64{2, 2, 2, 2}, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsi := NewRSI(3)
+			for _, v := range tt.closes {
+				rsi.Add(v)
+			}
+			if got := rsi.Value(); got != tt.want {
+				t.Errorf("rsi = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The MACD, stochastic and ADX series below were worked from their textbook
+// definitions over the reference bars, independently of this package
+func TestMACD(t *testing.T) {
+	want := struct{ line, signal, hist []float64 }{
+		line: []float64{nan, nan, nan, nan, nan, 0.1888, 0.1837, 0.2092, 0.3148, 0.3547,
+			0.1897, 0.0973, 0.1039, 0.1515, 0.2068, 0.2420, 0.1965, -0.0772, -0.1574, 0.0207,
+			-0.1308, -0.0184, -0.2970, -0.4077, -0.7340, -0.9942, -0.4394, -0.1892, 0.1200, 0.0483},
+		signal: []float64{nan, nan, nan, nan, nan, nan, nan, 0.1939, 0.2543, 0.3045,
+			0.2471, 0.1722, 0.1381, 0.1448, 0.1758, 0.2089, 0.2027, 0.0628, -0.0473, -0.0133,
+			-0.0721, -0.0452, -0.1711, -0.2894, -0.5117, -0.7529, -0.5962, -0.3927, -0.1363, -0.0440},
+		hist: []float64{nan, nan, nan, nan, nan, nan, nan, 0.0153, 0.0605, 0.0502,
+			-0.0574, -0.0749, -0.0341, 0.0067, 0.0310, 0.0331, -0.0062, -0.1399, -0.1101, 0.0340,
+			-0.0587, 0.0269, -0.1259, -0.1183, -0.2223, -0.2413, 0.1568, 0.2035, 0.2564, 0.0923},
+	}
+
+	m := NewMACD(3, 6, 3)
+	var line, signal, hist []float64
+	for _, bar := range refBars() {
+		m.Update(bar)
+		line, signal, hist = append(line, m.Value()), append(signal, m.Signal()), append(hist, m.Histogram())
+	}
+	expectSeries(t, "macd", line, want.line, 1e-4)
+	expectSeries(t, "signal", signal, want.signal, 1e-4)
+	expectSeries(t, "histogram", hist, want.hist, 1e-4)
+	if got := m.WarmUp(); got != 8 {
+		t.Errorf("warm-up = %d, want 8", got)
+	}
+}
+
+func TestStochastic(t *testing.T) {
+	wantK := []float64{nan, nan, nan, nan, nan, nan, 89.9487, 93.8526, 94.3868, 97.6104,
+		81.9699, 64.8751, 54.7959, 67.2029, 83.3546, 93.7261, 92.1050, 62.9225, 38.3716, 35.7438,
+		37.9242, 51.9788, 32.2139, 33.7157, 31.0223, 40.7637, 60.7560, 71.2027, 89.4220, 89.1239}
+	wantD := []float64{nan, nan, nan, nan, nan, nan, nan, nan, 92.7294, 95.2833,
+		91.3224, 81.4851, 67.2136, 62.2913, 68.4511, 81.4278, 89.7286, 82.9179, 64.4664, 45.6793,
+		37.3465, 41.8822, 40.7056, 39.3028, 32.3173, 35.1672, 44.1807, 57.5741, 73.7936, 83.2495}
+
+	s := NewStochastic(5, 3, 3)
+	var k, d []float64
+	for _, bar := range refBars() {
+		s.Update(bar)
+		k, d = append(k, s.K()), append(d, s.D())
+	}
+	expectSeries(t, "k", k, wantK, 1e-4)
+	expectSeries(t, "d", d, wantD, 1e-4)
+}
+
+func TestADX(t *testing.T) {
+	wantADX := []float64{nan, nan, nan, nan, nan, nan, nan, nan, nan, 70.8495,
+		58.4627, 49.1673, 40.2873, 37.1553, 31.8594, 26.3760, 23.1565, 27.7144, 32.6423, 27.1143,
+		26.2138, 25.4935, 32.4725, 39.3699, 49.7884, 58.1232, 58.6698, 55.7143, 51.7125, 49.0622}
+	wantPlus := []float64{nan, nan, nan, nan, nan, 16.6667, 21.2565, 23.2079, 40.5814, 46.6652,
+		31.0136, 23.0994, 27.1965, 33.9790, 22.5866, 25.8585, 25.4878, 16.5704, 13.4390, 28.8225,
+		21.0832, 16.3896, 10.5497, 8.7400, 3.2516, 2.5164, 10.8295, 15.3351, 16.7138, 14.5952}
+	wantMinus := []float64{nan, nan, nan, nan, nan, 5.8140, 5.1635, 4.0865, 3.0976, 2.6673,
+		25.9360, 29.3906, 24.7215, 20.5500, 27.9855, 23.6588, 20.7367, 44.7405, 42.9725, 31.8579,
+		33.4037, 25.9673, 42.7163, 44.1652, 72.9200, 56.4316, 44.5021, 39.3278, 35.2776, 32.8388}
+
+	a := NewADX(5)
+	var adx, plus, minus []float64
+	for _, bar := range refBars() {
+		a.Update(bar)
+		adx, plus, minus = append(adx, a.Value()), append(plus, a.PlusDI()), append(minus, a.MinusDI())
+	}
+	expectSeries(t, "adx", adx, wantADX, 1e-4)
+	expectSeries(t, "+di", plus, wantPlus, 1e-4)
+	expectSeries(t, "-di", minus, wantMinus, 1e-4)
+}
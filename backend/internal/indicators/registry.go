@@ -0,0 +1,222 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// Param is a numeric setting of an indicator created by name
+type Param struct {
+	Name    string  `json:"name"`
+	Default float64 `json:"default"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Integer bool    `json:"integer"`
+}
+
+// Spec describes an indicator that can be created by name
+type Spec struct {
+	Name    string   `json:"name"`
+	Title   string   `json:"title"`
+	Params  []Param  `json:"params"`
+	Outputs []string `json:"outputs"`
+	Overlay bool     `json:"overlay"` // Drawn over prices rather than in a pane of its own
+	build   func(p map[string]float64, session resample.Session) (Study, error)
+}
+
+// Study is an indicator created by name, with its outputs as a list in the
+// order of its spec's Outputs
+type Study interface {
+	Indicator
+	Values() []float64
+}
+
+// study adapts a typed indicator to Study
+type study struct {
+	Indicator
+	values func() []float64
+}
+
+func (s study) Values() []float64 { return s.values() }
+
+func length(name string, def float64) Param {
+	return Param{Name: name, Default: def, Min: 1, Max: 5000, Integer: true}
+}
+
+func single(s Series) (Study, error) {
+	return study{s, func() []float64 { return []float64{s.Value()} }}, nil
+}
+
+func band(ind Indicator, get func() Band) (Study, error) {
+	return study{ind, func() []float64 {
+		b := get()
+		return []float64{b.Upper, b.Middle, b.Lower}
+	}}, nil
+}
+
+func pivots(kind PivotKind) func(map[string]float64, resample.Session) (Study, error) {
+	return func(_ map[string]float64, session resample.Session) (Study, error) {
+		p, err := NewPivots(kind, session, resample.D1)
+		if err != nil {
+			return nil, err
+		}
+		return study{p, func() []float64 {
+			l := p.Levels()
+			return []float64{l.P, l.R1, l.R2, l.R3, l.S1, l.S2, l.S3}
+		}}, nil
+	}
+}
+
+var pivotOutputs = []string{"p", "r1", "r2", "r3", "s1", "s2", "s3"}
+
+var specs = map[string]Spec{
+	"sma": {Title: "Simple moving average", Params: []Param{length("period", 20)}, Outputs: []string{"sma"}, Overlay: true,
+		build: func(p map[string]float64, _ resample.Session) (Study, error) { return single(NewSMA(int(p["period"]))) }},
+	"ema": {Title: "Exponential moving average", Params: []Param{length("period", 20)}, Outputs: []string{"ema"}, Overlay: true,
+		build: func(p map[string]float64, _ resample.Session) (Study, error) { return single(NewEMA(int(p["period"]))) }},
+	"wma": {Title: "Weighted moving average", Params: []Param{length("period", 20)}, Outputs: []string{"wma"}, Overlay: true,
+		build: func(p map[string]float64, _ resample.Session) (Study, error) { return single(NewWMA(int(p["period"]))) }},
+	"stdev": {Title: "Standard deviation", Params: []Param{length("period", 20)}, Outputs: []string{"stdev"},
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			return single(NewStdDev(int(p["period"])))
+		}},
+	"rsi": {Title: "Relative strength index", Params: []Param{length("period", 14)}, Outputs: []string{"rsi"},
+		build: func(p map[string]float64, _ resample.Session) (Study, error) { return single(NewRSI(int(p["period"]))) }},
+	"macd": {Title: "MACD", Params: []Param{length("fast", 12), length("slow", 26), length("signal", 9)},
+		Outputs: []string{"macd", "signal", "histogram"},
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			if p["fast"] >= p["slow"] {
+				return nil, fmt.Errorf("macd fast period must be shorter than the slow one")
+			}
+			m := NewMACD(int(p["fast"]), int(p["slow"]), int(p["signal"]))
+			return study{m, func() []float64 { return []float64{m.Value(), m.Signal(), m.Histogram()} }}, nil
+		}},
+	"stochastic": {Title: "Stochastic oscillator", Params: []Param{length("period", 14), length("smooth", 3), length("d", 3)},
+		Outputs: []string{"k", "d"},
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			s := NewStochastic(int(p["period"]), int(p["smooth"]), int(p["d"]))
+			return study{s, func() []float64 { return []float64{s.K(), s.D()} }}, nil
+		}},
+	"adx": {Title: "Average directional index", Params: []Param{length("period", 14)}, Outputs: []string{"adx", "plus_di", "minus_di"},
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			a := NewADX(int(p["period"]))
+			return study{a, func() []float64 { return []float64{a.Value(), a.PlusDI(), a.MinusDI()} }}, nil
+		}},
+	"atr": {Title: "Average true range", Params: []Param{length("period", 14)}, Outputs: []string{"atr"},
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			a := NewATR(int(p["period"]))
+			return study{a, func() []float64 { return []float64{a.Value()} }}, nil
+		}},
+	"bollinger": {Title: "Bollinger bands", Params: []Param{length("period", 20), {Name: "mult", Default: 2, Min: 0.1, Max: 10}},
+		Outputs: []string{"upper", "middle", "lower"}, Overlay: true,
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			b := NewBollinger(int(p["period"]), p["mult"])
+			return band(b, b.Band)
+		}},
+	"keltner": {Title: "Keltner channels", Params: []Param{length("period", 20), length("atr_period", 10), {Name: "mult", Default: 2, Min: 0.1, Max: 10}},
+		Outputs: []string{"upper", "middle", "lower"}, Overlay: true,
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			k := NewKeltner(int(p["period"]), int(p["atr_period"]), p["mult"])
+			return band(k, k.Band)
+		}},
+	"donchian": {Title: "Donchian channels", Params: []Param{length("period", 20)}, Outputs: []string{"upper", "middle", "lower"}, Overlay: true,
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			d := NewDonchian(int(p["period"]))
+			return band(d, d.Band)
+		}},
+	"ichimoku": {Title: "Ichimoku cloud",
+		Params:  []Param{length("conversion", 9), length("base", 26), length("span_b", 52), length("displacement", 26)},
+		Outputs: []string{"conversion", "base", "span_a", "span_b", "lagging"}, Overlay: true,
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			ich := NewIchimoku(int(p["conversion"]), int(p["base"]), int(p["span_b"]), int(p["displacement"]))
+			return study{ich, func() []float64 {
+				l := ich.Lines()
+				return []float64{l.Conversion, l.Base, l.SpanA, l.SpanB, l.Lagging}
+			}}, nil
+		}},
+	"psar": {Title: "Parabolic SAR",
+		Params:  []Param{{Name: "step", Default: 0.02, Min: 0.001, Max: 1}, {Name: "max", Default: 0.2, Min: 0.001, Max: 1}},
+		Outputs: []string{"sar"}, Overlay: true,
+		build: func(p map[string]float64, _ resample.Session) (Study, error) {
+			s := NewPSAR(p["step"], p["max"])
+			return study{s, func() []float64 { return []float64{s.Value()} }}, nil
+		}},
+	"vwap": {Title: "Daily VWAP", Params: []Param{}, Outputs: []string{"vwap"}, Overlay: true,
+		build: func(_ map[string]float64, session resample.Session) (Study, error) {
+			w, err := NewVWAP(session, resample.D1)
+			if err != nil {
+				return nil, err
+			}
+			return study{w, func() []float64 { return []float64{w.Value()} }}, nil
+		}},
+	"pivots":           {Title: "Daily pivot points", Params: []Param{}, Outputs: pivotOutputs, Overlay: true, build: pivots(PivotClassic)},
+	"pivots_fibonacci": {Title: "Daily Fibonacci pivots", Params: []Param{}, Outputs: pivotOutputs, Overlay: true, build: pivots(PivotFibonacci)},
+	"pivots_camarilla": {Title: "Daily Camarilla pivots", Params: []Param{}, Outputs: pivotOutputs, Overlay: true, build: pivots(PivotCamarilla)},
+	"pivots_woodie":    {Title: "Daily Woodie pivots", Params: []Param{}, Outputs: pivotOutputs, Overlay: true, build: pivots(PivotWoodie)},
+}
+
+// Specs lists the indicators that can be created by name, sorted by name
+func Specs() []Spec {
+	list := make([]Spec, 0, len(specs))
+	for name, spec := range specs {
+		spec.Name = name
+		list = append(list, spec)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// New creates an indicator by name. Missing params take their defaults;
+// session anchors daily indicators, the forex New York close when zero.
+func New(name string, params map[string]float64, session resample.Session) (Study, []string, error) {
+	spec, ok := specs[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown indicator %q", name)
+	}
+	values := make(map[string]float64, len(spec.Params))
+	for _, p := range spec.Params {
+		values[p.Name] = p.Default
+	}
+	for key, v := range params {
+		var param *Param
+		for i := range spec.Params {
+			if spec.Params[i].Name == key {
+				param = &spec.Params[i]
+			}
+		}
+		switch {
+		case param == nil:
+			return nil, nil, fmt.Errorf("%s has no param %s", name, key)
+		case math.IsNaN(v) || v < param.Min || v > param.Max:
+			return nil, nil, fmt.Errorf("%s %s must be between %g and %g, got %g", name, key, param.Min, param.Max, v)
+		case param.Integer && v != math.Trunc(v):
+			return nil, nil, fmt.Errorf("%s %s must be a whole number, got %g", name, key, v)
+		}
+		values[key] = v
+	}
+	s, err := spec.build(values, session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, spec.Outputs, nil
+}
+
+// Compute runs a study over bars and returns each output's values, one per
+// bar
+func Compute(s Study, outputs []string, bars []marketdata.Bar) [][]float64 {
+	lines := make([][]float64, len(outputs))
+	for i := range lines {
+		lines[i] = make([]float64, len(bars))
+	}
+	for j, bar := range bars {
+		s.Update(bar)
+		for i, v := range s.Values() {
+			lines[i][j] = v
+		}
+	}
+	return lines
+}
@@ -0,0 +1,170 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// anchor reports when a bar starts a new session period
+type anchor struct {
+	session resample.Session
+	period  resample.Timeframe
+	start   time.Time
+}
+
+func newAnchor(session resample.Session, period resample.Timeframe) (anchor, error) {
+	switch period {
+	case resample.D1, resample.W1, resample.MN:
+	default:
+		return anchor{}, fmt.Errorf("anchor period must be D1, W1 or MN, got %q", period)
+	}
+	if session.Zone == nil {
+		session = resample.NewYorkClose()
+	}
+	return anchor{session: session, period: period}, nil
+}
+
+// next reports whether t is in a later period than the previous call's
+func (a *anchor) next(t time.Time) bool {
+	start, _ := a.session.Bounds(a.period, t)
+	if start.Equal(a.start) {
+		return false
+	}
+	a.start = start
+	return true
+}
+
+// VWAP is the volume-weighted average typical price since the start of the
+// session period. Periods without any volume, as in some forex exports,
+// fall back to the plain average.
+type VWAP struct {
+	anchor anchor
+	pv, v  float64
+	sum    float64
+	count  int
+}
+
+// NewVWAP restarts the average each period (D1, W1 or MN) of the session;
+// a zero session means the forex New York close
+func NewVWAP(session resample.Session, period resample.Timeframe) (*VWAP, error) {
+	a, err := newAnchor(session, period)
+	if err != nil {
+		return nil, err
+	}
+	return &VWAP{anchor: a}, nil
+}
+
+func (w *VWAP) WarmUp() int { return 1 }
+func (w *VWAP) Ready() bool { return w.count > 0 }
+
+func (w *VWAP) Update(bar marketdata.Bar) {
+	if w.anchor.next(bar.Time) {
+		w.pv, w.v, w.sum, w.count = 0, 0, 0, 0
+	}
+	price := Typical(bar)
+	if bar.Volume > 0 {
+		w.pv += price * bar.Volume
+		w.v += bar.Volume
+	}
+	w.sum += price
+	w.count++
+}
+
+// Value is the VWAP of the current period
+func (w *VWAP) Value() float64 {
+	switch {
+	case w.count == 0:
+		return math.NaN()
+	case w.v > 0:
+		return w.pv / w.v
+	}
+	return w.sum / float64(w.count)
+}
+
+// PivotKind selects how pivot levels are derived from the previous
+// period's high, low and close
+type PivotKind string
+
+// Supported pivot kinds
+const (
+	PivotClassic   PivotKind = "classic"
+	PivotFibonacci PivotKind = "fibonacci"
+	PivotCamarilla PivotKind = "camarilla"
+	PivotWoodie    PivotKind = "woodie"
+)
+
+// PivotLevels are a pivot and its resistance and support levels
+type PivotLevels struct {
+	P, R1, R2, R3, S1, S2, S3 float64
+}
+
+// Pivots are support and resistance levels for the current period, from
+// the previous period's range
+type Pivots struct {
+	kind           PivotKind
+	anchor         anchor
+	periods        int
+	high, low, cls float64 // Of the current period so far
+	levels         PivotLevels
+}
+
+// NewPivots computes levels for each period (D1, W1 or MN) of the session;
+// a zero session means the forex New York close
+func NewPivots(kind PivotKind, session resample.Session, period resample.Timeframe) (*Pivots, error) {
+	switch kind {
+	case PivotClassic, PivotFibonacci, PivotCamarilla, PivotWoodie:
+	case "":
+		kind = PivotClassic
+	default:
+		return nil, fmt.Errorf("unknown pivot kind %q", kind)
+	}
+	a, err := newAnchor(session, period)
+	if err != nil {
+		return nil, err
+	}
+	nan := math.NaN()
+	return &Pivots{kind: kind, anchor: a, levels: PivotLevels{nan, nan, nan, nan, nan, nan, nan}}, nil
+}
+
+// WarmUp is one bar into the second period; the count depends on the
+// timeframe, so this reports the minimum
+func (p *Pivots) WarmUp() int { return 2 }
+func (p *Pivots) Ready() bool { return p.periods > 1 }
+
+func (p *Pivots) Update(bar marketdata.Bar) {
+	if p.anchor.next(bar.Time) {
+		if p.periods > 0 {
+			p.levels = pivotLevels(p.kind, p.high, p.low, p.cls)
+		}
+		p.periods++
+		p.high, p.low = bar.High, bar.Low
+	}
+	p.high, p.low, p.cls = math.Max(p.high, bar.High), math.Min(p.low, bar.Low), bar.Close
+}
+
+// Levels returns the current period's levels, NaN during the first period
+func (p *Pivots) Levels() PivotLevels { return p.levels }
+
+func pivotLevels(kind PivotKind, h, l, c float64) PivotLevels {
+	r := h - l
+	p := (h + l + c) / 3
+	switch kind {
+	case PivotFibonacci:
+		return PivotLevels{P: p,
+			R1: p + 0.382*r, R2: p + 0.618*r, R3: p + r,
+			S1: p - 0.382*r, S2: p - 0.618*r, S3: p - r}
+	case PivotCamarilla:
+		return PivotLevels{P: p,
+			R1: c + r*1.1/12, R2: c + r*1.1/6, R3: c + r*1.1/4,
+			S1: c - r*1.1/12, S2: c - r*1.1/6, S3: c - r*1.1/4}
+	case PivotWoodie:
+		p = (h + l + 2*c) / 4
+	}
+	return PivotLevels{P: p,
+		R1: 2*p - l, R2: p + r, R3: h + 2*(p-l),
+		S1: 2*p - h, S2: p - r, S3: l - 2*(h-p)}
+}
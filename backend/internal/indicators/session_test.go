@@ -0,0 +1,97 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// hourly returns an hourly bar of January 2024, when the New York close of
+// 17:00 falls at 22:00 UTC
+func hourly(day, hour int, high, low, close, volume float64) marketdata.Bar {
+	return marketdata.Bar{
+		Time: time.Date(2024, time.January, day, hour, 0, 0, 0, time.UTC),
+		High: high, Low: low, Close: close, Volume: volume,
+	}
+}
+
+func TestVWAP(t *testing.T) {
+	bars := []marketdata.Bar{
+		hourly(2, 20, 1.10, 1.08, 1.09, 100),
+		hourly(2, 21, 1.12, 1.09, 1.11, 300),
+		// A new session opens at the close
+		hourly(2, 22, 1.13, 1.11, 1.12, 200),
+		hourly(2, 23, 1.14, 1.11, 1.13, 0),
+		hourly(3, 0, 1.15, 1.12, 1.14, 200),
+		// A session without volume takes the plain average
+		hourly(3, 22, 1.20, 1.18, 1.19, 0),
+		hourly(3, 23, 1.23, 1.20, 1.22, 0),
+	}
+	want := []float64{
+		1.09,
+		(1.09*100 + (1.12+1.09+1.11)/3*300) / 400,
+		1.12,
+		1.12, // No volume, so no weight
+		(1.12*200 + (1.15+1.12+1.14)/3*200) / 400,
+		1.19,
+		(1.19 + (1.23+1.20+1.22)/3) / 2,
+	}
+
+	w, err := NewVWAP(resample.Session{}, resample.D1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []float64
+	for _, bar := range bars {
+		w.Update(bar)
+		got = append(got, w.Value())
+	}
+	expectSeries(t, "vwap", got, want, 1e-12)
+
+	if _, err := NewVWAP(resample.Session{}, resample.H1); err == nil {
+		t.Error("an hourly anchor was accepted")
+	}
+}
+
+func TestPivots(t *testing.T) {
+	// Levels worked by hand from the first session's high of 1.12, low of
+	// 1.08 and close of 1.11, in the order P, R1, R2, R3, S1, S2, S3
+	tests := []struct {
+		kind PivotKind
+		want []float64
+	}{
+		{PivotClassic, []float64{1.103333, 1.126667, 1.143333, 1.166667, 1.086667, 1.063333, 1.046667}},
+		{PivotFibonacci, []float64{1.103333, 1.118613, 1.128053, 1.143333, 1.088053, 1.078613, 1.063333}},
+		{PivotCamarilla, []float64{1.103333, 1.113667, 1.117333, 1.121000, 1.106333, 1.102667, 1.099000}},
+		{PivotWoodie, []float64{1.105000, 1.130000, 1.145000, 1.170000, 1.090000, 1.065000, 1.050000}},
+	}
+	levels := func(l PivotLevels) []float64 {
+		return []float64{l.P, l.R1, l.R2, l.R3, l.S1, l.S2, l.S3}
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			p, err := NewPivots(tt.kind, resample.Session{}, resample.D1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Update(hourly(2, 20, 1.10, 1.08, 1.09, 0))
+			p.Update(hourly(2, 21, 1.12, 1.09, 1.11, 0))
+			if p.Ready() || !math.IsNaN(p.Levels().P) {
+				t.Fatalf("levels during the first session: %+v", p.Levels())
+			}
+
+			// Every bar of the next session sees the first session's levels
+			p.Update(hourly(2, 22, 1.30, 1.00, 1.20, 0))
+			expectSeries(t, "first bar", levels(p.Levels()), tt.want, 1e-6)
+			p.Update(hourly(2, 23, 1.40, 0.90, 1.25, 0))
+			expectSeries(t, "second bar", levels(p.Levels()), tt.want, 1e-6)
+			if !p.Ready() {
+				t.Error("not ready in the second session")
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package indicators
+
+import (
+	"math"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Ichimoku is the Ichimoku Kinko Hyo cloud. Each line is the midpoint of a
+// window's high and low.
+//
+// The leading spans are plotted Displacement bars ahead, so the cloud at
+// the latest bar was computed that many bars ago; SpanA and SpanB return it
+// without looking ahead. The lagging line is the close plotted Displacement
+// bars back; charts shift it, strategies can compare the close with old
+// prices directly.
+type Ichimoku struct {
+	conv, base, spanB *midpoint
+	displacement      int
+	leadA, leadB      *ring // Spans computed on recent bars, waiting to be plotted
+	line              IchimokuLines
+}
+
+// IchimokuLines are the Ichimoku values at one bar
+type IchimokuLines struct {
+	Conversion float64 // Tenkan-sen
+	Base       float64 // Kijun-sen
+	SpanA      float64 // Senkou span A of the cloud at this bar
+	SpanB      float64 // Senkou span B of the cloud at this bar
+	LeadA      float64 // Span A computed at this bar, plotted ahead
+	LeadB      float64
+	Lagging    float64 // Chikou span: this bar's close, plotted behind
+}
+
+// NewIchimoku builds the cloud from its conversion, base and span B
+// windows, conventionally (9, 26, 52) with the base window as displacement
+func NewIchimoku(conversion, base, spanB, displacement int) *Ichimoku {
+	displacement = period(displacement)
+	return &Ichimoku{
+		conv:         newMidpoint(conversion),
+		base:         newMidpoint(base),
+		spanB:        newMidpoint(spanB),
+		displacement: displacement,
+		leadA:        newRing(displacement + 1),
+		leadB:        newRing(displacement + 1),
+	}
+}
+
+// WarmUp is the bars until the cloud at the latest bar has a value
+func (ich *Ichimoku) WarmUp() int {
+	return max(ich.conv.n, ich.base.n, ich.spanB.n) + ich.displacement
+}
+
+func (ich *Ichimoku) Ready() bool {
+	return !math.IsNaN(ich.line.SpanA) && !math.IsNaN(ich.line.SpanB)
+}
+
+func (ich *Ichimoku) Update(bar marketdata.Bar) {
+	l := IchimokuLines{
+		Conversion: ich.conv.push(bar),
+		Base:       ich.base.push(bar),
+		LeadB:      ich.spanB.push(bar),
+		Lagging:    bar.Close,
+	}
+	l.LeadA = (l.Conversion + l.Base) / 2
+	ich.leadA.push(l.LeadA)
+	ich.leadB.push(l.LeadB)
+	l.SpanA = ich.leadA.ago(ich.displacement)
+	l.SpanB = ich.leadB.ago(ich.displacement)
+	ich.line = l
+}
+
+// Lines returns the latest values, NaN where still warming up
+func (ich *Ichimoku) Lines() IchimokuLines { return ich.line }
+
+// midpoint is the middle of the high-low range of the latest n bars
+type midpoint struct {
+	n         int
+	high, low *extreme
+}
+
+func newMidpoint(n int) *midpoint {
+	return &midpoint{n: period(n), high: newHighest(n), low: newLowest(n)}
+}
+
+func (m *midpoint) push(bar marketdata.Bar) float64 {
+	return (m.high.push(bar.High) + m.low.push(bar.Low)) / 2
+}
+
+// PSAR is Wilder's parabolic stop and reverse. The stop trails price,
+// accelerating towards it as the trend makes new extremes, and flips side
+// when price crosses it.
+type PSAR struct {
+	step, maxStep float64
+	bars          int
+	long          bool
+	sar, ep, af   float64
+	prev, prev2   marketdata.Bar
+	value         float64
+}
+
+// NewPSAR builds the SAR with its acceleration step and cap,
+// conventionally (0.02, 0.2)
+func NewPSAR(step, maxStep float64) *PSAR {
+	return &PSAR{step: step, maxStep: maxStep, value: math.NaN()}
+}
+
+func (p *PSAR) WarmUp() int { return 2 }
+func (p *PSAR) Ready() bool { return !math.IsNaN(p.value) }
+
+func (p *PSAR) Update(bar marketdata.Bar) {
+	p.bars++
+	defer func() { p.prev2, p.prev = p.prev, bar }()
+	switch p.bars {
+	case 1:
+		return
+	case 2:
+		// The first trend follows the first close-to-close move
+		p.long = bar.Close >= p.prev.Close
+		p.af = p.step
+		if p.long {
+			p.sar, p.ep = math.Min(p.prev.Low, bar.Low), math.Max(p.prev.High, bar.High)
+		} else {
+			p.sar, p.ep = math.Max(p.prev.High, bar.High), math.Min(p.prev.Low, bar.Low)
+		}
+		p.value = p.sar
+		return
+	}
+
+	sar := p.sar + p.af*(p.ep-p.sar)
+	// The stop never moves inside the last two bars' range
+	if p.long {
+		sar = math.Min(sar, math.Min(p.prev.Low, p.prev2.Low))
+		if bar.Low < sar {
+			// Reverse to the old extreme, kept outside this bar and the last
+			p.long, sar, p.ep, p.af = false, math.Max(p.ep, math.Max(p.prev.High, bar.High)), bar.Low, p.step
+		} else if bar.High > p.ep {
+			p.ep, p.af = bar.High, math.Min(p.af+p.step, p.maxStep)
+		}
+	} else {
+		sar = math.Max(sar, math.Max(p.prev.High, p.prev2.High))
+		if bar.High > sar {
+			p.long, sar, p.ep, p.af = true, math.Min(p.ep, math.Min(p.prev.Low, bar.Low)), bar.High, p.step
+		} else if bar.Low < p.ep {
+			p.ep, p.af = bar.Low, math.Min(p.af+p.step, p.maxStep)
+		}
+	}
+	p.sar = sar
+	p.value = sar
+}
+
+// Value is the stop for the latest bar, NaN on the first bar
+func (p *PSAR) Value() float64 { return p.value }
+
+// Long reports whether the SAR is below price, in an uptrend
+func (p *PSAR) Long() bool { return p.long }
@@ -0,0 +1,24 @@
+package indicators
+
+import "testing"
+
+// Worked by hand from Wilder's rules over the reference bars, taking the
+// first trend from the first close-to-close move
+func TestPSAR(t *testing.T) {
+	want := []float64{nan, 47.7900, 47.7900, 47.8344, 47.8770, 47.9179, 47.9859, 48.0830, 48.2097, 48.4149,
+		48.6634, 48.8772, 48.9000, 48.9000, 49.0806, 49.2600, 49.2600, 50.6500, 50.6500, 50.5832,
+		50.5191, 50.4575, 50.3984, 50.2593, 50.0498, 49.1998, 48.4348, 41.5500, 41.6868, 41.9657}
+	// The trend flips at bars 17 and 27
+	wantLong := func(i int) bool { return i < 17 || i >= 27 }
+
+	p := NewPSAR(0.02, 0.2)
+	var got []float64
+	for i, bar := range refBars() {
+		p.Update(bar)
+		got = append(got, p.Value())
+		if i > 0 && p.Long() != wantLong(i) {
+			t.Errorf("long[%d] = %v, want %v", i, p.Long(), wantLong(i))
+		}
+	}
+	expectSeries(t, "sar", got, want, 1e-4)
+}
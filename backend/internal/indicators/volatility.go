@@ -0,0 +1,127 @@
+package indicators
+
+import (
+	"math"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// ATR is Wilder's average true range. The first bar's true range is its
+// high minus low.
+type ATR struct {
+	rma       *EMA
+	prevClose float64
+}
+
+// NewATR averages the true range over n bars, conventionally 14
+func NewATR(n int) *ATR {
+	return &ATR{rma: NewRMA(n), prevClose: math.NaN()}
+}
+
+func (a *ATR) WarmUp() int { return a.rma.n }
+func (a *ATR) Ready() bool { return a.rma.Ready() }
+
+func (a *ATR) Update(bar marketdata.Bar) {
+	a.rma.Add(trueRange(bar, a.prevClose))
+	a.prevClose = bar.Close
+}
+
+// Value is the ATR, NaN while warming up
+func (a *ATR) Value() float64 { return a.rma.Value() }
+
+// Band is an indicator drawn as a middle line between an upper and a lower
+// one
+type Band struct {
+	Upper, Middle, Lower float64
+}
+
+func nanBand() Band {
+	return Band{math.NaN(), math.NaN(), math.NaN()}
+}
+
+// Bollinger bands sit a multiple of the standard deviation either side of
+// a simple moving average
+type Bollinger struct {
+	sma  *SMA
+	sd   *StdDev
+	mult float64
+	band Band
+}
+
+// NewBollinger builds bands over n bars, mult deviations wide,
+// conventionally (20, 2)
+func NewBollinger(n int, mult float64) *Bollinger {
+	return &Bollinger{sma: NewSMA(n), sd: NewStdDev(n), mult: mult, band: nanBand()}
+}
+
+func (b *Bollinger) WarmUp() int               { return b.sma.n }
+func (b *Bollinger) Ready() bool               { return b.sma.Ready() }
+func (b *Bollinger) Update(bar marketdata.Bar) { b.Add(bar.Close) }
+
+// Add takes the next value and returns the middle band
+func (b *Bollinger) Add(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	mid, sd := b.sma.Add(v), b.sd.Add(v)
+	b.band = Band{mid + b.mult*sd, mid, mid - b.mult*sd}
+	return mid
+}
+
+// Value is the middle band
+func (b *Bollinger) Value() float64 { return b.band.Middle }
+
+// Band returns the bands, NaN while warming up
+func (b *Bollinger) Band() Band { return b.band }
+
+// Keltner channels sit a multiple of the ATR either side of an EMA of the
+// close
+type Keltner struct {
+	ema  *EMA
+	atr  *ATR
+	mult float64
+	band Band
+}
+
+// NewKeltner builds channels around an n-bar EMA, mult ATRs of atrN bars
+// wide, conventionally (20, 10, 2)
+func NewKeltner(n, atrN int, mult float64) *Keltner {
+	return &Keltner{ema: NewEMA(n), atr: NewATR(atrN), mult: mult, band: nanBand()}
+}
+
+func (k *Keltner) WarmUp() int { return max(k.ema.n, k.atr.WarmUp()) }
+func (k *Keltner) Ready() bool { return k.ema.Ready() && k.atr.Ready() }
+
+func (k *Keltner) Update(bar marketdata.Bar) {
+	mid := k.ema.Add(bar.Close)
+	k.atr.Update(bar)
+	width := k.mult * k.atr.Value()
+	k.band = Band{mid + width, mid, mid - width}
+}
+
+// Band returns the channels, NaN while warming up
+func (k *Keltner) Band() Band { return k.band }
+
+// Donchian channels are the highest high and lowest low of the latest n
+// bars, with their midpoint
+type Donchian struct {
+	n         int
+	high, low *extreme
+	band      Band
+}
+
+// NewDonchian tracks the range of the latest n bars
+func NewDonchian(n int) *Donchian {
+	return &Donchian{n: period(n), high: newHighest(n), low: newLowest(n), band: nanBand()}
+}
+
+func (d *Donchian) WarmUp() int { return d.n }
+func (d *Donchian) Ready() bool { return !math.IsNaN(d.band.Middle) }
+
+func (d *Donchian) Update(bar marketdata.Bar) {
+	hi, lo := d.high.push(bar.High), d.low.push(bar.Low)
+	d.band = Band{hi, (hi + lo) / 2, lo}
+}
+
+// Band returns the channels, NaN while warming up
+func (d *Donchian) Band() Band { return d.band }
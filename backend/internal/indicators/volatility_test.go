@@ -0,0 +1,44 @@
+package indicators
+
+import "testing"
+
+// Worked from the textbook definitions over the reference bars,
+// independently of this package
+func TestATR(t *testing.T) {
+	want := []float64{nan, nan, nan, nan, 0.6160, 0.5748, 0.5118, 0.5075, 0.5260, 0.4848,
+		0.5738, 0.6111, 0.5788, 0.5551, 0.6641, 0.6273, 0.5718, 0.7014, 0.6912, 0.7449,
+		0.8139, 0.8371, 1.0397, 1.0038, 2.1570, 2.2296, 2.2617, 2.0474, 1.8259, 1.6727}
+
+	a := NewATR(5)
+	var got []float64
+	for _, bar := range refBars() {
+		a.Update(bar)
+		got = append(got, a.Value())
+	}
+	expectSeries(t, "atr", got, want, 1e-4)
+}
+
+func TestBollinger(t *testing.T) {
+	want := struct{ upper, middle, lower []float64 }{
+		upper: []float64{nan, nan, nan, nan, 49.0109, 49.0520, 49.1917, 49.4511, 50.0014, 50.3877,
+			50.3619, 50.2712, 50.2372, 50.2991, 50.4408, 50.7582, 50.7625, 50.9690, 51.0356, 51.0018,
+			50.7114, 50.3984, 50.6823, 50.8092, 50.7363, 50.8354, 49.5300, 49.1563, 49.4471, 49.6397},
+		middle: []float64{nan, nan, nan, nan, 48.5780, 48.7520, 48.8440, 48.9580, 49.2140, 49.4920,
+			49.5920, 49.6780, 49.7640, 49.7880, 49.8240, 50.0220, 50.2040, 50.1220, 49.9900, 49.9740,
+			49.7180, 49.6220, 49.4400, 49.2020, 48.4700, 47.7040, 47.2720, 47.1300, 47.2180, 47.4740},
+		lower: []float64{nan, nan, nan, nan, 48.1451, 48.4520, 48.4963, 48.4649, 48.4266, 48.5963,
+			48.8221, 49.0848, 49.2908, 49.2769, 49.2072, 49.2858, 49.6455, 49.2750, 48.9444, 48.9462,
+			48.7246, 48.8456, 48.1977, 47.5948, 46.2037, 44.5726, 45.0140, 45.1037, 44.9889, 45.3083},
+	}
+
+	b := NewBollinger(5, 2)
+	var upper, middle, lower []float64
+	for _, bar := range refBars() {
+		b.Update(bar)
+		band := b.Band()
+		upper, middle, lower = append(upper, band.Upper), append(middle, band.Middle), append(lower, band.Lower)
+	}
+	expectSeries(t, "upper", upper, want.upper, 1e-4)
+	expectSeries(t, "middle", middle, want.middle, 1e-4)
+	expectSeries(t, "lower", lower, want.lower, 1e-4)
+}
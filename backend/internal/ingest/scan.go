@@ -0,0 +1,133 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PervFVCK/strategyforge/internal/csvdialect"
+	"github.com/PervFVCK/strategyforge/internal/dukascopy"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/mt4"
+)
+
+// Sink receives parsed rows, typically a price store writer
+type Sink interface {
+	WriteTick(marketdata.Tick) error
+	WriteBar(marketdata.Bar) error
+}
+
+// Scan parses the file at path and hands every row to sink in file order.
+// It expects a file that already passed Validate with the same options.
+func Scan(path string, format Format, opts Options, sink Sink) error {
+	switch format {
+	case FormatCSV:
+		return scanCSV(path, opts, sink)
+	case FormatHST:
+		return scanHST(path, sink)
+	case FormatBI5:
+		return scanBI5(path, opts, sink)
+	default:
+		return errors.New("unsupported file format")
+	}
+}
+
+func scanCSV(path string, opts Options, sink Sink) error {
+	if opts.Dialect == nil {
+		return errors.New("a confirmed layout is required")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	reader, err := csvdialect.NewReader(f, *opts.Dialect)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if record.Tick != nil {
+			err = sink.WriteTick(*record.Tick)
+		} else {
+			err = sink.WriteBar(*record.Bar)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func scanHST(path string, sink Sink) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	reader, err := mt4.NewReader(f)
+	if err != nil {
+		return err
+	}
+	for {
+		bar, err := reader.Next()
+		if err == io.EOF || errors.Is(err, mt4.ErrTruncated) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sink.WriteBar(bar); err != nil {
+			return err
+		}
+	}
+}
+
+func scanBI5(path string, opts Options, sink Sink) error {
+	ticks, err := dukascopy.DecodeFile(path, opts.Hour, dukascopy.PointScale(opts.Symbol))
+	if err != nil {
+		return err
+	}
+	for _, tick := range ticks {
+		if err := sink.WriteTick(tick); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// multiSink duplicates rows to several sinks
+type multiSink []Sink
+
+// MultiSink returns a sink that writes every row to each of sinks in turn,
+// stopping at the first error
+func MultiSink(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) WriteTick(t marketdata.Tick) error {
+	for _, s := range m {
+		if err := s.WriteTick(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiSink) WriteBar(b marketdata.Bar) error {
+	for _, s := range m {
+		if err := s.WriteBar(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
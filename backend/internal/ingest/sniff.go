@@ -0,0 +1,105 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PervFVCK/strategyforge/internal/mt4"
+)
+
+// Format identifies the on-disk layout of an uploaded price file
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatCSV     Format = "csv"
+	FormatHST     Format = "hst"
+	FormatBI5     Format = "bi5"
+)
+
+// SniffSize is the number of leading bytes Sniff needs to make a decision
+const SniffSize = 4096
+
+// Sniff guesses the format of a file from its leading bytes, using the
+// file name only to break ties. Content always wins over the extension.
+func Sniff(head []byte, filename string) Format {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+
+	if looksLikeHST(head) {
+		return FormatHST
+	}
+
+	if looksLikeText(head) {
+		return FormatCSV
+	}
+
+	// LZMA streams have no magic number, so only trust the header check
+	// when the name agrees or the upload is a generic binary blob
+	if (ext == "bi5" || ext == "bin") && looksLikeLZMA(head) {
+		return FormatBI5
+	}
+
+	return FormatUnknown
+}
+
+// looksLikeHST checks for an MT4 v400/v401 history header
+func looksLikeHST(head []byte) bool {
+	if len(head) < mt4.HeaderSize {
+		return false
+	}
+	version := binary.LittleEndian.Uint32(head[0:4])
+	if version != mt4.Version400 && version != mt4.Version401 {
+		return false
+	}
+	// Symbol field (12 bytes at offset 68) must be printable ASCII
+	symbol := bytes.TrimRight(head[68:80], "\x00")
+	if len(symbol) == 0 {
+		return false
+	}
+	for _, b := range symbol {
+		if b < 0x20 || b > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// looksLikeLZMA checks for a plausible LZMA "alone" header
+func looksLikeLZMA(head []byte) bool {
+	if len(head) < 13 {
+		return false
+	}
+	// Properties byte encodes lc/lp/pb and must be below 9*5*5
+	if head[0] >= 225 {
+		return false
+	}
+	dictSize := binary.LittleEndian.Uint32(head[1:5])
+	return dictSize >= 1<<12 && dictSize <= 1<<30
+}
+
+// looksLikeText reports whether head is line-oriented printable text
+func looksLikeText(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+	// A multi-byte rune may be cut at the sniff boundary
+	sample := head
+	for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+		sample = sample[:len(sample)-1]
+	}
+	if !utf8.Valid(sample) {
+		return false
+	}
+	for _, b := range sample {
+		if b == 0 {
+			return false
+		}
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' {
+			return false
+		}
+	}
+	return bytes.IndexByte(sample, '\n') >= 0 || len(head) < SniffSize
+}
@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrTooLarge is returned when an upload exceeds the configured size limit
+var ErrTooLarge = errors.New("file exceeds maximum upload size")
+
+// StoredFile describes a file written to disk by WriteFile
+type StoredFile struct {
+	Path     string
+	Size     int64
+	Checksum string
+	Head     []byte // First SniffSize bytes, for format detection
+}
+
+// headCapture keeps a copy of the first SniffSize bytes written through it
+type headCapture struct {
+	buf []byte
+}
+
+func (h *headCapture) Write(p []byte) (int, error) {
+	if room := SniffSize - len(h.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		h.buf = append(h.buf, p[:room]...)
+	}
+	return len(p), nil
+}
+
+// WriteFile streams r to path without buffering it in memory, hashing it on
+// the way through. At most limit bytes are accepted; the partial file is
+// removed on any error so a failed upload never leaves debris behind.
+func WriteFile(r io.Reader, path string, limit int64) (*StoredFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmpPath)
+	}
+
+	hash := sha256.New()
+	head := &headCapture{}
+
+	// Read one byte past the limit so oversized uploads can be detected
+	written, err := io.Copy(io.MultiWriter(tmp, hash, head), io.LimitReader(r, limit+1))
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	if written > limit {
+		cleanup()
+		return nil, ErrTooLarge
+	}
+	if written == 0 {
+		cleanup()
+		return nil, ErrEmptyFile
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return &StoredFile{
+		Path:     path,
+		Size:     written,
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+		Head:     head.buf,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package ingest
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/PervFVCK/strategyforge/internal/instruments"
+)
+
+// InferSymbol extracts a symbol such as EURUSD from a file name like
+// "DAT_ASCII_EURUSD_M1_2023.csv" or "GBPJPY60.hst". Only symbols the
+// instrument registry can trade are recognized. Returns "" if none found.
+func InferSymbol(filename string) string {
+	name := strings.ToUpper(strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
+
+	for i := 0; i+6 <= len(name); i++ {
+		symbol := name[i : i+6]
+		if symbol[:3] == symbol[3:] {
+			continue
+		}
+		if _, err := instruments.Lookup(symbol); err == nil {
+			return symbol
+		}
+	}
+	return ""
+}
+
+// NormalizeSymbol uppercases a user-supplied symbol and strips separators
+// such as "EUR/USD" or "eur-usd"
+func NormalizeSymbol(symbol string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return -1
+	}, symbol)
+}
@@ -0,0 +1,189 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/csvdialect"
+	"github.com/PervFVCK/strategyforge/internal/dukascopy"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/mt4"
+)
+
+// Report summarizes what validation learned about a stored file
+type Report struct {
+	Format    Format     `json:"format"`
+	Kind      string     `json:"kind"`
+	Symbol    string     `json:"symbol,omitempty"`
+	Timeframe string     `json:"timeframe,omitempty"`
+	Digits    int        `json:"digits,omitempty"` // Price precision, when the format records it
+	Rows      int64      `json:"rows"`
+	Start     *time.Time `json:"start,omitempty"`
+	End       *time.Time `json:"end,omitempty"`
+	Warnings  []string   `json:"warnings,omitempty"`
+
+	// Text files only: the detected layout and the first parsed rows, so the
+	// client can confirm the guess before the dataset is used
+	Dialect *csvdialect.Dialect `json:"dialect,omitempty"`
+	Preview []csvdialect.Record `json:"preview,omitempty"`
+}
+
+// previewRows is how many parsed rows a Report carries
+const previewRows = 5
+
+// Options carries hints that cannot be read from the file itself
+type Options struct {
+	Symbol  string              // Used to pick the price scale of bi5 files
+	Hour    time.Time           // Start hour of a bi5 file; ticks are stored relative to it
+	Dialect *csvdialect.Dialect // Confirmed CSV layout; detected when nil
+}
+
+// ErrEmptyFile is returned when a file holds no data rows
+var ErrEmptyFile = errors.New("file contains no data rows")
+
+// Validate checks that the file at path is well formed for its format
+func Validate(path string, format Format, opts Options) (*Report, error) {
+	switch format {
+	case FormatCSV:
+		return validateCSV(path, opts.Dialect)
+	case FormatHST:
+		return validateHST(path)
+	case FormatBI5:
+		return validateBI5(path, opts)
+	default:
+		return nil, errors.New("unsupported file format")
+	}
+}
+
+func validateCSV(path string, dialect *csvdialect.Dialect) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if dialect == nil {
+		if dialect, err = csvdialect.DetectReader(f); err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	reader, err := csvdialect.NewReader(f, *dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Format:   FormatCSV,
+		Kind:     dialect.Kind,
+		Dialect:  dialect,
+		Warnings: dialect.Notes,
+	}
+
+	var first, last time.Time
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		t := record.Time()
+		if report.Rows == 0 {
+			first = t
+		}
+		last = t
+		if len(report.Preview) < previewRows {
+			report.Preview = append(report.Preview, record)
+		}
+		report.Rows++
+	}
+
+	if report.Rows == 0 {
+		return nil, ErrEmptyFile
+	}
+	report.Start, report.End = &first, &last
+
+	return report, nil
+}
+
+func validateHST(path string) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	reader, err := mt4.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	header := reader.Header()
+
+	report := &Report{
+		Format:    FormatHST,
+		Kind:      marketdata.KindBar,
+		Symbol:    header.Symbol,
+		Timeframe: mt4.PeriodName(header.Period),
+		Digits:    header.Digits,
+	}
+
+	var first, last time.Time
+	for {
+		bar, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, mt4.ErrTruncated) {
+			report.Warnings = append(report.Warnings, "trailing partial record ignored")
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", report.Rows+1, err)
+		}
+		if !bar.Valid() {
+			return nil, fmt.Errorf("record %d: inconsistent OHLC prices", report.Rows+1)
+		}
+		if report.Rows == 0 {
+			first = bar.Time
+		}
+		last = bar.Time
+		report.Rows++
+	}
+
+	if report.Rows == 0 {
+		return nil, ErrEmptyFile
+	}
+	report.Start, report.End = &first, &last
+
+	return report, nil
+}
+
+func validateBI5(path string, opts Options) (*Report, error) {
+	ticks, err := dukascopy.DecodeFile(path, opts.Hour, dukascopy.PointScale(opts.Symbol))
+	if err != nil {
+		return nil, err
+	}
+	if len(ticks) == 0 {
+		return nil, ErrEmptyFile
+	}
+
+	report := &Report{
+		Format: FormatBI5,
+		Kind:   marketdata.KindTick,
+		Digits: int(math.Round(math.Log10(dukascopy.PointScale(opts.Symbol)))),
+		Rows:   int64(len(ticks)),
+	}
+	start, end := ticks[0].Time, ticks[len(ticks)-1].Time
+	report.Start, report.End = &start, &end
+	return report, nil
+}
@@ -0,0 +1,134 @@
+// Package instruments describes tradable symbols: their currencies, price
+// increments and lot sizes.
+package instruments
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Instrument types
+const (
+	TypeForex = "forex"
+	TypeMetal = "metal"
+)
+
+// Instrument is the contract specification of a symbol
+type Instrument struct {
+	Symbol       string  `json:"symbol"`
+	Type         string  `json:"type"`
+	Base         string  `json:"base"`         // Currency or asset bought by a buy order
+	Quote        string  `json:"quote"`        // Currency prices and profits are in
+	Digits       int     `json:"digits"`       // Quoted decimal places
+	PipSize      float64 `json:"pipSize"`      // Price distance of one pip
+	ContractSize float64 `json:"contractSize"` // Units of Base per lot
+	MinLot       float64 `json:"minLot"`
+	LotStep      float64 `json:"lotStep"`
+	MaxLot       float64 `json:"maxLot"`
+}
+
+// Currencies that can be traded or held as an account currency
+var Currencies = []string{
+	"AUD", "CAD", "CHF", "CNH", "EUR", "GBP", "HKD", "HUF", "JPY", "MXN",
+	"NGN", "NOK", "NZD", "SEK", "SGD", "TRY", "USD", "ZAR",
+}
+
+// pipDigits lists quote currencies whose pip is not the fourth decimal.
+// Each is one of the Currencies.
+var pipDigits = map[string]int{
+	"JPY": 2,
+	"HUF": 2,
+	"NGN": 2,
+}
+
+var registry = map[string]Instrument{}
+
+func init() {
+	majors := []string{"EUR", "GBP", "AUD", "NZD", "USD", "CAD", "CHF", "JPY"}
+	// Conventional ordering: a pair is quoted with the earlier currency first
+	for i, base := range majors {
+		for _, quote := range majors[i+1:] {
+			register(forex(base, quote))
+		}
+	}
+	for _, quote := range []string{"NGN", "ZAR", "MXN", "TRY", "SGD", "HKD", "NOK", "SEK", "CNH"} {
+		register(forex("USD", quote))
+	}
+	for _, quote := range []string{"NOK", "SEK", "TRY", "ZAR"} {
+		register(forex("EUR", quote))
+	}
+	register(Instrument{
+		Symbol: "XAUUSD", Type: TypeMetal, Base: "XAU", Quote: "USD",
+		Digits: 2, PipSize: 0.1, ContractSize: 100, MinLot: 0.01, LotStep: 0.01, MaxLot: 50,
+	})
+	register(Instrument{
+		Symbol: "XAGUSD", Type: TypeMetal, Base: "XAG", Quote: "USD",
+		Digits: 3, PipSize: 0.01, ContractSize: 5000, MinLot: 0.01, LotStep: 0.01, MaxLot: 50,
+	})
+}
+
+func register(inst Instrument) {
+	registry[inst.Symbol] = inst
+}
+
+// forex builds the standard specification of a currency pair
+func forex(base, quote string) Instrument {
+	pip, ok := pipDigits[quote]
+	if !ok {
+		pip = 4
+	}
+	return Instrument{
+		Symbol:       base + quote,
+		Type:         TypeForex,
+		Base:         base,
+		Quote:        quote,
+		Digits:       pip + 1,
+		PipSize:      pow10(-pip),
+		ContractSize: 100000,
+		MinLot:       0.01,
+		LotStep:      0.01,
+		MaxLot:       100,
+	}
+}
+
+func pow10(n int) float64 {
+	v := 1.0
+	for ; n < 0; n++ {
+		v /= 10
+	}
+	return v
+}
+
+// Lookup returns the specification of a symbol. Unlisted six-letter symbols
+// made of known currencies get standard forex terms.
+func Lookup(symbol string) (Instrument, error) {
+	symbol = strings.ToUpper(symbol)
+	if inst, ok := registry[symbol]; ok {
+		return inst, nil
+	}
+	if len(symbol) == 6 && IsCurrency(symbol[:3]) && IsCurrency(symbol[3:]) {
+		return forex(symbol[:3], symbol[3:]), nil
+	}
+	return Instrument{}, fmt.Errorf("unknown instrument %q", symbol)
+}
+
+// All lists the registered instruments by symbol
+func All() []Instrument {
+	list := make([]Instrument, 0, len(registry))
+	for _, inst := range registry {
+		list = append(list, inst)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Symbol < list[j].Symbol })
+	return list
+}
+
+// IsCurrency reports whether code is a supported currency
+func IsCurrency(code string) bool {
+	for _, c := range Currencies {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
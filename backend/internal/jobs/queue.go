@@ -0,0 +1,395 @@
+// Package jobs runs long tasks, such as backtests, on a bounded pool of
+// background workers. Jobs are stored in the database, which serves as the
+// queue, so queued and interrupted jobs carry on after a restart.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+	"gorm.io/gorm"
+)
+
+// MaxUnfinishedPerUser bounds the queued and running jobs one user may have
+const MaxUnfinishedPerUser = 10
+
+// unfinishedStatuses are those of jobs that count against
+// MaxUnfinishedPerUser and can still be cancelled
+var unfinishedStatuses = []string{models.JobStatusQueued, models.JobStatusRunning}
+
+// How often a running job's progress reaches subscribers and the database
+const (
+	publishInterval = 250 * time.Millisecond
+	saveInterval    = 2 * time.Second
+)
+
+var (
+	// ErrJobNotFound is returned when a job does not exist or belongs to another user
+	ErrJobNotFound = errors.New("job not found")
+	// ErrQueueFull is returned when a user already has the most unfinished jobs allowed
+	ErrQueueFull = fmt.Errorf("at most %d jobs may be queued or running at once", MaxUnfinishedPerUser)
+	// ErrJobFinished is returned when cancelling a job that has already ended
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+// Reporter records how far a job has got, as a fraction from 0 to 1, with
+// an optional message
+type Reporter func(progress float64, message string)
+
+// Runner executes one kind of job and returns the ID of the result it
+// stored. It must return soon after ctx is cancelled.
+type Runner func(ctx context.Context, job *models.Job, report Reporter) (string, error)
+
+// Queue hands stored jobs to a fixed number of workers
+type Queue struct {
+	workers int
+	runners map[string]Runner
+	wake    chan struct{}
+
+	mu      sync.Mutex
+	running map[string]*run
+	subs    map[string]map[chan models.Job]struct{}
+}
+
+// run is the live state of a job a worker is executing
+type run struct {
+	job       models.Job
+	cancel    context.CancelFunc
+	cancelled bool // By its user
+	published time.Time
+	saved     time.Time
+}
+
+// NewQueue returns a queue with the given number of workers. Register its
+// runners before calling Start.
+func NewQueue(workers int) *Queue {
+	workers = max(workers, 1)
+	return &Queue{
+		workers: workers,
+		runners: make(map[string]Runner),
+		wake:    make(chan struct{}, workers),
+		running: make(map[string]*run),
+		subs:    make(map[string]map[chan models.Job]struct{}),
+	}
+}
+
+// Register sets the runner of a kind of job
+func (q *Queue) Register(kind string, runner Runner) {
+	q.runners[kind] = runner
+}
+
+// Start requeues jobs that were running when the server last stopped and
+// starts the workers
+func (q *Queue) Start() error {
+	err := database.DB.Model(&models.Job{}).Where("status = ?", models.JobStatusRunning).
+		Updates(map[string]any{"status": models.JobStatusQueued, "message": "Resumed after a restart"}).Error
+	if err != nil {
+		return fmt.Errorf("failed to requeue jobs: %w", err)
+	}
+	for range q.workers {
+		go q.work()
+		q.signal()
+	}
+	return nil
+}
+
+// Enqueue stores a job of the given kind for request and wakes a worker
+func (q *Queue) Enqueue(userID, kind string, request any) (*models.Job, error) {
+	if q.runners[kind] == nil {
+		return nil, fmt.Errorf("unknown job kind %q", kind)
+	}
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	job := &models.Job{
+		UserID:  userID,
+		Kind:    kind,
+		Status:  models.JobStatusQueued,
+		Request: string(encoded),
+	}
+	// The count and the insert share a transaction so concurrent requests
+	// cannot both take the last free place. Holding the lock as well queues
+	// this server's own requests rather than failing them on a busy database.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var unfinished int64
+		err := tx.Model(&models.Job{}).
+			Where("user_id = ? AND status IN ?", userID, unfinishedStatuses).
+			Count(&unfinished).Error
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if unfinished >= MaxUnfinishedPerUser {
+			return ErrQueueFull
+		}
+		if err := tx.Create(job).Error; err != nil {
+			return fmt.Errorf("failed to save job: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	q.signal()
+	return job, nil
+}
+
+// Get returns one of the user's jobs, with the latest progress of a running one
+func (q *Queue) Get(userID, id string) (*models.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.get(userID, id)
+}
+
+func (q *Queue) get(userID, id string) (*models.Job, error) {
+	if r := q.running[id]; r != nil {
+		if r.job.UserID != userID {
+			return nil, ErrJobNotFound
+		}
+		job := r.job
+		return &job, nil
+	}
+	var job models.Job
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &job, nil
+}
+
+// List returns the user's most recent jobs, newest first
+func (q *Queue) List(userID string, limit int) ([]models.Job, error) {
+	var list []models.Job
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&list).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range list {
+		if r := q.running[list[i].ID]; r != nil {
+			list[i] = r.job
+		}
+	}
+	return list, nil
+}
+
+// Cancel stops one of the user's jobs. A queued job is cancelled at once; a
+// running one is cancelled when its runner returns.
+func (q *Queue) Cancel(userID, id string) (*models.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, err := q.get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return nil, ErrJobFinished
+	}
+
+	if r := q.running[id]; r != nil {
+		r.cancelled = true
+		r.cancel()
+		r.job.Message = "Cancelling"
+		q.publish(r.job)
+		job := r.job
+		return &job, nil
+	}
+	// The update only applies while the job is still unfinished, so one a
+	// worker elsewhere has just ended keeps its outcome
+	now := time.Now()
+	result := database.DB.Model(&models.Job{}).
+		Where("id = ? AND status IN ?", id, unfinishedStatuses).
+		Updates(map[string]any{"status": models.JobStatusCancelled, "finished_at": now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to save job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrJobFinished
+	}
+	job.Status = models.JobStatusCancelled
+	job.FinishedAt = &now
+	q.publish(*job)
+	return job, nil
+}
+
+// Subscribe returns one of the user's jobs and a channel of its later
+// states. A slow subscriber sees only the latest state. Call the returned
+// function to unsubscribe.
+func (q *Queue) Subscribe(userID, id string) (*models.Job, <-chan models.Job, func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, err := q.get(userID, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	updates := make(chan models.Job, 1)
+	if q.subs[id] == nil {
+		q.subs[id] = make(map[chan models.Job]struct{})
+	}
+	q.subs[id][updates] = struct{}{}
+	unsubscribe := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		delete(q.subs[id], updates)
+		if len(q.subs[id]) == 0 {
+			delete(q.subs, id)
+		}
+	}
+	return job, updates, unsubscribe, nil
+}
+
+// publish passes a job's state to its subscribers, replacing any state
+// they have not read yet. The caller holds q.mu.
+func (q *Queue) publish(job models.Job) {
+	for updates := range q.subs[job.ID] {
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- job
+	}
+}
+
+// signal wakes a worker without waiting for one to be idle
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work runs jobs until there are none queued, then waits to be woken
+func (q *Queue) work() {
+	for {
+		job, ctx, err := q.claim()
+		if err != nil {
+			log.Printf("⚠️  Failed to claim job: %v", err)
+		}
+		if job == nil {
+			<-q.wake
+			continue
+		}
+		q.execute(ctx, job)
+	}
+}
+
+// claim marks the oldest queued job as running
+func (q *Queue) claim() (*models.Job, context.Context, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var job models.Job
+	err := database.DB.Where("status = ?", models.JobStatusQueued).Order("created_at ASC").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	job.Status = models.JobStatusRunning
+	job.StartedAt = &now
+	job.Attempts++
+	job.Progress = 0 // A resumed job starts over
+	if err := database.DB.Save(&job).Error; err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q.running[job.ID] = &run{job: job, cancel: cancel, saved: now}
+	q.publish(job)
+	return &job, ctx, nil
+}
+
+// execute runs a claimed job and stores how it ended
+func (q *Queue) execute(ctx context.Context, job *models.Job) {
+	resultID, err := q.invoke(ctx, job)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r := q.running[job.ID]
+	delete(q.running, job.ID)
+	r.cancel()
+
+	final := r.job
+	now := time.Now()
+	final.FinishedAt = &now
+	switch {
+	case r.cancelled:
+		final.Status = models.JobStatusCancelled
+		final.Message = ""
+	case err != nil:
+		final.Status = models.JobStatusFailed
+		final.Error = err.Error()
+	default:
+		final.Status = models.JobStatusCompleted
+		final.Progress = 100
+		final.Message = ""
+		final.ResultID = resultID
+	}
+	if err := database.DB.Save(&final).Error; err != nil {
+		log.Printf("⚠️  Failed to save job %s: %v", final.ID, err)
+	}
+	q.publish(final)
+}
+
+// invoke calls the job's runner, turning a panic into an error so one bad
+// run cannot take down the server
+func (q *Queue) invoke(ctx context.Context, job *models.Job) (resultID string, err error) {
+	runner := q.runners[job.Kind]
+	if runner == nil {
+		return "", fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("⚠️  Job %s panicked: %v", job.ID, p)
+			err = errors.New("internal error")
+		}
+	}()
+	return runner(ctx, job, func(progress float64, message string) {
+		q.report(job.ID, progress, message)
+	})
+}
+
+// report updates a running job's progress, passing it on at most every
+// publishInterval and storing it at most every saveInterval
+func (q *Queue) report(id string, progress float64, message string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r := q.running[id]
+	if r == nil || r.cancelled {
+		return
+	}
+	percent := math.Round(min(max(progress, 0), 1)*1000) / 10
+	changed := message != "" && message != r.job.Message
+	r.job.Progress = max(r.job.Progress, percent)
+	if message != "" {
+		r.job.Message = message
+	}
+
+	now := time.Now()
+	if changed || now.Sub(r.published) >= publishInterval {
+		r.published = now
+		q.publish(r.job)
+	}
+	if changed || now.Sub(r.saved) >= saveInterval {
+		r.saved = now
+		err := database.DB.Model(&models.Job{}).Where("id = ?", id).
+			Updates(map[string]any{"progress": r.job.Progress, "message": r.job.Message}).Error
+		if err != nil {
+			log.Printf("⚠️  Failed to save job %s: %v", id, err)
+		}
+	}
+}
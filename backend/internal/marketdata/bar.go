@@ -0,0 +1,28 @@
+package marketdata
+
+import "time"
+
+// Bar is an OHLC candle, normalized across data sources. Time is the bar's
+// open time in UTC.
+type Bar struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+	Spread float64   `json:"spread,omitempty"` // Price units, 0 when the source has none
+}
+
+// Range returns high minus low
+func (b Bar) Range() float64 {
+	return b.High - b.Low
+}
+
+// Valid reports whether the bar's prices are internally consistent
+func (b Bar) Valid() bool {
+	return b.Low > 0 &&
+		b.High >= b.Low &&
+		b.Open >= b.Low && b.Open <= b.High &&
+		b.Close >= b.Low && b.Close <= b.High
+}
@@ -0,0 +1,33 @@
+package marketdata
+
+import "time"
+
+// Tick is a single bid/ask quote, normalized across data sources
+type Tick struct {
+	Time      time.Time `json:"time"`
+	Bid       float64   `json:"bid"`
+	Ask       float64   `json:"ask"`
+	BidVolume float64   `json:"bidVolume"`
+	AskVolume float64   `json:"askVolume"`
+}
+
+// Mid returns the midpoint between bid and ask
+func (t Tick) Mid() float64 {
+	return (t.Bid + t.Ask) / 2
+}
+
+// Spread returns ask minus bid in price units
+func (t Tick) Spread() float64 {
+	return t.Ask - t.Bid
+}
+
+// Volume returns the combined bid and ask volume
+func (t Tick) Volume() float64 {
+	return t.BidVolume + t.AskVolume
+}
+
+// Kinds of price series a dataset can hold
+const (
+	KindTick = "tick"
+	KindBar  = "bar"
+)
@@ -0,0 +1,227 @@
+// Package metrics computes the performance statistics of a finished
+// backtest from its trades and daily equity.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+)
+
+// TradingDaysPerYear annualizes daily ratios. Forex trades five days a
+// week.
+const TradingDaysPerYear = 260
+
+// Report is the full set of statistics of a run. Ratios that are undefined,
+// such as a Sortino ratio without a losing day, are zero.
+type Report struct {
+	TotalReturn    float64 `json:"totalReturn"`    // Percent of the initial balance
+	AnnualReturn   float64 `json:"annualReturn"`   // Compounded, percent
+	Sharpe         float64 `json:"sharpe"`         // Annualized, from daily returns, no risk-free rate
+	Sortino        float64 `json:"sortino"`        // Annualized, penalizing only losing days
+	Calmar         float64 `json:"calmar"`         // Annual return over maximum drawdown
+	RecoveryFactor float64 `json:"recoveryFactor"` // Net profit over maximum drawdown amount
+
+	Expectancy           float64 `json:"expectancy"` // Average profit per trade
+	AverageWin           float64 `json:"averageWin"`
+	AverageLoss          float64 `json:"averageLoss"` // Positive amount
+	LargestWin           float64 `json:"largestWin"`
+	LargestLoss          float64 `json:"largestLoss"` // Positive amount
+	MaxConsecutiveWins   int     `json:"maxConsecutiveWins"`
+	MaxConsecutiveLosses int     `json:"maxConsecutiveLosses"`
+	AverageHoldingHours  float64 `json:"averageHoldingHours"`
+	AverageMAE           float64 `json:"averageMae"` // Pips
+	AverageMFE           float64 `json:"averageMfe"` // Pips
+	Exposure             float64 `json:"exposure"`   // Percent of the test with a position open
+
+	Monthly []Period `json:"monthly"`
+	Yearly  []Period `json:"yearly"`
+}
+
+// Period is the result of one calendar month or year, by trading day
+type Period struct {
+	Period string  `json:"period"` // 2006-01 for a month, 2006 for a year
+	Profit float64 `json:"profit"` // Change in equity
+	Return float64 `json:"return"` // Percent of the equity the period started with
+}
+
+// Compute derives the report of a finished run
+func Compute(r *engine.Result) *Report {
+	s := r.Summary
+	rep := &Report{}
+	if s.InitialBalance > 0 {
+		rep.TotalReturn = round(s.NetProfit/s.InitialBalance*100, 2)
+		if years := s.End.Sub(s.Start).Hours() / 24 / 365.25; years > 0 {
+			growth := s.FinalBalance / s.InitialBalance
+			if growth <= 0 {
+				rep.AnnualReturn = -100
+			} else {
+				rep.AnnualReturn = round(finite((math.Pow(growth, 1/years)-1)*100), 2)
+			}
+		}
+	}
+	if s.MaxDrawdown > 0 {
+		rep.Calmar = round(rep.AnnualReturn/s.MaxDrawdown, 2)
+	}
+	if s.MaxDrawdownAmount > 0 {
+		rep.RecoveryFactor = round(s.NetProfit/s.MaxDrawdownAmount, 2)
+	}
+
+	returns := dailyReturns(r.Daily, s.InitialBalance)
+	rep.Sharpe, rep.Sortino = ratios(returns)
+	trades(rep, r.Trades)
+	rep.Exposure = exposure(r.Trades, s.Start, s.End)
+	rep.Monthly = periods(r.Daily, s.InitialBalance, "2006-01")
+	rep.Yearly = periods(r.Daily, s.InitialBalance, "2006")
+	return rep
+}
+
+// dailyReturns is the change in equity from each trading day to the next,
+// the first measured from the initial balance
+func dailyReturns(daily []engine.EquityPoint, initial float64) []float64 {
+	returns := make([]float64, 0, len(daily))
+	prev := initial
+	for _, p := range daily {
+		if prev > 0 {
+			returns = append(returns, p.Equity/prev-1)
+		}
+		prev = p.Equity
+	}
+	return returns
+}
+
+// ratios returns the annualized Sharpe and Sortino ratios of daily returns
+func ratios(returns []float64) (sharpe, sortino float64) {
+	n := float64(len(returns))
+	if n < 2 {
+		return 0, 0
+	}
+	var sum, downside float64
+	for _, r := range returns {
+		sum += r
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	mean := sum / n
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	annual := math.Sqrt(TradingDaysPerYear)
+	if sd := math.Sqrt(variance / (n - 1)); sd > 0 {
+		sharpe = round(mean/sd*annual, 2)
+	}
+	if dd := math.Sqrt(downside / n); dd > 0 {
+		sortino = round(mean/dd*annual, 2)
+	}
+	return sharpe, sortino
+}
+
+// trades fills in the statistics of individual trades. As in the summary,
+// a trade that made nothing counts as a loss.
+func trades(rep *Report, list []engine.Trade) {
+	if len(list) == 0 {
+		return
+	}
+	var (
+		net, won, lost    float64
+		wins, losses      int
+		winRun, lossRun   int
+		holding, mae, mfe float64
+	)
+	for _, t := range list {
+		net += t.Profit
+		if t.Profit > 0 {
+			wins++
+			won += t.Profit
+			rep.LargestWin = max(rep.LargestWin, t.Profit)
+			winRun, lossRun = winRun+1, 0
+		} else {
+			losses++
+			lost -= t.Profit
+			rep.LargestLoss = max(rep.LargestLoss, -t.Profit)
+			winRun, lossRun = 0, lossRun+1
+		}
+		rep.MaxConsecutiveWins = max(rep.MaxConsecutiveWins, winRun)
+		rep.MaxConsecutiveLosses = max(rep.MaxConsecutiveLosses, lossRun)
+		holding += t.ExitTime.Sub(t.EntryTime).Hours()
+		mae += t.MAE
+		mfe += t.MFE
+	}
+	n := float64(len(list))
+	rep.Expectancy = round(net/n, 2)
+	if wins > 0 {
+		rep.AverageWin = round(won/float64(wins), 2)
+	}
+	if losses > 0 {
+		rep.AverageLoss = round(lost/float64(losses), 2)
+	}
+	rep.LargestWin = round(rep.LargestWin, 2)
+	rep.LargestLoss = round(rep.LargestLoss, 2)
+	rep.AverageHoldingHours = round(holding/n, 2)
+	rep.AverageMAE = round(mae/n, 1)
+	rep.AverageMFE = round(mfe/n, 1)
+}
+
+// exposure is the share of the test during which at least one position was
+// open, overlapping positions counted once
+func exposure(list []engine.Trade, start, end time.Time) float64 {
+	total := end.Sub(start)
+	if total <= 0 || len(list) == 0 {
+		return 0
+	}
+	spans := make([][2]time.Time, len(list))
+	for i, t := range list {
+		spans[i] = [2]time.Time{t.EntryTime, t.ExitTime}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0].Before(spans[j][0]) })
+
+	var open time.Duration
+	from, to := spans[0][0], spans[0][1]
+	for _, span := range spans[1:] {
+		if span[0].After(to) {
+			open += to.Sub(from)
+			from, to = span[0], span[1]
+		} else if span[1].After(to) {
+			to = span[1]
+		}
+	}
+	open += to.Sub(from)
+	return round(min(float64(open)/float64(total)*100, 100), 2)
+}
+
+// periods groups the daily equity by the calendar period that layout
+// formats
+func periods(daily []engine.EquityPoint, initial float64, layout string) []Period {
+	list := []Period{}
+	start := initial
+	for i, p := range daily {
+		key := p.Time.Format(layout)
+		if i+1 < len(daily) && daily[i+1].Time.Format(layout) == key {
+			continue
+		}
+		period := Period{Period: key, Profit: round(p.Equity-start, 2)}
+		if start > 0 {
+			period.Return = round((p.Equity/start-1)*100, 2)
+		}
+		list = append(list, period)
+		start = p.Equity
+	}
+	return list
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(v*scale) / scale
+}
+
+// finite turns the infinities and NaN of degenerate inputs into zero
+func finite(v float64) float64 {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
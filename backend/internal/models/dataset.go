@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/csvdialect"
+	"github.com/PervFVCK/strategyforge/internal/quality"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Dataset status values
+const (
+	DatasetStatusPending    = "pending_confirmation" // CSV layout awaiting user confirmation
+	DatasetStatusProcessing = "processing"
+	DatasetStatusReady      = "ready"
+	DatasetStatusFailed     = "failed"
+)
+
+// Dataset represents an uploaded price data file
+type Dataset struct {
+	ID          string              `gorm:"primaryKey;type:uuid" json:"id"`
+	UserID      string              `gorm:"index;not null" json:"userId"`
+	ParentID    string              `gorm:"index" json:"parentId,omitempty"` // Source of a repaired copy
+	Name        string              `gorm:"not null" json:"name"`
+	Symbol      string              `gorm:"index" json:"symbol"`
+	Format      string              `gorm:"not null" json:"format"` // csv, hst, bi5
+	Kind        string              `json:"kind"`                   // tick or bar
+	Timeframe   string              `json:"timeframe,omitempty"`
+	SizeBytes   int64               `json:"sizeBytes"`
+	Checksum    string              `gorm:"index" json:"checksum"` // SHA-256 of the raw upload
+	StoragePath string              `gorm:"not null" json:"-"`     // Never expose server paths
+	Rows        int64               `json:"rows"`
+	StartDate   *time.Time          `json:"startDate,omitempty"`
+	EndDate     *time.Time          `json:"endDate,omitempty"`
+	Dialect     *csvdialect.Dialect `gorm:"serializer:json" json:"dialect,omitempty"` // Detected CSV layout
+	Quality     *quality.Report     `gorm:"serializer:json" json:"quality,omitempty"`
+	Repair      *quality.Summary    `gorm:"serializer:json" json:"repair,omitempty"` // Set on repaired copies
+	Status      string              `gorm:"index;not null" json:"status"`
+	Error       string              `json:"error,omitempty"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt      `gorm:"index" json:"-"`
+}
+
+// BeforeCreate hook for Dataset
+func (d *Dataset) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// SeriesSymbol is the name the dataset's rows are stored under in its price
+// store. Datasets uploaded without a recognizable symbol share a placeholder.
+func (d *Dataset) SeriesSymbol() string {
+	if d.Symbol == "" {
+		return "UNKNOWN"
+	}
+	return d.Symbol
+}
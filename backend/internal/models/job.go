@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Job status values
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Job is a long-running task, such as a backtest, executed in the background
+type Job struct {
+	ID         string     `gorm:"primaryKey;type:uuid" json:"id"`
+	UserID     string     `gorm:"index;not null" json:"userId"`
+	Kind       string     `gorm:"not null" json:"kind"` // What runs it, such as backtest
+	Status     string     `gorm:"index;not null" json:"status"`
+	Request    string     `gorm:"type:text" json:"-"` // JSON stored as text
+	Progress   float64    `json:"progress"`           // Percent done
+	Message    string     `json:"message,omitempty"`
+	ResultID   string     `json:"resultId,omitempty"` // What the job stored, such as a backtest
+	Error      string     `json:"error,omitempty"`
+	Attempts   int        `json:"attempts"` // Starts, counting those a restart interrupted
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// BeforeCreate hook for Job
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Finished reports whether the job has reached a final status
+func (j *Job) Finished() bool {
+	switch j.Status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
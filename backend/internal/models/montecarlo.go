@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/montecarlo"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MonteCarloSimulation is a stored Monte Carlo simulation of a backtest's
+// trades. Running its config again, seed included, repeats it.
+type MonteCarloSimulation struct {
+	ID         string             `gorm:"primaryKey;type:uuid" json:"id"`
+	UserID     string             `gorm:"index;not null" json:"userId"`
+	BacktestID string             `gorm:"index;not null" json:"backtestId"`
+	Seed       int64              `json:"seed"`
+	Config     montecarlo.Config  `gorm:"serializer:json" json:"config"`
+	Report     *montecarlo.Report `gorm:"serializer:json" json:"report,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt"`
+}
+
+// BeforeCreate hook for MonteCarloSimulation
+func (m *MonteCarloSimulation) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/optimize"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Optimization is a stored parameter search: every trial it ran, ranked by
+// its objective, and the best parameters found
+type Optimization struct {
+	ID           string             `gorm:"primaryKey;type:uuid" json:"id"`
+	UserID       string             `gorm:"index;not null" json:"userId"`
+	StrategyID   string             `gorm:"index" json:"strategyId,omitempty"`
+	Strategy     string             `json:"strategy"` // Built-in strategy name or saved strategy title
+	DatasetID    string             `gorm:"index" json:"datasetId"`
+	Pair         string             `gorm:"not null" json:"pair"`
+	Timeframe    string             `gorm:"not null" json:"timeframe"`
+	Method       string             `gorm:"not null" json:"method"` // grid, random or genetic
+	Objective    optimize.Objective `gorm:"serializer:json" json:"objective"`
+	Space        optimize.Space     `gorm:"serializer:json" json:"space"`
+	Fixed        map[string]any     `gorm:"serializer:json" json:"fixed,omitempty"`   // Parameters held constant
+	Genetic      *optimize.Genetic  `gorm:"serializer:json" json:"genetic,omitempty"` // Settings of a genetic search
+	Generations  int                `json:"generations,omitempty"`                    // Generations a genetic search ran
+	Seed         int64              `json:"seed,omitempty"`                           // Seed of a random or genetic search, to repeat it
+	MinTrades    int                `json:"minTrades,omitempty"`
+	TotalTrials  int                `json:"totalTrials"`
+	Trials       []optimize.Trial   `gorm:"serializer:json" json:"trials,omitempty"`
+	BestParams   optimize.Params    `gorm:"serializer:json" json:"bestParams,omitempty"`
+	BestScore    float64            `json:"bestScore"`
+	BestResultID string             `json:"bestResultId,omitempty"` // Backtest of the best parameters
+	CreatedAt    time.Time          `json:"createdAt"`
+}
+
+// BeforeCreate hook for Optimization
+func (o *Optimization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// OptimizationCheckpoint is the state of a genetic search after its latest
+// generation, kept while its job runs so that a restart resumes it
+type OptimizationCheckpoint struct {
+	JobID     string             `gorm:"primaryKey" json:"jobId"`
+	State     optimize.Evolution `gorm:"serializer:json" json:"state"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
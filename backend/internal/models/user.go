@@ -3,6 +3,9 @@ package models
 import (
 	"time"
 
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/instruments"
+	"github.com/PervFVCK/strategyforge/internal/metrics"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -75,21 +78,33 @@ func (s *Strategy) BeforeCreate(tx *gorm.DB) error {
 
 // BacktestResult stores backtest execution results
 type BacktestResult struct {
-	ID            string    `gorm:"primaryKey;type:uuid" json:"id"`
-	UserID        string    `gorm:"index;not null" json:"userId"`
-	StrategyID    string    `gorm:"index" json:"strategyId,omitempty"`
-	Pair          string    `gorm:"not null" json:"pair"`
-	Timeframe     string    `gorm:"not null" json:"timeframe"`
-	StartDate     time.Time `json:"startDate"`
-	EndDate       time.Time `json:"endDate"`
-	InitialBalance float64  `json:"initialBalance"`
-	FinalBalance   float64  `json:"finalBalance"`
-	TotalTrades    int      `json:"totalTrades"`
-	WinRate        float64  `json:"winRate"`
-	ProfitFactor   float64  `json:"profitFactor"`
-	MaxDrawdown    float64  `json:"maxDrawdown"`
-	ResultData     string   `gorm:"type:text" json:"-"` // JSON stored as text
-	CreatedAt      time.Time `json:"createdAt"`
+	ID             string                  `gorm:"primaryKey;type:uuid" json:"id"`
+	UserID         string                  `gorm:"index;not null" json:"userId"`
+	ParentID       string                  `gorm:"index" json:"parentId,omitempty"` // Portfolio or walk-forward run a child result belongs to
+	StrategyID     string                  `gorm:"index" json:"strategyId,omitempty"`
+	Strategy       string                  `json:"strategy"` // Built-in strategy name or saved strategy title
+	DatasetID      string                  `gorm:"index" json:"datasetId,omitempty"`
+	Pair           string                  `gorm:"not null" json:"pair"`
+	Symbols        []string                `gorm:"serializer:json" json:"symbols,omitempty"` // Every symbol of a portfolio run; its Pair joins them
+	Windows        int                     `json:"windows,omitempty"`                        // Out-of-sample runs of a walk-forward analysis, stored as its children
+	Timeframe      string                  `gorm:"not null" json:"timeframe"`
+	Timeframes     []string                `gorm:"serializer:json" json:"timeframes"` // Every timeframe the strategy saw, its own first
+	StartDate      time.Time               `json:"startDate"`
+	EndDate        time.Time               `json:"endDate"`
+	InitialBalance float64                 `json:"initialBalance"`
+	FinalBalance   float64                 `json:"finalBalance"`
+	Currency       string                  `json:"currency"` // Account currency of the balances
+	Leverage       int                     `json:"leverage"` // 1:Leverage
+	Instrument     *instruments.Instrument `gorm:"serializer:json" json:"instrument,omitempty"`
+	TotalTrades    int                     `json:"totalTrades"`
+	WinRate        float64                 `json:"winRate"`
+	ProfitFactor   float64                 `json:"profitFactor"`
+	MaxDrawdown    float64                 `json:"maxDrawdown"`
+	Costs          *engine.Costs           `gorm:"serializer:json" json:"costs,omitempty"`      // Execution cost assumptions
+	CostTotals     *engine.CostTotals      `gorm:"serializer:json" json:"costTotals,omitempty"` // What those costs took
+	Metrics        *metrics.Report         `gorm:"serializer:json" json:"metrics,omitempty"`    // Performance statistics
+	ResultData     string                  `gorm:"type:text" json:"-"`                          // JSON stored as text
+	CreatedAt      time.Time               `json:"createdAt"`
 }
 
 // BeforeCreate hook for BacktestResult
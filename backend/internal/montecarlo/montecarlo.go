@@ -0,0 +1,284 @@
+// Package montecarlo tests how much a backtest's outcome owes to luck by
+// replaying its trades many times in altered sequences: reordered,
+// resampled, with trades missed and with extra slippage.
+package montecarlo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+)
+
+// Bounds and defaults of a simulation
+const (
+	DefaultSimulations = 1000
+	MaxSimulations     = 10000
+	DefaultRuinPercent = 50
+)
+
+// progressEvery is how many runs pass between progress reports
+const progressEvery = 50
+
+// Confidence levels of the intervals reported for each distribution
+var confidenceLevels = []float64{90, 95, 99}
+
+// Percentiles reported for each distribution
+var percentiles = []float64{1, 5, 10, 25, 50, 75, 90, 95, 99}
+
+// Config selects how each simulated run alters the trades. A run with the
+// same settings and seed repeats exactly.
+type Config struct {
+	Simulations  int     `json:"simulations"`  // 1000 when zero
+	Seed         int64   `json:"seed"`         // Picked when zero
+	Shuffle      bool    `json:"shuffle"`      // Reorder the trades
+	Resample     bool    `json:"resample"`     // Draw as many trades with replacement; implies a new order
+	SkipPercent  float64 `json:"skipPercent"`  // Chance of missing each trade
+	SlippagePips float64 `json:"slippagePips"` // Most extra slippage per trade, drawn evenly from zero up
+	RuinPercent  float64 `json:"ruinPercent"`  // Drawdown from the peak counted as ruin; 50 when zero
+}
+
+// Validate checks the configuration and fills in defaults other than the seed
+func (c *Config) Validate() error {
+	if c.Simulations == 0 {
+		c.Simulations = DefaultSimulations
+	}
+	if c.Simulations < 1 || c.Simulations > MaxSimulations {
+		return fmt.Errorf("simulations must be between 1 and %d", MaxSimulations)
+	}
+	if c.SkipPercent < 0 || c.SkipPercent >= 100 {
+		return errors.New("skipPercent must be at least 0 and below 100")
+	}
+	if c.SlippagePips < 0 {
+		return errors.New("slippagePips cannot be negative")
+	}
+	if c.RuinPercent == 0 {
+		c.RuinPercent = DefaultRuinPercent
+	}
+	if c.RuinPercent < 0 || c.RuinPercent > 100 {
+		return errors.New("ruinPercent must be a percent between 0 and 100")
+	}
+	if !c.Shuffle && !c.Resample && c.SkipPercent == 0 && c.SlippagePips == 0 {
+		return errors.New("choose at least one of shuffle, resample, skipPercent or slippagePips")
+	}
+	return nil
+}
+
+// Percentile is the value below which a share of the runs fell
+type Percentile struct {
+	Percent float64 `json:"percent"`
+	Value   float64 `json:"value"`
+}
+
+// Interval holds the central share of the runs given by its level
+type Interval struct {
+	Level float64 `json:"level"` // Percent
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+}
+
+// Distribution summarizes one outcome over every run
+type Distribution struct {
+	Mean        float64      `json:"mean"`
+	StdDev      float64      `json:"stdDev"`
+	Min         float64      `json:"min"`
+	Max         float64      `json:"max"`
+	Percentiles []Percentile `json:"percentiles"`
+	Intervals   []Interval   `json:"intervals"`
+}
+
+// Report is the outcome of a simulation. Drawdowns are percent of the peak
+// balance, measured between trades.
+type Report struct {
+	Config              Config       `json:"config"`
+	Trades              int          `json:"trades"` // In the backtest
+	InitialBalance      float64      `json:"initialBalance"`
+	FinalBalance        float64      `json:"finalBalance"` // Of the backtest
+	MaxDrawdown         float64      `json:"maxDrawdown"`  // Of the backtest's trade sequence
+	FinalBalances       Distribution `json:"finalBalances"`
+	MaxDrawdowns        Distribution `json:"maxDrawdowns"`
+	RiskOfRuin          float64      `json:"riskOfRuin"`          // Percent of runs whose drawdown reached RuinPercent
+	ProbabilityOfProfit float64      `json:"probabilityOfProfit"` // Percent of runs that ended above the initial balance
+}
+
+// Run simulates cfg.Simulations runs of the trades from initialBalance.
+// Trades keep the profit they made in the backtest, so runs of a strategy
+// that sizes by risk do not compound as it would. cfg must be validated.
+// Progress, when not nil, is told how many runs are done; Run stops with
+// ctx's error once it is cancelled.
+func Run(ctx context.Context, trades []engine.Trade, initialBalance float64, cfg Config, progress func(done, total int)) (*Report, error) {
+	if len(trades) == 0 {
+		return nil, errors.New("the backtest has no trades to simulate")
+	}
+	if initialBalance <= 0 {
+		return nil, errors.New("the backtest has no initial balance")
+	}
+
+	profits := make([]float64, len(trades))
+	pipValues := make([]float64, len(trades))
+	perLot := pipValuePerLot(trades)
+	for i, t := range trades {
+		profits[i] = t.Profit
+		pipValues[i] = pipValue(t, perLot)
+	}
+
+	base := &path{balance: initialBalance, peak: initialBalance}
+	for _, p := range profits {
+		base.add(p)
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	n := len(trades)
+	order := make([]int, n)
+	finals := make([]float64, cfg.Simulations)
+	drawdowns := make([]float64, cfg.Simulations)
+	var ruined, profitable int
+	for sim := range cfg.Simulations {
+		if sim%progressEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if progress != nil {
+				progress(sim, cfg.Simulations)
+			}
+		}
+		for i := range order {
+			if cfg.Resample {
+				order[i] = rng.Intn(n)
+			} else {
+				order[i] = i
+			}
+		}
+		if cfg.Shuffle && !cfg.Resample {
+			rng.Shuffle(n, func(i, j int) { order[i], order[j] = order[j], order[i] })
+		}
+
+		p := &path{balance: initialBalance, peak: initialBalance}
+		for _, i := range order {
+			if cfg.SkipPercent > 0 && rng.Float64()*100 < cfg.SkipPercent {
+				continue
+			}
+			profit := profits[i]
+			if cfg.SlippagePips > 0 {
+				profit -= rng.Float64() * cfg.SlippagePips * pipValues[i]
+			}
+			p.add(profit)
+		}
+		finals[sim], drawdowns[sim] = round(p.balance), round(p.drawdown)
+		if p.drawdown >= cfg.RuinPercent || p.balance <= 0 {
+			ruined++
+		}
+		if p.balance > initialBalance {
+			profitable++
+		}
+	}
+
+	sims := float64(cfg.Simulations)
+	return &Report{
+		Config:              cfg,
+		Trades:              n,
+		InitialBalance:      initialBalance,
+		FinalBalance:        round(base.balance),
+		MaxDrawdown:         round(base.drawdown),
+		FinalBalances:       distribution(finals),
+		MaxDrawdowns:        distribution(drawdowns),
+		RiskOfRuin:          round(float64(ruined) / sims * 100),
+		ProbabilityOfProfit: round(float64(profitable) / sims * 100),
+	}, nil
+}
+
+// path follows the balance of one run
+type path struct {
+	balance, peak float64
+	drawdown      float64 // Largest, percent of the peak
+}
+
+func (p *path) add(profit float64) {
+	p.balance += profit
+	p.peak = max(p.peak, p.balance)
+	if p.peak > 0 {
+		p.drawdown = max(p.drawdown, (p.peak-p.balance)/p.peak*100)
+	}
+}
+
+// precisePips is the smallest move from which a trade's own pip value is
+// taken; below it the rounding of profit and pips skews the ratio
+const precisePips = 10
+
+// pipValue is what one pip of a trade was worth in the account currency,
+// from its profit before commission and swap. A trade that moved too little
+// to tell is valued at the typical rate per lot.
+func pipValue(t engine.Trade, perLot float64) float64 {
+	if math.Abs(t.Pips) >= precisePips {
+		return grossPerPip(t)
+	}
+	return perLot * t.Volume
+}
+
+func grossPerPip(t engine.Trade) float64 {
+	return math.Abs((t.Profit + t.Commission - t.Swap) / t.Pips)
+}
+
+// pipValuePerLot is the median value of a pip per lot over the trades that
+// moved
+func pipValuePerLot(trades []engine.Trade) float64 {
+	var values []float64
+	for _, t := range trades {
+		if t.Pips != 0 && t.Volume > 0 {
+			values = append(values, grossPerPip(t)/t.Volume)
+		}
+	}
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	return quantile(values, 50)
+}
+
+// distribution summarizes values, sorting them
+func distribution(values []float64) Distribution {
+	sort.Float64s(values)
+	var sum, squares float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+
+	d := Distribution{
+		Mean:   round(mean),
+		StdDev: round(math.Sqrt(squares / float64(len(values)))),
+		Min:    values[0],
+		Max:    values[len(values)-1],
+	}
+	for _, pct := range percentiles {
+		d.Percentiles = append(d.Percentiles, Percentile{Percent: pct, Value: round(quantile(values, pct))})
+	}
+	for _, level := range confidenceLevels {
+		tail := (100 - level) / 2
+		d.Intervals = append(d.Intervals, Interval{
+			Level: level,
+			Low:   round(quantile(values, tail)),
+			High:  round(quantile(values, 100-tail)),
+		})
+	}
+	return d
+}
+
+// quantile interpolates the pct percentile of sorted values
+func quantile(sorted []float64, pct float64) float64 {
+	pos := pct / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := min(lo+1, len(sorted)-1)
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,332 @@
+// Package mt4 reads and writes MetaTrader 4 .hst history files.
+//
+// Both layouts MetaTrader has used are supported. Each file starts with a
+// 148-byte header followed by fixed-size little-endian records:
+//
+//	v400 (44 bytes): int32 time, float64 open, low, high, close, volume
+//	v401 (60 bytes): int64 time, float64 open, high, low, close,
+//	                 int64 tick volume, int32 spread, int64 real volume
+package mt4
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Supported file versions
+const (
+	Version400 = 400
+	Version401 = 401
+)
+
+// Layout sizes in bytes
+const (
+	HeaderSize    = 148
+	RecordSize400 = 44
+	RecordSize401 = 60
+)
+
+const defaultCopyright = "(C)opyright 2003, MetaQuotes Software Corp."
+
+var (
+	// ErrVersion is returned for files that are not v400 or v401
+	ErrVersion = errors.New("unsupported hst version")
+	// ErrTruncated is returned when a record is cut short
+	ErrTruncated = errors.New("truncated hst record")
+)
+
+// Header is the metadata block at the start of every .hst file
+type Header struct {
+	Version   int
+	Copyright string
+	Symbol    string
+	Period    int // Minutes per bar
+	Digits    int
+	TimeSign  time.Time
+	LastSync  time.Time
+}
+
+// RecordSize returns the size of one bar record for the header's version
+func (h Header) RecordSize() int {
+	if h.Version == Version401 {
+		return RecordSize401
+	}
+	return RecordSize400
+}
+
+// pointScale returns the number of points per price unit implied by Digits
+func (h Header) pointScale() float64 {
+	return math.Pow10(h.Digits)
+}
+
+// PeriodName converts an MT4 period in minutes to its timeframe name
+func PeriodName(period int) string {
+	switch period {
+	case 1:
+		return "M1"
+	case 5:
+		return "M5"
+	case 15:
+		return "M15"
+	case 30:
+		return "M30"
+	case 60:
+		return "H1"
+	case 240:
+		return "H4"
+	case 1440:
+		return "D1"
+	case 10080:
+		return "W1"
+	case 43200:
+		return "MN"
+	default:
+		return fmt.Sprintf("M%d", period)
+	}
+}
+
+func decodeHeader(b []byte) (Header, error) {
+	h := Header{
+		Version:   int(int32(binary.LittleEndian.Uint32(b[0:4]))),
+		Copyright: cString(b[4:68]),
+		Symbol:    cString(b[68:80]),
+		Period:    int(int32(binary.LittleEndian.Uint32(b[80:84]))),
+		Digits:    int(int32(binary.LittleEndian.Uint32(b[84:88]))),
+		TimeSign:  unixOrZero(int64(int32(binary.LittleEndian.Uint32(b[88:92])))),
+		LastSync:  unixOrZero(int64(int32(binary.LittleEndian.Uint32(b[92:96])))),
+	}
+	if h.Version != Version400 && h.Version != Version401 {
+		return h, fmt.Errorf("%w: %d", ErrVersion, h.Version)
+	}
+	if h.Period <= 0 {
+		return h, fmt.Errorf("invalid period %d", h.Period)
+	}
+	if h.Digits < 0 || h.Digits > 10 {
+		return h, fmt.Errorf("invalid digits %d", h.Digits)
+	}
+	return h, nil
+}
+
+func encodeHeader(h Header) []byte {
+	b := make([]byte, HeaderSize)
+	binary.LittleEndian.PutUint32(b[0:4], uint32(h.Version))
+	copy(b[4:67], h.Copyright)
+	copy(b[68:79], h.Symbol)
+	binary.LittleEndian.PutUint32(b[80:84], uint32(h.Period))
+	binary.LittleEndian.PutUint32(b[84:88], uint32(h.Digits))
+	binary.LittleEndian.PutUint32(b[88:92], uint32(unixOrZeroSeconds(h.TimeSign)))
+	binary.LittleEndian.PutUint32(b[92:96], uint32(unixOrZeroSeconds(h.LastSync)))
+	return b
+}
+
+// Reader decodes bars from an .hst stream
+type Reader struct {
+	r      *bufio.Reader
+	header Header
+	buf    []byte
+}
+
+// NewReader reads the header and prepares to decode bars
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	hb := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(br, hb); err != nil {
+		return nil, fmt.Errorf("failed to read hst header: %w", err)
+	}
+	header, err := decodeHeader(hb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		r:      br,
+		header: header,
+		buf:    make([]byte, header.RecordSize()),
+	}, nil
+}
+
+// Header returns the file header
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next returns the next bar, or io.EOF at the end of the file
+func (r *Reader) Next() (marketdata.Bar, error) {
+	n, err := io.ReadFull(r.r, r.buf)
+	if err == io.EOF {
+		return marketdata.Bar{}, io.EOF
+	}
+	if err != nil {
+		if n > 0 {
+			return marketdata.Bar{}, ErrTruncated
+		}
+		return marketdata.Bar{}, err
+	}
+
+	b := r.buf
+	if r.header.Version == Version401 {
+		return marketdata.Bar{
+			Time:   time.Unix(int64(binary.LittleEndian.Uint64(b[0:8])), 0).UTC(),
+			Open:   f64(b[8:16]),
+			High:   f64(b[16:24]),
+			Low:    f64(b[24:32]),
+			Close:  f64(b[32:40]),
+			Volume: float64(int64(binary.LittleEndian.Uint64(b[40:48]))),
+			Spread: float64(int32(binary.LittleEndian.Uint32(b[48:52]))) / r.header.pointScale(),
+		}, nil
+	}
+
+	return marketdata.Bar{
+		Time:   time.Unix(int64(int32(binary.LittleEndian.Uint32(b[0:4]))), 0).UTC(),
+		Open:   f64(b[4:12]),
+		Low:    f64(b[12:20]),
+		High:   f64(b[20:28]),
+		Close:  f64(b[28:36]),
+		Volume: f64(b[36:44]),
+	}, nil
+}
+
+// ReadAll decodes every bar from an .hst stream
+func ReadAll(r io.Reader) (Header, []marketdata.Bar, error) {
+	hr, err := NewReader(r)
+	if err != nil {
+		return Header{}, nil, err
+	}
+
+	var bars []marketdata.Bar
+	for {
+		bar, err := hr.Next()
+		if err == io.EOF {
+			return hr.Header(), bars, nil
+		}
+		if err != nil {
+			return hr.Header(), nil, err
+		}
+		bars = append(bars, bar)
+	}
+}
+
+// Writer encodes bars into an .hst stream
+type Writer struct {
+	w      *bufio.Writer
+	header Header
+	buf    []byte
+}
+
+// NewWriter writes the header and returns a writer for bars. Missing header
+// fields are defaulted: version 401, the MetaQuotes copyright string, and
+// TimeSign set to now.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	if h.Version == 0 {
+		h.Version = Version401
+	}
+	if h.Version != Version400 && h.Version != Version401 {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, h.Version)
+	}
+	if h.Symbol == "" || len(h.Symbol) > 11 {
+		return nil, errors.New("symbol must be 1-11 characters")
+	}
+	if h.Period <= 0 {
+		return nil, fmt.Errorf("invalid period %d", h.Period)
+	}
+	if h.Copyright == "" {
+		h.Copyright = defaultCopyright
+	}
+	if h.TimeSign.IsZero() {
+		h.TimeSign = time.Now().UTC()
+	}
+
+	bw := bufio.NewWriterSize(w, 64*1024)
+	if _, err := bw.Write(encodeHeader(h)); err != nil {
+		return nil, fmt.Errorf("failed to write hst header: %w", err)
+	}
+
+	return &Writer{
+		w:      bw,
+		header: h,
+		buf:    make([]byte, h.RecordSize()),
+	}, nil
+}
+
+// Write appends one bar
+func (w *Writer) Write(bar marketdata.Bar) error {
+	b := w.buf
+	if w.header.Version == Version401 {
+		binary.LittleEndian.PutUint64(b[0:8], uint64(bar.Time.Unix()))
+		putF64(b[8:16], bar.Open)
+		putF64(b[16:24], bar.High)
+		putF64(b[24:32], bar.Low)
+		putF64(b[32:40], bar.Close)
+		binary.LittleEndian.PutUint64(b[40:48], uint64(int64(math.Round(bar.Volume))))
+		binary.LittleEndian.PutUint32(b[48:52], uint32(int32(math.Round(bar.Spread*w.header.pointScale()))))
+		binary.LittleEndian.PutUint64(b[52:60], 0)
+	} else {
+		binary.LittleEndian.PutUint32(b[0:4], uint32(int32(bar.Time.Unix())))
+		putF64(b[4:12], bar.Open)
+		putF64(b[12:20], bar.Low)
+		putF64(b[20:28], bar.High)
+		putF64(b[28:36], bar.Close)
+		putF64(b[36:44], bar.Volume)
+	}
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+// Flush writes any buffered bars to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// WriteAll writes a complete .hst file
+func WriteAll(w io.Writer, h Header, bars []marketdata.Bar) error {
+	hw, err := NewWriter(w, h)
+	if err != nil {
+		return err
+	}
+	for _, bar := range bars {
+		if err := hw.Write(bar); err != nil {
+			return err
+		}
+	}
+	return hw.Flush()
+}
+
+func f64(b []byte) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+func putF64(b []byte, v float64) {
+	binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+}
+
+// cString decodes a NUL-padded fixed-width string field
+func cString(b []byte) string {
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func unixOrZero(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+func unixOrZeroSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,98 @@
+package mt4
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// testBars are whole-volume bars with spreads of whole points, which both
+// layouts hold exactly
+func testBars() []marketdata.Bar {
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	bars := make([]marketdata.Bar, 50)
+	for i := range bars {
+		open := 1.10000 + float64(i%7)*0.00013
+		bars[i] = marketdata.Bar{
+			Time:   start.Add(time.Duration(i) * time.Hour),
+			Open:   open,
+			High:   open + 0.00042,
+			Low:    open - 0.00031,
+			Close:  open + 0.00005,
+			Volume: float64(100 + i),
+			Spread: float64(i%3+1) / 1e5,
+		}
+	}
+	return bars
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, version := range []int{Version400, Version401} {
+		bars := testBars()
+		header := Header{Version: version, Symbol: "EURUSD", Period: 60, Digits: 5}
+
+		var file bytes.Buffer
+		if err := WriteAll(&file, header, bars); err != nil {
+			t.Fatalf("v%d: write: %v", version, err)
+		}
+		recordSize := RecordSize400
+		if version == Version401 {
+			recordSize = RecordSize401
+		}
+		if want := HeaderSize + len(bars)*recordSize; file.Len() != want {
+			t.Fatalf("v%d: file is %d bytes, want %d", version, file.Len(), want)
+		}
+
+		got, read, err := ReadAll(&file)
+		if err != nil {
+			t.Fatalf("v%d: read: %v", version, err)
+		}
+		if got.Version != version || got.Symbol != "EURUSD" || got.Period != 60 || got.Digits != 5 {
+			t.Errorf("v%d: header = %+v", version, got)
+		}
+		if got.Copyright != defaultCopyright || got.TimeSign.IsZero() {
+			t.Errorf("v%d: header defaults not written: %+v", version, got)
+		}
+		if len(read) != len(bars) {
+			t.Fatalf("v%d: read %d bars, want %d", version, len(read), len(bars))
+		}
+
+		for i, want := range bars {
+			// v400 records have no spread
+			if version == Version400 {
+				want.Spread = 0
+			}
+			if read[i] != want {
+				t.Errorf("v%d: bar %d = %+v, want %+v", version, i, read[i], want)
+			}
+		}
+	}
+}
+
+func TestReadTruncatedRecord(t *testing.T) {
+	var file bytes.Buffer
+	if err := WriteAll(&file, Header{Symbol: "EURUSD", Period: 1, Digits: 5}, testBars()[:2]); err != nil {
+		t.Fatal(err)
+	}
+	file.Truncate(file.Len() - 1)
+
+	if _, _, err := ReadAll(&file); !errors.Is(err, ErrTruncated) {
+		t.Errorf("err = %v, want ErrTruncated", err)
+	}
+}
+
+func TestWriterRejectsBadHeader(t *testing.T) {
+	for _, h := range []Header{
+		{Version: 402, Symbol: "EURUSD", Period: 1},
+		{Symbol: "", Period: 1},
+		{Symbol: "EURUSDEURUSD", Period: 1},
+		{Symbol: "EURUSD", Period: 0},
+	} {
+		if _, err := NewWriter(&bytes.Buffer{}, h); err == nil {
+			t.Errorf("NewWriter(%+v) accepted a bad header", h)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package optimize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Genetic search defaults
+const (
+	defaultPopulation  = 40
+	defaultGenerations = 25
+	defaultCrossover   = 0.8
+	defaultElite       = 2
+	defaultPatience    = 8
+	tournamentSize     = 3
+)
+
+// Genetic configures an evolutionary search. Each generation breeds the
+// next from its fittest sets by tournament selection, uniform crossover and
+// mutation, carrying the best over unchanged. The search stops early once
+// the best score stops improving.
+type Genetic struct {
+	Population  int     `json:"population"`  // Sets per generation; 40 when zero
+	Generations int     `json:"generations"` // Most generations; 25 when zero
+	Crossover   float64 `json:"crossover"`   // Chance a child mixes two parents rather than copying one; 0.8 when zero
+	Mutation    float64 `json:"mutation"`    // Chance each parameter of a child mutates; one over the parameter count when zero
+	Elite       int     `json:"elite"`       // Best sets carried over unchanged; 2 when zero
+	Patience    int     `json:"patience"`    // Generations without a better score before stopping; 8 when zero
+}
+
+// Validate checks the settings against the space searched, filling in
+// defaults
+func (g *Genetic) Validate(space Space) error {
+	if err := space.Validate(); err != nil {
+		return err
+	}
+	if g.Population == 0 {
+		g.Population = defaultPopulation
+	}
+	if g.Generations == 0 {
+		g.Generations = defaultGenerations
+	}
+	if g.Crossover == 0 {
+		g.Crossover = defaultCrossover
+	}
+	if g.Mutation == 0 {
+		g.Mutation = 1 / float64(len(space))
+	}
+	if g.Elite == 0 {
+		g.Elite = defaultElite
+	}
+	if g.Patience == 0 {
+		g.Patience = defaultPatience
+	}
+
+	switch {
+	case g.Population < 4:
+		return errors.New("population must be at least 4")
+	case g.Generations < 1:
+		return errors.New("generations must be at least 1")
+	case g.Population*g.Generations > MaxTrials:
+		return fmt.Errorf("population times generations must be at most %d", MaxTrials)
+	case g.Crossover < 0 || g.Crossover > 1:
+		return errors.New("crossover must be between 0 and 1")
+	case g.Mutation < 0 || g.Mutation > 1:
+		return errors.New("mutation must be between 0 and 1")
+	case g.Elite < 0 || g.Elite >= g.Population:
+		return errors.New("elite must be less than the population")
+	case g.Patience < 1:
+		return errors.New("patience must be at least 1")
+	}
+	return nil
+}
+
+// Evolution is a genetic search between generations. Stored after each
+// one, it lets an interrupted search carry on as it would have.
+type Evolution struct {
+	Generation int      `json:"generation"` // Generations evaluated
+	Population []Params `json:"population"` // The generation to evaluate next
+	Trials     []Trial  `json:"trials"`     // Every distinct set evaluated, unranked
+	Best       *float64 `json:"best"`       // Best score so far; nil until a trial is ranked
+	Stale      int      `json:"stale"`      // Generations since Best improved
+	Done       bool     `json:"done"`
+}
+
+// Evolve runs a genetic search from state until it is done, evaluating the
+// new sets of each generation with Search. Sets already evaluated are not
+// run again. checkpoint, when given, is called with the state after every
+// generation. Each generation draws from its own seed, so resuming from a
+// checkpoint gives the same result as an uninterrupted search.
+func Evolve(ctx context.Context, space Space, g Genetic, seed int64, obj Objective, minTrades, workers int, state *Evolution, eval Evaluate, checkpoint func(*Evolution) error, progress func(done, total int)) error {
+	names := space.Names()
+	if state.Generation == 0 && state.Population == nil {
+		population, err := space.Random(g.Population, seed)
+		if err != nil {
+			return err
+		}
+		state.Population = population
+	}
+	seen := make(map[string]int, len(state.Trials))
+	for i, t := range state.Trials {
+		seen[t.Params.key(names)] = i
+	}
+
+	total := g.Population * g.Generations
+	for !state.Done {
+		var fresh []Params
+		for _, set := range state.Population {
+			key := set.key(names)
+			if _, ok := seen[key]; !ok {
+				seen[key] = -1
+				fresh = append(fresh, set)
+			}
+		}
+		base := state.Generation * g.Population
+		out, err := Search(ctx, fresh, obj, minTrades, workers, eval, func(done, _ int) {
+			if progress != nil {
+				progress(base+done, total)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		for _, t := range out.Trials {
+			t.Rank = 0
+			seen[t.Params.key(names)] = len(state.Trials)
+			state.Trials = append(state.Trials, t)
+		}
+
+		fitness := make([]float64, len(state.Population))
+		improved := false
+		for i, set := range state.Population {
+			t := state.Trials[seen[set.key(names)]]
+			fitness[i] = math.Inf(-1)
+			if ranked(t, minTrades) {
+				fitness[i] = t.Score
+				if state.Best == nil || t.Score > *state.Best {
+					score := t.Score
+					state.Best, improved = &score, true
+				}
+			}
+		}
+		if improved {
+			state.Stale = 0
+		} else {
+			state.Stale++
+		}
+
+		state.Generation++
+		if state.Generation >= g.Generations || state.Stale >= g.Patience {
+			state.Done = true
+		} else {
+			rng := rand.New(rand.NewSource(seed + int64(state.Generation)))
+			state.Population = g.breed(rng, space, names, state.Population, fitness)
+		}
+		if checkpoint != nil {
+			if err := checkpoint(state); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// breed returns the next generation of population
+func (g Genetic) breed(rng *rand.Rand, space Space, names []string, population []Params, fitness []float64) []Params {
+	order := make([]int, len(population))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return fitness[order[a]] > fitness[order[b]] })
+
+	next := make([]Params, 0, g.Population)
+	for _, i := range order[:min(g.Elite, len(order))] {
+		next = append(next, population[i])
+	}
+	tournament := func() Params {
+		best := rng.Intn(len(population))
+		for range tournamentSize - 1 {
+			if i := rng.Intn(len(population)); fitness[i] > fitness[best] {
+				best = i
+			}
+		}
+		return population[best]
+	}
+	for len(next) < g.Population {
+		first := tournament()
+		child := make(Params, len(names))
+		for _, name := range names {
+			child[name] = first[name]
+		}
+		if rng.Float64() < g.Crossover {
+			second := tournament()
+			for _, name := range names {
+				if rng.Intn(2) == 0 {
+					child[name] = second[name]
+				}
+			}
+		}
+		for _, name := range names {
+			if rng.Float64() < g.Mutation {
+				child[name] = space[name].mutate(rng, child[name])
+			}
+		}
+		next = append(next, child)
+	}
+	return next
+}
+
+// mutate moves a value a random distance within the range, mostly to
+// somewhere nearby
+func (r Range) mutate(rng *rand.Rand, v float64) float64 {
+	points := r.points()
+	if points == nil {
+		v += rng.NormFloat64() * (r.Max - r.Min) / 10
+		return min(max(v, r.Min), r.Max)
+	}
+	at := 0
+	for i, p := range points {
+		if math.Abs(p-v) < math.Abs(points[at]-v) {
+			at = i
+		}
+	}
+	shift := int(math.Round(rng.NormFloat64() * max(1, float64(len(points))/10)))
+	if shift == 0 {
+		shift = 1 - 2*rng.Intn(2)
+	}
+	return points[min(max(at+shift, 0), len(points)-1)]
+}
@@ -0,0 +1,97 @@
+package optimize
+
+import (
+	"fmt"
+	"sort"
+)
+
+// HeatmapBins caps the cells along each axis of a heatmap. An axis with more
+// distinct values, as a continuous range searched at random has, is split
+// into this many equal bins.
+const HeatmapBins = 50
+
+// Heatmap is the best score found at each pair of values of two parameters,
+// taken over every value of the others. Scores[y][x] is nil where no ranked
+// trial used that pair.
+//
+// A binned axis lists the middle of each bin as its values and the bins'
+// bounds as its edges, one more than the values; a cell then holds the best
+// score of the trials falling in both its bins.
+type Heatmap struct {
+	X       string       `json:"x"`
+	Y       string       `json:"y"`
+	XValues []float64    `json:"xValues"`
+	YValues []float64    `json:"yValues"`
+	XEdges  []float64    `json:"xEdges,omitempty"`
+	YEdges  []float64    `json:"yEdges,omitempty"`
+	Scores  [][]*float64 `json:"scores"`
+}
+
+// NewHeatmap lays out ranked trials over parameters x and y
+func NewHeatmap(trials []Trial, x, y string) (*Heatmap, error) {
+	if x == y {
+		return nil, fmt.Errorf("heatmap needs two different parameters")
+	}
+	xs, ys := map[float64]int{}, map[float64]int{}
+	for _, t := range trials {
+		vx, okx := t.Params[x]
+		vy, oky := t.Params[y]
+		if !okx || !oky {
+			return nil, fmt.Errorf("trials do not vary both %s and %s", x, y)
+		}
+		xs[vx], ys[vy] = 0, 0
+	}
+
+	h := &Heatmap{X: x, Y: y}
+	h.XValues, h.XEdges = index(xs)
+	h.YValues, h.YEdges = index(ys)
+	h.Scores = make([][]*float64, len(h.YValues))
+	for i := range h.Scores {
+		h.Scores[i] = make([]*float64, len(h.XValues))
+	}
+	for _, t := range trials {
+		if t.Rank == 0 {
+			continue
+		}
+		cell := &h.Scores[ys[t.Params[y]]][xs[t.Params[x]]]
+		if *cell == nil || t.Score > **cell {
+			score := t.Score
+			*cell = &score
+		}
+	}
+	return h, nil
+}
+
+// index sorts the keys of a value set and maps each to its position, or to
+// its bin when there are more than HeatmapBins. It returns the values of the
+// positions and, when binned, the bins' edges.
+func index(set map[float64]int) ([]float64, []float64) {
+	values := make([]float64, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Float64s(values)
+	if len(values) <= HeatmapBins {
+		for i, v := range values {
+			set[v] = i
+		}
+		return values, nil
+	}
+
+	lo, hi := values[0], values[len(values)-1]
+	width := (hi - lo) / HeatmapBins
+	edges := make([]float64, HeatmapBins+1)
+	for i := range edges {
+		edges[i] = lo + float64(i)*width
+	}
+	edges[HeatmapBins] = hi
+	mids := make([]float64, HeatmapBins)
+	for i := range mids {
+		mids[i] = (edges[i] + edges[i+1]) / 2
+	}
+	for _, v := range values {
+		// The top edge belongs to the last bin
+		set[v] = min(int((v-lo)/width), HeatmapBins-1)
+	}
+	return mids, edges
+}
@@ -0,0 +1,395 @@
+// Package optimize searches a strategy's parameter space, running the
+// backtest engine once per parameter set and ranking the runs by an
+// objective.
+package optimize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/metrics"
+)
+
+// MaxTrials bounds the parameter sets one search may run
+const MaxTrials = 5000
+
+// Search methods
+const (
+	MethodGrid    = "grid"
+	MethodRandom  = "random"
+	MethodGenetic = "genetic"
+)
+
+// Params is one set of parameter values
+type Params map[string]float64
+
+// Range is the values a parameter may take: Values when given, otherwise
+// Min to Max in steps of Step. Random search draws any value between Min
+// and Max when Step is zero.
+type Range struct {
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+	Step   float64   `json:"step,omitempty"`
+	Values []float64 `json:"values,omitempty"`
+}
+
+// Space is the ranges of the parameters being searched
+type Space map[string]Range
+
+// points lists a range's values, or nil for a continuous range
+func (r Range) points() []float64 {
+	if len(r.Values) > 0 {
+		return r.Values
+	}
+	if r.Step <= 0 {
+		return nil
+	}
+	n := int(math.Floor((r.Max-r.Min)/r.Step+1e-9)) + 1
+	list := make([]float64, n)
+	for i := range list {
+		// Rounding keeps steps such as 0.1 from drifting
+		list[i] = math.Round((r.Min+float64(i)*r.Step)*1e9) / 1e9
+	}
+	return list
+}
+
+// Validate checks every range
+func (s Space) Validate() error {
+	if len(s) == 0 {
+		return errors.New("at least one parameter range is required")
+	}
+	for _, name := range s.Names() {
+		r := s[name]
+		if len(r.Values) > 0 {
+			continue
+		}
+		switch {
+		case math.IsNaN(r.Min) || math.IsNaN(r.Max) || r.Max < r.Min:
+			return fmt.Errorf("range of %s must have min no greater than max", name)
+		case r.Step < 0:
+			return fmt.Errorf("step of %s must not be negative", name)
+		case r.Step > 0 && (r.Max-r.Min)/r.Step >= MaxTrials:
+			return fmt.Errorf("range of %s has more than %d steps", name, MaxTrials)
+		}
+	}
+	return nil
+}
+
+// Names returns the parameter names in order
+func (s Space) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Extremes returns parameter sets at the low and high ends of every range,
+// enough to check that a strategy accepts the whole space
+func (s Space) Extremes() []Params {
+	low, high := Params{}, Params{}
+	for name, r := range s {
+		if points := r.points(); points != nil {
+			low[name], high[name] = slices.Min(points), slices.Max(points)
+		} else {
+			low[name], high[name] = r.Min, r.Max
+		}
+	}
+	return []Params{low, high}
+}
+
+// Grid returns every combination of the ranges' values. Every range needs
+// a step or values.
+func (s Space) Grid() ([]Params, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	names := s.Names()
+	total := 1
+	for _, name := range names {
+		points := s[name].points()
+		if points == nil {
+			return nil, fmt.Errorf("grid search needs a step or values for %s", name)
+		}
+		total *= len(points)
+		if total > MaxTrials {
+			return nil, fmt.Errorf("grid has more than %d combinations; narrow the ranges or use random search", MaxTrials)
+		}
+	}
+
+	sets := []Params{{}}
+	for _, name := range names {
+		var next []Params
+		for _, set := range sets {
+			for _, v := range s[name].points() {
+				combined := make(Params, len(set)+1)
+				for k, existing := range set {
+					combined[k] = existing
+				}
+				combined[name] = v
+				next = append(next, combined)
+			}
+		}
+		sets = next
+	}
+	return sets, nil
+}
+
+// Random draws n distinct sets with the given seed. It returns fewer when
+// the space has fewer than n points.
+func (s Space) Random(n int, seed int64) ([]Params, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if n <= 0 || n > MaxTrials {
+		return nil, fmt.Errorf("samples must be between 1 and %d", MaxTrials)
+	}
+	rng := rand.New(rand.NewSource(seed))
+	names := s.Names()
+	seen := make(map[string]bool)
+	var sets []Params
+	// Give up on finding new points after many misses in a row
+	for misses := 0; len(sets) < n && misses < 100*n; {
+		set := make(Params, len(names))
+		for _, name := range names {
+			r := s[name]
+			if points := r.points(); points != nil {
+				set[name] = points[rng.Intn(len(points))]
+			} else {
+				set[name] = r.Min + rng.Float64()*(r.Max-r.Min)
+			}
+		}
+		if key := set.key(names); !seen[key] {
+			seen[key] = true
+			sets = append(sets, set)
+			misses = 0
+		} else {
+			misses++
+		}
+	}
+	return sets, nil
+}
+
+func (p Params) key(names []string) string {
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = strconv.FormatFloat(p[name], 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Objective is what a search maximizes: one metric, or for "custom" the
+// weighted sum of several. Drawdown is minimized.
+type Objective struct {
+	Metric  string             `json:"metric"`
+	Weights map[string]float64 `json:"weights,omitempty"`
+}
+
+// Metrics an objective can use
+var objectiveMetrics = map[string]func(*engine.Summary, *metrics.Report) float64{
+	"netProfit":      func(s *engine.Summary, _ *metrics.Report) float64 { return s.NetProfit },
+	"profitFactor":   func(s *engine.Summary, _ *metrics.Report) float64 { return s.ProfitFactor },
+	"winRate":        func(s *engine.Summary, _ *metrics.Report) float64 { return s.WinRate },
+	"maxDrawdown":    func(s *engine.Summary, _ *metrics.Report) float64 { return s.MaxDrawdown },
+	"totalTrades":    func(s *engine.Summary, _ *metrics.Report) float64 { return float64(s.TotalTrades) },
+	"totalReturn":    func(_ *engine.Summary, r *metrics.Report) float64 { return r.TotalReturn },
+	"annualReturn":   func(_ *engine.Summary, r *metrics.Report) float64 { return r.AnnualReturn },
+	"sharpe":         func(_ *engine.Summary, r *metrics.Report) float64 { return r.Sharpe },
+	"sortino":        func(_ *engine.Summary, r *metrics.Report) float64 { return r.Sortino },
+	"calmar":         func(_ *engine.Summary, r *metrics.Report) float64 { return r.Calmar },
+	"recoveryFactor": func(_ *engine.Summary, r *metrics.Report) float64 { return r.RecoveryFactor },
+	"expectancy":     func(_ *engine.Summary, r *metrics.Report) float64 { return r.Expectancy },
+}
+
+// ObjectiveMetrics lists the metrics an objective can use
+func ObjectiveMetrics() []string {
+	names := make([]string, 0, len(objectiveMetrics))
+	for name := range objectiveMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the objective, defaulting to net profit
+func (o *Objective) Validate() error {
+	if o.Metric == "" {
+		o.Metric = "netProfit"
+	}
+	if o.Metric != "custom" {
+		if objectiveMetrics[o.Metric] == nil {
+			return fmt.Errorf("unknown objective %q; use one of %s or custom", o.Metric, strings.Join(ObjectiveMetrics(), ", "))
+		}
+		return nil
+	}
+	if len(o.Weights) == 0 {
+		return errors.New("a custom objective needs weights")
+	}
+	for name := range o.Weights {
+		if objectiveMetrics[name] == nil {
+			return fmt.Errorf("unknown metric %q in weights", name)
+		}
+	}
+	return nil
+}
+
+// Score rates a run; higher is better
+func (o Objective) Score(s *engine.Summary, r *metrics.Report) float64 {
+	if o.Metric != "custom" {
+		v := objectiveMetrics[o.Metric](s, r)
+		if o.Metric == "maxDrawdown" {
+			return -v
+		}
+		return v
+	}
+	var sum float64
+	for name, w := range o.Weights {
+		sum += w * objectiveMetrics[name](s, r)
+	}
+	return sum
+}
+
+// Trial is the outcome of one parameter set
+type Trial struct {
+	Params       Params  `json:"params"`
+	Score        float64 `json:"score"`
+	Rank         int     `json:"rank,omitempty"` // 1 for the best; zero when unranked
+	NetProfit    float64 `json:"netProfit"`
+	TotalTrades  int     `json:"totalTrades"`
+	WinRate      float64 `json:"winRate"`
+	ProfitFactor float64 `json:"profitFactor"`
+	MaxDrawdown  float64 `json:"maxDrawdown"`
+	Sharpe       float64 `json:"sharpe"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// Evaluate runs one parameter set
+type Evaluate func(ctx context.Context, params Params) (*engine.Result, error)
+
+// Outcome is a finished search
+type Outcome struct {
+	Trials      []Trial         // In the order of the sets searched
+	Best        *engine.Result  // Full result of the best trial; nil if none ranked
+	Report      *metrics.Report // Its metrics
+	Generations int             // Generations a genetic search ran
+}
+
+// Search evaluates every set on up to workers goroutines and ranks them by
+// obj. Trials with fewer than minTrades trades, or that failed, are left
+// unranked. Only the best trial's full result is kept.
+func Search(ctx context.Context, sets []Params, obj Objective, minTrades, workers int, eval Evaluate, progress func(done, total int)) (*Outcome, error) {
+	out := &Outcome{Trials: make([]Trial, len(sets))}
+	best := -1
+	var mu sync.Mutex
+	done := 0
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				trial := Trial{Params: sets[i]}
+				result, err := eval(ctx, sets[i])
+				var report *metrics.Report
+				if err != nil {
+					trial.Error = err.Error()
+				} else {
+					s := &result.Summary
+					report = metrics.Compute(result)
+					trial.Score = round(obj.Score(s, report))
+					trial.NetProfit = s.NetProfit
+					trial.TotalTrades = s.TotalTrades
+					trial.WinRate = s.WinRate
+					trial.ProfitFactor = s.ProfitFactor
+					trial.MaxDrawdown = s.MaxDrawdown
+					trial.Sharpe = report.Sharpe
+				}
+
+				mu.Lock()
+				out.Trials[i] = trial
+				if ranked(trial, minTrades) && (best < 0 || better(out.Trials, i, best)) {
+					best, out.Best, out.Report = i, result, report
+				}
+				done++
+				if progress != nil {
+					progress(done, len(sets))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range sets {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	Rank(out.Trials, minTrades)
+	return out, nil
+}
+
+// Rank numbers the trials with at least minTrades trades from best to
+// worst, leaving the others unranked
+func Rank(trials []Trial, minTrades int) {
+	order := make([]int, 0, len(trials))
+	for i := range trials {
+		trials[i].Rank = 0
+		if ranked(trials[i], minTrades) {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool { return better(trials, order[a], order[b]) })
+	for rank, i := range order {
+		trials[i].Rank = rank + 1
+	}
+}
+
+// Top returns the best ranked trial, if any was ranked
+func (o *Outcome) Top() (Trial, bool) {
+	for _, t := range o.Trials {
+		if t.Rank == 1 {
+			return t, true
+		}
+	}
+	return Trial{}, false
+}
+
+func ranked(t Trial, minTrades int) bool {
+	return t.Error == "" && t.TotalTrades >= minTrades
+}
+
+// better orders trials by score, then by the order they were listed
+func better(trials []Trial, i, j int) bool {
+	if trials[i].Score != trials[j].Score {
+		return trials[i].Score > trials[j].Score
+	}
+	return i < j
+}
+
+func round(v float64) float64 {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0
+	}
+	return math.Round(v*1e4) / 1e4
+}
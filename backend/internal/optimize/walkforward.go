@@ -0,0 +1,116 @@
+package optimize
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bounds on a walk-forward analysis
+const (
+	MaxWindows    = 50
+	MinWindowBars = 50 // Fewest bars in either part of a window
+)
+
+// Window is one step of a walk-forward analysis over a series of bars:
+// bars [InStart, InEnd) are optimized on and the best set is then tested
+// on [InEnd, OutEnd)
+type Window struct {
+	InStart, InEnd, OutEnd int
+}
+
+// CheckWindows checks a walk-forward layout before there are bars to split
+func CheckWindows(n int, outOfSample float64) error {
+	if n < 1 || n > MaxWindows {
+		return fmt.Errorf("windows must be between 1 and %d", MaxWindows)
+	}
+	if outOfSample <= 0 || outOfSample >= 100 {
+		return fmt.Errorf("outOfSample must be a percent between 0 and 100")
+	}
+	return nil
+}
+
+// Split lays out n walk-forward windows over a series of bars. Each
+// out-of-sample part is outOfSample percent of a window, and together they
+// run back to back up to the last bar. A rolling in-sample part has a fixed
+// length and ends where its out-of-sample part starts; an anchored one
+// starts at the first bar.
+func Split(bars, n int, outOfSample float64, anchored bool) ([]Window, error) {
+	if err := CheckWindows(n, outOfSample); err != nil {
+		return nil, err
+	}
+	ratio := (100 - outOfSample) / outOfSample // In-sample bars per out-of-sample bar
+	outLen := int(float64(bars) / (float64(n) + ratio))
+	inLen := int(float64(outLen) * ratio)
+	if outLen < MinWindowBars || inLen < MinWindowBars {
+		return nil, fmt.Errorf("%d bars is too few for %d windows of at least %d bars in and out of sample", bars, n, MinWindowBars)
+	}
+
+	start := bars - n*outLen - inLen
+	windows := make([]Window, n)
+	for i := range windows {
+		w := &windows[i]
+		w.InEnd = start + inLen + i*outLen
+		w.OutEnd = w.InEnd + outLen
+		if !anchored {
+			w.InStart = w.InEnd - inLen
+		}
+	}
+	return windows, nil
+}
+
+// WindowReport is the outcome of one walk-forward window. Returns are
+// percent of the starting balance, annualized over the part's duration.
+type WindowReport struct {
+	InSampleStart     time.Time `json:"inSampleStart"`
+	InSampleEnd       time.Time `json:"inSampleEnd"`
+	OutOfSampleStart  time.Time `json:"outOfSampleStart"`
+	OutOfSampleEnd    time.Time `json:"outOfSampleEnd"`
+	Params            Params    `json:"params"` // Best in-sample set
+	Score             float64   `json:"score"`  // Its objective in sample
+	InSampleProfit    float64   `json:"inSampleProfit"`
+	InSampleReturn    float64   `json:"inSampleReturn"`
+	OutOfSampleProfit float64   `json:"outOfSampleProfit"`
+	OutOfSampleReturn float64   `json:"outOfSampleReturn"`
+	OutOfSampleTrades int       `json:"outOfSampleTrades"`
+	Efficiency        float64   `json:"efficiency"` // Out-of-sample over in-sample return; zero when the latter is not positive
+	BacktestID        string    `json:"backtestId"` // Stored out-of-sample run
+}
+
+// WalkForwardReport sums up a walk-forward analysis
+type WalkForwardReport struct {
+	Anchored    bool           `json:"anchored"`
+	OutOfSample float64        `json:"outOfSample"` // Percent of each window
+	Objective   Objective      `json:"objective"`
+	Windows     []WindowReport `json:"windows"`
+	Efficiency  float64        `json:"efficiency"`        // Mean out-of-sample over mean in-sample return; zero when the latter is not positive
+	Stopped     string         `json:"stopped,omitempty"` // Why windows were left untested
+}
+
+// Annualized turns a profit made over [from, to) into a yearly percent of
+// balance. It is zero for an empty period.
+func Annualized(profit, balance float64, from, to time.Time) float64 {
+	days := to.Sub(from).Hours() / 24
+	if days <= 0 || balance <= 0 {
+		return 0
+	}
+	return round(profit / balance * 100 * 365 / days)
+}
+
+// Summarize fills in the efficiency of each window and of the whole analysis
+func (r *WalkForwardReport) Summarize() {
+	var in, out float64
+	for i := range r.Windows {
+		w := &r.Windows[i]
+		w.Efficiency = efficiency(w.OutOfSampleReturn, w.InSampleReturn)
+		in += w.InSampleReturn
+		out += w.OutOfSampleReturn
+	}
+	r.Efficiency = efficiency(out, in)
+}
+
+func efficiency(out, in float64) float64 {
+	if in <= 0 {
+		return 0
+	}
+	return round(out / in)
+}
@@ -0,0 +1,250 @@
+package pricestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Chunk file layout (little endian):
+//
+//	header       32 bytes: magic, kind, digits, column count, row count,
+//	             block count, first and last timestamp (unix ms)
+//	columns      per column: absolute data offset uint32, length uint32
+//	block index  per block, per column: base value int64, data offset uint32
+//	data         per column: zigzag varint deltas, restarting at each block
+//
+// Every column is an int64 series: timestamps in milliseconds, prices and
+// volumes in fixed point. Blocks let a reader start decoding at any multiple
+// of blockRows without touching earlier bytes.
+const (
+	chunkMagic      = "SFC1"
+	chunkHeaderSize = 32
+	blockRows       = 1024
+	blockEntrySize  = 12
+)
+
+// Column counts per kind
+const (
+	tickColumns = 5 // time, bid, ask, bid volume, ask volume
+	barColumns  = 7 // time, open, high, low, close, volume, spread
+)
+
+const (
+	kindTickCode = 1
+	kindBarCode  = 2
+)
+
+// ErrCorruptChunk is returned when a chunk file fails structural checks
+var ErrCorruptChunk = errors.New("corrupt chunk file")
+
+// encodeChunk serializes column-major rows. All columns must have equal length.
+func encodeChunk(kind uint8, digits uint8, cols [][]int64) []byte {
+	ncols := len(cols)
+	rows := len(cols[0])
+	blocks := (rows + blockRows - 1) / blockRows
+
+	dataStart := chunkHeaderSize + ncols*8 + blocks*ncols*blockEntrySize
+
+	index := make([]byte, blocks*ncols*blockEntrySize)
+	colData := make([][]byte, ncols)
+	var tmp [binary.MaxVarintLen64]byte
+
+	for c, values := range cols {
+		buf := make([]byte, 0, rows*2)
+		for b := 0; b < blocks; b++ {
+			first := b * blockRows
+			last := min(first+blockRows, rows)
+			base := values[first]
+
+			entry := index[(b*ncols+c)*blockEntrySize:]
+			binary.LittleEndian.PutUint64(entry[0:8], uint64(base))
+			binary.LittleEndian.PutUint32(entry[8:12], uint32(len(buf)))
+
+			prev := base
+			for _, v := range values[first+1 : last] {
+				n := binary.PutVarint(tmp[:], v-prev)
+				buf = append(buf, tmp[:n]...)
+				prev = v
+			}
+		}
+		colData[c] = buf
+	}
+
+	size := dataStart
+	for _, d := range colData {
+		size += len(d)
+	}
+
+	out := make([]byte, chunkHeaderSize, size)
+	copy(out[0:4], chunkMagic)
+	out[4] = kind
+	out[5] = digits
+	out[6] = uint8(ncols)
+	binary.LittleEndian.PutUint32(out[8:12], uint32(rows))
+	binary.LittleEndian.PutUint32(out[12:16], uint32(blocks))
+	binary.LittleEndian.PutUint64(out[16:24], uint64(cols[0][0]))
+	binary.LittleEndian.PutUint64(out[24:32], uint64(cols[0][rows-1]))
+
+	offset := dataStart
+	for _, d := range colData {
+		out = binary.LittleEndian.AppendUint32(out, uint32(offset))
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(d)))
+		offset += len(d)
+	}
+	out = append(out, index...)
+	for _, d := range colData {
+		out = append(out, d...)
+	}
+	return out
+}
+
+// chunk is a decoded view over an encoded (usually memory-mapped) file
+type chunk struct {
+	data   []byte
+	kind   uint8
+	digits uint8
+	ncols  int
+	rows   int
+	blocks int
+	start  int64
+	end    int64
+	cols   [][]byte // Data section of each column
+}
+
+func parseChunk(data []byte) (*chunk, error) {
+	if len(data) < chunkHeaderSize || string(data[0:4]) != chunkMagic {
+		return nil, ErrCorruptChunk
+	}
+
+	c := &chunk{
+		data:   data,
+		kind:   data[4],
+		digits: data[5],
+		ncols:  int(data[6]),
+		rows:   int(binary.LittleEndian.Uint32(data[8:12])),
+		blocks: int(binary.LittleEndian.Uint32(data[12:16])),
+		start:  int64(binary.LittleEndian.Uint64(data[16:24])),
+		end:    int64(binary.LittleEndian.Uint64(data[24:32])),
+	}
+
+	want := tickColumns
+	switch c.kind {
+	case kindTickCode:
+	case kindBarCode:
+		want = barColumns
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %d", ErrCorruptChunk, c.kind)
+	}
+	if c.ncols != want || c.blocks != (c.rows+blockRows-1)/blockRows {
+		return nil, ErrCorruptChunk
+	}
+
+	dir := chunkHeaderSize
+	indexEnd := dir + c.ncols*8 + c.blocks*c.ncols*blockEntrySize
+	if len(data) < indexEnd {
+		return nil, ErrCorruptChunk
+	}
+
+	c.cols = make([][]byte, c.ncols)
+	for i := range c.cols {
+		off := int(binary.LittleEndian.Uint32(data[dir+i*8:]))
+		n := int(binary.LittleEndian.Uint32(data[dir+i*8+4:]))
+		if off < indexEnd || off+n > len(data) {
+			return nil, fmt.Errorf("%w: column %d out of bounds", ErrCorruptChunk, i)
+		}
+		c.cols[i] = data[off : off+n]
+	}
+
+	// Cursors slice columns at block offsets without checking them, so a
+	// damaged index is caught here rather than as a panic mid-read
+	for b := range c.blocks {
+		for col, colData := range c.cols {
+			if _, off := c.blockEntry(b, col); off > len(colData) {
+				return nil, fmt.Errorf("%w: block %d of column %d out of bounds", ErrCorruptChunk, b, col)
+			}
+		}
+	}
+	return c, nil
+}
+
+// blockEntry returns the base value and data offset of a column in a block
+func (c *chunk) blockEntry(block, col int) (int64, int) {
+	entry := c.data[chunkHeaderSize+c.ncols*8+(block*c.ncols+col)*blockEntrySize:]
+	return int64(binary.LittleEndian.Uint64(entry[0:8])), int(binary.LittleEndian.Uint32(entry[8:12]))
+}
+
+// findBlock returns the last block whose first timestamp is <= t
+func (c *chunk) findBlock(t int64) int {
+	i := sort.Search(c.blocks, func(b int) bool {
+		base, _ := c.blockEntry(b, 0)
+		return base > t
+	})
+	if i == 0 {
+		return 0
+	}
+	return i - 1
+}
+
+// cursor decodes a chunk row by row. It is always positioned on a row
+// until advance reports the end.
+type cursor struct {
+	c     *chunk
+	row   int
+	pos   []int
+	value []int64
+}
+
+func newCursor(c *chunk) *cursor {
+	return &cursor{
+		c:     c,
+		pos:   make([]int, c.ncols),
+		value: make([]int64, c.ncols),
+	}
+}
+
+// seek positions the cursor on the first row of a block
+func (cur *cursor) seek(block int) {
+	cur.row = block * blockRows
+	for col := 0; col < cur.c.ncols; col++ {
+		cur.value[col], cur.pos[col] = cur.c.blockEntry(block, col)
+	}
+}
+
+// advance moves to the following row, returning false at the end
+func (cur *cursor) advance() (bool, error) {
+	cur.row++
+	if cur.row >= cur.c.rows {
+		return false, nil
+	}
+	if cur.row%blockRows == 0 {
+		cur.seek(cur.row / blockRows)
+		return true, nil
+	}
+	for col, data := range cur.c.cols {
+		delta, n := binary.Varint(data[cur.pos[col]:])
+		if n <= 0 {
+			return false, ErrCorruptChunk
+		}
+		cur.pos[col] += n
+		cur.value[col] += delta
+	}
+	return true, nil
+}
+
+// seekTime positions the cursor on the first row at or after t, using the
+// block index to skip everything before the containing block
+func (cur *cursor) seekTime(t int64) (bool, error) {
+	if cur.c.rows == 0 {
+		return false, nil
+	}
+	cur.seek(cur.c.findBlock(t))
+	for cur.value[0] < t {
+		ok, err := cur.advance()
+		if !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,98 @@
+package pricestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testChunk encodes bars spanning several blocks
+func testChunk() ([]byte, [][]int64) {
+	rows := 2*blockRows + 100
+	cols := make([][]int64, barColumns)
+	for c := range cols {
+		cols[c] = make([]int64, rows)
+		for i := range rows {
+			cols[c][i] = int64(1_700_000_000_000+i*60_000) + int64(c*(i%17))
+		}
+	}
+	return encodeChunk(kindBarCode, 5, cols), cols
+}
+
+// readChunk decodes every row of a chunk
+func readChunk(data []byte) ([][]int64, error) {
+	c, err := parseChunk(data)
+	if err != nil {
+		return nil, err
+	}
+	cols := make([][]int64, c.ncols)
+	if c.rows == 0 {
+		return cols, nil
+	}
+	cur := newCursor(c)
+	cur.seek(0)
+	for {
+		for i, v := range cur.value {
+			cols[i] = append(cols[i], v)
+		}
+		ok, err := cur.advance()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return cols, nil
+		}
+	}
+}
+
+func TestChunkRoundTrip(t *testing.T) {
+	data, want := testChunk()
+	got, err := readChunk(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for c := range want {
+		for i := range want[c] {
+			if got[c][i] != want[c][i] {
+				t.Fatalf("column %d row %d = %d, want %d", c, i, got[c][i], want[c][i])
+			}
+		}
+	}
+}
+
+func TestTruncatedChunkIsCorrupt(t *testing.T) {
+	data, _ := testChunk()
+	for n := range len(data) {
+		if _, err := readChunk(data[:n]); !errors.Is(err, ErrCorruptChunk) {
+			t.Fatalf("chunk cut to %d of %d bytes: err = %v, want ErrCorruptChunk", n, len(data), err)
+		}
+	}
+}
+
+func TestCorruptBlockIndexIsCaught(t *testing.T) {
+	data, _ := testChunk()
+	c, err := parseChunk(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := chunkHeaderSize + c.ncols*8 + (1*c.ncols+2)*blockEntrySize
+	binary.LittleEndian.PutUint32(data[entry+8:], uint32(len(c.cols[2])+1))
+
+	if _, err := parseChunk(data); !errors.Is(err, ErrCorruptChunk) {
+		t.Errorf("err = %v, want ErrCorruptChunk", err)
+	}
+}
+
+func TestDamagedChunkNeverPanics(t *testing.T) {
+	data, _ := testChunk()
+	c, _ := parseChunk(data)
+	indexEnd := chunkHeaderSize + c.ncols*8 + c.blocks*c.ncols*blockEntrySize
+
+	// Every byte of the header, column directory and block index is damaged
+	// in turn; decoding may fail but must not panic
+	for i := range indexEnd {
+		damaged := append([]byte(nil), data...)
+		damaged[i] ^= 0xff
+		readChunk(damaged)
+	}
+}
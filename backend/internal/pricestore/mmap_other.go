@@ -0,0 +1,14 @@
+//go:build !unix
+
+package pricestore
+
+import "os"
+
+// mapFile reads a chunk into memory on platforms without mmap
+func mapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,230 @@
+package pricestore
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// rows walks the rows of a series in [from, to), mapping one chunk at a time
+type rows struct {
+	dir     string
+	chunks  []ChunkInfo
+	next    int
+	from    int64
+	to      int64
+	cur     *cursor
+	release func() error
+	err     error
+}
+
+func (s *Store) rows(symbol, kind string, from, to time.Time) (*rows, *SeriesInfo, error) {
+	info, err := s.Info(symbol, kind)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &rows{
+		dir:  s.seriesDir(symbol, kind),
+		from: math.MinInt64,
+		to:   math.MaxInt64,
+	}
+	if !from.IsZero() {
+		r.from = from.UnixMilli()
+	}
+	if !to.IsZero() {
+		r.to = to.UnixMilli()
+	}
+
+	// Chunks are ordered and disjoint, so the first one that can hold a row
+	// at or after from is found by binary search
+	first := sort.Search(len(info.Chunks), func(i int) bool {
+		return info.Chunks[i].End >= r.from
+	})
+	for _, c := range info.Chunks[first:] {
+		if c.Start >= r.to {
+			break
+		}
+		r.chunks = append(r.chunks, c)
+	}
+	return r, info, nil
+}
+
+// advance moves to the next row in range, opening chunks as needed
+func (r *rows) advance() bool {
+	if r.err != nil {
+		return false
+	}
+	for {
+		if r.cur == nil {
+			if r.next >= len(r.chunks) {
+				return false
+			}
+			ok, err := r.open(r.chunks[r.next])
+			r.next++
+			if err != nil {
+				r.fail(err)
+				return false
+			}
+			if !ok {
+				r.closeChunk()
+				continue
+			}
+		} else {
+			ok, err := r.cur.advance()
+			if err != nil {
+				r.fail(err)
+				return false
+			}
+			if !ok {
+				r.closeChunk()
+				continue
+			}
+		}
+
+		if r.cur.value[0] >= r.to {
+			r.closeChunk()
+			r.next = len(r.chunks)
+			return false
+		}
+		return true
+	}
+}
+
+// open maps a chunk and positions the cursor on its first row in range
+func (r *rows) open(info ChunkInfo) (bool, error) {
+	path := filepath.Join(r.dir, info.File)
+	data, release, err := mapFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open chunk %s: %w", info.File, err)
+	}
+	r.release = release
+
+	c, err := parseChunk(data)
+	if err != nil {
+		return false, fmt.Errorf("chunk %s: %w", info.File, err)
+	}
+	r.cur = newCursor(c)
+	return r.cur.seekTime(r.from)
+}
+
+func (r *rows) fail(err error) {
+	r.err = err
+	r.closeChunk()
+}
+
+func (r *rows) closeChunk() {
+	r.cur = nil
+	if r.release != nil {
+		if err := r.release(); err != nil && r.err == nil {
+			r.err = err
+		}
+		r.release = nil
+	}
+}
+
+// TickIterator reads ticks in time order
+type TickIterator struct {
+	rows  *rows
+	scale float64
+	tick  marketdata.Tick
+}
+
+// Ticks iterates the ticks of symbol with from <= time < to. A zero bound is
+// open-ended. Callers must Close the iterator.
+func (s *Store) Ticks(symbol string, from, to time.Time) (*TickIterator, error) {
+	r, info, err := s.rows(symbol, marketdata.KindTick, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &TickIterator{rows: r, scale: math.Pow10(info.Digits)}, nil
+}
+
+// Next advances to the next tick, returning false at the end or on error
+func (it *TickIterator) Next() bool {
+	if !it.rows.advance() {
+		return false
+	}
+	v := it.rows.cur.value
+	it.tick = marketdata.Tick{
+		Time:      time.UnixMilli(v[0]).UTC(),
+		Bid:       float64(v[1]) / it.scale,
+		Ask:       float64(v[2]) / it.scale,
+		BidVolume: float64(v[3]) / volumeScale,
+		AskVolume: float64(v[4]) / volumeScale,
+	}
+	return true
+}
+
+// Tick returns the current tick
+func (it *TickIterator) Tick() marketdata.Tick {
+	return it.tick
+}
+
+// Err returns the first error encountered while reading
+func (it *TickIterator) Err() error {
+	return it.rows.err
+}
+
+// Close releases the mapped chunk
+func (it *TickIterator) Close() error {
+	it.rows.closeChunk()
+	it.rows.next = len(it.rows.chunks)
+	return it.rows.err
+}
+
+// BarIterator reads bars in time order
+type BarIterator struct {
+	rows  *rows
+	scale float64
+	bar   marketdata.Bar
+}
+
+// Bars iterates the bars of symbol opening at from <= time < to. A zero
+// bound is open-ended. Callers must Close the iterator.
+func (s *Store) Bars(symbol string, from, to time.Time) (*BarIterator, error) {
+	r, info, err := s.rows(symbol, marketdata.KindBar, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &BarIterator{rows: r, scale: math.Pow10(info.Digits)}, nil
+}
+
+// Next advances to the next bar, returning false at the end or on error
+func (it *BarIterator) Next() bool {
+	if !it.rows.advance() {
+		return false
+	}
+	v := it.rows.cur.value
+	it.bar = marketdata.Bar{
+		Time:   time.UnixMilli(v[0]).UTC(),
+		Open:   float64(v[1]) / it.scale,
+		High:   float64(v[2]) / it.scale,
+		Low:    float64(v[3]) / it.scale,
+		Close:  float64(v[4]) / it.scale,
+		Volume: float64(v[5]) / volumeScale,
+		Spread: float64(v[6]) / it.scale,
+	}
+	return true
+}
+
+// Bar returns the current bar
+func (it *BarIterator) Bar() marketdata.Bar {
+	return it.bar
+}
+
+// Err returns the first error encountered while reading
+func (it *BarIterator) Err() error {
+	return it.rows.err
+}
+
+// Close releases the mapped chunk
+func (it *BarIterator) Close() error {
+	it.rows.closeChunk()
+	it.rows.next = len(it.rows.chunks)
+	return it.rows.err
+}
@@ -0,0 +1,361 @@
+// Package pricestore is a compact columnar on-disk store for ticks and bars.
+//
+// Each symbol's series is split into chunk files, one per UTC day for ticks
+// and one per UTC month for bars, listed in a small JSON index with each
+// chunk's time range. Readers memory-map one chunk at a time and use the
+// index and the per-chunk block index to start scanning close to the
+// requested time, so a range scan never loads the whole series.
+package pricestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// DefaultDigits is the fixed-point precision used when the source's is unknown
+const DefaultDigits = 6
+
+// volumeScale keeps four decimals of volume, enough for Dukascopy's
+// fractional lots
+const volumeScale = 1e4
+
+const indexFile = "index.json"
+
+var (
+	// ErrNotFound is returned when a series has not been written
+	ErrNotFound = errors.New("series not found")
+)
+
+// ChunkInfo locates one chunk file and the time range it covers
+type ChunkInfo struct {
+	File  string `json:"file"`
+	Start int64  `json:"start"` // Unix milliseconds, inclusive
+	End   int64  `json:"end"`   // Unix milliseconds, inclusive
+	Count int    `json:"count"`
+}
+
+// SeriesInfo describes a stored series
+type SeriesInfo struct {
+	Symbol    string      `json:"symbol"`
+	Kind      string      `json:"kind"`
+	Digits    int         `json:"digits"`
+	Timeframe string      `json:"timeframe,omitempty"`
+	Count     int64       `json:"count"`
+	Start     time.Time   `json:"start"`
+	End       time.Time   `json:"end"`
+	Chunks    []ChunkInfo `json:"chunks"`
+}
+
+// Store is a directory of series
+type Store struct {
+	root string
+}
+
+// Open returns a store rooted at dir. Nothing is read until a series is used.
+func Open(dir string) *Store {
+	return &Store{root: dir}
+}
+
+func (s *Store) seriesDir(symbol, kind string) string {
+	return filepath.Join(s.root, symbol, kind)
+}
+
+// Info reads the index of a series
+func (s *Store) Info(symbol, kind string) (*SeriesInfo, error) {
+	data, err := os.ReadFile(filepath.Join(s.seriesDir(symbol, kind), indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var info SeriesInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %w", err)
+	}
+	return &info, nil
+}
+
+// Remove deletes every series in the store
+func (s *Store) Remove() error {
+	return os.RemoveAll(s.root)
+}
+
+// WriterOptions configures a new series
+type WriterOptions struct {
+	Digits    int    // Price decimals kept; DefaultDigits when zero
+	Timeframe string // Bar timeframe, recorded in the index
+}
+
+// Writer appends rows to a series. Rows are buffered per chunk and sorted on
+// flush. Rows should arrive in time order: a row for a chunk already on disk
+// reloads and rewrites that chunk, which is correct but slow.
+type Writer struct {
+	dir      string
+	info     SeriesInfo
+	kindCode uint8
+	scale    float64
+	key      string
+	cols     [][]int64
+	flushed  map[string]bool
+	existing map[string]ChunkInfo
+	closed   bool
+}
+
+// NewWriter opens a series for appending, creating it if needed
+func (s *Store) NewWriter(symbol, kind string, opts WriterOptions) (*Writer, error) {
+	if symbol == "" {
+		return nil, errors.New("symbol is required")
+	}
+	if opts.Digits <= 0 {
+		opts.Digits = DefaultDigits
+	}
+
+	w := &Writer{
+		dir:      s.seriesDir(symbol, kind),
+		scale:    math.Pow10(opts.Digits),
+		existing: make(map[string]ChunkInfo),
+		info: SeriesInfo{
+			Symbol:    symbol,
+			Kind:      kind,
+			Digits:    opts.Digits,
+			Timeframe: opts.Timeframe,
+		},
+	}
+
+	ncols := tickColumns
+	switch kind {
+	case marketdata.KindTick:
+		w.kindCode = kindTickCode
+	case marketdata.KindBar:
+		w.kindCode = kindBarCode
+		ncols = barColumns
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	w.cols = make([][]int64, ncols)
+
+	if prev, err := s.Info(symbol, kind); err == nil {
+		if prev.Digits != opts.Digits {
+			return nil, fmt.Errorf("series stored with %d digits, not %d", prev.Digits, opts.Digits)
+		}
+		for _, c := range prev.Chunks {
+			w.existing[c.File] = c
+		}
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(w.dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create series directory: %w", err)
+	}
+	return w, nil
+}
+
+func (w *Writer) fixed(v float64) int64 {
+	return int64(math.Round(v * w.scale))
+}
+
+// WriteTick appends a tick to a tick series
+func (w *Writer) WriteTick(t marketdata.Tick) error {
+	if w.kindCode != kindTickCode {
+		return errors.New("cannot write ticks to a bar series")
+	}
+	if err := w.roll(t.Time.UTC().Format("2006/0102") + ".sfc"); err != nil {
+		return err
+	}
+	w.append(
+		t.Time.UnixMilli(),
+		w.fixed(t.Bid),
+		w.fixed(t.Ask),
+		int64(math.Round(t.BidVolume*volumeScale)),
+		int64(math.Round(t.AskVolume*volumeScale)),
+	)
+	return nil
+}
+
+// WriteBar appends a bar to a bar series
+func (w *Writer) WriteBar(b marketdata.Bar) error {
+	if w.kindCode != kindBarCode {
+		return errors.New("cannot write bars to a tick series")
+	}
+	if err := w.roll(b.Time.UTC().Format("2006/01") + ".sfc"); err != nil {
+		return err
+	}
+	w.append(
+		b.Time.UnixMilli(),
+		w.fixed(b.Open),
+		w.fixed(b.High),
+		w.fixed(b.Low),
+		w.fixed(b.Close),
+		int64(math.Round(b.Volume*volumeScale)),
+		w.fixed(b.Spread),
+	)
+	return nil
+}
+
+func (w *Writer) append(values ...int64) {
+	for i, v := range values {
+		w.cols[i] = append(w.cols[i], v)
+	}
+}
+
+// roll flushes the current chunk when a row belongs to a different one
+func (w *Writer) roll(key string) error {
+	if w.closed {
+		return errors.New("writer is closed")
+	}
+	if key == w.key {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.key = key
+	if _, ok := w.existing[key]; ok {
+		return w.load()
+	}
+	return nil
+}
+
+// load decodes the current chunk's file into the buffer so it can be merged
+func (w *Writer) load() error {
+	data, err := os.ReadFile(filepath.Join(w.dir, w.key))
+	if err != nil {
+		return fmt.Errorf("failed to read chunk %s: %w", w.key, err)
+	}
+	c, err := parseChunk(data)
+	if err != nil {
+		return fmt.Errorf("chunk %s: %w", w.key, err)
+	}
+	if c.rows == 0 {
+		return nil
+	}
+
+	cur := newCursor(c)
+	cur.seek(0)
+	for {
+		w.append(cur.value...)
+		ok, err := cur.advance()
+		if err != nil {
+			return fmt.Errorf("chunk %s: %w", w.key, err)
+		}
+		if !ok {
+			return nil
+		}
+	}
+}
+
+// flush encodes the buffered chunk and replaces its file atomically
+func (w *Writer) flush() error {
+	rows := len(w.cols[0])
+	if rows == 0 {
+		return nil
+	}
+
+	sortColumns(w.cols)
+	data := encodeChunk(w.kindCode, uint8(w.info.Digits), w.cols)
+
+	path := filepath.Join(w.dir, w.key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	if err := writeAtomic(path, data); err != nil {
+		return err
+	}
+
+	w.existing[w.key] = ChunkInfo{
+		File:  w.key,
+		Start: w.cols[0][0],
+		End:   w.cols[0][rows-1],
+		Count: rows,
+	}
+	for i := range w.cols {
+		w.cols[i] = w.cols[i][:0]
+	}
+	return nil
+}
+
+// Close flushes the last chunk and writes the series index
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	w.info.Chunks = w.info.Chunks[:0]
+	w.info.Count = 0
+	for _, c := range w.existing {
+		w.info.Chunks = append(w.info.Chunks, c)
+		w.info.Count += int64(c.Count)
+	}
+	sort.Slice(w.info.Chunks, func(i, j int) bool {
+		return w.info.Chunks[i].Start < w.info.Chunks[j].Start
+	})
+	if n := len(w.info.Chunks); n > 0 {
+		w.info.Start = time.UnixMilli(w.info.Chunks[0].Start).UTC()
+		w.info.End = time.UnixMilli(w.info.Chunks[n-1].End).UTC()
+	}
+
+	data, err := json.MarshalIndent(w.info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	return writeAtomic(filepath.Join(w.dir, indexFile), data)
+}
+
+// Info returns the series description as of the last Close
+func (w *Writer) Info() SeriesInfo {
+	return w.info
+}
+
+// sortColumns orders rows by the time column if they are not already
+func sortColumns(cols [][]int64) {
+	times := cols[0]
+	if sort.SliceIsSorted(times, func(i, j int) bool { return times[i] < times[j] }) {
+		return
+	}
+
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return times[order[i]] < times[order[j]] })
+
+	tmp := make([]int64, len(times))
+	for _, col := range cols {
+		for i, src := range order {
+			tmp[i] = col[src]
+		}
+		copy(col, tmp)
+	}
+}
+
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
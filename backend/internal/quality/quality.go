@@ -0,0 +1,370 @@
+// Package quality checks price series for gaps, duplicates, ordering errors,
+// bad spreads and price spikes, and repairs them into a clean copy.
+package quality
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// Defaults used when Options fields are zero
+const (
+	DefaultSpikeSigma = 6.0
+	spikeWindow       = 500 // Returns kept for the rolling deviation
+	spikeWarmup       = 30  // Returns needed before spikes are flagged
+	maxGaps           = 100
+	maxSamples        = 100
+)
+
+// Issue kinds reported in samples
+const (
+	IssueDuplicate  = "duplicate"
+	IssueOutOfOrder = "out_of_order"
+	IssueSpread     = "bad_spread"
+	IssueSpike      = "spike"
+)
+
+// Options configures a quality pass
+type Options struct {
+	// Timeframe is the grid missing data is measured on: the bar timeframe
+	// for bar series, H1 for ticks when empty
+	Timeframe  resample.Timeframe
+	SpikeSigma float64          // DefaultSpikeSigma when zero
+	Session    resample.Session // Weekend boundaries; New York close when Zone is nil
+}
+
+func (o *Options) normalize(kind string) error {
+	if o.Timeframe == "" {
+		if kind == marketdata.KindBar {
+			return fmt.Errorf("bar timeframe is unknown")
+		}
+		o.Timeframe = resample.H1
+	}
+	if !o.Timeframe.Valid() {
+		return fmt.Errorf("unknown timeframe %q", o.Timeframe)
+	}
+	if o.SpikeSigma <= 0 {
+		o.SpikeSigma = DefaultSpikeSigma
+	}
+	if o.Session.Zone == nil {
+		o.Session = resample.NewYorkClose()
+	}
+	return nil
+}
+
+// Gap is a run of missing bars while the market was open
+type Gap struct {
+	From time.Time `json:"from"` // Open time of the first missing bar
+	To   time.Time `json:"to"`   // Open time of the bar that resumed the series
+	Bars int       `json:"bars"`
+}
+
+// Issue is an example of a flagged row
+type Issue struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Report summarizes a quality pass. Gaps and Samples are capped; the counts
+// are always complete.
+type Report struct {
+	Rows            int64     `json:"rows"`
+	Timeframe       string    `json:"timeframe"` // Grid missing bars were counted on
+	SpikeSigma      float64   `json:"spikeSigma"`
+	MissingBars     int64     `json:"missingBars"`
+	Gaps            []Gap     `json:"gaps,omitempty"`
+	Duplicates      int64     `json:"duplicates"`
+	OutOfOrder      int64     `json:"outOfOrder"` // Sorted into place when stored, so never repaired
+	ZeroSpreads     int64     `json:"zeroSpreads"`
+	NegativeSpreads int64     `json:"negativeSpreads"`
+	Spikes          int64     `json:"spikes"`
+	Samples         []Issue   `json:"samples,omitempty"`
+	Notes           []string  `json:"notes,omitempty"`
+	CheckedAt       time.Time `json:"checkedAt"`
+}
+
+// Clean reports whether the pass found nothing to repair. Rows out of order
+// in the file are not counted: the store keeps rows in time order.
+func (r *Report) Clean() bool {
+	return r.MissingBars == 0 && r.Duplicates == 0 &&
+		r.ZeroSpreads == 0 && r.NegativeSpreads == 0 && r.Spikes == 0
+}
+
+// flags marks what is wrong with a row
+type flags uint8
+
+const (
+	flagDuplicate flags = 1 << iota
+	flagOutOfOrder
+	flagSpread
+	flagSpike
+)
+
+// row is a tick or bar with the values the checks look at
+type row struct {
+	tick   marketdata.Tick
+	bar    marketdata.Bar
+	isTick bool
+	time   time.Time
+	price  float64 // Mid for ticks, close for bars
+	spread float64
+	flags  flags
+	ret    float64 // Log return from the previous good row
+}
+
+func tickRow(t marketdata.Tick) row {
+	return row{tick: t, isTick: true, time: t.Time, price: t.Mid(), spread: t.Spread()}
+}
+
+func barRow(b marketdata.Bar) row {
+	return row{bar: b, time: b.Time, price: b.Close, spread: b.Spread}
+}
+
+// analyzer flags rows as they stream past. Spikes need one row of lookahead,
+// a spike being a jump that the following row reverses, so every row is
+// handed to emit one row late; Flush releases the last.
+type analyzer struct {
+	opts    Options
+	kind    string
+	emit    func(r row, missing []time.Time) error
+	last    time.Time // Latest timestamp seen
+	started bool
+	hasBar  bool // A row has fixed the gap grid position
+	bucket  time.Time
+	good    float64 // Price of the last row not flagged as a spike
+	pending *row
+	gap     []time.Time // Missing bar opens before pending
+	returns [spikeWindow]float64
+	n       int
+	sum     float64
+	sumSq   float64
+}
+
+func newAnalyzer(kind string, opts Options, emit func(r row, missing []time.Time) error) (*analyzer, error) {
+	if err := opts.normalize(kind); err != nil {
+		return nil, err
+	}
+	return &analyzer{opts: opts, kind: kind, emit: emit}, nil
+}
+
+// push classifies r and emits the row before it
+func (a *analyzer) push(r row) error {
+	switch {
+	case a.started && r.time.Equal(a.last):
+		r.flags |= flagDuplicate
+	case a.started && r.time.Before(a.last):
+		r.flags |= flagOutOfOrder
+	}
+	if r.isTick || r.spread != 0 {
+		if r.spread <= 0 {
+			r.flags |= flagSpread
+		}
+	}
+	if !a.started || r.time.After(a.last) {
+		a.last = r.time
+	}
+	a.started = true
+
+	// Rows with broken timestamps take no part in gap or spike detection
+	if r.flags&(flagDuplicate|flagOutOfOrder) != 0 {
+		return a.emit(r, nil)
+	}
+
+	missing := a.advance(r.time)
+
+	if a.pending != nil {
+		a.judge(r.price)
+		if err := a.emitPending(); err != nil {
+			return err
+		}
+	}
+	if a.good > 0 && r.price > 0 {
+		r.ret = math.Log(r.price / a.good)
+	}
+	a.pending = &r
+	a.gap = missing
+	return nil
+}
+
+// advance moves the gap grid to t and returns the bar opens skipped over
+func (a *analyzer) advance(t time.Time) []time.Time {
+	start, _ := a.opts.Session.Bounds(a.opts.Timeframe, t)
+	if !a.hasBar {
+		a.hasBar, a.bucket = true, start
+		return nil
+	}
+	if !start.After(a.bucket) {
+		return nil
+	}
+
+	var missing []time.Time
+	_, next := a.opts.Session.Bounds(a.opts.Timeframe, a.bucket)
+	for next.Before(start) {
+		if a.open(next) {
+			missing = append(missing, next)
+		}
+		_, next = a.opts.Session.Bounds(a.opts.Timeframe, next)
+	}
+	a.bucket = start
+	return missing
+}
+
+// open reports whether the market should have traded in the bar opening at t
+func (a *analyzer) open(t time.Time) bool {
+	if a.opts.Session.Closed(a.opts.Timeframe, t) {
+		return false
+	}
+	day := a.opts.Session.TradingDay(t)
+	// Christmas and New Year's Day are the only days every venue closes
+	if day.Month() == time.December && day.Day() == 25 || day.Month() == time.January && day.Day() == 1 {
+		return false
+	}
+	return true
+}
+
+// judge decides whether the pending row is a spike now that the next price
+// is known, and updates the rolling deviation with good rows
+func (a *analyzer) judge(next float64) {
+	p := a.pending
+	if a.good <= 0 || p.price <= 0 {
+		if p.price > 0 && p.flags&flagSpread == 0 {
+			a.good = p.price
+		}
+		return
+	}
+
+	if a.n >= spikeWarmup && next > 0 {
+		count := float64(min(a.n, spikeWindow))
+		mean := a.sum / count
+		std := math.Sqrt(max(a.sumSq/count-mean*mean, 0))
+		back := math.Log(next / p.price)
+		limit := a.opts.SpikeSigma * std
+		if std > 0 && math.Abs(p.ret-mean) > limit && math.Abs(back-mean) > limit && (p.ret > 0) != (back > 0) {
+			p.flags |= flagSpike
+			return
+		}
+	}
+
+	if p.flags&flagSpread == 0 {
+		slot := a.n % spikeWindow
+		if a.n >= spikeWindow {
+			old := a.returns[slot]
+			a.sum -= old
+			a.sumSq -= old * old
+		}
+		a.returns[slot] = p.ret
+		a.sum += p.ret
+		a.sumSq += p.ret * p.ret
+		a.n++
+		a.good = p.price
+	}
+}
+
+func (a *analyzer) emitPending() error {
+	p, gap := *a.pending, a.gap
+	a.pending, a.gap = nil, nil
+	return a.emit(p, gap)
+}
+
+// flush emits the last row; with nothing after it, it cannot be a spike
+func (a *analyzer) flush() error {
+	if a.pending == nil {
+		return nil
+	}
+	return a.emitPending()
+}
+
+// Checker builds a Report from a stream of rows. It satisfies ingest.Sink
+// so it can watch an import as it happens.
+type Checker struct {
+	a      *analyzer
+	report Report
+	spread bool // Any row carried a spread
+}
+
+// NewChecker prepares a quality pass over a series of the given kind
+func NewChecker(kind string, opts Options) (*Checker, error) {
+	c := &Checker{}
+	a, err := newAnalyzer(kind, opts, c.record)
+	if err != nil {
+		return nil, err
+	}
+	c.a = a
+	c.report.Timeframe = string(a.opts.Timeframe)
+	c.report.SpikeSigma = a.opts.SpikeSigma
+	return c, nil
+}
+
+// WriteTick checks a tick
+func (c *Checker) WriteTick(t marketdata.Tick) error {
+	return c.a.push(tickRow(t))
+}
+
+// WriteBar checks a bar
+func (c *Checker) WriteBar(b marketdata.Bar) error {
+	return c.a.push(barRow(b))
+}
+
+func (c *Checker) record(r row, missing []time.Time) error {
+	rep := &c.report
+	rep.Rows++
+	if r.spread != 0 {
+		c.spread = true
+	}
+
+	if len(missing) > 0 {
+		rep.MissingBars += int64(len(missing))
+		if len(rep.Gaps) < maxGaps {
+			rep.Gaps = append(rep.Gaps, Gap{From: missing[0], To: r.time, Bars: len(missing)})
+		}
+	}
+
+	switch {
+	case r.flags&flagDuplicate != 0:
+		rep.Duplicates++
+		c.sample(r.time, IssueDuplicate, "")
+	case r.flags&flagOutOfOrder != 0:
+		rep.OutOfOrder++
+		c.sample(r.time, IssueOutOfOrder, "")
+	}
+	if r.flags&flagSpread != 0 {
+		if r.spread == 0 {
+			rep.ZeroSpreads++
+		} else {
+			rep.NegativeSpreads++
+		}
+		c.sample(r.time, IssueSpread, fmt.Sprintf("spread %g", r.spread))
+	}
+	if r.flags&flagSpike != 0 {
+		rep.Spikes++
+		c.sample(r.time, IssueSpike, fmt.Sprintf("price %g", r.price))
+	}
+	return nil
+}
+
+func (c *Checker) sample(t time.Time, kind, detail string) {
+	if len(c.report.Samples) < maxSamples {
+		c.report.Samples = append(c.report.Samples, Issue{Time: t, Kind: kind, Detail: detail})
+	}
+}
+
+// Report finishes the pass and returns its findings
+func (c *Checker) Report() (*Report, error) {
+	if err := c.a.flush(); err != nil {
+		return nil, err
+	}
+	rep := c.report
+	if c.a.kind == marketdata.KindBar && !c.spread {
+		rep.Notes = append(rep.Notes, "source has no spread column; spreads not checked")
+	}
+	if rep.OutOfOrder > 0 {
+		rep.Notes = append(rep.Notes, fmt.Sprintf("%d rows out of order in the file were stored in time order", rep.OutOfOrder))
+	}
+	rep.CheckedAt = time.Now().UTC()
+	return &rep, nil
+}
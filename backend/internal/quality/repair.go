@@ -0,0 +1,201 @@
+package quality
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/ingest"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Mode selects how flagged rows and gaps are repaired
+type Mode string
+
+const (
+	// ModeDrop removes flagged rows and leaves gaps open
+	ModeDrop Mode = "drop"
+	// ModeForwardFill repeats the last good price over flagged rows and,
+	// for bar series, over missing bars
+	ModeForwardFill Mode = "forward_fill"
+	// ModeInterpolate draws a straight line from the last good price to the
+	// next one across flagged rows and missing bars
+	ModeInterpolate Mode = "interpolate"
+)
+
+// ParseMode accepts a mode name, with dashes or underscores
+func ParseMode(s string) (Mode, error) {
+	switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "-", "_") {
+	case "drop":
+		return ModeDrop, nil
+	case "forward_fill", "ffill":
+		return ModeForwardFill, nil
+	case "interpolate":
+		return ModeInterpolate, nil
+	}
+	return "", fmt.Errorf("unknown repair mode %q", s)
+}
+
+// Summary counts what a repair changed
+type Summary struct {
+	Mode     Mode  `json:"mode"`
+	Dropped  int64 `json:"dropped"`  // Rows removed
+	Replaced int64 `json:"replaced"` // Flagged rows given repaired prices
+	Filled   int64 `json:"filled"`   // Missing bars inserted
+}
+
+// Repairer rewrites a series into sink with its problems fixed. Duplicate
+// rows are always dropped, as are rows out of order, though a series read
+// back from a store has none; the mode decides what happens to spikes, bad
+// spreads and gaps. Missing ticks are never invented.
+type Repairer struct {
+	a       *analyzer
+	sink    ingest.Sink
+	summary Summary
+	good    *row  // Last row written unchanged
+	held    []row // Rows awaiting the next good row, for interpolation
+}
+
+// NewRepairer prepares a repair of a series of the given kind
+func NewRepairer(kind string, mode Mode, opts Options, sink ingest.Sink) (*Repairer, error) {
+	switch mode {
+	case ModeDrop, ModeForwardFill, ModeInterpolate:
+	default:
+		return nil, fmt.Errorf("unknown repair mode %q", mode)
+	}
+	r := &Repairer{sink: sink, summary: Summary{Mode: mode}}
+	a, err := newAnalyzer(kind, opts, r.repair)
+	if err != nil {
+		return nil, err
+	}
+	r.a = a
+	return r, nil
+}
+
+// WriteTick feeds a tick to the repair
+func (r *Repairer) WriteTick(t marketdata.Tick) error {
+	return r.a.push(tickRow(t))
+}
+
+// WriteBar feeds a bar to the repair
+func (r *Repairer) WriteBar(b marketdata.Bar) error {
+	return r.a.push(barRow(b))
+}
+
+func (r *Repairer) repair(x row, missing []time.Time) error {
+	if x.flags&(flagDuplicate|flagOutOfOrder) != 0 {
+		r.summary.Dropped++
+		return nil
+	}
+
+	mode := r.summary.Mode
+	if !x.isTick && r.good != nil && mode != ModeDrop {
+		for _, t := range missing {
+			filler := row{time: t, bar: marketdata.Bar{Time: t}}
+			if mode == ModeInterpolate {
+				r.held = append(r.held, filler)
+				continue
+			}
+			if err := r.write(r.filled(filler)); err != nil {
+				return err
+			}
+			r.summary.Filled++
+		}
+	}
+
+	if x.flags&(flagSpread|flagSpike) != 0 {
+		switch {
+		case mode == ModeDrop || r.good == nil:
+			r.summary.Dropped++
+			return nil
+		case mode == ModeInterpolate:
+			r.held = append(r.held, x)
+			return nil
+		}
+		r.summary.Replaced++
+		return r.write(r.filled(x))
+	}
+
+	if err := r.release(&x); err != nil {
+		return err
+	}
+	if err := r.write(x); err != nil {
+		return err
+	}
+	r.good = &x
+	return nil
+}
+
+// filled returns x carrying the last good prices
+func (r *Repairer) filled(x row) row {
+	g := r.good
+	if x.isTick {
+		x.tick.Bid, x.tick.Ask = g.tick.Bid, g.tick.Ask
+		return x
+	}
+	price := g.bar.Close
+	x.bar.Open, x.bar.High, x.bar.Low, x.bar.Close = price, price, price, price
+	x.bar.Spread = g.bar.Spread
+	return x
+}
+
+// release writes held rows interpolated between the last good row and next.
+// With next nil they are forward-filled instead.
+func (r *Repairer) release(next *row) error {
+	for _, h := range r.held {
+		isGap := h.flags == 0 // Only inserted bars are held unflagged
+		if next == nil {
+			h = r.filled(h)
+		} else {
+			h = r.interpolated(h, *next)
+		}
+		if err := r.write(h); err != nil {
+			return err
+		}
+		if isGap {
+			r.summary.Filled++
+		} else {
+			r.summary.Replaced++
+		}
+	}
+	r.held = r.held[:0]
+	return nil
+}
+
+func (r *Repairer) interpolated(h, next row) row {
+	g := r.good
+	span := next.time.Sub(g.time)
+	f := 0.5
+	if span > 0 {
+		f = float64(h.time.Sub(g.time)) / float64(span)
+	}
+	lerp := func(a, b float64) float64 { return a + f*(b-a) }
+
+	if h.isTick {
+		h.tick.Bid = lerp(g.tick.Bid, next.tick.Bid)
+		h.tick.Ask = lerp(g.tick.Ask, next.tick.Ask)
+		return h
+	}
+	price := lerp(g.bar.Close, next.bar.Open)
+	h.bar.Open, h.bar.High, h.bar.Low, h.bar.Close = price, price, price, price
+	h.bar.Spread = lerp(g.bar.Spread, next.bar.Spread)
+	return h
+}
+
+func (r *Repairer) write(x row) error {
+	if x.isTick {
+		return r.sink.WriteTick(x.tick)
+	}
+	return r.sink.WriteBar(x.bar)
+}
+
+// Finish writes any rows still held and returns what the repair changed
+func (r *Repairer) Finish() (Summary, error) {
+	if err := r.a.flush(); err != nil {
+		return r.summary, err
+	}
+	if err := r.release(nil); err != nil {
+		return r.summary, err
+	}
+	return r.summary, nil
+}
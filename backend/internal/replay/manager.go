@@ -0,0 +1,112 @@
+package replay
+
+import (
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limits on the sessions held
+const (
+	MaxSessionsPerUser = 3
+	IdleTimeout        = 30 * time.Minute // A session without a client is dropped after this long
+)
+
+// ErrTooManySessions is returned when a user already has the most sessions
+var ErrTooManySessions = errors.New("too many replay sessions; close one first")
+
+// Manager holds the open sessions in memory. They do not survive a restart.
+type Manager struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewManager() *Manager {
+	return &Manager{sessions: make(map[string]*Session)}
+}
+
+// Add registers a session, dropping idle ones first
+func (m *Manager) Add(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+
+	count := 0
+	for _, other := range m.sessions {
+		if other.UserID == s.UserID {
+			count++
+		}
+	}
+	if count >= MaxSessionsPerUser {
+		return ErrTooManySessions
+	}
+	m.sessions[s.ID] = s
+	return nil
+}
+
+// Get returns one of the user's sessions
+func (m *Manager) Get(userID, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+
+	s, ok := m.sessions[id]
+	if !ok || s.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	return s, nil
+}
+
+// Connect returns the session a WebSocket ticket authorizes
+func (m *Manager) Connect(id, ticket string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+
+	s, ok := m.sessions[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(s.Ticket), []byte(ticket)) != 1 {
+		return nil, ErrSessionNotFound
+	}
+	return s, nil
+}
+
+// List returns the user's sessions, newest first
+func (m *Manager) List(userID string) []*Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+
+	list := []*Session{}
+	for _, s := range m.sessions {
+		if s.UserID == userID {
+			list = append(list, s)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// Remove drops one of the user's sessions. A connected client is left to
+// finish; the session can no longer be found.
+func (m *Manager) Remove(userID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok || s.UserID != userID {
+		return ErrSessionNotFound
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+// sweep drops the sessions left without a client for too long
+func (m *Manager) sweep() {
+	for id, s := range m.sessions {
+		if s.idle(IdleTimeout) {
+			delete(m.sessions, id)
+		}
+	}
+}
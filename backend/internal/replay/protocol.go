@@ -0,0 +1,252 @@
+package replay
+
+import (
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Commands a client sends
+const (
+	CommandPlay     = "play"     // Replay bars at Speed bars a second, or the current speed
+	CommandPause    = "pause"    // Stop playing
+	CommandStep     = "step"     // Replay Count bars
+	CommandSeek     = "seek"     // Move to Time, forwards or back
+	CommandRewind   = "rewind"   // Go back Count bars
+	CommandOrder    = "order"    // Place Order
+	CommandClose    = "close"    // Close PositionID
+	CommandCloseAll = "closeAll" // Close every position
+	CommandModify   = "modify"   // Set the StopLoss and TakeProfit of PositionID
+	CommandCancel   = "cancel"   // Cancel pending OrderID
+	CommandSave     = "save"     // Store the trades so far as a backtest
+)
+
+// Messages the server sends
+const (
+	MessageHistory = "history" // Bars up to the current one and every closed trade, after connecting or moving back
+	MessageBar     = "bar"     // A bar was replayed
+	MessageState   = "state"   // A command changed the session
+	MessageSaved   = "saved"   // The session was stored as BacktestID
+	MessageError   = "error"   // A command failed
+)
+
+// Command is a message from the client
+type Command struct {
+	Type       string        `json:"type"`
+	Speed      float64       `json:"speed,omitempty"`
+	Count      int           `json:"count,omitempty"` // One when zero
+	Time       time.Time     `json:"time,omitempty"`
+	Order      *engine.Order `json:"order,omitempty"` // A zero volume is sized by the session's rule
+	PositionID int64         `json:"positionId,omitempty"`
+	OrderID    int64         `json:"orderId,omitempty"`
+	StopLoss   float64       `json:"stopLoss,omitempty"`
+	TakeProfit float64       `json:"takeProfit,omitempty"`
+}
+
+// Message is sent to the client. Every message but an error carries the
+// session's state.
+type Message struct {
+	Type       string           `json:"type"`
+	Bars       []marketdata.Bar `json:"bars,omitempty"`   // history
+	Bar        *marketdata.Bar  `json:"bar,omitempty"`    // bar
+	Trades     []engine.Trade   `json:"trades,omitempty"` // Every closed trade for history, otherwise those closed since the last message
+	State      *State           `json:"state,omitempty"`
+	BacktestID string           `json:"backtestId,omitempty"` // saved
+	Message    string           `json:"message,omitempty"`    // error
+}
+
+// State is a session's position and account
+type State struct {
+	Time        time.Time         `json:"time"`      // Of the latest bar replayed
+	Replayed    int               `json:"replayed"`  // Bars replayed since the start
+	Remaining   int               `json:"remaining"` // Bars left
+	Playing     bool              `json:"playing"`
+	Speed       float64           `json:"speed"` // Bars a second
+	Balance     float64           `json:"balance"`
+	Equity      float64           `json:"equity"`
+	Margin      float64           `json:"margin"`
+	FreeMargin  float64           `json:"freeMargin"`
+	Positions   []engine.Position `json:"positions"`
+	Orders      []engine.Order    `json:"orders"`
+	TotalTrades int               `json:"totalTrades"`
+}
+
+// Conn is a client's connection, such as a WebSocket
+type Conn interface {
+	ReadJSON(v any) error
+	WriteJSON(v any) error
+}
+
+// Serve runs a client's connection until it closes: it sends the history,
+// then carries out the client's commands and replays bars while playing.
+// A session serves one client at a time.
+func (s *Session) Serve(conn Conn) error {
+	s.mu.Lock()
+	if s.connected {
+		s.mu.Unlock()
+		return ErrSessionInUse
+	}
+	s.connected = true
+	history := s.history()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.connected, s.playing, s.touched = false, false, time.Now()
+		s.mu.Unlock()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	commands := make(chan Command)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			var cmd Command
+			if err := conn.ReadJSON(&cmd); err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case commands <- cmd:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteJSON(history); err != nil {
+		return err
+	}
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+	for {
+		var msgs []Message
+		select {
+		case <-failed:
+			// The client went away
+			return nil
+		case cmd := <-commands:
+			msgs = s.handle(cmd)
+		case <-tick:
+			msgs = s.play()
+		}
+
+		s.mu.Lock()
+		playing, speed := s.playing, s.speed
+		s.mu.Unlock()
+		if ticker != nil && !playing {
+			ticker.Stop()
+			ticker, tick = nil, nil
+		}
+		interval := time.Duration(float64(time.Second) / speed)
+		if playing && ticker == nil {
+			ticker = time.NewTicker(interval)
+			tick = ticker.C
+		} else if playing {
+			ticker.Reset(interval)
+		}
+
+		for _, msg := range msgs {
+			if err := conn.WriteJSON(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle carries out a command and returns the messages for the client
+func (s *Session) handle(cmd Command) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.touched = time.Now()
+
+	count := max(cmd.Count, 1)
+	var err error
+	switch cmd.Type {
+	case CommandPlay:
+		if cmd.Speed < 0 || cmd.Speed > MaxSpeed {
+			return failure("speed must be between 0 and 50 bars a second")
+		}
+		if cmd.Speed > 0 {
+			s.speed = cmd.Speed
+		}
+		s.playing = s.next < len(s.bars)
+	case CommandPause:
+		s.playing = false
+	case CommandStep:
+		start := s.next
+		if err := s.advance(count); err != nil {
+			return failure(err.Error())
+		}
+		if s.next-start == 1 {
+			return []Message{s.barMessage()}
+		}
+		return []Message{s.history()}
+	case CommandSeek:
+		if err = s.seekTime(cmd.Time); err == nil {
+			return []Message{s.history()}
+		}
+	case CommandRewind:
+		if err = s.seek(s.next - count); err == nil {
+			return []Message{s.history()}
+		}
+	case CommandSave:
+		id, err := s.finish()
+		if err != nil {
+			return failure(err.Error())
+		}
+		s.sent = len(s.ctx.Trades())
+		return []Message{{Type: MessageSaved, BacktestID: id, State: s.state()}}
+	default:
+		err = s.trade(cmd)
+	}
+	if err != nil {
+		return failure(err.Error())
+	}
+	return []Message{{Type: MessageState, Trades: s.closed(), State: s.state()}}
+}
+
+// play replays the next bar while playing, stopping at the last one
+func (s *Session) play() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.touched = time.Now()
+	if !s.playing {
+		return nil
+	}
+	if err := s.advance(1); err != nil {
+		s.playing = false
+		return failure(err.Error())
+	}
+	if s.next == len(s.bars) {
+		s.playing = false
+	}
+	return []Message{s.barMessage()}
+}
+
+// barMessage reports the latest bar replayed
+func (s *Session) barMessage() Message {
+	bar := s.bars[s.next-1]
+	return Message{Type: MessageBar, Bar: &bar, Trades: s.closed(), State: s.state()}
+}
+
+// history reports every bar up to the current one and every closed trade
+func (s *Session) history() Message {
+	s.sent = len(s.ctx.Trades())
+	return Message{
+		Type:   MessageHistory,
+		Bars:   s.bars[:s.next],
+		Trades: s.ctx.Trades(),
+		State:  s.state(),
+	}
+}
+
+func failure(message string) []Message {
+	return []Message{{Type: MessageError, Message: message}}
+}
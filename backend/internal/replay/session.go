@@ -0,0 +1,277 @@
+// Package replay steps through historical bars as if they were arriving
+// live, letting a user trade them by hand through the backtest engine's
+// simulated broker.
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/google/uuid"
+)
+
+// Bounds of a session
+const (
+	MaxBars      = 200000 // Held in memory for seeking
+	ContextBars  = 300    // Bars before the start shown for context
+	DefaultSpeed = 2      // Bars per second
+	MaxSpeed     = 50
+)
+
+var (
+	// ErrSessionNotFound is returned when a session does not exist or belongs to another user
+	ErrSessionNotFound = errors.New("replay session not found")
+	// ErrSessionInUse is returned when connecting to a session that already has a client
+	ErrSessionInUse = errors.New("replay session is already connected")
+)
+
+// Saver stores the result of a session and returns its ID
+type Saver func(result *engine.Result) (string, error)
+
+// Session is one user's replay of a dataset. Its bars are held in memory;
+// those before Start are context and are not traded. Rewinding rebuilds the
+// engine and applies again the orders placed up to the new position, so a
+// session can move back and forth through its bars.
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"-"`
+	Ticket    string    `json:"ticket"` // Authorizes the WebSocket connection
+	DatasetID string    `json:"datasetId"`
+	Symbol    string    `json:"symbol"`
+	Timeframe string    `json:"timeframe"`
+	Start     time.Time `json:"start"` // Time of the first bar traded
+	End       time.Time `json:"end"`   // Time of the last bar
+	Bars      int       `json:"bars"`  // Bars that can be traded
+	CreatedAt time.Time `json:"createdAt"`
+
+	cfg   engine.Config
+	bars  []marketdata.Bar
+	first int // Index of the first bar traded
+	save  Saver
+
+	mu        sync.Mutex
+	eng       *engine.Engine
+	ctx       *engine.Context
+	next      int      // Index of the next bar to replay
+	actions   []action // Manual commands, in the order they were made
+	sent      int      // Closed trades already sent to the client
+	playing   bool
+	speed     float64
+	connected bool
+	touched   time.Time
+}
+
+// action is a trading command made once the bars before index at had been
+// replayed
+type action struct {
+	at  int
+	cmd Command
+}
+
+// trader is the strategy of a session. It places no orders of its own and
+// keeps the context for the session's commands.
+type trader struct {
+	ctx *engine.Context
+}
+
+func (t *trader) Init(ctx *engine.Context) error {
+	t.ctx = ctx
+	return nil
+}
+
+func (t *trader) OnBar(*engine.Context, marketdata.Bar) error {
+	return nil
+}
+
+// NewSession starts a replay of bars, trading from the first bar at or
+// after start. The bars before it are shown for context. save stores the
+// session's result when the client asks.
+func NewSession(userID, datasetID string, cfg engine.Config, bars []marketdata.Bar, start time.Time, ticket string, save Saver) (*Session, error) {
+	first := sort.Search(len(bars), func(i int) bool { return !bars[i].Time.Before(start) })
+	if first == len(bars) {
+		return nil, errors.New("no bars at or after the start")
+	}
+	bars = bars[max(first-ContextBars, 0):]
+	first = min(first, ContextBars)
+
+	s := &Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Ticket:    ticket,
+		DatasetID: datasetID,
+		Symbol:    cfg.Symbol,
+		Timeframe: string(cfg.Timeframe),
+		Start:     bars[first].Time,
+		End:       bars[len(bars)-1].Time,
+		Bars:      len(bars) - first,
+		CreatedAt: time.Now(),
+		cfg:       cfg,
+		bars:      bars,
+		first:     first,
+		save:      save,
+		speed:     DefaultSpeed,
+		touched:   time.Now(),
+	}
+	// The first bar is replayed at once so that orders have a price
+	if err := s.rebuild(first + 1); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// rebuild starts a new engine and replays up to bar index target, applying
+// again the commands made by then. Later commands are dropped.
+func (s *Session) rebuild(target int) error {
+	t := &trader{}
+	eng, err := engine.New(s.cfg, t)
+	if err != nil {
+		return err
+	}
+	s.eng, s.ctx, s.next, s.sent = eng, t.ctx, s.first, 0
+
+	kept := s.actions[:0]
+	for _, a := range s.actions {
+		if a.at > target {
+			break
+		}
+		if err := s.advance(a.at - s.next); err != nil {
+			return err
+		}
+		if err := s.apply(a.cmd); err != nil {
+			return err
+		}
+		kept = append(kept, a)
+	}
+	s.actions = kept
+	return s.advance(target - s.next)
+}
+
+// advance replays up to n more bars
+func (s *Session) advance(n int) error {
+	for ; n > 0 && s.next < len(s.bars); n-- {
+		if err := s.eng.OnBar(s.bars[s.next]); err != nil {
+			return err
+		}
+		s.next++
+	}
+	return nil
+}
+
+// apply carries out a trading command
+func (s *Session) apply(cmd Command) error {
+	switch cmd.Type {
+	case CommandOrder:
+		if cmd.Order == nil {
+			return errors.New("order is required")
+		}
+		o := *cmd.Order
+		if o.Type == "" {
+			o.Type = engine.Market
+		}
+		_, err := s.ctx.Submit(o)
+		return err
+	case CommandClose:
+		return s.ctx.Close(cmd.PositionID)
+	case CommandCloseAll:
+		s.ctx.CloseAll()
+		return nil
+	case CommandModify:
+		return s.ctx.Modify(cmd.PositionID, cmd.StopLoss, cmd.TakeProfit)
+	case CommandCancel:
+		return s.ctx.Cancel(cmd.OrderID)
+	}
+	return fmt.Errorf("unknown command %q", cmd.Type)
+}
+
+// trade records and carries out a trading command
+func (s *Session) trade(cmd Command) error {
+	if err := s.apply(cmd); err != nil {
+		return err
+	}
+	s.actions = append(s.actions, action{at: s.next, cmd: cmd})
+	return nil
+}
+
+// seek moves to bar index target, no earlier than just after the first
+// bar traded
+func (s *Session) seek(target int) error {
+	target = min(max(target, s.first+1), len(s.bars))
+	if target < s.next {
+		return s.rebuild(target)
+	}
+	return s.advance(target - s.next)
+}
+
+// seekTime moves to just after the last bar at or before t
+func (s *Session) seekTime(t time.Time) error {
+	return s.seek(sort.Search(len(s.bars), func(i int) bool { return s.bars[i].Time.After(t) }))
+}
+
+// finish saves the session's trades with positions still open closed at
+// the latest price. The session carries on from where it was.
+func (s *Session) finish() (string, error) {
+	at := s.next
+	result, err := s.eng.Finish()
+	if err != nil {
+		return "", err
+	}
+	if err := s.rebuild(at); err != nil {
+		return "", err
+	}
+	return s.save(result)
+}
+
+// state describes the session as it stands
+func (s *Session) state() *State {
+	st := &State{
+		Time:        s.bars[s.next-1].Time,
+		Replayed:    s.next - s.first,
+		Remaining:   len(s.bars) - s.next,
+		Playing:     s.playing,
+		Speed:       s.speed,
+		Balance:     s.ctx.Balance(),
+		Equity:      s.ctx.Equity(),
+		Margin:      s.ctx.Margin(),
+		FreeMargin:  s.ctx.FreeMargin(),
+		Positions:   s.ctx.Positions(),
+		Orders:      s.ctx.Orders(),
+		TotalTrades: len(s.ctx.Trades()),
+	}
+	if st.Positions == nil {
+		st.Positions = []engine.Position{}
+	}
+	if st.Orders == nil {
+		st.Orders = []engine.Order{}
+	}
+	return st
+}
+
+// closed returns the trades closed since it was last called
+func (s *Session) closed() []engine.Trade {
+	trades := s.ctx.Trades()
+	if s.sent >= len(trades) {
+		return nil
+	}
+	out := trades[s.sent:]
+	s.sent = len(trades)
+	return out
+}
+
+// Connected reports whether a client is attached
+func (s *Session) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+// idle reports whether the session has had no client for at least d
+func (s *Session) idle(d time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.connected && time.Since(s.touched) >= d
+}
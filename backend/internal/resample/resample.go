@@ -0,0 +1,248 @@
+// Package resample aggregates ticks or lower-timeframe bars into bars of the
+// standard timeframes, with session-aware daily boundaries.
+package resample
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// Basis selects which side of the quote bars are built from
+type Basis string
+
+const (
+	BasisBid Basis = "bid" // Default, matching MetaTrader charts
+	BasisAsk Basis = "ask"
+	BasisMid Basis = "mid"
+)
+
+// GapMode controls what happens to periods with no data
+type GapMode string
+
+const (
+	// GapSkip omits empty periods
+	GapSkip GapMode = "skip"
+	// GapFill emits flat zero-volume bars at the previous close for empty
+	// periods, except over the weekend
+	GapFill GapMode = "fill"
+)
+
+// Options configures a Resampler
+type Options struct {
+	Timeframe Timeframe
+	Basis     Basis   // BasisBid when empty
+	Gaps      GapMode // GapSkip when empty
+	Session   Session // NewYorkClose when Zone is nil
+
+	// Source is the timeframe of input bars, when known. Resampling to a
+	// shorter timeframe is rejected.
+	Source Timeframe
+}
+
+// ErrStop may be returned by an emit function to end resampling early
+var ErrStop = errors.New("stop resampling")
+
+// Resampler builds bars from a time-ordered stream of ticks or bars. Each
+// completed bar is passed to the emit function.
+type Resampler struct {
+	opts    Options
+	emit    func(marketdata.Bar) error
+	bar     marketdata.Bar
+	next    time.Time // Open time of the bar after the current one
+	open    bool      // A bar is in progress
+	spreads float64   // Sum of spreads in the current bar
+	samples int       // Rows in the current bar
+	last    *marketdata.Bar
+	dropped int
+}
+
+// New validates opts and returns a resampler that calls emit for every bar
+func New(opts Options, emit func(marketdata.Bar) error) (*Resampler, error) {
+	if !opts.Timeframe.Valid() {
+		return nil, fmt.Errorf("unknown timeframe %q", opts.Timeframe)
+	}
+	if opts.Source != "" && opts.Source.Valid() && opts.Source.Duration() > opts.Timeframe.Duration() {
+		return nil, fmt.Errorf("cannot resample %s bars down to %s", opts.Source, opts.Timeframe)
+	}
+	switch opts.Basis {
+	case "":
+		opts.Basis = BasisBid
+	case BasisBid, BasisAsk, BasisMid:
+	default:
+		return nil, fmt.Errorf("unknown price basis %q", opts.Basis)
+	}
+	switch opts.Gaps {
+	case "":
+		opts.Gaps = GapSkip
+	case GapSkip, GapFill:
+	default:
+		return nil, fmt.Errorf("unknown gap mode %q", opts.Gaps)
+	}
+	if opts.Session.Zone == nil {
+		opts.Session = NewYorkClose()
+	}
+	return &Resampler{opts: opts, emit: emit}, nil
+}
+
+// Dropped returns how many rows were ignored for arriving after a later bar
+// had already started
+func (r *Resampler) Dropped() int {
+	return r.dropped
+}
+
+// AddTick folds a tick into the current bar. Tick volume is the quoted
+// volume, or one per tick when the feed reports none.
+func (r *Resampler) AddTick(t marketdata.Tick) error {
+	price := t.Bid
+	switch r.opts.Basis {
+	case BasisAsk:
+		price = t.Ask
+	case BasisMid:
+		price = t.Mid()
+	}
+	volume := t.Volume()
+	if volume == 0 {
+		volume = 1
+	}
+	return r.add(marketdata.Bar{
+		Time:   t.Time,
+		Open:   price,
+		High:   price,
+		Low:    price,
+		Close:  price,
+		Volume: volume,
+		Spread: t.Spread(),
+	})
+}
+
+// AddBar folds a lower-timeframe bar into the current bar. Source bars are
+// taken to be bid prices; ask and mid bases add all or half the bar's spread.
+func (r *Resampler) AddBar(b marketdata.Bar) error {
+	offset := 0.0
+	switch r.opts.Basis {
+	case BasisAsk:
+		offset = b.Spread
+	case BasisMid:
+		offset = b.Spread / 2
+	}
+	b.Open += offset
+	b.High += offset
+	b.Low += offset
+	b.Close += offset
+	return r.add(b)
+}
+
+func (r *Resampler) add(b marketdata.Bar) error {
+	if r.open && b.Time.Before(r.bar.Time) {
+		r.dropped++
+		return nil
+	}
+
+	if !r.open || !b.Time.Before(r.next) {
+		if err := r.finish(); err != nil {
+			return err
+		}
+		start, next := r.opts.Session.Bounds(r.opts.Timeframe, b.Time)
+		if err := r.fill(start); err != nil {
+			return err
+		}
+		r.bar = marketdata.Bar{Time: start, Open: b.Open, High: b.High, Low: b.Low}
+		r.next = next
+		r.open = true
+		r.spreads, r.samples = 0, 0
+	}
+
+	r.bar.High = max(r.bar.High, b.High)
+	r.bar.Low = min(r.bar.Low, b.Low)
+	r.bar.Close = b.Close
+	r.bar.Volume += b.Volume
+	r.spreads += b.Spread
+	r.samples++
+	return nil
+}
+
+// finish emits the bar in progress. Its spread is the mean over its rows.
+func (r *Resampler) finish() error {
+	if !r.open {
+		return nil
+	}
+	r.open = false
+	r.bar.Spread = r.spreads / float64(r.samples)
+	last := r.bar
+	r.last = &last
+	return r.emit(r.bar)
+}
+
+// fill emits flat bars for the empty periods between the last bar and start
+func (r *Resampler) fill(start time.Time) error {
+	if r.opts.Gaps != GapFill || r.last == nil {
+		return nil
+	}
+	price := r.last.Close
+	_, t := r.opts.Session.Bounds(r.opts.Timeframe, r.last.Time)
+	for t.Before(start) {
+		bounds, next := r.opts.Session.Bounds(r.opts.Timeframe, t)
+		if !r.opts.Session.Closed(r.opts.Timeframe, bounds) {
+			bar := marketdata.Bar{Time: bounds, Open: price, High: price, Low: price, Close: price}
+			if err := r.emit(bar); err != nil {
+				return err
+			}
+		}
+		t = next
+	}
+	return nil
+}
+
+// Advance tells the resampler no rows before t are still to come. The bar
+// in progress is emitted if it ends by then, rather than when the next bar
+// starts.
+func (r *Resampler) Advance(t time.Time) error {
+	if r.open && !t.Before(r.next) {
+		return r.finish()
+	}
+	return nil
+}
+
+// Flush emits the final, possibly incomplete, bar
+func (r *Resampler) Flush() error {
+	return r.finish()
+}
+
+// Ticks resamples a slice of ticks
+func Ticks(ticks []marketdata.Tick, opts Options) ([]marketdata.Bar, error) {
+	var bars []marketdata.Bar
+	r, err := New(opts, func(b marketdata.Bar) error {
+		bars = append(bars, b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range ticks {
+		if err := r.AddTick(t); err != nil {
+			return nil, err
+		}
+	}
+	return bars, r.Flush()
+}
+
+// Bars resamples a slice of lower-timeframe bars
+func Bars(src []marketdata.Bar, opts Options) ([]marketdata.Bar, error) {
+	var bars []marketdata.Bar
+	r, err := New(opts, func(b marketdata.Bar) error {
+		bars = append(bars, b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range src {
+		if err := r.AddBar(b); err != nil {
+			return nil, err
+		}
+	}
+	return bars, r.Flush()
+}
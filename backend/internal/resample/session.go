@@ -0,0 +1,114 @@
+package resample
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // Session zones must resolve on hosts without zoneinfo
+)
+
+// Session defines when the trading day rolls over. Daily, weekly and monthly
+// bars open at the close, and H4 bars are counted from it, so a New York
+// 17:00 close gives the familiar 17:00, 21:00, 01:00, ... four-hour grid and
+// a Monday bar that opens Sunday evening.
+type Session struct {
+	Zone  *time.Location
+	Close time.Duration // Wall-clock time of day in Zone
+}
+
+// NewYorkClose is the forex convention: the day ends at 17:00 New York time
+func NewYorkClose() Session {
+	zone, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		// Embedded tzdata makes this unreachable; fall back to EST
+		zone = time.FixedZone("EST", -5*60*60)
+	}
+	return Session{Zone: zone, Close: 17 * time.Hour}
+}
+
+// MidnightUTC rolls over at 00:00 UTC, as many crypto and CFD feeds do
+func MidnightUTC() Session {
+	return Session{Zone: time.UTC}
+}
+
+// ParseSession builds a session from an IANA zone name and an HH:MM close
+func ParseSession(zone, close string) (Session, error) {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return Session{}, fmt.Errorf("unknown session zone %q", zone)
+	}
+	at, err := time.Parse("15:04", close)
+	if err != nil {
+		return Session{}, fmt.Errorf("session close must be HH:MM, got %q", close)
+	}
+	return Session{Zone: loc, Close: time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute}, nil
+}
+
+// shift is how far the close is from the following midnight. Adding it to
+// a wall-clock time moves the session open onto midnight of the day the
+// session is named after.
+func (s Session) shift() time.Duration {
+	return (24*time.Hour - s.Close%(24*time.Hour)) % (24 * time.Hour)
+}
+
+// wall returns t's wall clock in the session zone, shifted so that sessions
+// start at midnight, as a UTC time with no offset of its own
+func (s Session) wall(t time.Time) time.Time {
+	local := t.In(s.Zone)
+	naive := time.Date(local.Year(), local.Month(), local.Day(),
+		local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+	return naive.Add(s.shift())
+}
+
+// instant converts a shifted wall-clock time back to an absolute time
+func (s Session) instant(wall time.Time) time.Time {
+	w := wall.Add(-s.shift())
+	return time.Date(w.Year(), w.Month(), w.Day(),
+		w.Hour(), w.Minute(), w.Second(), w.Nanosecond(), s.Zone).UTC()
+}
+
+// Bounds returns the open time of the bar containing t and of the next bar
+func (s Session) Bounds(tf Timeframe, t time.Time) (time.Time, time.Time) {
+	if tf.intraday() {
+		start := t.UTC().Truncate(tf.Duration())
+		return start, start.Add(tf.Duration())
+	}
+
+	w := s.wall(t)
+	var start, next time.Time
+	switch tf {
+	case H4:
+		start = w.Truncate(4 * time.Hour)
+		next = start.Add(4 * time.Hour)
+	case D1:
+		start = time.Date(w.Year(), w.Month(), w.Day(), 0, 0, 0, 0, time.UTC)
+		next = start.AddDate(0, 0, 1)
+	case W1:
+		day := time.Date(w.Year(), w.Month(), w.Day(), 0, 0, 0, 0, time.UTC)
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) // Back to Monday
+		next = start.AddDate(0, 0, 7)
+	case MN:
+		start = time.Date(w.Year(), w.Month(), 1, 0, 0, 0, 0, time.UTC)
+		next = start.AddDate(0, 1, 0)
+	}
+	return s.instant(start), s.instant(next)
+}
+
+// TradingDay returns the date of the session t belongs to, at midnight UTC.
+// With a New York close, Sunday evening belongs to Monday.
+func (s Session) TradingDay(t time.Time) time.Time {
+	w := s.wall(t)
+	return time.Date(w.Year(), w.Month(), w.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Closed reports whether the bar opening at t falls on the weekend, when
+// forex markets are shut
+func (s Session) Closed(tf Timeframe, t time.Time) bool {
+	if tf == W1 || tf == MN {
+		return false
+	}
+	switch s.TradingDay(t).Weekday() {
+	case time.Saturday, time.Sunday:
+		return true
+	}
+	return false
+}
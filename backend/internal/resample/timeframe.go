@@ -0,0 +1,120 @@
+package resample
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeframe is a standard bar period. Names follow the MetaTrader convention.
+type Timeframe string
+
+// Supported timeframes
+const (
+	M1  Timeframe = "M1"
+	M5  Timeframe = "M5"
+	M15 Timeframe = "M15"
+	M30 Timeframe = "M30"
+	H1  Timeframe = "H1"
+	H4  Timeframe = "H4"
+	D1  Timeframe = "D1"
+	W1  Timeframe = "W1"
+	MN  Timeframe = "MN"
+)
+
+// Timeframes lists the supported timeframes from shortest to longest
+var Timeframes = []Timeframe{M1, M5, M15, M30, H1, H4, D1, W1, MN}
+
+// ParseTimeframe accepts a timeframe name in any case. MN1 and the common
+// 1m/1h/1d style aliases are also recognized.
+func ParseTimeframe(s string) (Timeframe, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	switch name {
+	case "MN1", "1MO", "1MN":
+		return MN, nil
+	case "1M":
+		// Lower-case 1m means a minute, upper-case 1M is ambiguous; minutes
+		// are by far the more common request
+		return M1, nil
+	case "5M":
+		return M5, nil
+	case "15M":
+		return M15, nil
+	case "30M":
+		return M30, nil
+	case "1H":
+		return H1, nil
+	case "4H":
+		return H4, nil
+	case "1D":
+		return D1, nil
+	case "1W":
+		return W1, nil
+	}
+	for _, tf := range Timeframes {
+		if string(tf) == name {
+			return tf, nil
+		}
+	}
+	return "", fmt.Errorf("unknown timeframe %q", s)
+}
+
+// Duration returns the nominal length of a bar. Months count as 30 days;
+// bucket boundaries for MN always follow the calendar.
+func (tf Timeframe) Duration() time.Duration {
+	switch tf {
+	case M1:
+		return time.Minute
+	case M5:
+		return 5 * time.Minute
+	case M15:
+		return 15 * time.Minute
+	case M30:
+		return 30 * time.Minute
+	case H1:
+		return time.Hour
+	case H4:
+		return 4 * time.Hour
+	case D1:
+		return 24 * time.Hour
+	case W1:
+		return 7 * 24 * time.Hour
+	case MN:
+		return 30 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// Valid reports whether tf is a supported timeframe
+func (tf Timeframe) Valid() bool {
+	return tf.Duration() > 0
+}
+
+// intraday timeframes align to the UTC clock; longer ones follow the session
+func (tf Timeframe) intraday() bool {
+	return tf.Duration() <= time.Hour
+}
+
+// Infer maps the spacing between consecutive bars to a timeframe. Spacings
+// longer than a day are matched loosely because weekends and month lengths
+// stretch them.
+func Infer(spacing time.Duration) (Timeframe, bool) {
+	switch {
+	case spacing <= 0:
+		return "", false
+	case spacing < 24*time.Hour:
+		for _, tf := range Timeframes {
+			if tf.Duration() == spacing {
+				return tf, true
+			}
+		}
+		return "", false
+	case spacing < 7*24*time.Hour:
+		return D1, true
+	case spacing < 28*24*time.Hour:
+		return W1, true
+	default:
+		return MN, true
+	}
+}
@@ -0,0 +1,662 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/instruments"
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/metrics"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+	"github.com/PervFVCK/strategyforge/internal/strategies"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+	"gorm.io/gorm"
+)
+
+// ErrBacktestNotFound is returned when a backtest does not exist or belongs to another user
+var ErrBacktestNotFound = errors.New("backtest not found")
+
+type BacktestService struct {
+	datasets    DatasetService
+	conversions ConversionService
+	strategies  StrategyService
+}
+
+// BacktestRequest describes a backtest run
+type BacktestRequest struct {
+	FileID         string                  `json:"fileId"`     // Dataset to test on
+	Strategy       string                  `json:"strategy"`   // Built-in strategy name
+	StrategyID     string                  `json:"strategyId"` // Saved strategy to run instead of a built-in one
+	Parameters     strategies.Params       `json:"parameters"`
+	Timeframe      string                  `json:"timeframe"` // Bars the strategy sees; the dataset's own when empty
+	Mode           string                  `json:"mode"`      // bar or tick
+	StartDate      string                  `json:"startDate"` // RFC 3339 or YYYY-MM-DD
+	EndDate        string                  `json:"endDate"`
+	InitialBalance float64                 `json:"initialBalance"`
+	Currency       string                  `json:"currency"`   // Account currency, USD when empty
+	Instrument     *instruments.Instrument `json:"instrument"` // Overrides the registry's specification of the dataset's symbol
+	Sizing         engine.Sizing           `json:"sizing"`
+	Costs          engine.Costs            `json:"costs"`
+	Margin         engine.MarginConfig     `json:"margin"`
+}
+
+// BacktestResponse is a stored run together with its full result
+type BacktestResponse struct {
+	Backtest *models.BacktestResult `json:"backtest"`
+	Result   *engine.Result         `json:"result"`
+}
+
+// Submit checks a backtest request and queues it to run in the background
+func (s *BacktestService) Submit(userID string, req BacktestRequest) (*models.Job, error) {
+	from, to, err := backtestRange(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := s.prepare(userID, req, from, to); err != nil {
+		return nil, err
+	}
+	if _, err := s.strategy(userID, &req); err != nil {
+		return nil, err
+	}
+	return enqueue(userID, JobBacktest, req)
+}
+
+// Run executes a backtest and stores its result. It reports progress through
+// the replayed period and stops when ctx is cancelled.
+func (s *BacktestService) Run(ctx context.Context, userID string, req BacktestRequest, report jobs.Reporter) (*BacktestResponse, error) {
+	from, to, err := backtestRange(req)
+	if err != nil {
+		return nil, err
+	}
+	dataset, cfg, err := s.prepare(userID, req, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.convert(userID, &cfg, from, to); err != nil {
+		return nil, err
+	}
+	strategy, err := s.strategy(userID, &req)
+	if err != nil {
+		return nil, err
+	}
+	eng, err := engine.New(cfg, strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	track := newTracker(ctx, report, from, to, dataset)
+	if eng.Config().Mode == engine.ModeTick {
+		err = s.datasets.Rows(dataset, from, to, tickFeed{eng, track})
+	} else {
+		err = s.datasets.Resample(dataset, resample.Options{Timeframe: cfg.Timeframe}, from, to, func(bar marketdata.Bar) error {
+			if err := track.at(bar.Time); err != nil {
+				return err
+			}
+			return eng.OnBar(bar)
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("backtest failed: %w", err)
+	}
+	result, err := eng.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("backtest failed: %w", err)
+	}
+
+	backtest, err := record(userID, req, dataset.ID, result, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(backtest).Error; err != nil {
+		return nil, fmt.Errorf("failed to save backtest: %w", err)
+	}
+	return &BacktestResponse{Backtest: backtest, Result: result}, nil
+}
+
+// BacktestDetail is a stored run with its full result as it was saved. A
+// portfolio run also lists its per-symbol results, and a walk-forward
+// analysis its out-of-sample runs.
+type BacktestDetail struct {
+	Backtest *models.BacktestResult   `json:"backtest"`
+	Symbols  []*models.BacktestResult `json:"symbols,omitempty"`
+	Windows  []*models.BacktestResult `json:"windows,omitempty"`
+	Result   json.RawMessage          `json:"result"`
+}
+
+// Get returns a stored backtest owned by the user
+func (s *BacktestService) Get(userID, id string) (*BacktestDetail, error) {
+	var backtest models.BacktestResult
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&backtest).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBacktestNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	detail := &BacktestDetail{Backtest: &backtest, Result: json.RawMessage(backtest.ResultData)}
+	children := &detail.Symbols
+	switch {
+	case backtest.Windows > 0:
+		children = &detail.Windows
+	case len(backtest.Symbols) == 0:
+		return detail, nil
+	}
+	err := database.DB.Where("parent_id = ? AND user_id = ?", backtest.ID, userID).
+		Order("created_at ASC").Find(children).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return detail, nil
+}
+
+// Limits on backtests returned by one list request
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// BacktestQuery filters and pages the user's backtest history. Empty
+// fields do not filter.
+type BacktestQuery struct {
+	Pair       string
+	Timeframe  string
+	Strategy   string // Built-in name or saved strategy title
+	StrategyID string
+	From       string // Runs made at or after; RFC 3339 or YYYY-MM-DD
+	To         string // Runs made before; a plain date includes the day
+	ParentID   string // Lists a portfolio or walk-forward run's children instead of top-level runs
+	Cursor     string // Next value of the previous page
+	Limit      int
+}
+
+// BacktestPage is one page of backtest history, newest first
+type BacktestPage struct {
+	Backtests []models.BacktestResult `json:"backtests"`
+	Next      string                  `json:"next,omitempty"` // Cursor of the following page
+}
+
+// List returns the user's backtests matching q, newest first, without their
+// result data
+func (s *BacktestService) List(userID string, q BacktestQuery) (*BacktestPage, error) {
+	from, err := parseDate(q.From, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from: %w", err)
+	}
+	to, err := parseDate(q.To, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to: %w", err)
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	limit = min(limit, maxHistoryLimit)
+
+	query := database.DB.Omit("result_data").Where("user_id = ?", userID)
+	if q.ParentID != "" {
+		query = query.Where("parent_id = ?", q.ParentID)
+	} else {
+		// Rows saved before portfolio runs existed have no parent_id
+		query = query.Where("(parent_id = '' OR parent_id IS NULL)")
+	}
+	for column, value := range map[string]string{
+		"pair":        q.Pair,
+		"timeframe":   q.Timeframe,
+		"strategy":    q.Strategy,
+		"strategy_id": q.StrategyID,
+	} {
+		if value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+	if q.Cursor != "" {
+		at, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", at, at, id)
+	}
+
+	page := &BacktestPage{}
+	if err := query.Order("created_at DESC, id DESC").Limit(limit + 1).Find(&page.Backtests).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if len(page.Backtests) > limit {
+		page.Backtests = page.Backtests[:limit]
+		last := page.Backtests[limit-1]
+		page.Next = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// encodeCursor makes an opaque cursor positioned after the given run
+func encodeCursor(at time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(at.UnixNano(), 10) + ":" + id))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	invalid := errors.New("invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, "", invalid
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	return time.Unix(0, n), id, nil
+}
+
+// Delete removes one of the user's backtests. Deleting a portfolio run
+// removes its per-symbol results too.
+func (s *BacktestService) Delete(userID, id string) error {
+	var backtest models.BacktestResult
+	if err := database.DB.Omit("result_data").Where("id = ? AND user_id = ?", id, userID).First(&backtest).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBacktestNotFound
+		}
+		return fmt.Errorf("database error: %w", err)
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("parent_id = ? AND user_id = ?", backtest.ID, userID).Delete(&models.BacktestResult{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("backtest_id = ? AND user_id = ?", backtest.ID, userID).Delete(&models.MonteCarloSimulation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&backtest).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete backtest: %w", err)
+	}
+	return nil
+}
+
+func backtestRange(req BacktestRequest) (time.Time, time.Time, error) {
+	from, err := parseDate(req.StartDate, false)
+	if err != nil {
+		return from, from, fmt.Errorf("invalid startDate: %w", err)
+	}
+	to, err := parseDate(req.EndDate, true)
+	if err != nil {
+		return from, to, fmt.Errorf("invalid endDate: %w", err)
+	}
+	return from, to, nil
+}
+
+// prepare loads the request's dataset and builds the engine configuration
+// for it, without exchange rates
+func (s *BacktestService) prepare(userID string, req BacktestRequest, from, to time.Time) (*models.Dataset, engine.Config, error) {
+	var cfg engine.Config
+	if req.FileID == "" {
+		return nil, cfg, errors.New("fileId is required")
+	}
+	dataset, err := s.datasets.Get(userID, req.FileID)
+	if err != nil {
+		return nil, cfg, err
+	}
+	if dataset.Status != models.DatasetStatusReady {
+		return nil, cfg, ErrDatasetNotReady
+	}
+
+	cfg = engine.Config{
+		Symbol:         dataset.SeriesSymbol(),
+		Mode:           engine.Mode(req.Mode),
+		InitialBalance: req.InitialBalance,
+		Sizing:         req.Sizing,
+		Costs:          req.Costs,
+		Margin:         req.Margin,
+	}
+	// Exchange rates are loaded for the currency before the engine starts
+	if cfg.Currency, err = engine.AccountCurrency(req.Currency); err != nil {
+		return nil, cfg, err
+	}
+	if req.Instrument != nil {
+		cfg.Instrument = *req.Instrument
+		cfg.Instrument.Symbol = cfg.Symbol
+	} else if cfg.Instrument, err = instruments.Lookup(cfg.Symbol); err != nil {
+		return nil, cfg, fmt.Errorf("%w; pass an instrument specification", err)
+	}
+	if cfg.Timeframe, err = backtestTimeframe(req.Timeframe, dataset); err != nil {
+		return nil, cfg, err
+	}
+	if cfg.Mode == engine.ModeTick && dataset.Kind != marketdata.KindTick {
+		return nil, cfg, errors.New("tick mode needs a tick dataset")
+	}
+	return dataset, cfg, nil
+}
+
+// convert loads the exchange rates cfg needs beyond its instrument's own
+// prices. It reads data, so it is left out of prepare's cheap checks.
+func (s *BacktestService) convert(userID string, cfg *engine.Config, from, to time.Time) error {
+	rates, err := s.conversions.Converter(userID, cfg.Currency, cfg.Instrument, from, to)
+	if err != nil {
+		return err
+	}
+	if rates != nil {
+		cfg.Converter = rates
+	}
+	return nil
+}
+
+// strategy builds the requested built-in or saved strategy. A saved one's
+// name is stored in req.Strategy.
+func (s *BacktestService) strategy(userID string, req *BacktestRequest) (engine.Strategy, error) {
+	if req.StrategyID == "" {
+		return strategies.New(req.Strategy, req.Parameters)
+	}
+	saved, runner, err := s.strategies.Load(userID, req.StrategyID, req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	req.Strategy = saved.Name
+	return runner, nil
+}
+
+// record builds the stored row of a result: its summary columns and
+// metrics, with data as ResultData
+func record(userID string, req BacktestRequest, datasetID string, result *engine.Result, data any) (*models.BacktestResult, error) {
+	cfg, summary := &result.Config, result.Summary
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	backtest := &models.BacktestResult{
+		UserID:         userID,
+		StrategyID:     req.StrategyID,
+		Strategy:       req.Strategy,
+		DatasetID:      datasetID,
+		Pair:           cfg.Symbol,
+		Timeframe:      string(cfg.Timeframe),
+		Timeframes:     []string{string(cfg.Timeframe)},
+		StartDate:      summary.Start,
+		EndDate:        summary.End,
+		InitialBalance: summary.InitialBalance,
+		Currency:       cfg.Currency,
+		Leverage:       cfg.Margin.Leverage,
+		FinalBalance:   summary.FinalBalance,
+		TotalTrades:    summary.TotalTrades,
+		WinRate:        summary.WinRate,
+		ProfitFactor:   summary.ProfitFactor,
+		MaxDrawdown:    summary.MaxDrawdown,
+		Costs:          &cfg.Costs,
+		CostTotals:     &summary.Costs,
+		Metrics:        metrics.Compute(result),
+		ResultData:     string(encoded),
+	}
+	if cfg.Instrument.Symbol != "" {
+		backtest.Instrument = &cfg.Instrument
+	}
+	for _, tf := range cfg.Timeframes {
+		backtest.Timeframes = append(backtest.Timeframes, string(tf))
+	}
+	return backtest, nil
+}
+
+// PortfolioRequest describes a run of one strategy over several datasets,
+// one per symbol, trading from a shared account. The other settings apply
+// to every symbol; fileId and instrument overrides are not used.
+type PortfolioRequest struct {
+	BacktestRequest
+	FileIDs []string              `json:"fileIds"`
+	Limits  engine.ExposureLimits `json:"limits"`
+}
+
+// PortfolioResponse is a stored portfolio run: the parent result for the
+// whole account, a child result per symbol, and the full result
+type PortfolioResponse struct {
+	Backtest *models.BacktestResult   `json:"backtest"`
+	Symbols  []*models.BacktestResult `json:"symbols"`
+	Result   *engine.PortfolioResult  `json:"result"`
+}
+
+// SubmitPortfolio checks a portfolio backtest request and queues it to run
+// in the background
+func (s *BacktestService) SubmitPortfolio(userID string, req PortfolioRequest) (*models.Job, error) {
+	if _, _, _, err := s.preparePortfolio(userID, &req); err != nil {
+		return nil, err
+	}
+	return enqueue(userID, JobPortfolio, req)
+}
+
+// RunPortfolio executes a portfolio backtest and stores the parent result
+// with a child result per symbol. It reports progress and stops when ctx is
+// cancelled.
+func (s *BacktestService) RunPortfolio(ctx context.Context, userID string, req PortfolioRequest, report jobs.Reporter) (*PortfolioResponse, error) {
+	datasets, cfgs, runners, err := s.preparePortfolio(userID, &req)
+	if err != nil {
+		return nil, err
+	}
+	from, to, _ := backtestRange(req.BacktestRequest)
+	for i := range cfgs {
+		if err := s.convert(userID, &cfgs[i], from, to); err != nil {
+			return nil, err
+		}
+	}
+	portfolio, err := engine.NewPortfolio(cfgs, req.Limits, runners)
+	if err != nil {
+		return nil, err
+	}
+
+	track := newTracker(ctx, report, from, to, datasets...)
+	err = s.merge(datasets, cfgs[0].Timeframe, from, to, func(i int, bar marketdata.Bar) error {
+		if err := track.at(bar.Time); err != nil {
+			return err
+		}
+		return portfolio.OnBar(i, bar)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backtest failed: %w", err)
+	}
+	result, err := portfolio.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("backtest failed: %w", err)
+	}
+	return s.savePortfolio(userID, req, datasets, result)
+}
+
+// preparePortfolio loads every dataset of a portfolio request and builds
+// each symbol's configuration, without exchange rates, and strategy
+func (s *BacktestService) preparePortfolio(userID string, req *PortfolioRequest) ([]*models.Dataset, []engine.Config, []engine.Strategy, error) {
+	if len(req.FileIDs) < 2 {
+		return nil, nil, nil, errors.New("fileIds must list at least two datasets")
+	}
+	if len(req.FileIDs) > engine.MaxPortfolioSymbols {
+		return nil, nil, nil, fmt.Errorf("a portfolio can trade at most %d symbols", engine.MaxPortfolioSymbols)
+	}
+	if req.Instrument != nil {
+		return nil, nil, nil, errors.New("instrument overrides apply to single-symbol backtests")
+	}
+	from, to, err := backtestRange(req.BacktestRequest)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	datasets := make([]*models.Dataset, len(req.FileIDs))
+	cfgs := make([]engine.Config, len(req.FileIDs))
+	runners := make([]engine.Strategy, len(req.FileIDs))
+	for i, id := range req.FileIDs {
+		one := req.BacktestRequest
+		one.FileID = id
+		if datasets[i], cfgs[i], err = s.prepare(userID, one, from, to); err != nil {
+			return nil, nil, nil, err
+		}
+		// Each symbol runs its own instance of the strategy
+		if runners[i], err = s.strategy(userID, &one); err != nil {
+			return nil, nil, nil, err
+		}
+		req.Strategy = one.Strategy
+	}
+	return datasets, cfgs, runners, nil
+}
+
+// merge replays the bars of several datasets in time order, passing each
+// with its dataset's index. Every dataset is resampled on a goroutine of
+// its own.
+func (s *BacktestService) merge(datasets []*models.Dataset, tf resample.Timeframe, from, to time.Time, emit func(int, marketdata.Bar) error) error {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(done)
+
+	feeds := make([]chan marketdata.Bar, len(datasets))
+	errs := make([]error, len(datasets))
+	for i, dataset := range datasets {
+		feed := make(chan marketdata.Bar, 256)
+		feeds[i] = feed
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(feed)
+			errs[i] = s.datasets.Resample(dataset, resample.Options{Timeframe: tf}, from, to, func(bar marketdata.Bar) error {
+				select {
+				case feed <- bar:
+					return nil
+				case <-done:
+					return resample.ErrStop
+				}
+			})
+		}()
+	}
+
+	heads := make([]marketdata.Bar, len(feeds))
+	live := make([]bool, len(feeds))
+	advance := func(i int) error {
+		heads[i], live[i] = <-feeds[i]
+		if !live[i] && errs[i] != nil {
+			return fmt.Errorf("%s: %w", datasets[i].SeriesSymbol(), errs[i])
+		}
+		return nil
+	}
+	for i := range feeds {
+		if err := advance(i); err != nil {
+			return err
+		}
+	}
+	for {
+		next := -1
+		for i := range heads {
+			if live[i] && (next < 0 || heads[i].Time.Before(heads[next].Time)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return nil
+		}
+		if err := emit(next, heads[next]); err != nil {
+			return err
+		}
+		if err := advance(next); err != nil {
+			return err
+		}
+	}
+}
+
+// savePortfolio stores the account's result as the parent and each
+// symbol's as a child of it. The parent's ResultData leaves out the
+// breakdown, which the children hold.
+func (s *BacktestService) savePortfolio(userID string, req PortfolioRequest, datasets []*models.Dataset, result *engine.PortfolioResult) (*PortfolioResponse, error) {
+	account := *result
+	account.Breakdown = nil
+	parent, err := record(userID, req.BacktestRequest, "", &account.Result, account)
+	if err != nil {
+		return nil, err
+	}
+	parent.Symbols = result.Symbols
+
+	children := make([]*models.BacktestResult, len(result.Breakdown))
+	for i, child := range result.Breakdown {
+		if children[i], err = record(userID, req.BacktestRequest, datasets[i].ID, child, child); err != nil {
+			return nil, err
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(parent).Error; err != nil {
+			return err
+		}
+		for _, child := range children {
+			child.ParentID = parent.ID
+			if err := tx.Create(child).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save backtest: %w", err)
+	}
+	return &PortfolioResponse{Backtest: parent, Symbols: children, Result: result}, nil
+}
+
+// backtestTimeframe picks the strategy's bar timeframe: the requested one,
+// else the dataset's own, else H1 for tick data
+func backtestTimeframe(requested string, dataset *models.Dataset) (resample.Timeframe, error) {
+	if requested != "" {
+		return resample.ParseTimeframe(requested)
+	}
+	if dataset.Timeframe != "" {
+		return resample.ParseTimeframe(dataset.Timeframe)
+	}
+	if dataset.Kind == marketdata.KindTick {
+		return resample.H1, nil
+	}
+	return "", errors.New("timeframe is required for this dataset")
+}
+
+// parseDate accepts RFC 3339 or a plain date. A plain end date includes the
+// whole day.
+func parseDate(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC 3339 or YYYY-MM-DD")
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// tickFeed passes stored ticks to a tick-mode engine
+type tickFeed struct {
+	engine *engine.Engine
+	track  *tracker
+}
+
+func (f tickFeed) WriteTick(t marketdata.Tick) error {
+	if err := f.track.at(t.Time); err != nil {
+		return err
+	}
+	return f.engine.OnTick(t)
+}
+
+func (f tickFeed) WriteBar(b marketdata.Bar) error {
+	return errors.New("tick mode received a bar")
+}
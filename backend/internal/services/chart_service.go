@@ -0,0 +1,219 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/chart"
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/indicators"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+)
+
+// Bounds on the indicators of one chart
+const (
+	maxChartIndicators = 10
+	maxChartWarmUp     = 5000 // Bars read before the range for indicators to warm up
+)
+
+type ChartService struct {
+	backtests BacktestService
+}
+
+// ChartQuery selects what a backtest's chart shows. Zero fields take
+// defaults.
+type ChartQuery struct {
+	From       time.Time // The backtest's start when zero
+	To         time.Time // Exclusive; the backtest's end when zero
+	Width      int       // Pixels; the timeframe is picked to give about one bar each
+	Timeframe  string    // Overrides the picked timeframe
+	Indicators string    // Such as ema:period=50;bollinger:period=20,mult=2
+	Sampling   string    // Of the equity curve: lttb or minmax
+}
+
+// Chart returns the candles of a backtest's dataset over a visible range,
+// with indicators, the backtest's trades as markers and its equity curve
+// thinned to the chart's width
+func (s *ChartService) Chart(userID, backtestID string, q ChartQuery) (*chart.Chart, error) {
+	if q.Width == 0 {
+		q.Width = chart.DefaultWidth
+	}
+	if q.Width < chart.MinWidth || q.Width > chart.MaxWidth {
+		return nil, fmt.Errorf("width must be between %d and %d pixels", chart.MinWidth, chart.MaxWidth)
+	}
+	switch q.Sampling = strings.ToLower(q.Sampling); q.Sampling {
+	case "":
+		q.Sampling = chart.SamplingLTTB
+	case chart.SamplingLTTB, chart.SamplingMinMax:
+	default:
+		return nil, fmt.Errorf("unknown sampling %q; use lttb or minmax", q.Sampling)
+	}
+
+	detail, err := s.backtests.Get(userID, backtestID)
+	if err != nil {
+		return nil, err
+	}
+	backtest := detail.Backtest
+	if backtest.DatasetID == "" {
+		return nil, errors.New("a portfolio backtest has no single dataset; chart one of its symbols")
+	}
+	dataset, err := s.backtests.datasets.Get(userID, backtest.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Trades []engine.Trade       `json:"trades"`
+		Equity []engine.EquityPoint `json:"equity"`
+	}
+	if err := json.Unmarshal(detail.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to read backtest result: %w", err)
+	}
+
+	from, to := q.From, q.To
+	if from.IsZero() {
+		from = backtest.StartDate
+	}
+	if to.IsZero() {
+		// The last bar of the backtest starts at its end date
+		to = backtest.EndDate.Add(time.Second)
+		if tf, err := resample.ParseTimeframe(backtest.Timeframe); err == nil {
+			to = backtest.EndDate.Add(tf.Duration())
+		}
+	}
+	if !to.After(from) {
+		return nil, errors.New("to must be after from")
+	}
+
+	// Tick data can be charted down to minutes
+	base := resample.M1
+	if dataset.Timeframe != "" {
+		if base, err = resample.ParseTimeframe(dataset.Timeframe); err != nil {
+			return nil, err
+		}
+	}
+	tf := chart.PickTimeframe(base, to.Sub(from), q.Width)
+	if q.Timeframe != "" {
+		if tf, err = resample.ParseTimeframe(q.Timeframe); err != nil {
+			return nil, err
+		}
+		if tf.Duration() < base.Duration() {
+			return nil, fmt.Errorf("timeframe %s is finer than the dataset's %s", tf, base)
+		}
+	}
+
+	studies, err := chartStudies(q.Indicators)
+	if err != nil {
+		return nil, err
+	}
+	warmUp := 0
+	for _, st := range studies {
+		warmUp = max(warmUp, st.study.WarmUp())
+	}
+	warmUp = min(warmUp, maxChartWarmUp)
+
+	// Twice the nominal time back leaves room for weekends and gaps
+	start := from.Add(-2 * time.Duration(warmUp) * tf.Duration())
+	var bars []marketdata.Bar
+	first := 0 // Index of the first bar in the range
+	err = s.backtests.datasets.Resample(dataset, resample.Options{Timeframe: tf}, start, to, func(bar marketdata.Bar) error {
+		// Bars that close by the start of the range only warm up indicators
+		if !bar.Time.Add(tf.Duration()).After(from) {
+			if warmUp == 0 {
+				return nil
+			}
+			if first == warmUp {
+				bars = bars[1:]
+			} else {
+				first++
+			}
+		} else if len(bars)-first == chart.MaxBars {
+			return fmt.Errorf("more than %d bars to chart; use a higher timeframe or a shorter range", chart.MaxBars)
+		}
+		bars = append(bars, bar)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+
+	visible := bars[first:]
+	c := &chart.Chart{
+		Symbol:     backtest.Pair,
+		Timeframe:  string(tf),
+		From:       from,
+		To:         to,
+		Width:      q.Width,
+		Indicators: []chart.Indicator{},
+		Markers:    chart.Markers(result.Trades, visible, tf),
+		Sampling:   q.Sampling,
+	}
+	c.Candles, c.Volume = chart.Candles(visible)
+	for _, st := range studies {
+		ind := chart.Indicator{Name: st.name, Title: st.spec.Title, Params: st.params, Overlay: st.spec.Overlay}
+		for i, values := range indicators.Compute(st.study, st.spec.Outputs, bars) {
+			ind.Lines = append(ind.Lines, chart.Line{Name: st.spec.Outputs[i], Data: chart.Points(visible, values[first:])})
+		}
+		c.Indicators = append(c.Indicators, ind)
+	}
+	c.Equity, c.Balance = chart.Curves(result.Equity, from, to, q.Width, q.Sampling)
+	return c, nil
+}
+
+// chartStudy is an indicator requested for a chart
+type chartStudy struct {
+	name   string
+	params map[string]float64
+	spec   indicators.Spec
+	study  indicators.Study
+}
+
+// chartStudies creates the indicators listed as name:param=value,... and
+// separated by semicolons
+func chartStudies(list string) ([]chartStudy, error) {
+	specs := make(map[string]indicators.Spec)
+	for _, spec := range indicators.Specs() {
+		specs[spec.Name] = spec
+	}
+
+	var studies []chartStudy
+	for _, item := range strings.Split(list, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if len(studies) == maxChartIndicators {
+			return nil, fmt.Errorf("at most %d indicators can be charted", maxChartIndicators)
+		}
+		name, args, _ := strings.Cut(item, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		params := make(map[string]float64)
+		for _, arg := range strings.Split(args, ",") {
+			if strings.TrimSpace(arg) == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(arg, "=")
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid %s param %q; expected name=number", name, arg)
+			}
+			params[strings.TrimSpace(key)] = v
+		}
+		study, _, err := indicators.New(name, params, resample.Session{})
+		if err != nil {
+			return nil, err
+		}
+		spec := specs[name]
+		for _, p := range spec.Params {
+			if _, ok := params[p.Name]; !ok {
+				params[p.Name] = p.Default
+			}
+		}
+		studies = append(studies, chartStudy{name: name, params: params, spec: spec, study: study})
+	}
+	return studies, nil
+}
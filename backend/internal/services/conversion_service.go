@@ -0,0 +1,132 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/fx"
+	"github.com/PervFVCK/strategyforge/internal/instruments"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+)
+
+// conversionLead loads rates from before the requested range so that a run
+// starting after a weekend or holiday has a rate from its first price
+const conversionLead = 7 * 24 * time.Hour
+
+type ConversionService struct {
+	datasets DatasetService
+}
+
+// Converter builds exchange rates for trading inst in an account currency
+// from the user's own datasets, covering [from, to). It returns nil when
+// the instrument's prices are enough.
+func (s *ConversionService) Converter(userID, account string, inst instruments.Instrument, from, to time.Time) (*fx.Table, error) {
+	need := fx.Need(account, inst)
+	if need == "" {
+		return nil, nil
+	}
+	if !from.IsZero() {
+		from = from.Add(-conversionLead)
+	}
+
+	table := fx.NewTable()
+	direct, err := s.load(table, userID, need, account, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if direct {
+		return table, nil
+	}
+
+	// Cross through USD when the user has no direct pair
+	left, err := s.load(table, userID, need, "USD", from, to)
+	if err != nil {
+		return nil, err
+	}
+	right, err := s.load(table, userID, "USD", account, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if !left || !right {
+		return nil, fmt.Errorf("converting %s to %s needs a %s%s or %s%s dataset, or both legs through USD",
+			need, account, need, account, account, need)
+	}
+	return table, nil
+}
+
+// load adds the pair of two currencies to the table from a ready dataset,
+// in whichever direction the user has it. It reports whether one was found.
+func (s *ConversionService) load(table *fx.Table, userID, a, b string, from, to time.Time) (bool, error) {
+	if a == b {
+		return true, nil
+	}
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		dataset, err := s.find(userID, pair[0]+pair[1], from, to)
+		if err != nil {
+			return false, err
+		}
+		if dataset == nil {
+			continue
+		}
+
+		series, err := s.series(dataset, from, to)
+		if err != nil {
+			return false, fmt.Errorf("loading %s rates: %w", dataset.Symbol, err)
+		}
+		if series.Len() == 0 {
+			continue
+		}
+		table.Set(pair[0], pair[1], series)
+		return true, nil
+	}
+	return false, nil
+}
+
+// find picks the user's ready dataset of a symbol, preferring one that
+// covers the range and then the newest
+func (s *ConversionService) find(userID, symbol string, from, to time.Time) (*models.Dataset, error) {
+	var datasets []models.Dataset
+	err := database.DB.
+		Where("user_id = ? AND symbol = ? AND status = ?", userID, symbol, models.DatasetStatusReady).
+		Order("created_at DESC").
+		Find(&datasets).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if len(datasets) == 0 {
+		return nil, nil
+	}
+	for i := range datasets {
+		d := &datasets[i]
+		if d.StartDate == nil || d.EndDate == nil {
+			continue
+		}
+		if (from.IsZero() || !d.StartDate.After(from)) && (to.IsZero() || !d.EndDate.Before(to)) {
+			return d, nil
+		}
+	}
+	return &datasets[0], nil
+}
+
+// series reads hourly opening prices, or the dataset's own bars when they
+// are coarser. An open is known from the moment its bar starts, so a rate
+// looked up at any time never comes from later data.
+func (s *ConversionService) series(dataset *models.Dataset, from, to time.Time) (*fx.Series, error) {
+	tf := resample.H1
+	if source, err := resample.ParseTimeframe(dataset.Timeframe); err == nil && source.Duration() > tf.Duration() {
+		tf = source
+	}
+
+	series := &fx.Series{}
+	err := s.datasets.Resample(dataset, resample.Options{Timeframe: tf, Basis: resample.BasisMid}, from, to, func(bar marketdata.Bar) error {
+		series.Add(bar.Time, bar.Open)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
@@ -0,0 +1,847 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/csvdialect"
+	"github.com/PervFVCK/strategyforge/internal/dukascopy"
+	"github.com/PervFVCK/strategyforge/internal/ingest"
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/mt4"
+	"github.com/PervFVCK/strategyforge/internal/pricestore"
+	"github.com/PervFVCK/strategyforge/internal/quality"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+	"github.com/PervFVCK/strategyforge/internal/utils"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Default upload settings, overridable via environment
+const (
+	defaultUploadDir      = "./data/uploads"
+	defaultStoreDir       = "./data/store"
+	defaultMaxUploadSize  = "100MB"
+	defaultAllowedFormats = "csv,hst,bin,bi5,txt"
+)
+
+// Limits on bars returned by one request
+const (
+	defaultBarLimit = 5000
+	maxBarLimit     = 50000
+)
+
+var (
+	// ErrDatasetNotFound is returned when a dataset does not exist or belongs to another user
+	ErrDatasetNotFound = errors.New("dataset not found")
+	// ErrNotConfirmable is returned when confirming a dataset that is not a
+	// CSV file waiting for its layout to be confirmed
+	ErrNotConfirmable = errors.New("only CSV datasets waiting for their layout can be confirmed")
+	// ErrDatasetNotReady is returned when reading rows of a dataset still being imported
+	ErrDatasetNotReady = errors.New("dataset is not ready")
+)
+
+type DatasetService struct{}
+
+// UploadRequest describes an incoming price data file
+type UploadRequest struct {
+	Filename string // Set from the received file
+	Path     string // The file's name as sent, which may keep Dukascopy's YYYY/MM/DD directories
+	Symbol   string // Optional, inferred from the file name when empty
+	Hour     string // Optional RFC 3339 start hour for Dukascopy .bi5 files
+}
+
+// UploadResponse is returned to the client after a successful upload
+type UploadResponse struct {
+	FileID  string          `json:"fileId"`
+	Dataset *models.Dataset `json:"dataset"`
+	Report  *ingest.Report  `json:"report"`
+	Job     *models.Job     `json:"job,omitempty"` // Importing the rows, once the layout is known
+}
+
+// ImportRequest is a queued copy of a validated file into its dataset's
+// price store. A CSV file is read with the dataset's confirmed layout.
+type ImportRequest struct {
+	DatasetID string    `json:"datasetId"`
+	Symbol    string    `json:"symbol,omitempty"`
+	Hour      time.Time `json:"hour,omitempty"` // Start hour of a bi5 file
+	Digits    int       `json:"digits,omitempty"`
+}
+
+// MaxUploadSize returns the configured upload limit in bytes
+func (s *DatasetService) MaxUploadSize() int64 {
+	size, err := utils.ParseByteSize(utils.GetEnv("MAX_UPLOAD_SIZE", defaultMaxUploadSize))
+	if err != nil {
+		size, _ = utils.ParseByteSize(defaultMaxUploadSize)
+	}
+	return size
+}
+
+// IsAllowedFile checks the file extension against ALLOWED_FILE_TYPES
+func (s *DatasetService) IsAllowedFile(filename string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if ext == "" {
+		return false
+	}
+	for _, allowed := range strings.Split(utils.GetEnv("ALLOWED_FILE_TYPES", defaultAllowedFormats), ",") {
+		if strings.TrimSpace(strings.ToLower(allowed)) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadedFile is an upload saved to disk, waiting for the form fields that
+// may follow it
+type UploadedFile struct {
+	datasetID string
+	filename  string
+	path      string // As sent, before directories are dropped
+	stored    *ingest.StoredFile
+}
+
+// Receive streams an uploaded file to disk so the rest of the form can be
+// read. Pass it to Upload, or to Discard when the form turns out invalid.
+func (s *DatasetService) Receive(userID, filename string, r io.Reader) (*UploadedFile, error) {
+	path := utils.SanitizeInput(filename)
+	filename = filepath.Base(path)
+	if !s.IsAllowedFile(filename) {
+		return nil, fmt.Errorf("file type not allowed: %s", filepath.Ext(filename))
+	}
+
+	datasetID := uuid.New().String()
+	dir := filepath.Join(utils.GetEnv("UPLOAD_DIR", defaultUploadDir), userID)
+	stored, err := ingest.WriteFile(r, filepath.Join(dir, datasetID+strings.ToLower(filepath.Ext(filename))), s.MaxUploadSize())
+	if err != nil {
+		return nil, err
+	}
+	return &UploadedFile{datasetID: datasetID, filename: filename, path: path, stored: stored}, nil
+}
+
+// Discard removes a received file that will not be uploaded
+func (s *DatasetService) Discard(file *UploadedFile) {
+	os.Remove(file.stored.Path)
+}
+
+// Upload detects and validates the format of a received file, and records a
+// Dataset for the user. The stored file is removed if validation fails. A
+// file whose layout needs no confirmation is queued to be imported.
+func (s *DatasetService) Upload(userID string, req UploadRequest, file *UploadedFile) (*UploadResponse, error) {
+	req.Filename, req.Path = file.filename, file.path
+	datasetID, stored := file.datasetID, file.stored
+
+	format := ingest.Sniff(stored.Head, req.Filename)
+	if format == ingest.FormatUnknown {
+		os.Remove(stored.Path)
+		return nil, errors.New("unrecognized file format")
+	}
+
+	symbol := ingest.NormalizeSymbol(req.Symbol)
+	if symbol == "" {
+		symbol = ingest.InferSymbol(req.Filename)
+	}
+
+	opts := ingest.Options{Symbol: symbol}
+	if format == ingest.FormatBI5 {
+		hour, err := bi5Hour(req)
+		if err != nil {
+			os.Remove(stored.Path)
+			return nil, err
+		}
+		opts.Hour = hour
+	}
+
+	report, err := ingest.Validate(stored.Path, format, opts)
+	if err != nil {
+		os.Remove(stored.Path)
+		return nil, fmt.Errorf("invalid %s file: %w", format, err)
+	}
+
+	if report.Timeframe == "" {
+		report.Timeframe = inferTimeframe(report)
+	}
+
+	// Formats with a symbol in their header win over file name guesses
+	if req.Symbol == "" && report.Symbol != "" {
+		symbol = ingest.NormalizeSymbol(report.Symbol)
+	}
+
+	dataset := models.Dataset{
+		ID:          datasetID,
+		UserID:      userID,
+		Name:        req.Filename,
+		Symbol:      symbol,
+		Format:      string(format),
+		Kind:        report.Kind,
+		Timeframe:   report.Timeframe,
+		SizeBytes:   stored.Size,
+		Checksum:    stored.Checksum,
+		StoragePath: stored.Path,
+		Rows:        report.Rows,
+		StartDate:   report.Start,
+		EndDate:     report.End,
+		Dialect:     report.Dialect,
+		Status:      models.DatasetStatusProcessing,
+	}
+
+	// Text layouts are a guess until the user confirms them
+	if format == ingest.FormatCSV {
+		dataset.Status = models.DatasetStatusPending
+	}
+
+	if err := database.DB.Create(&dataset).Error; err != nil {
+		os.Remove(stored.Path)
+		return nil, fmt.Errorf("failed to save dataset: %w", err)
+	}
+
+	response := &UploadResponse{
+		FileID:  dataset.ID,
+		Dataset: &dataset,
+		Report:  report,
+	}
+	if dataset.Status == models.DatasetStatusProcessing {
+		req := ImportRequest{DatasetID: dataset.ID, Symbol: opts.Symbol, Hour: opts.Hour, Digits: report.Digits}
+		if response.Job, err = enqueue(userID, JobImport, req); err != nil {
+			database.DB.Delete(&dataset)
+			os.Remove(stored.Path)
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// Confirm accepts the detected CSV layout, or a corrected one, and checks the
+// whole file against it before queuing the import. The dataset becomes ready
+// only if every row parses.
+func (s *DatasetService) Confirm(userID, id string, dialect *csvdialect.Dialect) (*UploadResponse, error) {
+	dataset, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	// Once imported, or while importing, the stored rows may be read by jobs
+	// and replays, so the layout is settled
+	if dataset.Format != string(ingest.FormatCSV) || dataset.ParentID != "" || dataset.Status != models.DatasetStatusPending {
+		return nil, ErrNotConfirmable
+	}
+
+	if dialect == nil {
+		dialect = dataset.Dialect
+	}
+	if dialect == nil {
+		return nil, errors.New("no layout to confirm")
+	}
+	if err := dialect.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid layout: %w", err)
+	}
+
+	report, err := ingest.Validate(dataset.StoragePath, ingest.FormatCSV, ingest.Options{Dialect: dialect})
+	if err != nil {
+		return nil, fmt.Errorf("file does not match layout: %w", err)
+	}
+
+	dataset.Dialect = dialect
+	dataset.Kind = report.Kind
+	dataset.Timeframe = inferTimeframe(report)
+	dataset.Rows = report.Rows
+	dataset.StartDate = report.Start
+	dataset.EndDate = report.End
+	dataset.Status = models.DatasetStatusProcessing
+	dataset.Error = ""
+
+	// Only one of two confirmations racing each other moves it on
+	result := database.DB.Model(dataset).Where("status = ?", models.DatasetStatusPending).Select("*").Updates(dataset)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to save dataset: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotConfirmable
+	}
+
+	job, err := enqueue(userID, JobImport, ImportRequest{DatasetID: dataset.ID, Digits: report.Digits})
+	if err != nil {
+		// Left to be confirmed again once the queue has room
+		dataset.Status = models.DatasetStatusPending
+		database.DB.Model(dataset).Update("status", dataset.Status)
+		return nil, err
+	}
+
+	return &UploadResponse{
+		FileID:  dataset.ID,
+		Dataset: dataset,
+		Report:  report,
+		Job:     job,
+	}, nil
+}
+
+// Import copies a validated file into its dataset's price store, marking the
+// dataset ready, or failed with the reason
+func (s *DatasetService) Import(ctx context.Context, userID string, req ImportRequest, report jobs.Reporter) (*models.Dataset, error) {
+	dataset, err := s.Get(userID, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+	if dataset.Status != models.DatasetStatusProcessing {
+		return nil, errors.New("dataset is not waiting to be imported")
+	}
+
+	opts := ingest.Options{Symbol: req.Symbol, Hour: req.Hour, Dialect: dataset.Dialect}
+	track := newTracker(ctx, report, time.Time{}, time.Time{}, dataset)
+	if err := s.importDataset(dataset, opts, req.Digits, track); err != nil {
+		return nil, err
+	}
+	return dataset, nil
+}
+
+// Store returns the columnar store holding a dataset's rows
+func (s *DatasetService) Store(dataset *models.Dataset) *pricestore.Store {
+	return pricestore.Open(filepath.Join(utils.GetEnv("STORE_DIR", defaultStoreDir), dataset.ID))
+}
+
+// abandonImport fails the dataset of a cancelled import job, which a job
+// cancelled while queued never gets to do
+func abandonImport(job *models.Job) error {
+	var req ImportRequest
+	if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+		return fmt.Errorf("invalid job request: %w", err)
+	}
+	err := database.DB.Model(&models.Dataset{}).
+		Where("id = ? AND user_id = ? AND status = ?", req.DatasetID, job.UserID, models.DatasetStatusProcessing).
+		Updates(map[string]any{"status": models.DatasetStatusFailed, "error": "import cancelled"}).Error
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	return nil
+}
+
+// importDataset copies a validated file into the dataset's price store and
+// marks the dataset ready, or failed with the reason if the copy fails
+func (s *DatasetService) importDataset(dataset *models.Dataset, opts ingest.Options, digits int, track *tracker) error {
+	err := s.writeStore(dataset, opts, digits, track)
+	if err != nil {
+		dataset.Status = models.DatasetStatusFailed
+		dataset.Error = err.Error()
+	} else {
+		dataset.Status = models.DatasetStatusReady
+	}
+
+	if saveErr := database.DB.Save(dataset).Error; saveErr != nil {
+		return fmt.Errorf("failed to save dataset: %w", saveErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to import rows: %w", err)
+	}
+	return nil
+}
+
+func (s *DatasetService) writeStore(dataset *models.Dataset, opts ingest.Options, digits int, track *tracker) error {
+	store := s.Store(dataset)
+	if err := store.Remove(); err != nil {
+		return err
+	}
+
+	writer, err := store.NewWriter(dataset.SeriesSymbol(), dataset.Kind, pricestore.WriterOptions{
+		Digits:    digits,
+		Timeframe: dataset.Timeframe,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The quality pass watches the rows in file order, before the store
+	// sorts them, so ordering problems are still visible. A series it cannot
+	// check, such as bars of no known timeframe, is still imported, with the
+	// reason recorded in place of a report.
+	var sink ingest.Sink = writer
+	checker, checkErr := quality.NewChecker(dataset.Kind, qualityOptions(dataset, 0))
+	if checkErr == nil {
+		sink = ingest.MultiSink(writer, checker)
+	}
+
+	if err := ingest.Scan(dataset.StoragePath, ingest.Format(dataset.Format), opts, trackedSink{sink, track}); err != nil {
+		store.Remove()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		store.Remove()
+		return err
+	}
+
+	if checkErr != nil {
+		dataset.Quality = &quality.Report{
+			Rows:      dataset.Rows,
+			Notes:     []string{fmt.Sprintf("quality not checked: %v", checkErr)},
+			CheckedAt: time.Now().UTC(),
+		}
+		return nil
+	}
+	if dataset.Quality, err = checker.Report(); err != nil {
+		return fmt.Errorf("failed to check quality: %w", err)
+	}
+	return nil
+}
+
+// trackedSink reports how far an import has got and stops it once its job
+// is cancelled
+type trackedSink struct {
+	ingest.Sink
+	track *tracker
+}
+
+func (t trackedSink) WriteTick(tick marketdata.Tick) error {
+	if err := t.track.at(tick.Time); err != nil {
+		return err
+	}
+	return t.Sink.WriteTick(tick)
+}
+
+func (t trackedSink) WriteBar(bar marketdata.Bar) error {
+	if err := t.track.at(bar.Time); err != nil {
+		return err
+	}
+	return t.Sink.WriteBar(bar)
+}
+
+// qualityOptions measures gaps on the dataset's own timeframe
+func qualityOptions(dataset *models.Dataset, spikeSigma float64) quality.Options {
+	opts := quality.Options{SpikeSigma: spikeSigma}
+	if dataset.Kind == marketdata.KindBar {
+		opts.Timeframe = resample.Timeframe(dataset.Timeframe)
+	}
+	return opts
+}
+
+// RepairRequest selects how a dataset is repaired
+type RepairRequest struct {
+	Mode       string  `json:"mode"`       // drop, forward_fill or interpolate
+	SpikeSigma float64 `json:"spikeSigma"` // Spike threshold; the default when zero
+}
+
+// Repair writes a cleaned copy of a ready dataset as a new derived dataset.
+// The original is left untouched.
+func (s *DatasetService) Repair(userID, id string, req RepairRequest) (*models.Dataset, error) {
+	source, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if source.Status != models.DatasetStatusReady {
+		return nil, ErrDatasetNotReady
+	}
+	mode, err := quality.ParseMode(req.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if req.SpikeSigma < 0 {
+		return nil, errors.New("spikeSigma must be positive")
+	}
+
+	info, err := s.Store(source).Info(source.SeriesSymbol(), source.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset rows: %w", err)
+	}
+
+	dataset := models.Dataset{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		ParentID:    source.ID,
+		Name:        fmt.Sprintf("%s (%s)", source.Name, strings.ReplaceAll(string(mode), "_", " ")),
+		Symbol:      source.Symbol,
+		Format:      source.Format,
+		Kind:        source.Kind,
+		Timeframe:   source.Timeframe,
+		SizeBytes:   source.SizeBytes,
+		Checksum:    source.Checksum,
+		StoragePath: source.StoragePath, // Derived datasets share their parent's raw file
+		Status:      models.DatasetStatusProcessing,
+	}
+
+	store := s.Store(&dataset)
+	writer, err := store.NewWriter(dataset.SeriesSymbol(), dataset.Kind, pricestore.WriterOptions{
+		Digits:    info.Digits,
+		Timeframe: dataset.Timeframe,
+	})
+	if err != nil {
+		return nil, err
+	}
+	opts := qualityOptions(source, req.SpikeSigma)
+	checker, err := quality.NewChecker(dataset.Kind, opts)
+	if err != nil {
+		store.Remove()
+		return nil, err
+	}
+	repairer, err := quality.NewRepairer(dataset.Kind, mode, opts, ingest.MultiSink(writer, checker))
+	if err != nil {
+		store.Remove()
+		return nil, err
+	}
+
+	summary, err := s.copyRows(source, repairer)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		dataset.Quality, err = checker.Report()
+	}
+	if err != nil {
+		store.Remove()
+		return nil, fmt.Errorf("repair failed: %w", err)
+	}
+
+	written := writer.Info()
+	dataset.Repair = &summary
+	dataset.Rows = written.Count
+	if written.Count > 0 {
+		dataset.StartDate, dataset.EndDate = &written.Start, &written.End
+	}
+	dataset.Status = models.DatasetStatusReady
+
+	if err := database.DB.Create(&dataset).Error; err != nil {
+		store.Remove()
+		return nil, fmt.Errorf("failed to save dataset: %w", err)
+	}
+	return &dataset, nil
+}
+
+// copyRows feeds every stored row of a dataset through a repairer
+func (s *DatasetService) copyRows(dataset *models.Dataset, repairer *quality.Repairer) (quality.Summary, error) {
+	if err := s.Rows(dataset, time.Time{}, time.Time{}, repairer); err != nil {
+		return quality.Summary{}, err
+	}
+	return repairer.Finish()
+}
+
+// Rows streams a dataset's stored rows with from <= time < to into sink, in
+// time order. Either bound may be zero.
+func (s *DatasetService) Rows(dataset *models.Dataset, from, to time.Time, sink ingest.Sink) error {
+	store := s.Store(dataset)
+	if dataset.Kind == marketdata.KindTick {
+		it, err := store.Ticks(dataset.SeriesSymbol(), from, to)
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+		for it.Next() {
+			if err := sink.WriteTick(it.Tick()); err != nil {
+				return err
+			}
+		}
+		return it.Err()
+	}
+
+	it, err := store.Bars(dataset.SeriesSymbol(), from, to)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for it.Next() {
+		if err := sink.WriteBar(it.Bar()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// ExportQuery selects how a dataset is exported
+type ExportQuery struct {
+	Format    string // hst, a MetaTrader 4 history file
+	Version   int    // Of an hst file: 400 or 401; 401 when zero
+	Timeframe string // The dataset's own when empty, or M1 for ticks
+}
+
+// Export is an exported dataset ready to send. Its file is already removed
+// from disk and closes once read to the end.
+type Export struct {
+	Filename string
+	File     *os.File
+	Size     int64
+}
+
+// Export writes a ready dataset's bars to a file MetaTrader can load into
+// its history
+func (s *DatasetService) Export(userID, id string, q ExportQuery) (*Export, error) {
+	if format := strings.ToLower(q.Format); format != "" && format != string(ingest.FormatHST) {
+		return nil, fmt.Errorf("unknown export format %q; use hst", q.Format)
+	}
+	if q.Version == 0 {
+		q.Version = mt4.Version401
+	}
+	if q.Version != mt4.Version400 && q.Version != mt4.Version401 {
+		return nil, fmt.Errorf("hst version must be %d or %d", mt4.Version400, mt4.Version401)
+	}
+
+	dataset, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if dataset.Status != models.DatasetStatusReady {
+		return nil, ErrDatasetNotReady
+	}
+	tf := resample.M1
+	if q.Timeframe != "" {
+		tf, err = resample.ParseTimeframe(q.Timeframe)
+	} else if dataset.Timeframe != "" {
+		tf, err = resample.ParseTimeframe(dataset.Timeframe)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.Store(dataset).Info(dataset.SeriesSymbol(), dataset.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset rows: %w", err)
+	}
+
+	// The file is written in full before any of it is sent, so a failure
+	// still gets an error response
+	file, err := os.CreateTemp("", "export-*.hst")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+	os.Remove(file.Name())
+	fail := func(err error) (*Export, error) {
+		file.Close()
+		return nil, err
+	}
+
+	symbol := dataset.SeriesSymbol()
+	header := mt4.Header{
+		Version: q.Version,
+		Symbol:  symbol[:min(len(symbol), 11)],
+		Period:  int(tf.Duration() / time.Minute),
+		Digits:  info.Digits,
+	}
+	writer, err := mt4.NewWriter(file, header)
+	if err != nil {
+		return fail(err)
+	}
+	err = s.Resample(dataset, resample.Options{Timeframe: tf}, time.Time{}, time.Time{}, writer.Write)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return fail(fmt.Errorf("failed to write export: %w", err))
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return fail(fmt.Errorf("failed to read export: %w", err))
+	}
+
+	// Named as MetaTrader names its own history files, such as EURUSD60.hst
+	return &Export{
+		Filename: fmt.Sprintf("%s%d.hst", header.Symbol, header.Period),
+		File:     file,
+		Size:     size,
+	}, nil
+}
+
+// bi5Hour resolves the hour a Dukascopy file covers, preferring an explicit
+// form value over the Dukascopy path convention in the name the file was
+// sent with. Tick times in the file are offsets from it, so one of the two
+// is required.
+func bi5Hour(req UploadRequest) (time.Time, error) {
+	if req.Hour != "" {
+		hour, err := time.Parse(time.RFC3339, req.Hour)
+		if err != nil {
+			return time.Time{}, errors.New("hour must be an RFC 3339 timestamp")
+		}
+		return hour.UTC().Truncate(time.Hour), nil
+	}
+	if hour, ok := dukascopy.ParseHourPath(req.Path); ok {
+		return hour, nil
+	}
+	return time.Time{}, errors.New("hour is required when a .bi5 file name is not a Dukascopy path such as 2023/00/02/13h_ticks.bi5 or 2023_00_02_13h_ticks.bi5")
+}
+
+// Get returns a dataset owned by the user
+func (s *DatasetService) Get(userID, id string) (*models.Dataset, error) {
+	var dataset models.Dataset
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&dataset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDatasetNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &dataset, nil
+}
+
+// List returns all datasets owned by the user, newest first
+func (s *DatasetService) List(userID string) ([]models.Dataset, error) {
+	var datasets []models.Dataset
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&datasets).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return datasets, nil
+}
+
+// inferTimeframe guesses a text file's bar timeframe from the smallest gap
+// between its preview rows
+func inferTimeframe(report *ingest.Report) string {
+	if report.Kind != marketdata.KindBar {
+		return ""
+	}
+	var spacing time.Duration
+	for i := 1; i < len(report.Preview); i++ {
+		gap := report.Preview[i].Time().Sub(report.Preview[i-1].Time())
+		if gap > 0 && (spacing == 0 || gap < spacing) {
+			spacing = gap
+		}
+	}
+	tf, _ := resample.Infer(spacing)
+	return string(tf)
+}
+
+// BarsQuery selects a range of resampled bars
+type BarsQuery struct {
+	Timeframe    string
+	Basis        string    // bid, ask or mid
+	Gaps         string    // skip or fill
+	SessionZone  string    // IANA zone of the session close; New York when empty
+	SessionClose string    // HH:MM; 17:00 in New York, otherwise midnight
+	From         time.Time // Zero for the start of data
+	To           time.Time // Exclusive; zero for the end of data
+	Limit        int
+}
+
+// BarsResponse is one page of resampled bars
+type BarsResponse struct {
+	Symbol    string           `json:"symbol"`
+	Timeframe string           `json:"timeframe"`
+	Basis     string           `json:"basis"`
+	Bars      []marketdata.Bar `json:"bars"`
+	Next      *time.Time       `json:"next,omitempty"` // From value for the following page
+}
+
+// Bars resamples a ready dataset to the requested timeframe
+func (s *DatasetService) Bars(userID, id string, q BarsQuery) (*BarsResponse, error) {
+	dataset, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tf, err := resample.ParseTimeframe(q.Timeframe)
+	if err != nil {
+		return nil, err
+	}
+	session, err := parseSession(q.SessionZone, q.SessionClose)
+	if err != nil {
+		return nil, err
+	}
+	opts := resample.Options{
+		Timeframe: tf,
+		Basis:     resample.Basis(strings.ToLower(q.Basis)),
+		Gaps:      resample.GapMode(strings.ToLower(q.Gaps)),
+		Session:   session,
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultBarLimit
+	}
+	limit = min(limit, maxBarLimit)
+
+	response := &BarsResponse{
+		Symbol:    dataset.Symbol,
+		Timeframe: string(tf),
+		Bars:      []marketdata.Bar{},
+	}
+	err = s.Resample(dataset, opts, q.From, q.To, func(bar marketdata.Bar) error {
+		if len(response.Bars) == limit {
+			next := bar.Time
+			response.Next = &next
+			return resample.ErrStop
+		}
+		response.Bars = append(response.Bars, bar)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response.Basis = string(opts.Basis)
+	if response.Basis == "" {
+		response.Basis = string(resample.BasisBid)
+	}
+	return response, nil
+}
+
+// Resample streams a dataset's rows through a resampler, calling emit with
+// every bar that overlaps [from, to). Either bound may be zero.
+func (s *DatasetService) Resample(dataset *models.Dataset, opts resample.Options, from, to time.Time, emit func(marketdata.Bar) error) error {
+	if dataset.Status != models.DatasetStatusReady {
+		return ErrDatasetNotReady
+	}
+	if opts.Session.Zone == nil {
+		opts.Session = resample.NewYorkClose()
+	}
+	if dataset.Kind == marketdata.KindBar {
+		opts.Source = resample.Timeframe(dataset.Timeframe)
+	}
+
+	// Widen the source range to whole bars so the first and last are complete
+	if !from.IsZero() {
+		from, _ = opts.Session.Bounds(opts.Timeframe, from)
+	}
+	if !to.IsZero() {
+		if start, next := opts.Session.Bounds(opts.Timeframe, to); !start.Equal(to) {
+			to = next
+		}
+	}
+
+	r, err := resample.New(opts, emit)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Rows(dataset, from, to, resampleSink{r}); err != nil {
+		return stopped(err)
+	}
+	return stopped(r.Flush())
+}
+
+// resampleSink feeds stored rows to a resampler
+type resampleSink struct {
+	r *resample.Resampler
+}
+
+func (s resampleSink) WriteTick(t marketdata.Tick) error {
+	return s.r.AddTick(t)
+}
+
+func (s resampleSink) WriteBar(b marketdata.Bar) error {
+	return s.r.AddBar(b)
+}
+
+// stopped treats an early stop requested by the emit function as success
+func stopped(err error) error {
+	if errors.Is(err, resample.ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// parseSession resolves the session close, defaulting to New York 17:00
+func parseSession(zone, close string) (resample.Session, error) {
+	switch {
+	case zone == "" && close == "":
+		return resample.NewYorkClose(), nil
+	case zone == "":
+		zone = "America/New_York"
+	case close == "":
+		close = "00:00"
+		if zone == "America/New_York" {
+			close = "17:00"
+		}
+	}
+	return resample.ParseSession(zone, close)
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/models"
+)
+
+// Kinds of background job
+const (
+	JobBacktest     = "backtest"
+	JobPortfolio    = "portfolio"
+	JobOptimization = "optimization"
+	JobWalkForward  = "walkforward"
+	JobMonteCarlo   = "montecarlo"
+	JobImport       = "import"
+)
+
+// Jobs listed by one request
+const jobListLimit = 50
+
+// trackEvery is how many rows a replay takes between progress reports
+const trackEvery = 64
+
+type JobService struct{}
+
+// queue runs background jobs once StartJobs has been called
+var queue *jobs.Queue
+
+// StartJobs starts the pool of workers that run background jobs, resuming
+// any a previous server left unfinished
+func StartJobs(workers int) error {
+	queue = jobs.NewQueue(workers)
+	queue.Register(JobBacktest, runBacktestJob)
+	queue.Register(JobPortfolio, runPortfolioJob)
+	queue.Register(JobOptimization, runOptimizationJob)
+	queue.Register(JobWalkForward, runWalkForwardJob)
+	queue.Register(JobMonteCarlo, runMonteCarloJob)
+	queue.Register(JobImport, runImportJob)
+	return queue.Start()
+}
+
+// enqueue stores a job for the workers to pick up
+func enqueue(userID, kind string, request any) (*models.Job, error) {
+	if queue == nil {
+		return nil, errors.New("background jobs are not running")
+	}
+	return queue.Enqueue(userID, kind, request)
+}
+
+// Get returns one of the user's jobs
+func (s *JobService) Get(userID, id string) (*models.Job, error) {
+	if queue == nil {
+		return nil, jobs.ErrJobNotFound
+	}
+	return queue.Get(userID, id)
+}
+
+// List returns the user's most recent jobs, newest first
+func (s *JobService) List(userID string) ([]models.Job, error) {
+	if queue == nil {
+		return []models.Job{}, nil
+	}
+	return queue.List(userID, jobListLimit)
+}
+
+// Cancel stops one of the user's queued or running jobs
+func (s *JobService) Cancel(userID, id string) (*models.Job, error) {
+	if queue == nil {
+		return nil, jobs.ErrJobNotFound
+	}
+	job, err := queue.Cancel(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Kind == JobImport {
+		if err := abandonImport(job); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// Subscribe returns one of the user's jobs and a channel of its later
+// states, with a function to unsubscribe
+func (s *JobService) Subscribe(userID, id string) (*models.Job, <-chan models.Job, func(), error) {
+	if queue == nil {
+		return nil, nil, nil, jobs.ErrJobNotFound
+	}
+	return queue.Subscribe(userID, id)
+}
+
+func runBacktestJob(ctx context.Context, job *models.Job, report jobs.Reporter) (string, error) {
+	var req BacktestRequest
+	if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+		return "", fmt.Errorf("invalid job request: %w", err)
+	}
+	response, err := (&BacktestService{}).Run(ctx, job.UserID, req, report)
+	if err != nil {
+		return "", err
+	}
+	return response.Backtest.ID, nil
+}
+
+func runPortfolioJob(ctx context.Context, job *models.Job, report jobs.Reporter) (string, error) {
+	var req PortfolioRequest
+	if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+		return "", fmt.Errorf("invalid job request: %w", err)
+	}
+	response, err := (&BacktestService{}).RunPortfolio(ctx, job.UserID, req, report)
+	if err != nil {
+		return "", err
+	}
+	return response.Backtest.ID, nil
+}
+
+func runOptimizationJob(ctx context.Context, job *models.Job, report jobs.Reporter) (string, error) {
+	var req OptimizeRequest
+	if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+		return "", fmt.Errorf("invalid job request: %w", err)
+	}
+	opt, err := (&OptimizationService{}).Run(ctx, job.ID, job.UserID, req, report)
+	if err != nil {
+		return "", err
+	}
+	return opt.ID, nil
+}
+
+func runWalkForwardJob(ctx context.Context, job *models.Job, report jobs.Reporter) (string, error) {
+	var req WalkForwardRequest
+	if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+		return "", fmt.Errorf("invalid job request: %w", err)
+	}
+	backtest, err := (&WalkForwardService{}).Run(ctx, job.UserID, req, report)
+	if err != nil {
+		return "", err
+	}
+	return backtest.ID, nil
+}
+
+func runMonteCarloJob(ctx context.Context, job *models.Job, report jobs.Reporter) (string, error) {
+	var req MonteCarloRequest
+	if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+		return "", fmt.Errorf("invalid job request: %w", err)
+	}
+	sim, err := (&MonteCarloService{}).Run(ctx, job.UserID, req, report)
+	if err != nil {
+		return "", err
+	}
+	return sim.ID, nil
+}
+
+func runImportJob(ctx context.Context, job *models.Job, report jobs.Reporter) (string, error) {
+	var req ImportRequest
+	if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+		return "", fmt.Errorf("invalid job request: %w", err)
+	}
+	dataset, err := (&DatasetService{}).Import(ctx, job.UserID, req, report)
+	if err != nil {
+		return "", err
+	}
+	return dataset.ID, nil
+}
+
+// tracker reports how far a replay has got through its period and stops it
+// once its context is cancelled
+type tracker struct {
+	ctx    context.Context
+	report jobs.Reporter
+	start  time.Time
+	span   time.Duration
+	rows   int
+}
+
+// newTracker covers [from, to) narrowed to the datasets' data. A nil report
+// only checks for cancellation.
+func newTracker(ctx context.Context, report jobs.Reporter, from, to time.Time, datasets ...*models.Dataset) *tracker {
+	var first, last time.Time
+	for _, d := range datasets {
+		if d.StartDate != nil && (first.IsZero() || d.StartDate.Before(first)) {
+			first = *d.StartDate
+		}
+		if d.EndDate != nil && d.EndDate.After(last) {
+			last = *d.EndDate
+		}
+	}
+	if from.IsZero() || from.Before(first) {
+		from = first
+	}
+	if to.IsZero() || to.After(last) {
+		to = last
+	}
+	return &tracker{ctx: ctx, report: report, start: from, span: to.Sub(from)}
+}
+
+// at notes a row at time row, returning the context's error once it is cancelled
+func (t *tracker) at(row time.Time) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	t.rows++
+	if t.report != nil && t.span > 0 && t.rows%trackEvery == 0 {
+		t.report(float64(row.Sub(t.start))/float64(t.span), "")
+	}
+	return nil
+}
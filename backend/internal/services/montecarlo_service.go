@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/montecarlo"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+	"gorm.io/gorm"
+)
+
+// ErrSimulationNotFound is returned when a Monte Carlo simulation does not exist or belongs to another user
+var ErrSimulationNotFound = errors.New("simulation not found")
+
+type MonteCarloService struct {
+	backtests BacktestService
+}
+
+// MonteCarloRequest is a queued simulation of a backtest's trades
+type MonteCarloRequest struct {
+	BacktestID string `json:"backtestId"`
+	montecarlo.Config
+}
+
+// Submit checks a simulation of one of the user's backtests and queues it
+// to run in the background. The seed is picked now when cfg leaves it zero,
+// so a resumed job repeats the same runs.
+func (s *MonteCarloService) Submit(userID, backtestID string, cfg montecarlo.Config) (*models.Job, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	var count int64
+	err := database.DB.Model(&models.BacktestResult{}).
+		Where("id = ? AND user_id = ?", backtestID, userID).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrBacktestNotFound
+	}
+	return enqueue(userID, JobMonteCarlo, MonteCarloRequest{BacktestID: backtestID, Config: cfg})
+}
+
+// Run simulates the request over the backtest's trades and stores the
+// report with the seed used
+func (s *MonteCarloService) Run(ctx context.Context, userID string, req MonteCarloRequest, report jobs.Reporter) (*models.MonteCarloSimulation, error) {
+	cfg := req.Config
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	report(0, "Loading trades")
+	detail, err := s.backtests.Get(userID, req.BacktestID)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Summary engine.Summary `json:"summary"`
+		Trades  []engine.Trade `json:"trades"`
+	}
+	if err := json.Unmarshal(detail.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to read backtest result: %w", err)
+	}
+
+	report(0, "Simulating")
+	rep, err := montecarlo.Run(ctx, result.Trades, result.Summary.InitialBalance, cfg, func(done, total int) {
+		report(float64(done)/float64(total), "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &models.MonteCarloSimulation{
+		UserID:     userID,
+		BacktestID: req.BacktestID,
+		Seed:       cfg.Seed,
+		Config:     cfg,
+		Report:     rep,
+	}
+	if err := database.DB.Create(sim).Error; err != nil {
+		return nil, fmt.Errorf("failed to save simulation: %w", err)
+	}
+	return sim, nil
+}
+
+// Get returns one of the user's simulations with its report
+func (s *MonteCarloService) Get(userID, id string) (*models.MonteCarloSimulation, error) {
+	var sim models.MonteCarloSimulation
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&sim).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSimulationNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &sim, nil
+}
+
+// List returns the simulations of one of the user's backtests, newest
+// first, without their reports
+func (s *MonteCarloService) List(userID, backtestID string) ([]models.MonteCarloSimulation, error) {
+	var list []models.MonteCarloSimulation
+	err := database.DB.Omit("report").Where("backtest_id = ? AND user_id = ?", backtestID, userID).
+		Order("created_at DESC").Find(&list).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return list, nil
+}
+
+// Delete removes one of the user's simulations
+func (s *MonteCarloService) Delete(userID, id string) error {
+	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.MonteCarloSimulation{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete simulation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSimulationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/dsl"
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/metrics"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/optimize"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+	"github.com/PervFVCK/strategyforge/internal/strategies"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+	"gorm.io/gorm"
+)
+
+// ErrOptimizationNotFound is returned when an optimization does not exist or belongs to another user
+var ErrOptimizationNotFound = errors.New("optimization not found")
+
+// Bounds on a parameter search
+const (
+	defaultSamples      = 100
+	maxOptimizationBars = 2000000 // Held in memory and replayed for every trial
+)
+
+type OptimizationService struct {
+	backtests  BacktestService
+	strategies StrategyService
+}
+
+// OptimizeRequest describes a parameter search. Parameters holds the
+// values of the parameters that are not searched.
+type OptimizeRequest struct {
+	BacktestRequest
+	Method    string             `json:"method"` // grid, random or genetic; grid when empty
+	Ranges    optimize.Space     `json:"ranges"`
+	Samples   int                `json:"samples"`   // Sets drawn by random search; 100 when zero
+	Genetic   optimize.Genetic   `json:"genetic"`   // Settings of a genetic search
+	Seed      int64              `json:"seed"`      // Random or genetic search seed; picked when zero
+	Objective string             `json:"objective"` // Metric to maximize, or custom; netProfit when empty
+	Weights   map[string]float64 `json:"weights"`   // Metric weights of a custom objective
+	MinTrades int                `json:"minTrades"` // Runs with fewer trades are not ranked
+}
+
+// searchPlan is a checked request ready to run
+type searchPlan struct {
+	dataset   *models.Dataset
+	cfg       engine.Config
+	from, to  time.Time
+	sets      []optimize.Params
+	objective optimize.Objective
+	build     func(optimize.Params) (engine.Strategy, error)
+}
+
+// Submit checks a parameter search and queues it to run in the background
+func (s *OptimizationService) Submit(userID string, req OptimizeRequest) (*models.Job, error) {
+	if req.Seed == 0 && (req.Method == optimize.MethodRandom || req.Method == optimize.MethodGenetic) {
+		req.Seed = time.Now().UnixNano()
+	}
+	if _, err := s.plan(userID, &req); err != nil {
+		return nil, err
+	}
+	return enqueue(userID, JobOptimization, req)
+}
+
+// plan checks a request: its dataset, its parameter sets and that the
+// strategy accepts both ends of every range
+func (s *OptimizationService) plan(userID string, req *OptimizeRequest) (*searchPlan, error) {
+	if engine.Mode(req.Mode) == engine.ModeTick {
+		return nil, errors.New("optimization runs in bar mode")
+	}
+	p := &searchPlan{objective: optimize.Objective{Metric: req.Objective, Weights: req.Weights}}
+	if err := p.objective.Validate(); err != nil {
+		return nil, err
+	}
+	for name := range req.Ranges {
+		if _, fixed := req.Parameters[name]; fixed {
+			return nil, fmt.Errorf("parameter %s is both fixed and searched", name)
+		}
+	}
+
+	var err error
+	switch req.Method {
+	case "", optimize.MethodGrid:
+		req.Method = optimize.MethodGrid
+		p.sets, err = req.Ranges.Grid()
+	case optimize.MethodRandom:
+		if req.Samples == 0 {
+			req.Samples = defaultSamples
+		}
+		p.sets, err = req.Ranges.Random(req.Samples, req.Seed)
+	case optimize.MethodGenetic:
+		// Sets are bred as the search goes
+		err = req.Genetic.Validate(req.Ranges)
+	default:
+		return nil, fmt.Errorf("unknown search method %q", req.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.from, p.to, err = backtestRange(req.BacktestRequest); err != nil {
+		return nil, err
+	}
+	if p.dataset, p.cfg, err = s.backtests.prepare(userID, req.BacktestRequest, p.from, p.to); err != nil {
+		return nil, err
+	}
+	if p.build, err = s.factory(userID, &req.BacktestRequest); err != nil {
+		return nil, err
+	}
+	for _, set := range req.Ranges.Extremes() {
+		if _, err := p.build(set); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// factory returns a constructor of the request's strategy for a set of
+// searched values. A saved strategy is compiled once and its name stored
+// in req.Strategy.
+func (s *OptimizationService) factory(userID string, req *BacktestRequest) (func(optimize.Params) (engine.Strategy, error), error) {
+	fixed := req.Parameters
+	merge := func(set optimize.Params) map[string]any {
+		params := make(map[string]any, len(fixed)+len(set))
+		maps.Copy(params, fixed)
+		for name, v := range set {
+			params[name] = v
+		}
+		return params
+	}
+
+	if req.StrategyID == "" {
+		name := req.Strategy
+		if _, err := strategies.New(name, fixed); err != nil {
+			return nil, err
+		}
+		return func(set optimize.Params) (engine.Strategy, error) {
+			return strategies.New(name, merge(set))
+		}, nil
+	}
+	saved, prog, err := s.strategies.Compile(userID, req.StrategyID)
+	if err != nil {
+		return nil, err
+	}
+	req.Strategy = saved.Name
+	return func(set optimize.Params) (engine.Strategy, error) {
+		return dsl.NewStrategy(prog, merge(set))
+	}, nil
+}
+
+// Run executes a parameter search on every CPU core and stores it with a
+// backtest of the best parameters. A genetic search keeps a checkpoint
+// under jobID until it ends, resuming from one an earlier run of the job
+// left.
+func (s *OptimizationService) Run(ctx context.Context, jobID, userID string, req OptimizeRequest, report jobs.Reporter) (*models.Optimization, error) {
+	p, err := s.plan(userID, &req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backtests.convert(userID, &p.cfg, p.from, p.to); err != nil {
+		return nil, err
+	}
+
+	report(0, "Loading data")
+	bars, err := s.load(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	report(0, "Running trials")
+	eval := func(ctx context.Context, set optimize.Params) (*engine.Result, error) {
+		strategy, err := p.build(set)
+		if err != nil {
+			return nil, err
+		}
+		return replayBars(ctx, p.cfg, strategy, nil, bars)
+	}
+	out, err := s.search(ctx, jobID, &req, p, eval, func(done, total int) {
+		report(float64(done)/float64(total), "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Best == nil && failed(out.Trials) {
+		return nil, fmt.Errorf("every trial failed: %s", out.Trials[0].Error)
+	}
+	return s.save(userID, req, p, out)
+}
+
+// search runs the request's search on every CPU core. A genetic search
+// with a checkpoint key stores its state under it after every generation,
+// picking up from the state already there.
+func (s *OptimizationService) search(ctx context.Context, checkpoint string, req *OptimizeRequest, p *searchPlan, eval optimize.Evaluate, progress func(done, total int)) (*optimize.Outcome, error) {
+	if req.Method != optimize.MethodGenetic {
+		return optimize.Search(ctx, p.sets, p.objective, req.MinTrades, runtime.NumCPU(), eval, progress)
+	}
+
+	var saved models.OptimizationCheckpoint
+	var store func(*optimize.Evolution) error
+	if checkpoint != "" {
+		err := database.DB.Where("job_id = ?", checkpoint).First(&saved).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		saved.JobID = checkpoint
+		store = func(state *optimize.Evolution) error {
+			saved.State = *state
+			if err := database.DB.Save(&saved).Error; err != nil {
+				return fmt.Errorf("failed to save checkpoint: %w", err)
+			}
+			return nil
+		}
+		defer database.DB.Where("job_id = ?", checkpoint).Delete(&models.OptimizationCheckpoint{})
+	}
+
+	state := &saved.State
+	err := optimize.Evolve(ctx, req.Ranges, req.Genetic, req.Seed, p.objective, req.MinTrades, runtime.NumCPU(), state, eval, store, progress)
+	if err != nil {
+		return nil, err
+	}
+	optimize.Rank(state.Trials, req.MinTrades)
+	out := &optimize.Outcome{Trials: state.Trials, Generations: state.Generation}
+	// Only scores were kept, so the best set runs once more
+	if top, ok := out.Top(); ok {
+		if out.Best, err = eval(ctx, top.Params); err != nil {
+			return nil, err
+		}
+		out.Report = metrics.Compute(out.Best)
+	}
+	return out, nil
+}
+
+// failed reports whether every trial ended in an error
+func failed(trials []optimize.Trial) bool {
+	for _, t := range trials {
+		if t.Error == "" {
+			return false
+		}
+	}
+	return len(trials) > 0
+}
+
+// load reads the plan's bars into memory for replaying
+func (s *OptimizationService) load(ctx context.Context, p *searchPlan) ([]marketdata.Bar, error) {
+	var bars []marketdata.Bar
+	track := newTracker(ctx, nil, p.from, p.to, p.dataset)
+	err := s.backtests.datasets.Resample(p.dataset, resample.Options{Timeframe: p.cfg.Timeframe}, p.from, p.to, func(bar marketdata.Bar) error {
+		if err := track.at(bar.Time); err != nil {
+			return err
+		}
+		if len(bars) == maxOptimizationBars {
+			return fmt.Errorf("more than %d bars to test; use a higher timeframe or a shorter range", maxOptimizationBars)
+		}
+		bars = append(bars, bar)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+	return bars, nil
+}
+
+// replayBars runs a strategy over bars held in memory, after handing it the
+// warm-up bars, which it cannot trade on
+func replayBars(ctx context.Context, cfg engine.Config, strategy engine.Strategy, warmUp, bars []marketdata.Bar) (*engine.Result, error) {
+	eng, err := engine.New(cfg, strategy)
+	if err != nil {
+		return nil, err
+	}
+	for _, bar := range warmUp {
+		if err := eng.WarmUp(bar); err != nil {
+			return nil, err
+		}
+	}
+	for i, bar := range bars {
+		if i%trackEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if err := eng.OnBar(bar); err != nil {
+			return nil, err
+		}
+	}
+	return eng.Finish()
+}
+
+// withParams returns req with a searched set over its fixed parameters
+func withParams(req BacktestRequest, set optimize.Params) BacktestRequest {
+	params := make(strategies.Params, len(req.Parameters)+len(set))
+	maps.Copy(params, req.Parameters)
+	for name, v := range set {
+		params[name] = v
+	}
+	req.Parameters = params
+	return req
+}
+
+// save stores the search and a backtest of its best parameters
+func (s *OptimizationService) save(userID string, req OptimizeRequest, p *searchPlan, out *optimize.Outcome) (*models.Optimization, error) {
+	opt := &models.Optimization{
+		UserID:      userID,
+		StrategyID:  req.StrategyID,
+		Strategy:    req.Strategy,
+		DatasetID:   p.dataset.ID,
+		Pair:        p.cfg.Symbol,
+		Timeframe:   string(p.cfg.Timeframe),
+		Method:      req.Method,
+		Objective:   p.objective,
+		Space:       req.Ranges,
+		Fixed:       req.Parameters,
+		MinTrades:   req.MinTrades,
+		TotalTrials: len(out.Trials),
+		Trials:      out.Trials,
+	}
+	switch req.Method {
+	case optimize.MethodRandom:
+		opt.Seed = req.Seed
+	case optimize.MethodGenetic:
+		opt.Seed, opt.Genetic, opt.Generations = req.Seed, &req.Genetic, out.Generations
+	}
+
+	var best *models.BacktestResult
+	if top, ok := out.Top(); ok {
+		opt.BestParams, opt.BestScore = top.Params, top.Score
+	}
+	if out.Best != nil {
+		var err error
+		if best, err = record(userID, withParams(req.BacktestRequest, opt.BestParams), p.dataset.ID, out.Best, out.Best); err != nil {
+			return nil, err
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if best != nil {
+			if err := tx.Create(best).Error; err != nil {
+				return err
+			}
+			opt.BestResultID = best.ID
+		}
+		return tx.Create(opt).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save optimization: %w", err)
+	}
+	return opt, nil
+}
+
+// Get returns one of the user's optimizations with every trial
+func (s *OptimizationService) Get(userID, id string) (*models.Optimization, error) {
+	var opt models.Optimization
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&opt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOptimizationNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &opt, nil
+}
+
+// List returns the user's optimizations, newest first, without their trials
+func (s *OptimizationService) List(userID string) ([]models.Optimization, error) {
+	var list []models.Optimization
+	if err := database.DB.Omit("trials").Where("user_id = ?", userID).Order("created_at DESC").Find(&list).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return list, nil
+}
+
+// Heatmap lays out an optimization's scores over two of its parameters.
+// They may be left empty when exactly two were searched.
+func (s *OptimizationService) Heatmap(userID, id, x, y string) (*optimize.Heatmap, error) {
+	opt, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if x == "" && y == "" {
+		names := opt.Space.Names()
+		if len(names) != 2 {
+			return nil, errors.New("x and y are required unless exactly two parameters were searched")
+		}
+		x, y = names[0], names[1]
+	}
+	return optimize.NewHeatmap(opt.Trials, x, y)
+}
+
+// Delete removes one of the user's optimizations. The backtest of its best
+// parameters stays in the history.
+func (s *OptimizationService) Delete(userID, id string) error {
+	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Optimization{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete optimization: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrOptimizationNotFound
+	}
+	return nil
+}
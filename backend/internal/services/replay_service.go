@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+	"github.com/PervFVCK/strategyforge/internal/replay"
+	"github.com/PervFVCK/strategyforge/internal/resample"
+	"github.com/PervFVCK/strategyforge/internal/utils"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+)
+
+// replayStrategy is the strategy name stored with a saved replay session
+const replayStrategy = "replay"
+
+// Open replay sessions, shared by every request
+var replays = replay.NewManager()
+
+type ReplayService struct {
+	backtests BacktestService
+}
+
+// ReplayRequest describes a replay session. The account settings are those
+// of a backtest; no strategy is run and tick mode is not supported.
+type ReplayRequest struct {
+	FileID         string              `json:"fileId"`
+	Timeframe      string              `json:"timeframe"` // The dataset's own when empty
+	StartDate      string              `json:"startDate"` // First bar traded; bars before it are shown for context
+	EndDate        string              `json:"endDate"`   // The end of the dataset when empty
+	InitialBalance float64             `json:"initialBalance"`
+	Currency       string              `json:"currency"`
+	Sizing         engine.Sizing       `json:"sizing"`
+	Costs          engine.Costs        `json:"costs"`
+	Margin         engine.MarginConfig `json:"margin"`
+}
+
+// Create loads a dataset's bars and opens a replay session over them. The
+// session's ticket authorizes its WebSocket.
+func (s *ReplayService) Create(userID string, req ReplayRequest) (*replay.Session, error) {
+	if req.StartDate == "" {
+		return nil, errors.New("startDate is required")
+	}
+	backtest := BacktestRequest{
+		FileID:         req.FileID,
+		Strategy:       replayStrategy,
+		Timeframe:      req.Timeframe,
+		StartDate:      req.StartDate,
+		EndDate:        req.EndDate,
+		InitialBalance: req.InitialBalance,
+		Currency:       req.Currency,
+		Sizing:         req.Sizing,
+		Costs:          req.Costs,
+		Margin:         req.Margin,
+	}
+	start, to, err := backtestRange(backtest)
+	if err != nil {
+		return nil, err
+	}
+	if !to.IsZero() && !to.After(start) {
+		return nil, errors.New("endDate must be after startDate")
+	}
+	dataset, cfg, err := s.backtests.prepare(userID, backtest, start, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enough time back for the context bars across weekends and gaps
+	from := start.Add(-3 * replay.ContextBars * cfg.Timeframe.Duration())
+	if err := s.backtests.convert(userID, &cfg, from, to); err != nil {
+		return nil, err
+	}
+	var bars []marketdata.Bar
+	traded := 0
+	err = s.backtests.datasets.Resample(dataset, resample.Options{Timeframe: cfg.Timeframe}, from, to, func(bar marketdata.Bar) error {
+		if bar.Time.Before(start) {
+			if len(bars) == replay.ContextBars {
+				bars = bars[1:]
+			}
+		} else if traded++; traded > replay.MaxBars {
+			return fmt.Errorf("more than %d bars to replay; use a higher timeframe or set an endDate", replay.MaxBars)
+		}
+		bars = append(bars, bar)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+
+	ticket, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+	save := func(result *engine.Result) (string, error) {
+		saved, err := record(userID, backtest, dataset.ID, result, result)
+		if err != nil {
+			return "", err
+		}
+		if err := database.DB.Create(saved).Error; err != nil {
+			return "", fmt.Errorf("failed to save replay: %w", err)
+		}
+		return saved.ID, nil
+	}
+	session, err := replay.NewSession(userID, dataset.ID, cfg, bars, start, ticket, save)
+	if err != nil {
+		return nil, err
+	}
+	if err := replays.Add(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Get returns one of the user's open sessions
+func (s *ReplayService) Get(userID, id string) (*replay.Session, error) {
+	return replays.Get(userID, id)
+}
+
+// List returns the user's open sessions, newest first
+func (s *ReplayService) List(userID string) []*replay.Session {
+	return replays.List(userID)
+}
+
+// Connect returns the session a WebSocket ticket is for
+func (s *ReplayService) Connect(id, ticket string) (*replay.Session, error) {
+	return replays.Connect(id, ticket)
+}
+
+// Delete closes one of the user's sessions. Trades not saved are lost.
+func (s *ReplayService) Delete(userID, id string) error {
+	return replays.Remove(userID, id)
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/PervFVCK/strategyforge/internal/dsl"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/utils"
+	"github.com/PervFVCK/strategyforge/internal/visual"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+	"gorm.io/gorm"
+)
+
+type StrategyService struct{}
+
+var (
+	// ErrStrategyNotFound is returned when a strategy does not exist or is not visible to the user
+	ErrStrategyNotFound = errors.New("strategy not found")
+	// ErrInvalidStrategy is returned when saving code that does not compile
+	ErrInvalidStrategy = errors.New("strategy code has errors")
+)
+
+// StrategyRequest is the payload for creating or updating a strategy
+type StrategyRequest struct {
+	Name        string `json:"name"` // Taken from the code's strategy line when empty
+	Description string `json:"description"`
+	Code        string `json:"code"` // Text, or a visual builder graph as JSON
+	IsPublic    bool   `json:"isPublic"`
+}
+
+// Strategy code formats. Code is stored as text, or as a visual builder
+// graph in JSON.
+const (
+	FormatText  = "text"
+	FormatGraph = "graph"
+)
+
+// StrategyCheck is the compiler's feedback on a strategy's code
+type StrategyCheck struct {
+	Valid  bool            `json:"valid"`
+	Format string          `json:"format"`
+	Name   string          `json:"name,omitempty"`
+	Errors []StrategyError `json:"errors"`
+	Params []StrategyParam `json:"params"`
+}
+
+// StrategyError is a problem located in text by position, or in a graph
+// by node
+type StrategyError struct {
+	Pos     *dsl.Pos `json:"pos,omitempty"`
+	Node    string   `json:"node,omitempty"`
+	Message string   `json:"message"`
+}
+
+// StrategyParam describes a tunable param declared by the code
+type StrategyParam struct {
+	Name    string   `json:"name"`
+	Default float64  `json:"default"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+}
+
+// ConvertRequest asks for code in the other format
+type ConvertRequest struct {
+	Code string `json:"code"`
+	To   string `json:"to"` // text or graph
+}
+
+// Check compiles code and reports its errors, or its params when valid
+func (s *StrategyService) Check(code string) *StrategyCheck {
+	check := &StrategyCheck{Format: codeFormat(code), Errors: []StrategyError{}, Params: []StrategyParam{}}
+	prog, err := compileCode(code)
+	if err != nil {
+		check.Errors = strategyErrors(err)
+		return check
+	}
+	check.Valid = true
+	check.Name = prog.Name
+	for _, p := range prog.Params {
+		check.Params = append(check.Params, StrategyParam{Name: p.Name, Default: p.Default, Min: p.Min, Max: p.Max})
+	}
+	return check
+}
+
+// Convert compiles code and writes it in the requested format. Comments and
+// graph layout are not carried over.
+func (s *StrategyService) Convert(req ConvertRequest) (string, *StrategyCheck, error) {
+	if req.To != FormatText && req.To != FormatGraph {
+		return "", nil, fmt.Errorf("cannot convert to %q; use %s or %s", req.To, FormatText, FormatGraph)
+	}
+	prog, err := compileCode(req.Code)
+	if err != nil {
+		check := &StrategyCheck{Format: codeFormat(req.Code), Errors: strategyErrors(err), Params: []StrategyParam{}}
+		return "", check, ErrInvalidStrategy
+	}
+	if req.To == FormatText {
+		return dsl.Format(prog), nil, nil
+	}
+	code, err := visual.FromProgram(prog).Marshal()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to write graph: %w", err)
+	}
+	return code, nil, nil
+}
+
+func codeFormat(code string) string {
+	if visual.IsGraph(code) {
+		return FormatGraph
+	}
+	return FormatText
+}
+
+// compileCode compiles text or a graph into a checked program
+func compileCode(code string) (*dsl.Program, error) {
+	if visual.IsGraph(code) {
+		return visual.Compile([]byte(code))
+	}
+	return dsl.Compile(code)
+}
+
+// strategyErrors lists a compile error's problems
+func strategyErrors(err error) []StrategyError {
+	var list []StrategyError
+	var textErrs dsl.Errors
+	var graphErrs visual.Errors
+	switch {
+	case errors.As(err, &textErrs):
+		for _, e := range textErrs {
+			pos := e.Pos
+			list = append(list, StrategyError{Pos: &pos, Message: e.Message})
+		}
+	case errors.As(err, &graphErrs):
+		for _, e := range graphErrs {
+			list = append(list, StrategyError{Node: e.Node, Message: e.Message})
+		}
+	default:
+		list = append(list, StrategyError{Message: err.Error()})
+	}
+	return list
+}
+
+// Create saves a new strategy after checking its code. Invalid code returns
+// ErrInvalidStrategy along with the check.
+func (s *StrategyService) Create(userID string, req StrategyRequest) (*models.Strategy, *StrategyCheck, error) {
+	check, err := s.validate(&req)
+	if err != nil {
+		return nil, check, err
+	}
+	strategy := models.Strategy{
+		UserID:      userID,
+		Name:        req.Name,
+		Description: req.Description,
+		Code:        req.Code,
+		IsPublic:    req.IsPublic,
+	}
+	if err := database.DB.Create(&strategy).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to save strategy: %w", err)
+	}
+	return &strategy, check, nil
+}
+
+// Update replaces one of the user's strategies after checking its code
+func (s *StrategyService) Update(userID, id string, req StrategyRequest) (*models.Strategy, *StrategyCheck, error) {
+	strategy, err := s.owned(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	check, err := s.validate(&req)
+	if err != nil {
+		return nil, check, err
+	}
+	strategy.Name = req.Name
+	strategy.Description = req.Description
+	strategy.Code = req.Code
+	strategy.IsPublic = req.IsPublic
+	if err := database.DB.Save(strategy).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to save strategy: %w", err)
+	}
+	return strategy, check, nil
+}
+
+// validate cleans a request and compiles its code. The check is returned
+// with any problem found in the request itself.
+func (s *StrategyService) validate(req *StrategyRequest) (*StrategyCheck, error) {
+	req.Name = utils.SanitizeInput(req.Name)
+	req.Description = utils.SanitizeInput(req.Description)
+	check := s.Check(req.Code)
+	if !check.Valid {
+		return check, ErrInvalidStrategy
+	}
+	if req.Name == "" {
+		req.Name = utils.SanitizeInput(check.Name)
+	}
+	if len(req.Name) < 1 || len(req.Name) > 100 {
+		return check, errors.New("name must be between 1 and 100 characters")
+	}
+	if len(req.Description) > 2000 {
+		return check, errors.New("description must be at most 2000 characters")
+	}
+	return check, nil
+}
+
+// Get returns a strategy the user owns or one that is public
+func (s *StrategyService) Get(userID, id string) (*models.Strategy, error) {
+	var strategy models.Strategy
+	err := database.DB.Where("id = ? AND (user_id = ? OR is_public = ?)", id, userID, true).First(&strategy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStrategyNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &strategy, nil
+}
+
+// owned returns a strategy only when the user owns it
+func (s *StrategyService) owned(userID, id string) (*models.Strategy, error) {
+	var strategy models.Strategy
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&strategy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStrategyNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &strategy, nil
+}
+
+// List returns the user's own strategies, most recently updated first
+func (s *StrategyService) List(userID string) ([]models.Strategy, error) {
+	var strategies []models.Strategy
+	if err := database.DB.Where("user_id = ?", userID).Order("updated_at DESC").Find(&strategies).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return strategies, nil
+}
+
+// Delete removes one of the user's strategies. Backtests run with it keep
+// its name.
+func (s *StrategyService) Delete(userID, id string) error {
+	strategy, err := s.owned(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Delete(strategy).Error; err != nil {
+		return fmt.Errorf("failed to delete strategy: %w", err)
+	}
+	return nil
+}
+
+// Compile parses and checks a strategy visible to the user. Its program can
+// build any number of runs.
+func (s *StrategyService) Compile(userID, id string) (*models.Strategy, *dsl.Program, error) {
+	strategy, err := s.Get(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	prog, err := compileCode(strategy.Code)
+	if err != nil {
+		return nil, nil, fmt.Errorf("strategy %s does not compile: %w", strategy.Name, err)
+	}
+	return strategy, prog, nil
+}
+
+// Load compiles a strategy visible to the user, ready to run with the
+// given param overrides
+func (s *StrategyService) Load(userID, id string, overrides map[string]any) (*models.Strategy, *dsl.Strategy, error) {
+	strategy, prog, err := s.Compile(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	runner, err := dsl.NewStrategy(prog, overrides)
+	if err != nil {
+		return nil, nil, err
+	}
+	return strategy, runner, nil
+}
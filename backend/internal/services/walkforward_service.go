@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/jobs"
+	"github.com/PervFVCK/strategyforge/internal/models"
+	"github.com/PervFVCK/strategyforge/internal/optimize"
+	"github.com/PervFVCK/strategyforge/pkg/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Walk-forward defaults
+const (
+	defaultWindows     = 5
+	defaultOutOfSample = 25
+)
+
+type WalkForwardService struct {
+	optimizations OptimizationService
+}
+
+// WalkForwardRequest describes a walk-forward analysis: the parameter
+// search of OptimizeRequest repeated on each in-sample window, with the
+// best set then tested on the out-of-sample window after it
+type WalkForwardRequest struct {
+	OptimizeRequest
+	Windows     int     `json:"windows"`     // Out-of-sample windows; 5 when zero
+	OutOfSample float64 `json:"outOfSample"` // Percent of each window tested out of sample; 25 when zero
+	Anchored    bool    `json:"anchored"`    // In-sample windows grow from the first bar instead of rolling
+}
+
+// WalkForwardResult is the ResultData of a walk-forward analysis: the
+// out-of-sample runs stitched into one, and the report of every window
+type WalkForwardResult struct {
+	engine.Result
+	WalkForward *optimize.WalkForwardReport `json:"walkForward"`
+}
+
+// Submit checks a walk-forward analysis and queues it to run in the background
+func (s *WalkForwardService) Submit(userID string, req WalkForwardRequest) (*models.Job, error) {
+	if req.Seed == 0 && (req.Method == optimize.MethodRandom || req.Method == optimize.MethodGenetic) {
+		req.Seed = time.Now().UnixNano()
+	}
+	if req.Windows == 0 {
+		req.Windows = defaultWindows
+	}
+	if req.OutOfSample == 0 {
+		req.OutOfSample = defaultOutOfSample
+	}
+	// Whether there are enough bars for them is known once the data is loaded
+	if err := optimize.CheckWindows(req.Windows, req.OutOfSample); err != nil {
+		return nil, err
+	}
+	if _, err := s.optimizations.plan(userID, &req.OptimizeRequest); err != nil {
+		return nil, err
+	}
+	return enqueue(userID, JobWalkForward, req)
+}
+
+// Run executes a walk-forward analysis. Each out-of-sample run starts from
+// the balance the one before it ended on, with a fresh strategy whose
+// indicators first warm up on the window's in-sample bars without trading,
+// so it can trade from the first out-of-sample bar; should the account be
+// wiped out, the windows after are left untested. The stitched result is stored with
+// the out-of-sample runs as its children.
+func (s *WalkForwardService) Run(ctx context.Context, userID string, req WalkForwardRequest, report jobs.Reporter) (*models.BacktestResult, error) {
+	p, err := s.optimizations.plan(userID, &req.OptimizeRequest)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.optimizations.backtests.convert(userID, &p.cfg, p.from, p.to); err != nil {
+		return nil, err
+	}
+
+	report(0, "Loading data")
+	bars, err := s.optimizations.load(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := optimize.Split(len(bars), req.Windows, req.OutOfSample, req.Anchored)
+	if err != nil {
+		return nil, err
+	}
+
+	wf := &optimize.WalkForwardReport{Anchored: req.Anchored, OutOfSample: req.OutOfSample, Objective: p.objective}
+	var runs []*engine.Result
+	var children []*models.BacktestResult
+	balance := p.cfg.InitialBalance
+	if balance == 0 {
+		balance = engine.DefaultInitialBalance
+	}
+	n := float64(len(windows))
+	for i, w := range windows {
+		report(float64(i)/n, fmt.Sprintf("Window %d of %d", i+1, len(windows)))
+		in := bars[w.InStart:w.InEnd]
+		eval := func(ctx context.Context, set optimize.Params) (*engine.Result, error) {
+			strategy, err := p.build(set)
+			if err != nil {
+				return nil, err
+			}
+			return replayBars(ctx, p.cfg, strategy, nil, in)
+		}
+		out, err := s.optimizations.search(ctx, "", &req.OptimizeRequest, p, eval, func(done, total int) {
+			report((float64(i)+float64(done)/float64(total))/n, "")
+		})
+		if err != nil {
+			return nil, err
+		}
+		top, ok := out.Top()
+		if !ok {
+			if failed(out.Trials) {
+				return nil, fmt.Errorf("window %d: every trial failed: %s", i+1, out.Trials[0].Error)
+			}
+			return nil, fmt.Errorf("window %d: no parameter set made %d trades in sample", i+1, req.MinTrades)
+		}
+
+		strategy, err := p.build(top.Params)
+		if err != nil {
+			return nil, err
+		}
+		cfg := p.cfg
+		cfg.InitialBalance = balance
+		// The in-sample bars warm the strategy's indicators up, so the
+		// out-of-sample window trades from its first bar as it would live
+		result, err := replayBars(ctx, cfg, strategy, in, bars[w.InEnd:w.OutEnd])
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", i+1, err)
+		}
+		child, err := record(userID, withParams(req.BacktestRequest, top.Params), p.dataset.ID, result, result)
+		if err != nil {
+			return nil, err
+		}
+		child.ID = uuid.New().String()
+		runs, children = append(runs, result), append(children, child)
+
+		is, oos := &out.Best.Summary, &result.Summary
+		wf.Windows = append(wf.Windows, optimize.WindowReport{
+			InSampleStart:     is.Start,
+			InSampleEnd:       is.End,
+			OutOfSampleStart:  oos.Start,
+			OutOfSampleEnd:    oos.End,
+			Params:            top.Params,
+			Score:             top.Score,
+			InSampleProfit:    is.NetProfit,
+			InSampleReturn:    optimize.Annualized(is.NetProfit, is.InitialBalance, is.Start, is.End),
+			OutOfSampleProfit: oos.NetProfit,
+			OutOfSampleReturn: optimize.Annualized(oos.NetProfit, oos.InitialBalance, oos.Start, oos.End),
+			OutOfSampleTrades: oos.TotalTrades,
+			BacktestID:        child.ID,
+		})
+		if balance = oos.FinalBalance; balance <= 0 {
+			wf.Stopped = fmt.Sprintf("the account was wiped out in window %d", i+1)
+			break
+		}
+	}
+	wf.Summarize()
+	return s.save(userID, req, p, runs, children, wf)
+}
+
+// save stores the stitched out-of-sample result as the parent of each
+// window's run
+func (s *WalkForwardService) save(userID string, req WalkForwardRequest, p *searchPlan, runs []*engine.Result, children []*models.BacktestResult, wf *optimize.WalkForwardReport) (*models.BacktestResult, error) {
+	stitched, err := engine.Stitch(runs)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := record(userID, req.BacktestRequest, p.dataset.ID, stitched, WalkForwardResult{*stitched, wf})
+	if err != nil {
+		return nil, err
+	}
+	parent.Windows = len(children)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(parent).Error; err != nil {
+			return err
+		}
+		for _, child := range children {
+			child.ParentID = parent.ID
+			if err := tx.Create(child).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save walk-forward analysis: %w", err)
+	}
+	return parent, nil
+}
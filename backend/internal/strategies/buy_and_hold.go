@@ -0,0 +1,29 @@
+package strategies
+
+import (
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// buyAndHold buys on the first bar and holds to the end, a baseline to
+// compare other strategies against
+type buyAndHold struct {
+	bought bool
+}
+
+func newBuyAndHold(params Params) (engine.Strategy, error) {
+	return &buyAndHold{}, nil
+}
+
+func (s *buyAndHold) Init(ctx *engine.Context) error {
+	return nil
+}
+
+func (s *buyAndHold) OnBar(ctx *engine.Context, bar marketdata.Bar) error {
+	if s.bought || ctx.WarmingUp() {
+		return nil
+	}
+	s.bought = true
+	_, err := ctx.Buy(0, 0, 0)
+	return err
+}
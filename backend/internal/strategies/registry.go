@@ -0,0 +1,69 @@
+// Package strategies holds the built-in strategies that can be run by name
+package strategies
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+)
+
+// Params are the user-supplied strategy parameters
+type Params map[string]any
+
+// Float returns a numeric parameter, or def when it is absent
+func (p Params) Float(name string, def float64) (float64, error) {
+	v, ok := p[name]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	default:
+		return 0, fmt.Errorf("parameter %s must be a number", name)
+	}
+}
+
+// Int returns a whole-number parameter, or def when it is absent
+func (p Params) Int(name string, def int) (int, error) {
+	f, err := p.Float(name, float64(def))
+	if err != nil {
+		return 0, err
+	}
+	if f != float64(int(f)) {
+		return 0, fmt.Errorf("parameter %s must be a whole number", name)
+	}
+	return int(f), nil
+}
+
+// Factory builds a strategy from its parameters
+type Factory func(params Params) (engine.Strategy, error)
+
+var registry = map[string]Factory{
+	"sma_crossover": newSMACrossover,
+	"buy_and_hold":  newBuyAndHold,
+}
+
+// New builds the named built-in strategy
+func New(name string, params Params) (engine.Strategy, error) {
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+	return factory(params)
+}
+
+// Names lists the built-in strategies
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
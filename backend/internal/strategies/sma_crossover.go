@@ -0,0 +1,84 @@
+package strategies
+
+import (
+	"errors"
+
+	"github.com/PervFVCK/strategyforge/internal/engine"
+	"github.com/PervFVCK/strategyforge/internal/marketdata"
+)
+
+// smaCrossover goes long when the fast moving average crosses above the slow
+// one and short when it crosses below, always holding one position
+type smaCrossover struct {
+	fast, slow     int
+	stopLossPips   float64
+	takeProfitPips float64
+	prevDiff       float64
+	primed         bool
+}
+
+func newSMACrossover(params Params) (engine.Strategy, error) {
+	s := &smaCrossover{}
+	var err error
+	if s.fast, err = params.Int("fast", 10); err != nil {
+		return nil, err
+	}
+	if s.slow, err = params.Int("slow", 30); err != nil {
+		return nil, err
+	}
+	if s.stopLossPips, err = params.Float("stopLossPips", 0); err != nil {
+		return nil, err
+	}
+	if s.takeProfitPips, err = params.Float("takeProfitPips", 0); err != nil {
+		return nil, err
+	}
+	if s.fast < 1 || s.slow <= s.fast {
+		return nil, errors.New("need 1 <= fast < slow")
+	}
+	if s.stopLossPips < 0 || s.takeProfitPips < 0 {
+		return nil, errors.New("stop loss and take profit must not be negative")
+	}
+	return s, nil
+}
+
+func (s *smaCrossover) Init(ctx *engine.Context) error {
+	return nil
+}
+
+func (s *smaCrossover) OnBar(ctx *engine.Context, bar marketdata.Bar) error {
+	history := ctx.History(s.slow)
+	if len(history) < s.slow {
+		return nil
+	}
+	diff := mean(history[s.slow-s.fast:]) - mean(history)
+	crossed := s.primed && (diff > 0) != (s.prevDiff > 0) && diff != 0
+	s.prevDiff, s.primed = diff, true
+	if !crossed || ctx.WarmingUp() {
+		return nil
+	}
+
+	ctx.CloseAll()
+	side := engine.Buy
+	if diff < 0 {
+		side = engine.Sell
+	}
+
+	// Protective levels are set relative to the close the signal came from
+	order := engine.Order{Side: side, Type: engine.Market}
+	if s.stopLossPips > 0 {
+		order.StopLoss = bar.Close - float64(side)*ctx.Pips(s.stopLossPips)
+	}
+	if s.takeProfitPips > 0 {
+		order.TakeProfit = bar.Close + float64(side)*ctx.Pips(s.takeProfitPips)
+	}
+	_, err := ctx.Submit(order)
+	return err
+}
+
+func mean(bars []marketdata.Bar) float64 {
+	sum := 0.0
+	for _, b := range bars {
+		sum += b.Close
+	}
+	return sum / float64(len(bars))
+}
@@ -0,0 +1,356 @@
+package visual
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/PervFVCK/strategyforge/internal/dsl"
+)
+
+// Compile parses and checks a graph into a program
+func Compile(data []byte) (*dsl.Program, error) {
+	g, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return g.Program()
+}
+
+// Node visit states while building expressions
+const (
+	unvisited = iota
+	visiting
+	built
+)
+
+type compiler struct {
+	g     *Graph
+	byID  map[string]int
+	named map[string]int // Node index by Name
+	state []int
+	exprs []dsl.Expr // Built expression of each value node
+	prog  *dsl.Program
+	errs  Errors
+}
+
+// Program compiles the graph into the same form as parsed source and
+// type-checks it. Named nodes become lets, each declared after the lets it
+// reads.
+//
+// The checker locates problems by source position, so each node is given
+// line i+2 and each param a line after the nodes; line 1 is the graph.
+func (g *Graph) Program() (*dsl.Program, error) {
+	c := &compiler{
+		g:     g,
+		byID:  make(map[string]int, len(g.Nodes)),
+		named: make(map[string]int),
+		state: make([]int, len(g.Nodes)),
+		exprs: make([]dsl.Expr, len(g.Nodes)),
+		prog:  &dsl.Program{Name: g.Name},
+	}
+	if len(g.Name) > 100 {
+		c.errs.add("", "name is longer than 100 characters")
+	}
+
+	for j, p := range g.Params {
+		if !dsl.ValidName(p.Name) {
+			c.errs.add("", "param name %q is not a valid name", p.Name)
+		}
+		c.prog.Params = append(c.prog.Params, &dsl.Param{
+			Pos:     dsl.Pos{Line: len(g.Nodes) + 2 + j, Column: 1},
+			Name:    p.Name,
+			Default: p.Default,
+			Min:     p.Min,
+			Max:     p.Max,
+		})
+	}
+	for i, n := range g.Nodes {
+		if n.ID == "" {
+			c.errs.add("", "node %d has no id", i+1)
+			continue
+		}
+		if _, taken := c.byID[n.ID]; taken {
+			c.errs.add(n.ID, "id %s is used by more than one node", n.ID)
+			continue
+		}
+		c.byID[n.ID] = i
+		if !nodeTypes[n.Type] {
+			c.errs.add(n.ID, "unknown node type %q", n.Type)
+		}
+		if n.Name == "" {
+			if n.Timeframe != "" {
+				c.errs.add(n.ID, "only named nodes can be computed on a timeframe of their own")
+			}
+			continue
+		}
+		if !dsl.ValidName(n.Name) {
+			c.errs.add(n.ID, "name %q is not a valid name", n.Name)
+		} else if other, ok := c.named[n.Name]; ok {
+			c.errs.add(n.ID, "name %s is already used by node %s", n.Name, g.Nodes[other].ID)
+		} else {
+			c.named[n.Name] = i
+		}
+	}
+	if len(c.errs) > 0 {
+		return nil, c.errs
+	}
+
+	// Named nodes are declared in node order, even when nothing reads them yet
+	for i, n := range g.Nodes {
+		if n.Name != "" && !isAction(n.Type) {
+			c.value(i)
+		}
+	}
+	for i := range g.Nodes {
+		c.action(i)
+	}
+	if len(c.errs) > 0 {
+		return nil, c.errs
+	}
+	for i, n := range g.Nodes {
+		if c.state[i] == unvisited && !isAction(n.Type) {
+			c.errs.add(n.ID, "node is not connected to an entry, exit or size")
+		}
+	}
+	if len(c.errs) > 0 {
+		return nil, c.errs
+	}
+
+	if err := dsl.Check(c.prog); err != nil {
+		return nil, c.locate(err)
+	}
+	return c.prog, nil
+}
+
+var nodeTypes = map[string]bool{
+	NodeNumber: true, NodeBool: true, NodeValue: true, NodeIndicator: true,
+	NodeMath: true, NodeCompare: true, NodeLogic: true, NodeLookback: true,
+	NodeSize: true, NodeEntry: true, NodeExit: true,
+}
+
+func isAction(typ string) bool {
+	return typ == NodeSize || typ == NodeEntry || typ == NodeExit
+}
+
+func (c *compiler) pos(i int) dsl.Pos {
+	return dsl.Pos{Line: i + 2, Column: 1}
+}
+
+// locate turns the checker's source positions back into nodes
+func (c *compiler) locate(err error) error {
+	errs, ok := err.(dsl.Errors)
+	if !ok {
+		return Errors{{Message: err.Error()}}
+	}
+	var out Errors
+	for _, e := range errs {
+		node := ""
+		if i := e.Pos.Line - 2; i >= 0 && i < len(c.g.Nodes) {
+			node = c.g.Nodes[i].ID
+		}
+		out.add(node, "%s", e.Message)
+	}
+	return out
+}
+
+// action compiles an action node into the program
+func (c *compiler) action(i int) {
+	n := c.g.Nodes[i]
+	switch n.Type {
+	case NodeSize:
+		c.state[i] = built
+		if c.prog.Size != nil {
+			c.errs.add(n.ID, "size is already set by another node")
+			return
+		}
+		c.prog.Size = c.input(i, n.Size, "size")
+	case NodeEntry:
+		c.state[i] = built
+		if !c.side(n) {
+			return
+		}
+		entry := &dsl.Entry{Pos: c.pos(i), Side: n.Side, When: c.input(i, n.When, "when")}
+		if n.Size != "" {
+			entry.Size = c.input(i, n.Size, "size")
+		}
+		if n.StopLoss != "" {
+			entry.StopLoss = c.input(i, n.StopLoss, "stopLoss")
+		}
+		if n.TakeProfit != "" {
+			entry.TakeProfit = c.input(i, n.TakeProfit, "takeProfit")
+		}
+		c.prog.Entries = append(c.prog.Entries, entry)
+	case NodeExit:
+		c.state[i] = built
+		if !c.side(n) {
+			return
+		}
+		c.prog.Exits = append(c.prog.Exits, &dsl.Exit{Pos: c.pos(i), Side: n.Side, When: c.input(i, n.When, "when")})
+	}
+}
+
+func (c *compiler) side(n Node) bool {
+	if n.Side != dsl.Long && n.Side != dsl.Short {
+		c.errs.add(n.ID, "side must be long or short, not %q", n.Side)
+		return false
+	}
+	return true
+}
+
+// input builds the value node connected to one of node i's ports
+func (c *compiler) input(i int, ref, port string) dsl.Expr {
+	n := c.g.Nodes[i]
+	if ref == "" {
+		c.errs.add(n.ID, "%s is not connected", port)
+		return nil
+	}
+	j, ok := c.byID[ref]
+	if !ok {
+		c.errs.add(n.ID, "%s is connected to node %s, which does not exist", port, ref)
+		return nil
+	}
+	if isAction(c.g.Nodes[j].Type) {
+		c.errs.add(n.ID, "%s is connected to %s node %s, which has no value", port, c.g.Nodes[j].Type, ref)
+		return nil
+	}
+	return c.value(j)
+}
+
+// inputs builds a node's Inputs, which must number between min and max
+func (c *compiler) inputs(i, min, max int) []dsl.Expr {
+	n := c.g.Nodes[i]
+	if len(n.Inputs) < min || len(n.Inputs) > max {
+		switch {
+		case min == max:
+			c.errs.add(n.ID, "%s node needs %d input%s, got %d", n.Type, min, plural(min), len(n.Inputs))
+		default:
+			c.errs.add(n.ID, "%s node needs at least %d inputs, got %d", n.Type, min, len(n.Inputs))
+		}
+		return nil
+	}
+	args := make([]dsl.Expr, len(n.Inputs))
+	for k, ref := range n.Inputs {
+		args[k] = c.input(i, ref, "input "+strconv.Itoa(k+1))
+	}
+	return args
+}
+
+// value builds the expression of value node i. A named node is declared
+// as a let the first time and read by name after that.
+func (c *compiler) value(i int) dsl.Expr {
+	n := c.g.Nodes[i]
+	switch c.state[i] {
+	case built:
+		return c.exprs[i]
+	case visiting:
+		c.errs.add(n.ID, "node is part of a cycle")
+		return nil
+	}
+	c.state[i] = visiting
+	expr := c.build(i)
+	c.state[i] = built
+	if expr != nil && n.Name != "" {
+		c.prog.Lets = append(c.prog.Lets, &dsl.Let{Pos: c.pos(i), Name: n.Name, Timeframe: n.Timeframe, Value: expr})
+		expr = &dsl.Ident{At: c.pos(i), Name: n.Name}
+	}
+	c.exprs[i] = expr
+	return expr
+}
+
+func (c *compiler) build(i int) dsl.Expr {
+	n := c.g.Nodes[i]
+	at := c.pos(i)
+	switch n.Type {
+	case NodeNumber:
+		if n.Number == nil {
+			c.errs.add(n.ID, "number node has no number")
+			return nil
+		}
+		return &dsl.Number{At: at, Value: *n.Number}
+	case NodeBool:
+		if n.Bool == nil {
+			c.errs.add(n.ID, "bool node has no value")
+			return nil
+		}
+		return &dsl.Bool{At: at, Value: *n.Bool}
+	case NodeValue:
+		if n.Value == "" {
+			c.errs.add(n.ID, "value node does not name a value")
+			return nil
+		}
+		// Reading a named node builds it first, so its let comes before this use
+		if j, ok := c.named[n.Value]; ok && j != i {
+			if c.value(j) == nil {
+				return nil
+			}
+		}
+		return &dsl.Ident{At: at, Name: n.Value}
+	case NodeIndicator:
+		if n.Function == "" {
+			c.errs.add(n.ID, "indicator node has no function")
+			return nil
+		}
+		args := c.inputs(i, 0, math.MaxInt)
+		return &dsl.Call{At: at, Func: n.Function, Args: args}
+	case NodeMath:
+		if n.Op == "neg" {
+			if args := c.inputs(i, 1, 1); args != nil {
+				return &dsl.Unary{At: at, Op: "-", X: args[0]}
+			}
+			return nil
+		}
+		return c.binary(i, []string{"+", "-", "*", "/", "%"})
+	case NodeCompare:
+		return c.binary(i, []string{"==", "!=", "<", "<=", ">", ">="})
+	case NodeLogic:
+		switch n.Op {
+		case "not":
+			if args := c.inputs(i, 1, 1); args != nil {
+				return &dsl.Unary{At: at, Op: "not", X: args[0]}
+			}
+			return nil
+		case "and", "or":
+			args := c.inputs(i, 2, math.MaxInt)
+			if args == nil {
+				return nil
+			}
+			expr := args[0]
+			for _, arg := range args[1:] {
+				expr = &dsl.Binary{At: at, Op: n.Op, X: expr, Y: arg}
+			}
+			return expr
+		}
+		c.errs.add(n.ID, "logic op must be and, or or not, not %q", n.Op)
+		return nil
+	case NodeLookback:
+		if args := c.inputs(i, 2, 2); args != nil {
+			return &dsl.Index{At: at, X: args[0], Offset: args[1]}
+		}
+		return nil
+	}
+	return nil
+}
+
+func (c *compiler) binary(i int, ops []string) dsl.Expr {
+	n := c.g.Nodes[i]
+	valid := false
+	for _, op := range ops {
+		valid = valid || op == n.Op
+	}
+	if !valid {
+		c.errs.add(n.ID, "%s op %q is not one of %v", n.Type, n.Op, ops)
+		return nil
+	}
+	args := c.inputs(i, 2, 2)
+	if args == nil {
+		return nil
+	}
+	return &dsl.Binary{At: c.pos(i), Op: n.Op, X: args[0], Y: args[1]}
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
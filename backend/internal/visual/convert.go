@@ -0,0 +1,114 @@
+package visual
+
+import (
+	"fmt"
+
+	"github.com/PervFVCK/strategyforge/internal/dsl"
+)
+
+// FromProgram lays out a program as a graph. Lets become named nodes, and
+// every use of a let connects to its node. Compiling the graph gives back
+// an equivalent program.
+func FromProgram(prog *dsl.Program) *Graph {
+	b := &builder{
+		g:    &Graph{Version: Version, Name: prog.Name, Params: []Param{}, Nodes: []Node{}},
+		lets: make(map[string]string),
+	}
+	for _, p := range prog.Params {
+		b.g.Params = append(b.g.Params, Param{Name: p.Name, Default: p.Default, Min: p.Min, Max: p.Max})
+	}
+	for _, l := range prog.Lets {
+		id := b.expr(l.Value)
+		n := b.node(id)
+		if n.Name != "" {
+			// A let that only renames another needs a node of its own
+			id = b.add(Node{Type: NodeValue, Value: n.Name})
+			n = b.node(id)
+		}
+		n.Name, n.Timeframe = l.Name, l.Timeframe
+		b.lets[l.Name] = id
+	}
+	if prog.Size != nil {
+		b.add(Node{Type: NodeSize, Size: b.expr(prog.Size)})
+	}
+	for _, e := range prog.Entries {
+		n := Node{Type: NodeEntry, Side: e.Side, When: b.expr(e.When)}
+		if e.Size != nil {
+			n.Size = b.expr(e.Size)
+		}
+		if e.StopLoss != nil {
+			n.StopLoss = b.expr(e.StopLoss)
+		}
+		if e.TakeProfit != nil {
+			n.TakeProfit = b.expr(e.TakeProfit)
+		}
+		b.add(n)
+	}
+	for _, e := range prog.Exits {
+		b.add(Node{Type: NodeExit, Side: e.Side, When: b.expr(e.When)})
+	}
+	return b.g
+}
+
+type builder struct {
+	g    *Graph
+	lets map[string]string // Node ID by let name
+}
+
+func (b *builder) add(n Node) string {
+	n.ID = fmt.Sprintf("n%d", len(b.g.Nodes)+1)
+	b.g.Nodes = append(b.g.Nodes, n)
+	return n.ID
+}
+
+func (b *builder) node(id string) *Node {
+	for i := range b.g.Nodes {
+		if b.g.Nodes[i].ID == id {
+			return &b.g.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// expr adds the nodes of an expression and returns the ID of its result
+func (b *builder) expr(e dsl.Expr) string {
+	switch e := e.(type) {
+	case *dsl.Number:
+		v := e.Value
+		return b.add(Node{Type: NodeNumber, Number: &v})
+	case *dsl.Bool:
+		v := e.Value
+		return b.add(Node{Type: NodeBool, Bool: &v})
+	case *dsl.Ident:
+		if id, ok := b.lets[e.Name]; ok {
+			return id
+		}
+		return b.add(Node{Type: NodeValue, Value: e.Name})
+	case *dsl.Unary:
+		x := b.expr(e.X)
+		if e.Op == "not" {
+			return b.add(Node{Type: NodeLogic, Op: "not", Inputs: []string{x}})
+		}
+		return b.add(Node{Type: NodeMath, Op: "neg", Inputs: []string{x}})
+	case *dsl.Binary:
+		x, y := b.expr(e.X), b.expr(e.Y)
+		typ := NodeCompare
+		switch e.Op {
+		case "and", "or":
+			typ = NodeLogic
+		case "+", "-", "*", "/", "%":
+			typ = NodeMath
+		}
+		return b.add(Node{Type: typ, Op: e.Op, Inputs: []string{x, y}})
+	case *dsl.Call:
+		inputs := make([]string, len(e.Args))
+		for i, a := range e.Args {
+			inputs[i] = b.expr(a)
+		}
+		return b.add(Node{Type: NodeIndicator, Function: e.Func, Inputs: inputs})
+	case *dsl.Index:
+		x, offset := b.expr(e.X), b.expr(e.Offset)
+		return b.add(Node{Type: NodeLookback, Inputs: []string{x, offset}})
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+// Package visual is the node-graph form of a strategy, as edited in the
+// no-code builder. A graph compiles to the same dsl.Program as source text,
+// and converts to and from that text.
+package visual
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Version is the graph format this server writes. Graphs declare the version
+// they were written in so the format can change without breaking saved ones.
+const Version = 1
+
+// Limits on one graph
+const (
+	MaxSize  = 256 << 10 // Bytes of JSON
+	MaxNodes = 500
+)
+
+// Node types. Value nodes produce a number or a bool from their inputs;
+// action nodes (size, entry and exit) use values and produce none.
+const (
+	NodeNumber    = "number"    // Number: a constant
+	NodeBool      = "bool"      // Bool: true or false
+	NodeValue     = "value"     // Value: a built-in value such as close, a param, or a named node
+	NodeIndicator = "indicator" // Function applied to Inputs: indicators such as ema and rsi, crossover, or helpers such as abs
+	NodeMath      = "math"      // Op + - * / % on two Inputs, or neg on one
+	NodeCompare   = "compare"   // Op == != < <= > >= on two Inputs
+	NodeLogic     = "logic"     // Op and or or on two or more Inputs, or not on one
+	NodeLookback  = "lookback"  // Inputs[0] as it was Inputs[1] bars ago
+	NodeSize      = "size"      // Default lots of entries, from Size
+	NodeEntry     = "entry"     // Opens Side when When holds, with optional Size, StopLoss and TakeProfit
+	NodeExit      = "exit"      // Closes Side when When holds
+)
+
+// Graph is a strategy built from connected nodes
+type Graph struct {
+	Version int     `json:"version"`
+	Name    string  `json:"name,omitempty"`
+	Params  []Param `json:"params"`
+	Nodes   []Node  `json:"nodes"`
+}
+
+// Param is a tunable constant, read by value nodes
+type Param struct {
+	Name    string   `json:"name"`
+	Default float64  `json:"default"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+}
+
+// Node is one block on the canvas. Which fields apply depends on Type;
+// connections name other nodes by ID.
+type Node struct {
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	Name       string   `json:"name,omitempty"`      // Turns a value node into a let that value nodes can read by name
+	Timeframe  string   `json:"timeframe,omitempty"` // Computes a named node on a higher timeframe's bars
+	Number     *float64 `json:"number,omitempty"`
+	Bool       *bool    `json:"bool,omitempty"`
+	Value      string   `json:"value,omitempty"`
+	Function   string   `json:"function,omitempty"`
+	Op         string   `json:"op,omitempty"`
+	Inputs     []string `json:"inputs,omitempty"`
+	Side       string   `json:"side,omitempty"` // long or short
+	When       string   `json:"when,omitempty"`
+	Size       string   `json:"size,omitempty"`
+	StopLoss   string   `json:"stopLoss,omitempty"`
+	TakeProfit string   `json:"takeProfit,omitempty"`
+	Position   *Point   `json:"position,omitempty"` // Canvas layout, kept but not interpreted
+}
+
+// Point is a position on the canvas
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Error is a problem with a graph, located by node where possible
+type Error struct {
+	Node    string `json:"node,omitempty"` // Empty for problems with the graph as a whole
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	if e.Node == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("node %s: %s", e.Node, e.Message)
+}
+
+// Errors is every problem found in a graph
+type Errors []Error
+
+func (es *Errors) add(node, format string, args ...any) {
+	if len(*es) < maxErrors {
+		*es = append(*es, Error{Node: node, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (es Errors) Error() string {
+	lines := make([]string, len(es))
+	for i, e := range es {
+		lines[i] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (es Errors) err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
+
+// maxErrors caps how many problems are reported for one graph
+const maxErrors = 20
+
+// IsGraph reports whether stored strategy code is a graph rather than text
+func IsGraph(code string) bool {
+	return strings.HasPrefix(strings.TrimSpace(code), "{")
+}
+
+// Parse reads a graph from JSON without compiling it. Unknown fields are
+// rejected so that typos in hand-edited graphs are not silently ignored.
+func Parse(data []byte) (*Graph, error) {
+	if len(data) > MaxSize {
+		return nil, Errors{{Message: fmt.Sprintf("graph is larger than %d KB", MaxSize>>10)}}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var g Graph
+	if err := dec.Decode(&g); err != nil {
+		return nil, Errors{{Message: fmt.Sprintf("invalid graph JSON: %v", err)}}
+	}
+	if dec.More() {
+		return nil, Errors{{Message: "invalid graph JSON: unexpected data after the graph"}}
+	}
+	switch {
+	case g.Version == 0:
+		return nil, Errors{{Message: "version is required"}}
+	case g.Version > Version:
+		return nil, Errors{{Message: fmt.Sprintf("graph version %d is newer than the supported version %d", g.Version, Version)}}
+	case g.Version < 0:
+		return nil, Errors{{Message: fmt.Sprintf("invalid graph version %d", g.Version)}}
+	}
+	if len(g.Nodes) > MaxNodes {
+		return nil, Errors{{Message: fmt.Sprintf("graph has more than %d nodes", MaxNodes)}}
+	}
+	return &g, nil
+}
+
+// Marshal writes a graph as indented JSON
+func (g *Graph) Marshal() (string, error) {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -68,6 +68,11 @@ func RunMigrations() error {
 		&models.User{},
 		&models.Strategy{},
 		&models.BacktestResult{},
+		&models.Dataset{},
+		&models.Job{},
+		&models.Optimization{},
+		&models.OptimizationCheckpoint{},
+		&models.MonteCarloSimulation{},
 	)
 
 	if err != nil {
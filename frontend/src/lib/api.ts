@@ -184,6 +184,162 @@ export const backtestApi = {
     const response = await api.get(`/backtest/${id}`)
     return response.data
   },
+
+  listBacktests: async (params?: {
+    pair?: string
+    timeframe?: string
+    strategy?: string
+    strategyId?: string
+    from?: string
+    to?: string
+    parentId?: string
+    cursor?: string
+    limit?: number
+  }) => {
+    const response = await api.get('/backtest', { params })
+    return response.data
+  },
+
+  deleteBacktest: async (id: string) => {
+    const response = await api.delete(`/backtest/${id}`)
+    return response.data
+  },
+
+  // Queues a simulation; the finished job's resultId is the simulation
+  runMonteCarlo: async (id: string, data: {
+    simulations?: number
+    seed?: number
+    shuffle?: boolean
+    resample?: boolean
+    skipPercent?: number
+    slippagePips?: number
+    ruinPercent?: number
+  }) => {
+    const response = await api.post(`/backtest/${id}/montecarlo`, data)
+    return response.data
+  },
+
+  listMonteCarlo: async (id: string) => {
+    const response = await api.get(`/backtest/${id}/montecarlo`)
+    return response.data
+  },
+
+  getMonteCarlo: async (id: string) => {
+    const response = await api.get(`/montecarlo/${id}`)
+    return response.data
+  },
+
+  deleteMonteCarlo: async (id: string) => {
+    const response = await api.delete(`/montecarlo/${id}`)
+    return response.data
+  },
+
+  // Series data ready for Lightweight Charts; indicators are listed as
+  // name:param=value,... separated by semicolons
+  getChart: async (id: string, params: {
+    from?: string
+    to?: string
+    width?: number
+    tf?: string
+    indicators?: string
+    sampling?: 'lttb' | 'minmax'
+  } = {}) => {
+    const response = await api.get(`/backtest/${id}/chart`, { params })
+    return response.data
+  },
+
+  getJob: async (id: string) => {
+    const response = await api.get(`/jobs/${id}`)
+    return response.data
+  },
+
+  cancelJob: async (id: string) => {
+    const response = await api.post(`/jobs/${id}/cancel`)
+    return response.data
+  },
+
+  optimize: async (data: {
+    fileId: string
+    timeframe?: string
+    strategy?: string
+    strategyId?: string
+    parameters?: Record<string, unknown>
+    method?: 'grid' | 'random' | 'genetic'
+    ranges: Record<string, { min?: number; max?: number; step?: number; values?: number[] }>
+    samples?: number
+    genetic?: {
+      population?: number
+      generations?: number
+      crossover?: number
+      mutation?: number
+      elite?: number
+      patience?: number
+    }
+    seed?: number
+    objective?: string
+    weights?: Record<string, number>
+    minTrades?: number
+  }) => {
+    const response = await api.post('/optimize', data)
+    return response.data
+  },
+
+  walkForward: async (data: {
+    fileId: string
+    timeframe?: string
+    strategy?: string
+    strategyId?: string
+    parameters?: Record<string, unknown>
+    method?: 'grid' | 'random' | 'genetic'
+    ranges: Record<string, { min?: number; max?: number; step?: number; values?: number[] }>
+    objective?: string
+    windows?: number
+    outOfSample?: number
+    anchored?: boolean
+  }) => {
+    const response = await api.post('/walkforward', data)
+    return response.data
+  },
+
+  getOptimization: async (id: string) => {
+    const response = await api.get(`/optimizations/${id}`)
+    return response.data
+  },
+
+  getHeatmap: async (id: string, x?: string, y?: string) => {
+    const response = await api.get(`/optimizations/${id}/heatmap`, { params: { x, y } })
+    return response.data
+  },
+
+  createReplay: async (data: {
+    fileId: string
+    timeframe?: string
+    startDate: string
+    endDate?: string
+    initialBalance?: number
+    currency?: string
+  }) => {
+    const response = await api.post('/replay', data)
+    return response.data
+  },
+
+  listReplays: async () => {
+    const response = await api.get('/replay')
+    return response.data
+  },
+
+  deleteReplay: async (id: string) => {
+    const response = await api.delete(`/replay/${id}`)
+    return response.data
+  },
+
+  // The session's ticket stands in for the JWT, which a WebSocket cannot send
+  openReplay: (id: string, ticket: string) => {
+    const url = new URL(`${API_URL}/replay/${id}/ws`, window.location.href)
+    url.protocol = url.protocol === 'https:' ? 'wss:' : 'ws:'
+    url.searchParams.set('ticket', ticket)
+    return new WebSocket(url)
+  },
 }
 
 export default api